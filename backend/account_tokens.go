@@ -0,0 +1,325 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Password reset and email verification
+//
+// Both flows email the user a link containing a random token. Only the
+// token's hash is stored; a token expires and can be used once.
+
+// UserToken purposes
+const (
+	tokenPurposePasswordReset = "password_reset"
+	tokenPurposeVerifyEmail   = "verify_email"
+)
+
+const (
+	passwordResetTTL = time.Hour
+	verifyEmailTTL   = 48 * time.Hour
+)
+
+var errInvalidUserToken = errors.New("invalid or expired token")
+
+// appURL returns an absolute link to a frontend page, based on APP_URL
+func appURL(path string, query url.Values) string {
+	base := strings.TrimSuffix(os.Getenv("APP_URL"), "/")
+	if base == "" {
+		base = "http://localhost:3000"
+	}
+	if len(query) == 0 {
+		return base + path
+	}
+	return base + path + "?" + query.Encode()
+}
+
+// createUserToken issues a new token for the user, invalidating older unused
+// tokens with the same purpose
+func createUserToken(db *gorm.DB, userID uint, purpose string, ttl time.Duration) (string, error) {
+	token, err := generateSecureToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&UserToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			TokenHash: hashToken(token),
+			ExpiresAt: time.Now().Add(ttl),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// consumeUserToken marks a token as used and returns it.
+// Unknown, expired and already used tokens all return errInvalidUserToken.
+func consumeUserToken(db *gorm.DB, token, purpose string) (*UserToken, error) {
+	var userToken UserToken
+	if err := db.Where("token_hash = ? AND purpose = ?", hashToken(token), purpose).First(&userToken).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errInvalidUserToken
+		}
+		return nil, err
+	}
+
+	if userToken.UsedAt != nil || time.Now().After(userToken.ExpiresAt) {
+		return nil, errInvalidUserToken
+	}
+
+	// Guard against two requests using the same token at once
+	result := db.Model(&UserToken{}).
+		Where("id = ? AND used_at IS NULL", userToken.ID).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errInvalidUserToken
+	}
+
+	return &userToken, nil
+}
+
+// userEmailJob is the payload of the jobs that email a user a token link.
+// The token is only created when the job runs, so it never sits in the queue.
+type userEmailJob struct {
+	UserID uint `json:"user_id"`
+}
+
+// sendVerificationEmail queues an email with a link for the user to confirm
+// their address. Nothing more is queued while one is waiting to be sent.
+func sendVerificationEmail(db *gorm.DB, user *User) error {
+	_, err := enqueueJob(db, jobEmailVerification, userEmailJob{UserID: user.ID}, jobOptions{
+		UniqueKey: fmt.Sprintf("%s:%d", jobEmailVerification, user.ID),
+	})
+	return err
+}
+
+// sendPasswordResetEmail queues an email with a link for the user to choose a
+// new password. Nothing more is queued while one is waiting to be sent.
+func sendPasswordResetEmail(db *gorm.DB, user *User) error {
+	_, err := enqueueJob(db, jobPasswordResetEmail, userEmailJob{UserID: user.ID}, jobOptions{
+		UniqueKey: fmt.Sprintf("%s:%d", jobPasswordResetEmail, user.ID),
+	})
+	return err
+}
+
+// loadUserEmailJobUser loads the user a token email job is for
+func loadUserEmailJobUser(db *gorm.DB, payload []byte) (*User, error) {
+	var args userEmailJob
+	if err := decodeJobPayload(payload, &args); err != nil {
+		return nil, err
+	}
+
+	var user User
+	if err := db.First(&user, args.UserID).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// runEmailVerificationJob emails the user a link to confirm their address
+func runEmailVerificationJob(db *gorm.DB, payload []byte) error {
+	user, err := loadUserEmailJobUser(db, payload)
+	if err != nil || user == nil || user.EmailVerifiedAt != nil {
+		return err
+	}
+
+	token, err := createUserToken(db, user.ID, tokenPurposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+
+	link := appURL("/verify-email", url.Values{"token": {token}})
+	return mailer.Send(EmailMessage{
+		To:      user.Email,
+		Subject: "Verify your email address",
+		Body: "Hi " + user.Name + ",\n\n" +
+			"Please confirm your email address by opening the link below:\n\n" +
+			link + "\n\n" +
+			"The link expires in 48 hours.\n",
+	})
+}
+
+// runPasswordResetEmailJob emails the user a link to choose a new password
+func runPasswordResetEmailJob(db *gorm.DB, payload []byte) error {
+	user, err := loadUserEmailJobUser(db, payload)
+	if err != nil || user == nil || !user.IsActive {
+		return err
+	}
+
+	token, err := createUserToken(db, user.ID, tokenPurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+
+	link := appURL("/reset-password", url.Values{"token": {token}})
+	return mailer.Send(EmailMessage{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "Hi " + user.Name + ",\n\n" +
+			"Someone asked to reset the password for your account. If it was you, open the link below:\n\n" +
+			link + "\n\n" +
+			"The link expires in 1 hour. If you didn't ask for this, you can ignore this email.\n",
+	})
+}
+
+// Handlers
+
+func forgotPasswordHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Email string `json:"email"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		if req.Email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Email is required"})
+			return
+		}
+
+		// Respond the same way whether or not the account exists
+		var user User
+		if err := db.Where("email = ?", req.Email).First(&user).Error; err == nil && user.IsActive {
+			if err := sendPasswordResetEmail(db, &user); err != nil {
+				log.Printf("Failed to queue password reset email for user %d: %v", user.ID, err)
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "If an account exists for this email, a reset link has been sent"})
+	}
+}
+
+func resetPasswordHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Token       string `json:"token"`
+			NewPassword string `json:"newPassword"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		if req.Token == "" || req.NewPassword == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Token and new password are required"})
+			return
+		}
+
+		userToken, err := consumeUserToken(db, req.Token, tokenPurposePasswordReset)
+		if err != nil {
+			if err == errInvalidUserToken {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid or expired reset token"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to reset password"})
+			}
+			return
+		}
+
+		passwordHash, err := hashPassword(req.NewPassword)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to hash password"})
+			return
+		}
+
+		// Following the emailed link also proves the user owns the address
+		now := time.Now()
+		if err := db.Model(&User{}).Where("id = ?", userToken.UserID).Updates(map[string]interface{}{
+			"password_hash":     passwordHash,
+			"email_verified_at": gorm.Expr("COALESCE(email_verified_at, ?)", now),
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update password"})
+			return
+		}
+
+		revokeUserSessions(db, userToken.UserID, revokeReasonPasswordReset)
+
+		c.JSON(http.StatusOK, gin.H{"message": "Password has been reset"})
+	}
+}
+
+func verifyEmailHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Token string `json:"token"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil || req.Token == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Token is required"})
+			return
+		}
+
+		userToken, err := consumeUserToken(db, req.Token, tokenPurposeVerifyEmail)
+		if err != nil {
+			if err == errInvalidUserToken {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid or expired verification token"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to verify email"})
+			}
+			return
+		}
+
+		if err := db.Model(&User{}).
+			Where("id = ? AND email_verified_at IS NULL", userToken.UserID).
+			Update("email_verified_at", time.Now()).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to verify email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Email verified"})
+	}
+}
+
+func resendVerificationHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		user, _ := c.Get("user")
+		currentUser := user.(*User)
+
+		if currentUser.EmailVerifiedAt != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Email is already verified"})
+			return
+		}
+
+		if err := sendVerificationEmail(db, currentUser); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to send verification email"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Verification email sent"})
+	}
+}
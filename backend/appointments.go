@@ -0,0 +1,353 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Appointments
+//
+// Once an offer is accepted, the requester books visits with the provider
+// inside the provider's availability. Either party can reschedule or cancel a
+// visit while the request is in progress, and cancelling the request cancels
+// its appointments.
+
+// errorCodeAppointmentsLocked is returned when the request isn't in progress
+const errorCodeAppointmentsLocked = "appointments_locked"
+
+// appointmentInput is a time to book or move an appointment to
+type appointmentInput struct {
+	StartsAt        time.Time `json:"starts_at"`        // RFC 3339
+	DurationMinutes int       `json:"duration_minutes"` // Optional: defaults to 60, or the current length when rescheduling
+}
+
+// appointmentTimes validates input and returns the start and end in UTC.
+// It writes an error response and returns false when they aren't usable.
+func appointmentTimes(c *gin.Context, input *appointmentInput, defaultMinutes int) (time.Time, time.Time, bool) {
+	if input.StartsAt.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "starts_at is required"})
+		return time.Time{}, time.Time{}, false
+	}
+	if input.DurationMinutes == 0 {
+		input.DurationMinutes = defaultMinutes
+	}
+	if input.DurationMinutes < minAppointmentMinutes || input.DurationMinutes > maxAppointmentMinutes {
+		c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("duration_minutes must be between %d and %d", minAppointmentMinutes, maxAppointmentMinutes)})
+		return time.Time{}, time.Time{}, false
+	}
+
+	start := input.StartsAt.UTC().Truncate(time.Minute)
+	if !start.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Appointments must be in the future"})
+		return time.Time{}, time.Time{}, false
+	}
+	return start, start.Add(time.Duration(input.DurationMinutes) * time.Minute), true
+}
+
+// checkRequestInProgress writes a 409 and returns false unless appointments can change
+func checkRequestInProgress(c *gin.Context, request *ServiceRequest) bool {
+	if request.Status == requestStatusInProgress {
+		return true
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"message": "Appointments can only change while the request is in progress",
+		"code":    errorCodeAppointmentsLocked,
+	})
+	return false
+}
+
+// cancelRequestAppointments cancels a request's scheduled appointments
+func cancelRequestAppointments(tx *gorm.DB, requestID uint, actorID *uint, reason string) error {
+	var appointments []Appointment
+	if err := tx.Where("service_request_id = ? AND status = ?", requestID, appointmentStatusScheduled).
+		Find(&appointments).Error; err != nil {
+		return err
+	}
+
+	for i := range appointments {
+		if err := transitionAppointment(tx, &appointments[i], appointmentStatusCancelled, actorID, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// serviceRequestAppointmentsHandler handles GET and POST /api/service-requests/:id/appointments
+func serviceRequestAppointmentsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid service request ID"})
+			return
+		}
+
+		var request ServiceRequest
+		if err := db.First(&request, requestID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Service request not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service request"})
+			}
+			return
+		}
+
+		access, ok := checkCommunityAccess(c, db, request.CommunityID)
+		if !ok {
+			return
+		}
+
+		providerID, err := acceptedProviderID(db, &request)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch accepted offer"})
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet:
+			if access.UserID != request.RequesterID && access.UserID != providerID && !access.CanModerate {
+				c.JSON(http.StatusForbidden, gin.H{"message": "Only the parties and community moderators can see appointments"})
+				return
+			}
+			listAppointments(c, db, &request)
+		case http.MethodPost:
+			if access.UserID != request.RequesterID {
+				c.JSON(http.StatusForbidden, gin.H{"message": "Only the requester can book appointments"})
+				return
+			}
+			bookAppointment(c, db, &request, providerID)
+		default:
+			c.JSON(http.StatusMethodNotAllowed, gin.H{"message": "Method not allowed"})
+		}
+	}
+}
+
+// listAppointments writes a request's appointments, soonest first
+func listAppointments(c *gin.Context, db *gorm.DB, request *ServiceRequest) {
+	var appointments []Appointment
+	if err := db.Preload("Provider", publicUserColumns).
+		Preload("Requester", publicUserColumns).
+		Where("service_request_id = ?", request.ID).
+		Order("starts_at ASC").
+		Find(&appointments).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch appointments"})
+		return
+	}
+
+	c.JSON(http.StatusOK, appointments)
+}
+
+// bookAppointment handles POST /api/service-requests/:id/appointments (requester)
+func bookAppointment(c *gin.Context, db *gorm.DB, request *ServiceRequest, providerID uint) {
+	var input appointmentInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+
+	start, end, ok := appointmentTimes(c, &input, defaultAppointmentMinutes)
+	if !ok {
+		return
+	}
+
+	if providerID == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Appointments can only be booked once an offer is accepted",
+			"code":    errorCodeAppointmentsLocked,
+		})
+		return
+	}
+	if !checkRequestInProgress(c, request) {
+		return
+	}
+
+	var provider User
+	if err := db.First(&provider, providerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch provider"})
+		return
+	}
+
+	appointment := Appointment{
+		ServiceRequestID: request.ID,
+		ProviderID:       provider.ID,
+		RequesterID:      request.RequesterID,
+		StartsAt:         start,
+		EndsAt:           end,
+		TimeZone:         userLocation(&provider).String(),
+		Status:           appointmentStatusScheduled,
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Serialize bookings for the provider so two can't take the same slot
+		if err := lockRow(tx, "users", provider.ID); err != nil {
+			return err
+		}
+		if err := checkSlot(tx, &provider, start, end, 0); err != nil {
+			return err
+		}
+		return tx.Create(&appointment).Error
+	})
+	if err != nil {
+		respondSlotError(c, err, "Failed to book appointment")
+		return
+	}
+
+	c.JSON(http.StatusCreated, appointment)
+}
+
+// loadAppointmentParty loads the :id appointment for its requester or provider
+// while the request is in progress. It writes an error response and returns
+// false otherwise.
+func loadAppointmentParty(c *gin.Context, db *gorm.DB) (*Appointment, uint, bool) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return nil, 0, false
+	}
+
+	appointmentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid appointment ID"})
+		return nil, 0, false
+	}
+
+	var appointment Appointment
+	if err := db.Preload("ServiceRequest").Preload("Provider").First(&appointment, appointmentID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Appointment not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch appointment"})
+		}
+		return nil, 0, false
+	}
+
+	if userID != appointment.RequesterID && userID != appointment.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Only the requester and the provider can change appointments"})
+		return nil, 0, false
+	}
+
+	if !checkRequestInProgress(c, &appointment.ServiceRequest) {
+		return nil, 0, false
+	}
+
+	return &appointment, userID, true
+}
+
+// writeAppointment reloads an appointment for a response
+func writeAppointment(c *gin.Context, db *gorm.DB, appointmentID uint) {
+	var appointment Appointment
+	if err := db.First(&appointment, appointmentID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to load updated appointment"})
+		return
+	}
+
+	c.JSON(http.StatusOK, appointment)
+}
+
+// rescheduleAppointmentHandler handles POST /api/appointments/:id/reschedule (requester or provider)
+func rescheduleAppointmentHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		appointment, _, ok := loadAppointmentParty(c, db)
+		if !ok {
+			return
+		}
+
+		var input appointmentInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		currentMinutes := int(appointment.EndsAt.Sub(appointment.StartsAt) / time.Minute)
+		start, end, ok := appointmentTimes(c, &input, currentMinutes)
+		if !ok {
+			return
+		}
+
+		if appointment.Status != appointmentStatusScheduled {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "Cancelled appointments can't be rescheduled",
+				"code":    errorCodeIllegalTransition,
+			})
+			return
+		}
+
+		provider := &appointment.Provider
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := lockRow(tx, "users", provider.ID); err != nil {
+				return err
+			}
+			if err := checkSlot(tx, provider, start, end, appointment.ID); err != nil {
+				return err
+			}
+			result := tx.Model(&Appointment{}).
+				Where("id = ? AND status = ?", appointment.ID, appointmentStatusScheduled).
+				Updates(map[string]interface{}{
+					"starts_at": start,
+					"ends_at":   end,
+					"time_zone": userLocation(provider).String(),
+					"sequence":  gorm.Expr("sequence + 1"),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				// Cancelled in the meantime
+				return appointmentLifecycle.check(appointmentStatusCancelled, appointmentStatusScheduled)
+			}
+			return nil
+		})
+		if err != nil {
+			respondSlotError(c, err, "Failed to reschedule appointment")
+			return
+		}
+
+		writeAppointment(c, db, appointment.ID)
+	}
+}
+
+// cancelAppointmentHandler handles POST /api/appointments/:id/cancel (requester or provider)
+func cancelAppointmentHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		appointment, userID, ok := loadAppointmentParty(c, db)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			Reason string `json:"reason"`
+		}
+		// The reason is optional, so an empty body is fine
+		_ = c.ShouldBindJSON(&input)
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return transitionAppointment(tx, appointment, appointmentStatusCancelled, &userID, strings.TrimSpace(input.Reason))
+		})
+		if err != nil {
+			respondTransitionError(c, err, "Failed to cancel appointment")
+			return
+		}
+
+		writeAppointment(c, db, appointment.ID)
+	}
+}
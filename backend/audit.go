@@ -0,0 +1,342 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Audit log
+//
+// Administrative actions are recorded in audit_events, which is append-only:
+// the application never updates or deletes rows, and migration 202402041310
+// adds database triggers that reject it.
+
+// Audit actions
+const (
+	auditUserCreate            = "user.create"
+	auditUserUpdate            = "user.update"
+	auditUserDelete            = "user.delete"
+	auditUserMFAReset          = "user.mfa_reset"
+	auditCommunityCreate       = "community.create"
+	auditCommunityUpdate       = "community.update"
+	auditCommunityDelete       = "community.delete"
+	auditCommunityMemberAdd    = "community.member_add"
+	auditCommunityMemberRemove = "community.member_remove"
+	auditCommunityMemberRole   = "community.member_role_change"
+	auditJoinRequestApprove    = "join_request.approve"
+	auditJoinRequestReject     = "join_request.reject"
+	auditMFAPolicyUpdate       = "mfa_policy.update"
+	auditLockoutClear          = "lockout.clear"
+	auditDisputeResolve        = "dispute.resolve"
+	auditWebhookCreate         = "webhook.create"
+	auditWebhookUpdate         = "webhook.update"
+	auditWebhookDelete         = "webhook.delete"
+	auditWebhookRotateSecret   = "webhook.rotate_secret"
+	auditJobRetry              = "job.retry"
+	auditJobDelete             = "job.delete"
+)
+
+// auditEntry describes one action to record
+type auditEntry struct {
+	Action      string
+	TargetType  string // user, community, membership, join_request, mfa_policy, lockout, dispute, webhook, job
+	TargetID    uint
+	CommunityID *uint
+	Before      map[string]interface{} // nil for creations
+	After       map[string]interface{} // nil for deletions
+}
+
+// auditChange is the before/after value of one field
+type auditChange struct {
+	From interface{} `json:"from"`
+	To   interface{} `json:"to"`
+}
+
+// auditDiff keeps the fields whose value differs between before and after
+func auditDiff(before, after map[string]interface{}) map[string]auditChange {
+	diff := make(map[string]auditChange)
+	for key, to := range after {
+		from := before[key]
+		if !reflect.DeepEqual(from, to) {
+			diff[key] = auditChange{From: from, To: to}
+		}
+	}
+	for key, from := range before {
+		if _, ok := after[key]; !ok {
+			diff[key] = auditChange{From: from, To: nil}
+		}
+	}
+	return diff
+}
+
+// userAuditFields is the part of a user that audit events track
+func userAuditFields(user *User) map[string]interface{} {
+	return map[string]interface{}{
+		"name":      user.Name,
+		"email":     user.Email,
+		"role":      string(user.Role),
+		"is_active": user.IsActive,
+	}
+}
+
+// communityAuditFields is the part of a community that audit events track
+func communityAuditFields(community *Community) map[string]interface{} {
+	return map[string]interface{}{
+		"name":             community.Name,
+		"slug":             community.Slug,
+		"description":      community.Description,
+		"subdomain":        community.Subdomain,
+		"custom_domain":    community.CustomDomain,
+		"address":          community.Address,
+		"city":             community.City,
+		"state":            community.State,
+		"country":          community.Country,
+		"zip_code":         community.ZipCode,
+		"is_active":        community.IsActive,
+		"currency":         community.Currency,
+		"platform_fee_bps": community.PlatformFeeBps,
+		"tax_rate_bps":     community.TaxRateBps,
+		"tax_label":        community.TaxLabel,
+	}
+}
+
+// recordAudit stores an audit event for the current request.
+// Failures are logged rather than failing the action that already happened.
+func recordAudit(db *gorm.DB, c *gin.Context, entry auditEntry) {
+	changes, err := json.Marshal(auditDiff(entry.Before, entry.After))
+	if err != nil {
+		log.Printf("Failed to encode audit changes for %s: %v", entry.Action, err)
+		changes = []byte("{}")
+	}
+
+	event := AuditEvent{
+		Action:      entry.Action,
+		TargetType:  entry.TargetType,
+		TargetID:    entry.TargetID,
+		CommunityID: entry.CommunityID,
+		Changes:     string(changes),
+		IPAddress:   c.ClientIP(),
+		UserAgent:   c.Request.UserAgent(),
+	}
+	if userID, err := getCurrentUser(c); err == nil {
+		event.ActorID = &userID
+	}
+	if user, exists := c.Get("user"); exists {
+		event.ActorEmail = user.(*User).Email
+	}
+
+	if err := db.Create(&event).Error; err != nil {
+		log.Printf("Failed to record audit event %s: %v", entry.Action, err)
+	}
+}
+
+// auditQuery applies the filters shared by the list and export endpoints.
+// It writes a 400 and returns false for malformed filters.
+func auditQuery(c *gin.Context, db *gorm.DB) (*gorm.DB, bool) {
+	query := db.Model(&AuditEvent{})
+
+	for param, column := range map[string]string{
+		"actor_id":     "actor_id",
+		"target_id":    "target_id",
+		"community_id": "community_id",
+	} {
+		if value := c.Query(param); value != "" {
+			id, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid " + param})
+				return nil, false
+			}
+			query = query.Where(column+" = ?", id)
+		}
+	}
+
+	if action := c.Query("action"); action != "" {
+		query = query.Where("action = ?", action)
+	}
+	if targetType := c.Query("target_type"); targetType != "" {
+		query = query.Where("target_type = ?", targetType)
+	}
+
+	for param, op := range map[string]string{"from": ">=", "to": "<"} {
+		if value := c.Query(param); value != "" {
+			t, err := parseAuditTime(value)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid " + param + " (use RFC 3339 or YYYY-MM-DD)"})
+				return nil, false
+			}
+			query = query.Where("created_at "+op+" ?", t)
+		}
+	}
+
+	return query, true
+}
+
+// parseAuditTime accepts an RFC 3339 timestamp or a plain date
+func parseAuditTime(value string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// getAuditEventsHandler handles GET /api/admin/audit
+func getAuditEventsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		query, ok := auditQuery(c, db)
+		if !ok {
+			return
+		}
+
+		respondAuditEvents(c, query)
+	}
+}
+
+// getCommunityAuditEventsHandler handles GET /api/communities/:id/audit
+// The community's own audit trail, for its admins.
+func getCommunityAuditEventsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		query, ok := auditQuery(c, db)
+		if !ok {
+			return
+		}
+
+		respondAuditEvents(c, query.Where("community_id = ?", getCommunityAccess(c).CommunityID))
+	}
+}
+
+// respondAuditEvents writes a page of the events matching query, newest first
+func respondAuditEvents(c *gin.Context, query *gorm.DB) {
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count audit events"})
+		return
+	}
+
+	page, pageSize := parsePagination(c)
+
+	var events []AuditEvent
+	if err := query.Order("created_at DESC, id DESC").
+		Offset((page - 1) * pageSize).
+		Limit(pageSize).
+		Find(&events).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch audit events"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"events":    events,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// exportAuditEventsHandler handles GET /api/admin/audit/export
+// Streams every matching event as CSV, oldest first.
+func exportAuditEventsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		query, ok := auditQuery(c, db)
+		if !ok {
+			return
+		}
+
+		writeAuditExport(c, query, "audit")
+	}
+}
+
+// exportCommunityAuditEventsHandler handles GET /api/communities/:id/audit/export
+func exportCommunityAuditEventsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		query, ok := auditQuery(c, db)
+		if !ok {
+			return
+		}
+
+		access := getCommunityAccess(c)
+		writeAuditExport(c, query.Where("community_id = ?", access.CommunityID),
+			fmt.Sprintf("audit-community-%d", access.CommunityID))
+	}
+}
+
+// writeAuditExport streams the events matching query as CSV, oldest first
+func writeAuditExport(c *gin.Context, query *gorm.DB, name string) {
+	filename := fmt.Sprintf("%s-%s.csv", name, time.Now().Format("2006-01-02"))
+	c.Header("Content-Type", "text/csv; charset=utf-8")
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	w := csv.NewWriter(c.Writer)
+	w.Write([]string{"id", "time", "actor_id", "actor_email", "action", "target_type", "target_id", "community_id", "changes", "ip_address", "user_agent"})
+
+	var batch []AuditEvent
+	err := query.Order("id ASC").FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+		for _, event := range batch {
+			w.Write([]string{
+				strconv.FormatUint(uint64(event.ID), 10),
+				event.CreatedAt.UTC().Format(time.RFC3339),
+				optionalID(event.ActorID),
+				csvSafe(event.ActorEmail),
+				csvSafe(event.Action),
+				csvSafe(event.TargetType),
+				strconv.FormatUint(uint64(event.TargetID), 10),
+				optionalID(event.CommunityID),
+				csvSafe(event.Changes),
+				csvSafe(event.IPAddress),
+				csvSafe(event.UserAgent),
+			})
+		}
+		w.Flush()
+		return w.Error()
+	}).Error
+	if err != nil {
+		// Headers are already sent; all we can do is stop and log
+		log.Printf("Audit export failed: %v", err)
+	}
+	w.Flush()
+}
+
+// csvSafe stops spreadsheets from running a cell as a formula, by prefixing
+// values that start with a formula character with a quote
+func csvSafe(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
+
+func optionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -21,9 +22,10 @@ func init() {
 }
 
 type Claims struct {
-	UserID uint     `json:"user_id"`
-	Email  string   `json:"email"`
-	Role   UserRole `json:"role"`
+	UserID    uint     `json:"user_id"`
+	Email     string   `json:"email"`
+	Role      UserRole `json:"role"`
+	SessionID uint     `json:"sid"`
 	jwt.RegisteredClaims
 }
 
@@ -37,13 +39,14 @@ func checkPasswordHash(password, hash string) bool {
 	return err == nil
 }
 
-func generateToken(user *User) (string, error) {
+func generateToken(user *User, sessionID uint) (string, error) {
 	claims := &Claims{
-		UserID: user.ID,
-		Email:  user.Email,
-		Role:   user.Role,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.Role,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
 			IssuedAt:  jwt.NewNumericDate(time.Now()),
 		},
 	}
@@ -73,7 +76,7 @@ func setAuthCookie(c *gin.Context, token string) {
 	c.SetCookie(
 		"auth_token",
 		token,
-		86400, // 24 hours
+		int(accessTokenTTL.Seconds()),
 		"/",
 		"",
 		os.Getenv("MODE") == "production",
@@ -91,9 +94,17 @@ func clearAuthCookie(c *gin.Context) {
 		os.Getenv("MODE") == "production",
 		true, // HttpOnly
 	)
+	clearRefreshCookie(c)
 }
 
+// getAuthToken reads the "Authorization: Bearer" header, falling back to the auth_token cookie
 func getAuthToken(c *gin.Context) string {
+	if header := c.GetHeader("Authorization"); header != "" {
+		if scheme, token, ok := strings.Cut(header, " "); ok && strings.EqualFold(scheme, "Bearer") {
+			return strings.TrimSpace(token)
+		}
+	}
+
 	token, err := c.Cookie("auth_token")
 	if err == nil {
 		return token
@@ -111,15 +122,44 @@ func authMiddleware(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		claims, err := validateToken(token)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid token"})
-			c.Abort()
-			return
+		var userID, sessionID uint
+		var pat *PersonalAccessToken
+
+		if strings.HasPrefix(token, personalAccessTokenPrefix) {
+			var err error
+			pat, err = authenticatePersonalAccessToken(db, token, c.ClientIP())
+			if err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid token"})
+				c.Abort()
+				return
+			}
+
+			if !tokenScopeAllows(pat.scopeList(), c.Request.Method, c.FullPath()) {
+				c.JSON(http.StatusForbidden, gin.H{"message": "Token scope does not allow this request"})
+				c.Abort()
+				return
+			}
+
+			userID = pat.UserID
+		} else {
+			claims, err := validateToken(token)
+			if err != nil || claims.SessionID == 0 {
+				c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid token"})
+				c.Abort()
+				return
+			}
+
+			if _, err := validateSession(db, claims.SessionID, claims.UserID); err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"message": "Session expired"})
+				c.Abort()
+				return
+			}
+
+			userID, sessionID = claims.UserID, claims.SessionID
 		}
 
 		var user User
-		if err := db.First(&user, claims.UserID).Error; err != nil {
+		if err := db.First(&user, userID).Error; err != nil {
 			c.JSON(http.StatusUnauthorized, gin.H{"message": "User not found"})
 			c.Abort()
 			return
@@ -135,6 +175,11 @@ func authMiddleware(db *gorm.DB) gin.HandlerFunc {
 		c.Set("userID", user.ID)
 		c.Set("userRole", user.Role)
 		c.Set("user", &user)
+		if pat != nil {
+			c.Set("personalAccessToken", pat)
+		} else {
+			c.Set("sessionID", sessionID)
+		}
 
 		c.Next()
 	}
@@ -181,6 +226,14 @@ func getCurrentUser(c *gin.Context) (uint, error) {
 	return userID.(uint), nil
 }
 
+// currentUserName returns the signed-in user's name, for telling others what they did
+func currentUserName(c *gin.Context) string {
+	if user, exists := c.Get("user"); exists {
+		return user.(*User).Name
+	}
+	return "Someone"
+}
+
 // Auth handlers
 
 func loginHandler(db *gorm.DB) gin.HandlerFunc {
@@ -200,39 +253,84 @@ func loginHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if !checkLoginThrottle(db, c, req.Email) {
+			return
+		}
+
 		var user User
 		if err := db.Where("email = ?", req.Email).First(&user).Error; err != nil {
+			recordLoginFailure(c, req.Email)
+			recordLoginEvent(db, c, nil, req.Email, false, loginReasonInvalidCredentials)
 			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid credentials"})
 			return
 		}
 
 		if !user.IsActive {
+			recordLoginEvent(db, c, &user.ID, req.Email, false, loginReasonInactive)
 			c.JSON(http.StatusForbidden, gin.H{"message": "User is inactive"})
 			return
 		}
 
 		if !checkPasswordHash(req.Password, user.PasswordHash) {
+			recordLoginFailure(c, req.Email)
+			recordLoginEvent(db, c, &user.ID, req.Email, false, loginReasonInvalidCredentials)
 			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid credentials"})
 			return
 		}
 
-		token, err := generateToken(&user)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate token"})
-			return
+		// Two-factor authentication: finish the login at /auth/mfa/verify
+		// (or /auth/mfa/enroll + /auth/mfa/confirm when 2FA is mandatory)
+		mfaPurpose := ""
+		if user.TOTPEnabledAt != nil {
+			mfaPurpose = mfaPurposeVerify
+		} else {
+			required, err := isMFARequired(db, user.Role)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch MFA policy"})
+				return
+			}
+			if required {
+				mfaPurpose = mfaPurposeEnroll
+			}
 		}
 
-		setAuthCookie(c, token)
+		if mfaPurpose != "" {
+			mfaToken, err := generateMFAToken(&user, mfaPurpose)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate token"})
+				return
+			}
 
-		c.JSON(http.StatusOK, gin.H{
-			"user":  sanitizeUser(&user),
-			"token": token,
-		})
+			recordLoginEvent(db, c, &user.ID, req.Email, false, loginReasonMFAPending)
+			c.JSON(http.StatusOK, gin.H{
+				"mfa_required":            mfaPurpose == mfaPurposeVerify,
+				"mfa_enrollment_required": mfaPurpose == mfaPurposeEnroll,
+				"mfa_token":               mfaToken,
+				"expires_in":              int(mfaTokenTTL.Seconds()),
+			})
+			return
+		}
+
+		// Only once a session is issued, so a known password can't be used to
+		// reset the account's count between guesses at the second factor
+		recordLoginSuccess(c, req.Email)
+		recordLoginEvent(db, c, &user.ID, req.Email, true, loginReasonSuccess)
+		respondWithSession(c, db, &user, nil)
 	}
 }
 
-func logoutHandler() gin.HandlerFunc {
+func logoutHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// Revoke the session if the access token is still readable
+		if claims, err := validateToken(getAuthToken(c)); err == nil && claims.SessionID != 0 {
+			revokeSession(db, claims.SessionID, revokeReasonLogout)
+		} else if token, err := c.Cookie("refresh_token"); err == nil && token != "" {
+			var refreshToken RefreshToken
+			if err := db.Where("token_hash = ?", hashToken(token)).First(&refreshToken).Error; err == nil {
+				revokeSession(db, refreshToken.SessionID, revokeReasonLogout)
+			}
+		}
+
 		clearAuthCookie(c)
 		c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 	}
@@ -314,30 +412,32 @@ func setupSuperUserHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		token, err := generateToken(&user)
+		token, refreshToken, err := startSession(c, db, &user)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate token"})
 			return
 		}
 
-		setAuthCookie(c, token)
-
 		c.JSON(http.StatusCreated, gin.H{
-			"user":  sanitizeUser(&user),
-			"token": token,
+			"user":          sanitizeUser(&user),
+			"token":         token,
+			"refresh_token": refreshToken,
+			"expires_in":    int(accessTokenTTL.Seconds()),
 		})
 	}
 }
 
 func sanitizeUser(user *User) map[string]interface{} {
 	return map[string]interface{}{
-		"ID":        user.ID,
-		"CreatedAt": user.CreatedAt,
-		"UpdatedAt": user.UpdatedAt,
-		"DeletedAt": user.DeletedAt,
-		"Name":      user.Name,
-		"Email":     user.Email,
-		"Role":      user.Role,
-		"IsActive":  user.IsActive,
+		"ID":              user.ID,
+		"CreatedAt":       user.CreatedAt,
+		"UpdatedAt":       user.UpdatedAt,
+		"DeletedAt":       user.DeletedAt,
+		"Name":            user.Name,
+		"Email":           user.Email,
+		"Role":            user.Role,
+		"IsActive":        user.IsActive,
+		"EmailVerifiedAt": user.EmailVerifiedAt,
+		"MFAEnabled":      user.TOTPEnabledAt != nil,
 	}
 }
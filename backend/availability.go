@@ -0,0 +1,501 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Provider availability
+//
+// Providers publish weekly availability windows and blackout dates in their
+// own time zone. Appointments must fall inside a window on a day that isn't
+// blacked out, and can't overlap the provider's other appointments. Times in
+// the API are RFC 3339, so clients in other zones get the right instant.
+
+// Error codes for booking conflicts
+const (
+	errorCodeOutsideAvailability = "outside_availability"
+	errorCodeSlotTaken           = "slot_taken"
+)
+
+// Appointment lengths, in minutes
+const (
+	defaultAppointmentMinutes = 60
+	minAppointmentMinutes     = 15
+	maxAppointmentMinutes     = 12 * 60
+	slotStepMinutes           = 30
+	maxSlotDays               = 31
+)
+
+// dateLayout is how blackout dates are written
+const dateLayout = "2006-01-02"
+
+var (
+	errOutsideAvailability = errors.New("outside the provider's availability")
+	errSlotTaken           = errors.New("the provider already has an appointment at that time")
+)
+
+// availabilityWindowJSON is a weekly window as the client sends and receives it
+type availabilityWindowJSON struct {
+	Weekday int    `json:"weekday"` // 0 = Sunday
+	Start   string `json:"start"`   // HH:MM
+	End     string `json:"end"`     // HH:MM, up to 24:00
+}
+
+// parseClock parses HH:MM into minutes after midnight; 24:00 is allowed
+func parseClock(value string) (int, bool) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 2 || len(parts[0]) != 2 || len(parts[1]) != 2 {
+		return 0, false
+	}
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	if err1 != nil || err2 != nil || minutes < 0 || minutes > 59 || hours < 0 || hours > 24 {
+		return 0, false
+	}
+	total := hours*60 + minutes
+	if total > 24*60 {
+		return 0, false
+	}
+	return total, true
+}
+
+// formatClock formats minutes after midnight as HH:MM
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// userLocation returns the user's time zone, falling back to UTC
+func userLocation(user *User) *time.Location {
+	loc, err := time.LoadLocation(user.TimeZone)
+	if err != nil || user.TimeZone == "" {
+		return time.UTC
+	}
+	return loc
+}
+
+// checkSlot returns errOutsideAvailability unless start-end falls inside one of
+// the provider's windows on a day that isn't blacked out, and errSlotTaken when
+// it overlaps another scheduled appointment (other than excludeID)
+func checkSlot(tx *gorm.DB, provider *User, start, end time.Time, excludeID uint) error {
+	loc := userLocation(provider)
+	localStart, localEnd := start.In(loc), end.In(loc)
+
+	startMinute := localStart.Hour()*60 + localStart.Minute()
+	endMinute := localEnd.Hour()*60 + localEnd.Minute()
+	sameDay := localStart.Format(dateLayout) == localEnd.Format(dateLayout)
+	if !sameDay && endMinute == 0 && localEnd.Sub(localStart) <= 24*time.Hour {
+		// Ends exactly at midnight
+		endMinute, sameDay = 24*60, true
+	}
+	if !sameDay {
+		return errOutsideAvailability
+	}
+
+	var windows int64
+	if err := tx.Model(&AvailabilityWindow{}).
+		Where("provider_id = ? AND weekday = ? AND start_minute <= ? AND end_minute >= ?",
+			provider.ID, int(localStart.Weekday()), startMinute, endMinute).
+		Count(&windows).Error; err != nil {
+		return err
+	}
+	if windows == 0 {
+		return errOutsideAvailability
+	}
+
+	day := localStart.Format(dateLayout)
+	var blackouts int64
+	if err := tx.Model(&AvailabilityBlackout{}).
+		Where("provider_id = ? AND starts_on <= ? AND ends_on >= ?", provider.ID, day, day).
+		Count(&blackouts).Error; err != nil {
+		return err
+	}
+	if blackouts > 0 {
+		return errOutsideAvailability
+	}
+
+	var overlapping int64
+	if err := tx.Model(&Appointment{}).
+		Where("provider_id = ? AND status = ? AND id != ? AND starts_at < ? AND ends_at > ?",
+			provider.ID, appointmentStatusScheduled, excludeID, end.UTC(), start.UTC()).
+		Count(&overlapping).Error; err != nil {
+		return err
+	}
+	if overlapping > 0 {
+		return errSlotTaken
+	}
+	return nil
+}
+
+// respondSlotError writes the response for an error from checkSlot
+func respondSlotError(c *gin.Context, err error, fallback string) {
+	switch err {
+	case errOutsideAvailability:
+		c.JSON(http.StatusConflict, gin.H{"message": "That time is outside the provider's availability", "code": errorCodeOutsideAvailability})
+	case errSlotTaken:
+		c.JSON(http.StatusConflict, gin.H{"message": "The provider already has an appointment at that time", "code": errorCodeSlotTaken})
+	default:
+		respondTransitionError(c, err, fallback)
+	}
+}
+
+// loadProvider loads the :id user for the availability endpoints
+func loadProvider(c *gin.Context, db *gorm.DB, providerID uint) (*User, bool) {
+	var provider User
+	if err := db.First(&provider, providerID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch user"})
+		}
+		return nil, false
+	}
+	return &provider, true
+}
+
+// writeAvailability responds with a provider's zone, weekly windows and upcoming blackouts
+func writeAvailability(c *gin.Context, db *gorm.DB, provider *User) {
+	var windows []AvailabilityWindow
+	if err := db.Where("provider_id = ?", provider.ID).
+		Order("weekday ASC, start_minute ASC").
+		Find(&windows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch availability"})
+		return
+	}
+
+	today := time.Now().In(userLocation(provider)).Format(dateLayout)
+	var blackouts []AvailabilityBlackout
+	if err := db.Where("provider_id = ? AND ends_on >= ?", provider.ID, today).
+		Order("starts_on ASC").
+		Find(&blackouts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch blackouts"})
+		return
+	}
+
+	out := make([]availabilityWindowJSON, len(windows))
+	for i, w := range windows {
+		out[i] = availabilityWindowJSON{Weekday: w.Weekday, Start: formatClock(w.StartMinute), End: formatClock(w.EndMinute)}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"time_zone": userLocation(provider).String(),
+		"windows":   out,
+		"blackouts": blackouts,
+	})
+}
+
+// getAvailabilityHandler handles GET /api/users/:id/availability
+func getAvailabilityHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		providerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user ID"})
+			return
+		}
+
+		provider, ok := loadProvider(c, db, uint(providerID))
+		if !ok {
+			return
+		}
+
+		writeAvailability(c, db, provider)
+	}
+}
+
+// updateAvailabilityHandler handles PUT /api/users/:id/availability (the user or an admin)
+// Replaces the weekly windows and sets the time zone they are in.
+func updateAvailabilityHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		providerID, ok := authorizeSelfOrAdmin(c)
+		if !ok {
+			return
+		}
+
+		provider, ok := loadProvider(c, db, providerID)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			TimeZone string                   `json:"time_zone"`
+			Windows  []availabilityWindowJSON `json:"windows"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		timeZone := provider.TimeZone
+		if input.TimeZone != "" {
+			if _, err := time.LoadLocation(input.TimeZone); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown time zone"})
+				return
+			}
+			timeZone = input.TimeZone
+		}
+
+		windows := make([]AvailabilityWindow, 0, len(input.Windows))
+		for _, w := range input.Windows {
+			start, okStart := parseClock(w.Start)
+			end, okEnd := parseClock(w.End)
+			if w.Weekday < 0 || w.Weekday > 6 || !okStart || !okEnd || start >= end {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Each window needs a weekday from 0 (Sunday) to 6 and a start before its end, as HH:MM"})
+				return
+			}
+			windows = append(windows, AvailabilityWindow{ProviderID: provider.ID, Weekday: w.Weekday, StartMinute: start, EndMinute: end})
+		}
+
+		sort.Slice(windows, func(i, j int) bool {
+			if windows[i].Weekday != windows[j].Weekday {
+				return windows[i].Weekday < windows[j].Weekday
+			}
+			return windows[i].StartMinute < windows[j].StartMinute
+		})
+		for i := 1; i < len(windows); i++ {
+			if windows[i].Weekday == windows[i-1].Weekday && windows[i].StartMinute < windows[i-1].EndMinute {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Windows on the same day can't overlap"})
+				return
+			}
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(provider).Update("time_zone", timeZone).Error; err != nil {
+				return err
+			}
+			if err := tx.Where("provider_id = ?", provider.ID).Delete(&AvailabilityWindow{}).Error; err != nil {
+				return err
+			}
+			if len(windows) == 0 {
+				return nil
+			}
+			return tx.Create(&windows).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save availability"})
+			return
+		}
+
+		provider.TimeZone = timeZone
+		writeAvailability(c, db, provider)
+	}
+}
+
+// createBlackoutHandler handles POST /api/users/:id/blackouts (the user or an admin)
+// Appointments already booked on those days are kept.
+func createBlackoutHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		providerID, ok := authorizeSelfOrAdmin(c)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			StartsOn string `json:"starts_on"`
+			EndsOn   string `json:"ends_on"` // Optional: defaults to starts_on
+			Reason   string `json:"reason"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		if input.EndsOn == "" {
+			input.EndsOn = input.StartsOn
+		}
+		startsOn, err1 := time.Parse(dateLayout, input.StartsOn)
+		endsOn, err2 := time.Parse(dateLayout, input.EndsOn)
+		if err1 != nil || err2 != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Dates must be YYYY-MM-DD"})
+			return
+		}
+		if endsOn.Before(startsOn) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "A blackout can't end before it starts"})
+			return
+		}
+
+		blackout := AvailabilityBlackout{
+			ProviderID: providerID,
+			StartsOn:   input.StartsOn,
+			EndsOn:     input.EndsOn,
+			Reason:     strings.TrimSpace(input.Reason),
+		}
+		if err := db.Create(&blackout).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create blackout"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, blackout)
+	}
+}
+
+// deleteBlackoutHandler handles DELETE /api/users/:id/blackouts/:blackoutId (the user or an admin)
+func deleteBlackoutHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		providerID, ok := authorizeSelfOrAdmin(c)
+		if !ok {
+			return
+		}
+
+		blackoutID, err := strconv.ParseUint(c.Param("blackoutId"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid blackout ID"})
+			return
+		}
+
+		result := db.Where("id = ? AND provider_id = ?", blackoutID, providerID).Delete(&AvailabilityBlackout{})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete blackout"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Blackout not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Blackout deleted"})
+	}
+}
+
+// availableSlot is a bookable start and end
+type availableSlot struct {
+	StartsAt time.Time `json:"starts_at"`
+	EndsAt   time.Time `json:"ends_at"`
+}
+
+// getAvailableSlotsHandler handles GET /api/users/:id/slots
+// Free slots of duration minutes, every 30 minutes, for days (default 7) from
+// the from date (default today), in the provider's time zone.
+func getAvailableSlotsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		providerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user ID"})
+			return
+		}
+
+		provider, ok := loadProvider(c, db, uint(providerID))
+		if !ok {
+			return
+		}
+		loc := userLocation(provider)
+		now := time.Now().In(loc)
+
+		from := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+		if value := c.Query("from"); value != "" {
+			parsed, err := time.ParseInLocation(dateLayout, value, loc)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "from must be YYYY-MM-DD"})
+				return
+			}
+			from = parsed
+		}
+
+		days, _ := strconv.Atoi(c.DefaultQuery("days", "7"))
+		if days < 1 || days > maxSlotDays {
+			days = 7
+		}
+		duration, _ := strconv.Atoi(c.DefaultQuery("duration", strconv.Itoa(defaultAppointmentMinutes)))
+		if duration < minAppointmentMinutes || duration > maxAppointmentMinutes {
+			c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("duration must be between %d and %d minutes", minAppointmentMinutes, maxAppointmentMinutes)})
+			return
+		}
+		until := from.AddDate(0, 0, days)
+
+		var windows []AvailabilityWindow
+		var blackouts []AvailabilityBlackout
+		var booked []Appointment
+		if err := db.Where("provider_id = ?", provider.ID).Order("start_minute ASC").Find(&windows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch availability"})
+			return
+		}
+		if err := db.Where("provider_id = ? AND ends_on >= ? AND starts_on < ?",
+			provider.ID, from.Format(dateLayout), until.Format(dateLayout)).Find(&blackouts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch blackouts"})
+			return
+		}
+		if err := db.Where("provider_id = ? AND status = ? AND starts_at < ? AND ends_at > ?",
+			provider.ID, appointmentStatusScheduled, until.UTC(), from.UTC()).Find(&booked).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch appointments"})
+			return
+		}
+
+		slots := []availableSlot{}
+		for day := from; day.Before(until); day = day.AddDate(0, 0, 1) {
+			date := day.Format(dateLayout)
+			blackedOut := false
+			for _, b := range blackouts {
+				if b.StartsOn <= date && date <= b.EndsOn {
+					blackedOut = true
+					break
+				}
+			}
+			if blackedOut {
+				continue
+			}
+
+			for _, w := range windows {
+				if w.Weekday != int(day.Weekday()) {
+					continue
+				}
+				for minute := w.StartMinute; minute+duration <= w.EndMinute; minute += slotStepMinutes {
+					start := time.Date(day.Year(), day.Month(), day.Day(), minute/60, minute%60, 0, 0, loc)
+					end := start.Add(time.Duration(duration) * time.Minute)
+					if !start.After(now) {
+						continue
+					}
+					free := true
+					for _, a := range booked {
+						if a.StartsAt.Before(end) && a.EndsAt.After(start) {
+							free = false
+							break
+						}
+					}
+					if free {
+						slots = append(slots, availableSlot{StartsAt: start, EndsAt: end})
+					}
+				}
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"time_zone":        loc.String(),
+			"duration_minutes": duration,
+			"slots":            slots,
+		})
+	}
+}
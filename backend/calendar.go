@@ -0,0 +1,172 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Provider calendar feed
+//
+// Each provider has an iCalendar (RFC 5545) feed of their appointments that
+// calendar apps can subscribe to. Calendar apps can't log in, so the feed URL
+// carries a token; only its hash is stored, and generating a new one revokes
+// the old URL. Cancelled appointments stay in the feed as cancelled so
+// subscribers remove them.
+
+// calendarFeedHistory is how far back the feed goes
+const calendarFeedHistory = 90 * 24 * time.Hour
+
+// icsTimeLayout is a UTC date-time in iCalendar
+const icsTimeLayout = "20060102T150405Z"
+
+// icsEscape escapes a TEXT value
+func icsEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// icsLine writes a content line, folded at 75 octets without splitting a character
+func icsLine(b *strings.Builder, name, value string) {
+	line := name + ":" + value
+	for len(line) > 75 {
+		cut := 75
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+	}
+	b.WriteString(line + "\r\n")
+}
+
+// renderCalendar writes a provider's appointments as an iCalendar feed
+func renderCalendar(provider *User, appointments []Appointment) string {
+	var b strings.Builder
+	icsLine(&b, "BEGIN", "VCALENDAR")
+	icsLine(&b, "VERSION", "2.0")
+	icsLine(&b, "PRODID", "-//Commune//Appointments//EN")
+	icsLine(&b, "CALSCALE", "GREGORIAN")
+	icsLine(&b, "METHOD", "PUBLISH")
+	icsLine(&b, "X-WR-CALNAME", icsEscape(provider.Name+" - appointments"))
+	icsLine(&b, "X-WR-TIMEZONE", userLocation(provider).String())
+
+	for _, a := range appointments {
+		request := &a.ServiceRequest
+		status := "CONFIRMED"
+		if a.Status == appointmentStatusCancelled {
+			status = "CANCELLED"
+		}
+
+		icsLine(&b, "BEGIN", "VEVENT")
+		icsLine(&b, "UID", fmt.Sprintf("appointment-%d@commune", a.ID))
+		icsLine(&b, "DTSTAMP", a.UpdatedAt.UTC().Format(icsTimeLayout))
+		icsLine(&b, "DTSTART", a.StartsAt.UTC().Format(icsTimeLayout))
+		icsLine(&b, "DTEND", a.EndsAt.UTC().Format(icsTimeLayout))
+		icsLine(&b, "SEQUENCE", strconv.Itoa(a.Sequence))
+		icsLine(&b, "STATUS", status)
+		icsLine(&b, "SUMMARY", icsEscape(request.Title))
+		icsLine(&b, "DESCRIPTION", icsEscape(fmt.Sprintf("Service request #%d for %s (%s)", request.ID, a.Requester.Name, a.Requester.Email)))
+		if location := communityAddressLines(&request.Community); len(location) > 0 {
+			icsLine(&b, "LOCATION", icsEscape(strings.Join(location, ", ")))
+		}
+		icsLine(&b, "END", "VEVENT")
+	}
+
+	icsLine(&b, "END", "VCALENDAR")
+	return b.String()
+}
+
+// createCalendarTokenHandler handles POST /api/users/:id/calendar-token (the user or an admin)
+// Returns a new feed URL. The token is only shown once.
+func createCalendarTokenHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, ok := authorizeSelfOrAdmin(c)
+		if !ok {
+			return
+		}
+
+		token, err := generateSecureToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate token"})
+			return
+		}
+
+		result := db.Model(&User{}).Where("id = ?", userID).Update("calendar_token_hash", hashToken(token))
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save token"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{
+			"token": token,
+			"url":   fmt.Sprintf("/api/users/%d/calendar.ics?token=%s", userID, token),
+		})
+	}
+}
+
+// calendarFeedHandler handles GET /api/users/:id/calendar.ics
+// Opened with the feed token, or by the user or an admin when logged in.
+func calendarFeedHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		providerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user ID"})
+			return
+		}
+
+		token := c.Query("token")
+		if token == "" {
+			authMiddleware(db)(c)
+			if c.IsAborted() {
+				return
+			}
+			if _, ok := authorizeSelfOrAdmin(c); !ok {
+				return
+			}
+		}
+
+		var provider User
+		if err := db.First(&provider, providerID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch user"})
+			}
+			return
+		}
+
+		if token != "" && (provider.CalendarTokenHash == "" ||
+			subtle.ConstantTimeCompare([]byte(hashToken(token)), []byte(provider.CalendarTokenHash)) != 1) {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid calendar token"})
+			return
+		}
+
+		var appointments []Appointment
+		if err := db.Preload("ServiceRequest.Community").
+			Preload("Requester", publicUserColumns).
+			Where("provider_id = ? AND ends_at >= ?", provider.ID, time.Now().Add(-calendarFeedHistory).UTC()).
+			Order("starts_at ASC").
+			Find(&appointments).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch appointments"})
+			return
+		}
+
+		c.Header("Content-Disposition", `inline; filename="appointments.ics"`)
+		c.Data(http.StatusOK, "text/calendar; charset=utf-8", []byte(renderCalendar(&provider, appointments)))
+	}
+}
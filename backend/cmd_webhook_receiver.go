@@ -0,0 +1,129 @@
+//go:build webhookreceiver
+// +build webhookreceiver
+
+package main
+
+// A local HTTP receiver for testing community webhooks. It checks each
+// delivery's signature and prints the event.
+//
+//	go run -tags webhookreceiver cmd_webhook_receiver.go -secret whsec_...
+//
+// Register http://localhost:9000/ as the webhook URL. -fail N answers the
+// first N deliveries with a 500, to watch them being retried.
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+func main() {
+	addr := flag.String("addr", ":9000", "address to listen on")
+	secret := flag.String("secret", os.Getenv("WEBHOOK_SECRET"), "the endpoint's signing secret (default $WEBHOOK_SECRET)")
+	tolerance := flag.Duration("tolerance", 5*time.Minute, "oldest signature timestamp accepted")
+	fail := flag.Int("fail", 0, "answer the first N deliveries with a 500")
+	flag.Parse()
+
+	if *secret == "" {
+		log.Fatal("A secret is required: pass -secret or set WEBHOOK_SECRET")
+	}
+
+	var mu sync.Mutex
+	failuresLeft := *fail
+
+	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "POST only", http.StatusMethodNotAllowed)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, 1<<20))
+		if err != nil {
+			http.Error(w, "Failed to read body", http.StatusBadRequest)
+			return
+		}
+
+		event := r.Header.Get("X-Commune-Event")
+		delivery := r.Header.Get("X-Commune-Delivery")
+
+		if err := verifySignature(*secret, r.Header.Get("X-Commune-Signature"), body, *tolerance); err != nil {
+			log.Printf("Rejected delivery %s (%s): %v", delivery, event, err)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
+
+		mu.Lock()
+		failing := failuresLeft > 0
+		if failing {
+			failuresLeft--
+		}
+		mu.Unlock()
+		if failing {
+			log.Printf("Failing delivery %s (%s) on purpose", delivery, event)
+			http.Error(w, "Failing on purpose", http.StatusInternalServerError)
+			return
+		}
+
+		var pretty bytes.Buffer
+		if json.Indent(&pretty, body, "", "  ") != nil {
+			pretty.Reset()
+			pretty.Write(body)
+		}
+		log.Printf("Delivery %s: %s (event %s)\n%s", delivery, event, r.Header.Get("X-Commune-Event-ID"), pretty.String())
+
+		fmt.Fprintln(w, "ok")
+	})
+
+	log.Printf("Webhook receiver listening on %s", *addr)
+	log.Fatal(http.ListenAndServe(*addr, nil))
+}
+
+// verifySignature checks an X-Commune-Signature header ("t=<unix time>,v1=<hex>"),
+// where v1 is the HMAC-SHA256 of "<t>.<body>" keyed with the endpoint secret
+func verifySignature(secret, header string, body []byte, tolerance time.Duration) error {
+	var timestamp, signature string
+	for _, part := range strings.Split(header, ",") {
+		key, value, _ := strings.Cut(part, "=")
+		switch key {
+		case "t":
+			timestamp = value
+		case "v1":
+			signature = value
+		}
+	}
+	if timestamp == "" || signature == "" {
+		return fmt.Errorf("missing or malformed signature header")
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("malformed signature timestamp")
+	}
+	if age := time.Since(time.Unix(unix, 0)); age > tolerance || age < -tolerance {
+		return fmt.Errorf("signature timestamp is %s away from now", age.Round(time.Second))
+	}
+
+	given, err := hex.DecodeString(signature)
+	if err != nil {
+		return fmt.Errorf("malformed signature")
+	}
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	if !hmac.Equal(given, mac.Sum(nil)) {
+		return fmt.Errorf("signature does not match")
+	}
+	return nil
+}
@@ -0,0 +1,487 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Comment handlers
+
+const (
+	defaultCommentDepth = 3
+	maxCommentDepth     = 10
+)
+
+// commentTarget identifies the post, service request, offer or milestone a thread hangs off
+type commentTarget struct {
+	Column string // comments column holding the parent ID, e.g. "post_id"
+	ID     uint
+	Access *communityAccess
+}
+
+// apply sets the target foreign key on a new comment
+func (t *commentTarget) apply(comment *Comment) {
+	id := t.ID
+	switch t.Column {
+	case "post_id":
+		comment.PostID = &id
+	case "service_request_id":
+		comment.ServiceRequestID = &id
+	case "service_offer_id":
+		comment.ServiceOfferID = &id
+	case "milestone_id":
+		comment.MilestoneID = &id
+	}
+}
+
+// commentTargetResolver loads the parent object from the URL and checks community access.
+// It writes an error response and returns false when the target cannot be used.
+type commentTargetResolver func(c *gin.Context, db *gorm.DB) (*commentTarget, bool)
+
+// postCommentTarget resolves /communities/:id/posts/:postId
+func postCommentTarget(c *gin.Context, db *gorm.DB) (*commentTarget, bool) {
+	access, ok := resolveCommunityAccess(c, db)
+	if !ok {
+		return nil, false
+	}
+
+	post, ok := findCommunityPost(c, db, access.CommunityID)
+	if !ok {
+		return nil, false
+	}
+
+	if !post.IsPublished && !access.canEditPost(post) {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Post not found"})
+		return nil, false
+	}
+
+	return &commentTarget{Column: "post_id", ID: post.ID, Access: access}, true
+}
+
+// serviceRequestCommentTarget resolves /service-requests/:id
+func serviceRequestCommentTarget(c *gin.Context, db *gorm.DB) (*commentTarget, bool) {
+	requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid service request ID"})
+		return nil, false
+	}
+
+	var request ServiceRequest
+	if err := db.First(&request, requestID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Service request not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service request"})
+		}
+		return nil, false
+	}
+
+	access, ok := checkCommunityAccess(c, db, request.CommunityID)
+	if !ok {
+		return nil, false
+	}
+
+	return &commentTarget{Column: "service_request_id", ID: request.ID, Access: access}, true
+}
+
+// serviceOfferCommentTarget resolves /service-offers/:id
+func serviceOfferCommentTarget(c *gin.Context, db *gorm.DB) (*commentTarget, bool) {
+	offerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid service offer ID"})
+		return nil, false
+	}
+
+	var offer ServiceOffer
+	if err := db.Preload("ServiceRequest").First(&offer, offerID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Service offer not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service offer"})
+		}
+		return nil, false
+	}
+
+	access, ok := checkCommunityAccess(c, db, offer.ServiceRequest.CommunityID)
+	if !ok {
+		return nil, false
+	}
+
+	return &commentTarget{Column: "service_offer_id", ID: offer.ID, Access: access}, true
+}
+
+// milestoneCommentTarget resolves /milestones/:id
+func milestoneCommentTarget(c *gin.Context, db *gorm.DB) (*commentTarget, bool) {
+	milestone, ok := findMilestone(c, db)
+	if !ok {
+		return nil, false
+	}
+
+	access, ok := checkCommunityAccess(c, db, milestone.ServiceOffer.ServiceRequest.CommunityID)
+	if !ok {
+		return nil, false
+	}
+
+	return &commentTarget{Column: "milestone_id", ID: milestone.ID, Access: access}, true
+}
+
+// commentThread is a comment with its replies nested underneath it
+type commentThread struct {
+	Comment
+	Replies    []*commentThread
+	ReplyCount int // direct replies, including any cut off by the depth limit
+}
+
+// buildCommentThreads arranges a flat, oldest-first list of comments into trees
+// of at most maxDepth levels. Removed comments are kept as tombstones.
+func buildCommentThreads(comments []Comment, maxDepth int) []*commentThread {
+	children := make(map[uint][]*commentThread)
+	var roots []*commentThread
+
+	for i := range comments {
+		node := &commentThread{Comment: comments[i], Replies: []*commentThread{}}
+		if node.IsRemoved {
+			node.Content = ""
+			node.AuthorID = 0
+			node.Author = User{}
+		}
+		if node.ParentCommentID == nil {
+			roots = append(roots, node)
+		} else {
+			children[*node.ParentCommentID] = append(children[*node.ParentCommentID], node)
+		}
+	}
+
+	var attach func(nodes []*commentThread, depth int)
+	attach = func(nodes []*commentThread, depth int) {
+		for _, node := range nodes {
+			node.ReplyCount = len(children[node.ID])
+			if depth < maxDepth {
+				node.Replies = append(node.Replies, children[node.ID]...)
+				attach(node.Replies, depth+1)
+			}
+		}
+	}
+	attach(roots, 1)
+
+	if roots == nil {
+		roots = []*commentThread{}
+	}
+	return roots
+}
+
+// listCommentsHandler handles GET .../comments?depth=N
+func listCommentsHandler(db *gorm.DB, resolve commentTargetResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		target, ok := resolve(c, db)
+		if !ok {
+			return
+		}
+
+		depth := defaultCommentDepth
+		if depthStr := c.Query("depth"); depthStr != "" {
+			parsed, err := strconv.Atoi(depthStr)
+			if err != nil || parsed < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid depth"})
+				return
+			}
+			depth = parsed
+		}
+		if depth > maxCommentDepth {
+			depth = maxCommentDepth
+		}
+
+		var comments []Comment
+		if err := db.Preload("Author", publicUserColumns).
+			Where(target.Column+" = ?", target.ID).
+			Order("created_at ASC").
+			Find(&comments).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch comments"})
+			return
+		}
+
+		c.JSON(http.StatusOK, buildCommentThreads(comments, depth))
+	}
+}
+
+// createCommentHandler handles POST .../comments
+// Set parent_comment_id to reply to an existing comment in the same thread.
+func createCommentHandler(db *gorm.DB, resolve commentTargetResolver) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		target, ok := resolve(c, db)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			Content         string `json:"content"`
+			ParentCommentID *uint  `json:"parent_comment_id"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		if input.Content == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Content is required"})
+			return
+		}
+
+		comment := Comment{
+			Content:  input.Content,
+			AuthorID: target.Access.UserID,
+		}
+		target.apply(&comment)
+
+		if input.ParentCommentID != nil {
+			var parent Comment
+			if err := db.Where(target.Column+" = ?", target.ID).First(&parent, *input.ParentCommentID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					c.JSON(http.StatusBadRequest, gin.H{"message": "Parent comment does not belong to this thread"})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch parent comment"})
+				}
+				return
+			}
+			if parent.IsRemoved {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Cannot reply to a removed comment"})
+				return
+			}
+			comment.ParentCommentID = &parent.ID
+		}
+
+		if err := db.Create(&comment).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create comment"})
+			return
+		}
+
+		db.Preload("Author", publicUserColumns).First(&comment, comment.ID)
+
+		c.JSON(http.StatusCreated, comment)
+	}
+}
+
+// findComment loads the :id comment
+func findComment(c *gin.Context, db *gorm.DB) (*Comment, bool) {
+	commentID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid comment ID"})
+		return nil, false
+	}
+
+	var comment Comment
+	if err := db.First(&comment, commentID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Comment not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch comment"})
+		}
+		return nil, false
+	}
+
+	return &comment, true
+}
+
+// commentCommunityID returns the community a comment was posted in
+func commentCommunityID(db *gorm.DB, comment *Comment) (uint, error) {
+	switch {
+	case comment.PostID != nil:
+		var post Post
+		if err := db.Select("community_id").First(&post, *comment.PostID).Error; err != nil {
+			return 0, err
+		}
+		return post.CommunityID, nil
+	case comment.ServiceRequestID != nil:
+		var request ServiceRequest
+		if err := db.Select("community_id").First(&request, *comment.ServiceRequestID).Error; err != nil {
+			return 0, err
+		}
+		return request.CommunityID, nil
+	case comment.ServiceOfferID != nil:
+		var offer ServiceOffer
+		if err := db.Preload("ServiceRequest").First(&offer, *comment.ServiceOfferID).Error; err != nil {
+			return 0, err
+		}
+		return offer.ServiceRequest.CommunityID, nil
+	case comment.MilestoneID != nil:
+		var milestone Milestone
+		if err := db.Preload("ServiceOffer.ServiceRequest").First(&milestone, *comment.MilestoneID).Error; err != nil {
+			return 0, err
+		}
+		return milestone.ServiceOffer.ServiceRequest.CommunityID, nil
+	}
+	return 0, gorm.ErrRecordNotFound
+}
+
+// updateCommentHandler handles PUT /api/comments/:id (author only)
+func updateCommentHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		comment, ok := findComment(c, db)
+		if !ok {
+			return
+		}
+
+		if comment.AuthorID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only the author can edit this comment"})
+			return
+		}
+
+		if comment.IsRemoved {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Cannot edit a removed comment"})
+			return
+		}
+
+		var input struct {
+			Content string `json:"content"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		if input.Content == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Content is required"})
+			return
+		}
+
+		if err := db.Model(comment).Update("content", input.Content).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update comment"})
+			return
+		}
+
+		db.Preload("Author", publicUserColumns).First(comment, comment.ID)
+
+		c.JSON(http.StatusOK, comment)
+	}
+}
+
+// deleteCommentHandler handles DELETE /api/comments/:id (author only)
+// A comment with replies is turned into a tombstone instead of being deleted.
+func deleteCommentHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		comment, ok := findComment(c, db)
+		if !ok {
+			return
+		}
+
+		if comment.AuthorID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only the author can delete this comment"})
+			return
+		}
+
+		var replyCount int64
+		if err := db.Model(&Comment{}).Where("parent_comment_id = ?", comment.ID).Count(&replyCount).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete comment"})
+			return
+		}
+
+		if replyCount == 0 {
+			// Soft delete
+			if err := db.Delete(comment).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete comment"})
+				return
+			}
+		} else {
+			now := time.Now()
+			if err := db.Model(comment).Updates(map[string]interface{}{
+				"content":       "",
+				"is_removed":    true,
+				"removed_by_id": userID,
+				"removed_at":    now,
+			}).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete comment"})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Comment deleted successfully"})
+	}
+}
+
+// removeCommentHandler handles POST /api/comments/:id/remove
+// Moderators replace the comment with a tombstone; the original content is
+// kept in the database but never returned.
+func removeCommentHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		comment, ok := findComment(c, db)
+		if !ok {
+			return
+		}
+
+		communityID, err := commentCommunityID(db, comment)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to resolve comment community"})
+			return
+		}
+
+		access, ok := checkCommunityAccess(c, db, communityID)
+		if !ok {
+			return
+		}
+
+		if !access.CanModerate {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only community moderators can remove comments"})
+			return
+		}
+
+		if comment.IsRemoved {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Comment has already been removed"})
+			return
+		}
+
+		now := time.Now()
+		if err := db.Model(comment).Updates(map[string]interface{}{
+			"is_removed":    true,
+			"removed_by_id": access.UserID,
+			"removed_at":    now,
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to remove comment"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Comment removed"})
+	}
+}
@@ -1,8 +1,10 @@
 package main
 
 import (
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
@@ -17,8 +19,13 @@ func getCommunitiesHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		query := db.Where("is_active = ?", true)
+		if tenant := getTenant(c); tenant != nil {
+			query = query.Where("id = ?", tenant.ID)
+		}
+
 		var communities []Community
-		if err := db.Where("is_active = ?", true).Find(&communities).Error; err != nil {
+		if err := query.Find(&communities).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch communities"})
 			return
 		}
@@ -41,6 +48,10 @@ func getCommunityByIDHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if !checkTenantCommunity(c, uint(id)) {
+			return
+		}
+
 		var community Community
 		if err := db.First(&community, id).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
@@ -73,6 +84,10 @@ func createCommunityHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Hosts are matched case-insensitively by the tenant middleware
+		req.Subdomain = strings.ToLower(strings.TrimSpace(req.Subdomain))
+		req.CustomDomain = strings.ToLower(strings.TrimSpace(req.CustomDomain))
+
 		// Generate slug from name if not provided
 		if req.Slug == "" {
 			req.Slug = GenerateSlug(req.Name)
@@ -94,23 +109,26 @@ func createCommunityHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(db, c, auditEntry{
+			Action:      auditCommunityCreate,
+			TargetType:  "community",
+			TargetID:    req.ID,
+			CommunityID: &req.ID,
+			After:       communityAuditFields(&req),
+		})
+
 		c.JSON(http.StatusCreated, req)
 	}
 }
 
 func updateCommunityHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		requireCommunityRole(db, RoleAdmin)(c)
 		if c.IsAborted() {
 			return
 		}
 
-		idStr := c.Param("id")
-		id, err := strconv.ParseUint(idStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid community ID"})
-			return
-		}
+		id := getCommunityAccess(c).CommunityID
 
 		var community Community
 		if err := db.First(&community, id).Error; err != nil {
@@ -143,10 +161,10 @@ func updateCommunityHandler(db *gorm.DB) gin.HandlerFunc {
 			updates["description"] = description
 		}
 		if subdomain, ok := req["Subdomain"].(string); ok {
-			updates["subdomain"] = subdomain
+			updates["subdomain"] = strings.ToLower(strings.TrimSpace(subdomain))
 		}
 		if customDomain, ok := req["CustomDomain"].(string); ok {
-			updates["custom_domain"] = customDomain
+			updates["custom_domain"] = strings.ToLower(strings.TrimSpace(customDomain))
 		}
 		if address, ok := req["Address"].(string); ok {
 			updates["address"] = address
@@ -166,6 +184,37 @@ func updateCommunityHandler(db *gorm.DB) gin.HandlerFunc {
 		if isActive, ok := req["IsActive"].(bool); ok {
 			updates["is_active"] = isActive
 		}
+		if currency, ok := req["Currency"].(string); ok {
+			currency = strings.ToUpper(strings.TrimSpace(currency))
+			if !isCurrencyCode(currency) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Currency must be a three-letter ISO 4217 code"})
+				return
+			}
+			updates["currency"] = currency
+		}
+		if feeBps, ok := req["PlatformFeeBps"].(float64); ok {
+			if feeBps < 0 || feeBps > 10000 || feeBps != math.Trunc(feeBps) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Platform fee must be a whole number of basis points between 0 and 10000"})
+				return
+			}
+			updates["platform_fee_bps"] = int(feeBps)
+		}
+		if taxBps, ok := req["TaxRateBps"].(float64); ok {
+			if taxBps < 0 || taxBps > 10000 || taxBps != math.Trunc(taxBps) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Tax rate must be a whole number of basis points between 0 and 10000"})
+				return
+			}
+			updates["tax_rate_bps"] = int(taxBps)
+		}
+		if taxLabel, ok := req["TaxLabel"].(string); ok {
+			taxLabel = strings.TrimSpace(taxLabel)
+			if taxLabel == "" {
+				taxLabel = "Tax"
+			}
+			updates["tax_label"] = taxLabel
+		}
+
+		before := communityAuditFields(&community)
 
 		if len(updates) > 0 {
 			if err := db.Model(&community).Updates(updates).Error; err != nil {
@@ -180,6 +229,17 @@ func updateCommunityHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if len(updates) > 0 {
+			recordAudit(db, c, auditEntry{
+				Action:      auditCommunityUpdate,
+				TargetType:  "community",
+				TargetID:    community.ID,
+				CommunityID: &community.ID,
+				Before:      before,
+				After:       communityAuditFields(&community),
+			})
+		}
+
 		c.JSON(http.StatusOK, community)
 	}
 }
@@ -214,26 +274,29 @@ func deleteCommunityHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(db, c, auditEntry{
+			Action:      auditCommunityDelete,
+			TargetType:  "community",
+			TargetID:    community.ID,
+			CommunityID: &community.ID,
+			Before:      communityAuditFields(&community),
+		})
+
 		c.JSON(http.StatusOK, gin.H{"message": "Community deleted successfully"})
 	}
 }
 
 func getCommunityMembersHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authMiddleware(db)(c)
+		requireCommunityRole(db)(c)
 		if c.IsAborted() {
 			return
 		}
 
-		idStr := c.Param("id")
-		id, err := strconv.ParseUint(idStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid community ID"})
-			return
-		}
+		id := getCommunityAccess(c).CommunityID
 
 		var userCommunities []UserCommunity
-		if err := db.Preload("User").Where("community_id = ? AND is_active = ?", id, true).Find(&userCommunities).Error; err != nil {
+		if err := db.Preload("User", publicUserColumns).Where("community_id = ? AND is_active = ?", id, true).Find(&userCommunities).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch community members"})
 			return
 		}
@@ -244,17 +307,12 @@ func getCommunityMembersHandler(db *gorm.DB) gin.HandlerFunc {
 
 func addCommunityMemberHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		requireCommunityRole(db, RoleAdmin)(c)
 		if c.IsAborted() {
 			return
 		}
 
-		idStr := c.Param("id")
-		communityID, err := strconv.ParseUint(idStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid community ID"})
-			return
-		}
+		communityID := getCommunityAccess(c).CommunityID
 
 		var req struct {
 			UserID uint     `json:"userId"`
@@ -275,6 +333,11 @@ func addCommunityMemberHandler(db *gorm.DB) gin.HandlerFunc {
 			req.Role = RoleUser
 		}
 
+		if !isValidCommunityRole(req.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid community role"})
+			return
+		}
+
 		// Check if user exists
 		var user User
 		if err := db.First(&user, req.UserID).Error; err != nil {
@@ -282,16 +345,9 @@ func addCommunityMemberHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Check if community exists
-		var community Community
-		if err := db.First(&community, communityID).Error; err != nil {
-			c.JSON(http.StatusNotFound, gin.H{"message": "Community not found"})
-			return
-		}
-
 		// Check if membership already exists
 		var existing UserCommunity
-		err = db.Where("user_id = ? AND community_id = ?", req.UserID, communityID).First(&existing).Error
+		err := db.Where("user_id = ? AND community_id = ?", req.UserID, communityID).First(&existing).Error
 		if err == nil {
 			c.JSON(http.StatusConflict, gin.H{"message": "User is already a member of this community"})
 			return
@@ -299,7 +355,7 @@ func addCommunityMemberHandler(db *gorm.DB) gin.HandlerFunc {
 
 		userCommunity := UserCommunity{
 			UserID:      req.UserID,
-			CommunityID: uint(communityID),
+			CommunityID: communityID,
 			Role:        req.Role,
 			IsActive:    true,
 		}
@@ -309,8 +365,16 @@ func addCommunityMemberHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(db, c, auditEntry{
+			Action:      auditCommunityMemberAdd,
+			TargetType:  "membership",
+			TargetID:    req.UserID,
+			CommunityID: &communityID,
+			After:       map[string]interface{}{"role": string(req.Role)},
+		})
+
 		// Preload relationships
-		db.Preload("User").Preload("Community").First(&userCommunity, "user_id = ? AND community_id = ?", req.UserID, communityID)
+		db.Preload("User", publicUserColumns).Preload("Community").First(&userCommunity, "user_id = ? AND community_id = ?", req.UserID, communityID)
 
 		c.JSON(http.StatusCreated, userCommunity)
 	}
@@ -318,25 +382,24 @@ func addCommunityMemberHandler(db *gorm.DB) gin.HandlerFunc {
 
 func removeCommunityMemberHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		requireCommunityRole(db, RoleAdmin)(c)
 		if c.IsAborted() {
 			return
 		}
 
-		communityIDStr := c.Param("id")
-		communityID, err := strconv.ParseUint(communityIDStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid community ID"})
-			return
-		}
+		communityID := getCommunityAccess(c).CommunityID
 
-		userIDStr := c.Param("userID")
+		userIDStr := c.Param("userId")
 		userID, err := strconv.ParseUint(userIDStr, 10, 32)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user ID"})
 			return
 		}
 
+		// Keep the role for the audit log
+		var member UserCommunity
+		db.Where("user_id = ? AND community_id = ?", userID, communityID).First(&member)
+
 		result := db.Where("user_id = ? AND community_id = ?", userID, communityID).Delete(&UserCommunity{})
 		if result.Error != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to remove member"})
@@ -348,25 +411,28 @@ func removeCommunityMemberHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(db, c, auditEntry{
+			Action:      auditCommunityMemberRemove,
+			TargetType:  "membership",
+			TargetID:    uint(userID),
+			CommunityID: &communityID,
+			Before:      map[string]interface{}{"role": string(member.Role)},
+		})
+
 		c.JSON(http.StatusOK, gin.H{"message": "Member removed successfully"})
 	}
 }
 
 func updateCommunityMemberRoleHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		requireCommunityRole(db, RoleAdmin)(c)
 		if c.IsAborted() {
 			return
 		}
 
-		communityIDStr := c.Param("id")
-		communityID, err := strconv.ParseUint(communityIDStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid community ID"})
-			return
-		}
+		communityID := getCommunityAccess(c).CommunityID
 
-		userIDStr := c.Param("userID")
+		userIDStr := c.Param("userId")
 		userID, err := strconv.ParseUint(userIDStr, 10, 32)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user ID"})
@@ -387,6 +453,11 @@ func updateCommunityMemberRoleHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if !isValidCommunityRole(req.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid community role"})
+			return
+		}
+
 		var userCommunity UserCommunity
 		if err := db.Where("user_id = ? AND community_id = ?", userID, communityID).First(&userCommunity).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
@@ -397,13 +468,24 @@ func updateCommunityMemberRoleHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		previousRole := userCommunity.Role
+
 		if err := db.Model(&userCommunity).Update("role", req.Role).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update member role"})
 			return
 		}
 
+		recordAudit(db, c, auditEntry{
+			Action:      auditCommunityMemberRole,
+			TargetType:  "membership",
+			TargetID:    uint(userID),
+			CommunityID: &communityID,
+			Before:      map[string]interface{}{"role": string(previousRole)},
+			After:       map[string]interface{}{"role": string(req.Role)},
+		})
+
 		// Reload with relationships
-		db.Preload("User").Preload("Community").Where("user_id = ? AND community_id = ?", userID, communityID).First(&userCommunity)
+		db.Preload("User", publicUserColumns).Preload("Community").Where("user_id = ? AND community_id = ?", userID, communityID).First(&userCommunity)
 
 		c.JSON(http.StatusOK, userCommunity)
 	}
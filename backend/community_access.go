@@ -0,0 +1,183 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Community-scoped authorization
+//
+// Inside a community, permissions come from UserCommunity.Role rather than
+// the global User.Role. The only global override is super_admin.
+
+// communityRoles are the roles a membership can hold
+var communityRoles = []UserRole{RoleAdmin, RoleModerator, RoleServiceProvider, RoleUser}
+
+// isValidCommunityRole reports whether a role can be assigned to a membership
+func isValidCommunityRole(role UserRole) bool {
+	for _, r := range communityRoles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// communityAccess describes what the current user may do inside a community
+type communityAccess struct {
+	CommunityID  uint
+	UserID       uint
+	Membership   *UserCommunity // nil for super admins who are not members
+	IsSuperAdmin bool
+	CanModerate  bool
+}
+
+// hasRole reports whether the user holds one of the roles in this community.
+// Super admins hold every role.
+func (a *communityAccess) hasRole(roles ...UserRole) bool {
+	if a.IsSuperAdmin {
+		return true
+	}
+	if a.Membership == nil {
+		return false
+	}
+	for _, role := range roles {
+		if a.Membership.Role == role {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveCommunityAccess loads the :id community and checks that the current
+// user is an active member of it. Super admins are always allowed in.
+// It writes an error response and returns false when access is denied.
+func resolveCommunityAccess(c *gin.Context, db *gorm.DB) (*communityAccess, bool) {
+	communityID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid community ID"})
+		return nil, false
+	}
+
+	return checkCommunityAccess(c, db, uint(communityID))
+}
+
+// checkCommunityAccess is resolveCommunityAccess for a community ID that
+// comes from somewhere other than the URL (e.g. a service request)
+func checkCommunityAccess(c *gin.Context, db *gorm.DB, communityID uint) (*communityAccess, bool) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return nil, false
+	}
+
+	if !checkTenantCommunity(c, communityID) {
+		return nil, false
+	}
+
+	var community Community
+	if err := db.First(&community, communityID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Community not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch community"})
+		}
+		return nil, false
+	}
+
+	userRole, _ := c.Get("userRole")
+	access := &communityAccess{
+		CommunityID:  community.ID,
+		UserID:       userID,
+		IsSuperAdmin: userRole == RoleSuperAdmin,
+	}
+
+	membership, err := getCommunityMembership(db, userID, community.ID)
+	if err != nil && err != gorm.ErrRecordNotFound {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch membership"})
+		return nil, false
+	}
+	access.Membership = membership
+	access.CanModerate = access.hasRole(RoleAdmin, RoleModerator)
+
+	if access.Membership == nil && !access.IsSuperAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"message": "You are not a member of this community"})
+		return nil, false
+	}
+
+	c.Set("communityAccess", access)
+
+	return access, true
+}
+
+// authorizeCommunityRole checks that the current user holds one of the roles
+// in the given community. It writes an error response and returns false otherwise.
+func authorizeCommunityRole(c *gin.Context, db *gorm.DB, communityID uint, roles ...UserRole) (*communityAccess, bool) {
+	access, ok := checkCommunityAccess(c, db, communityID)
+	if !ok {
+		return nil, false
+	}
+
+	if !access.hasRole(roles...) {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Insufficient community permissions"})
+		return nil, false
+	}
+
+	return access, true
+}
+
+// communityModeratorIDs returns the active admins and moderators of a
+// community, and the super admins, who can moderate every community
+func communityModeratorIDs(db *gorm.DB, communityID uint) ([]uint, error) {
+	var ids []uint
+	if err := db.Model(&UserCommunity{}).
+		Where("community_id = ? AND is_active = ? AND role IN ?", communityID, true, []UserRole{RoleAdmin, RoleModerator}).
+		Pluck("user_id", &ids).Error; err != nil {
+		return nil, err
+	}
+
+	var superAdminIDs []uint
+	if err := db.Model(&User{}).
+		Where("role = ? AND is_active = ?", RoleSuperAdmin, true).
+		Pluck("id", &superAdminIDs).Error; err != nil {
+		return nil, err
+	}
+	return append(ids, superAdminIDs...), nil
+}
+
+// Middleware to require a community role for the :id community.
+// With no roles, any active member is allowed.
+func requireCommunityRole(db *gorm.DB, roles ...UserRole) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		access, ok := resolveCommunityAccess(c, db)
+		if !ok {
+			c.Abort()
+			return
+		}
+
+		if len(roles) > 0 && !access.hasRole(roles...) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Insufficient community permissions"})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// getCommunityAccess returns the access resolved by requireCommunityRole
+func getCommunityAccess(c *gin.Context) *communityAccess {
+	access, exists := c.Get("communityAccess")
+	if !exists {
+		return nil
+	}
+	return access.(*communityAccess)
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Completion confirmation
+//
+// A request isn't completed by one side alone. The accepted provider marks the
+// work done, which starts a confirmation window; the requester then confirms
+// or disputes it. Requests neither confirmed nor disputed in time are
+// confirmed automatically. COMPLETION_CONFIRMATION_DAYS sets the window
+// (default 7 days).
+
+// errorCodeWindowClosed is returned when the confirmation window has passed
+const errorCodeWindowClosed = "confirmation_window_closed"
+
+const defaultConfirmationDays = 7
+
+// autoConfirmInterval is how often requests past their window are confirmed
+const autoConfirmInterval = 5 * time.Minute
+
+// confirmationWindow is how long a requester has to confirm or dispute, set up in main
+var confirmationWindow = defaultConfirmationDays * 24 * time.Hour
+
+// loadConfirmationWindow reads the confirmation window from the environment
+func loadConfirmationWindow() time.Duration {
+	days := defaultConfirmationDays
+	if value := os.Getenv("COMPLETION_CONFIRMATION_DAYS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 {
+			log.Printf("Invalid COMPLETION_CONFIRMATION_DAYS %q, using %d", value, defaultConfirmationDays)
+		} else {
+			days = parsed
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// completionStatuses are only left through the completion actions
+var completionStatuses = map[string]bool{
+	requestStatusAwaitingConfirmation: true,
+	requestStatusDisputed:             true,
+	requestStatusCompleted:            true,
+}
+
+// checkDirectStatusChange writes a 409 and returns false unless a status
+// change sent to PUT /service-requests/:id cancels a request outside the
+// completion workflow. Work starts through accept-offer, accept-terms and
+// dispute rework, and finishes through mark-done, confirm and dispute.
+func checkDirectStatusChange(c *gin.Context, from, to string) bool {
+	if from == to || (to == requestStatusCancelled && !completionStatuses[from]) {
+		return true
+	}
+	c.JSON(http.StatusConflict, gin.H{
+		"message": "Requests can only be cancelled here; other status changes have their own actions",
+		"code":    errorCodeIllegalTransition,
+	})
+	return false
+}
+
+// acceptedProviderID returns the provider of a request's accepted offer, or 0 when there is none
+func acceptedProviderID(db *gorm.DB, request *ServiceRequest) (uint, error) {
+	if request.AcceptedOfferID == nil {
+		return 0, nil
+	}
+
+	var offer ServiceOffer
+	if err := db.Select("id", "provider_id").First(&offer, *request.AcceptedOfferID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return offer.ProviderID, nil
+}
+
+// loadCompletionParty loads a request for one of its completion actions.
+// It writes an error response and returns false for anyone other than the
+// requester (requester true) or the accepted provider.
+func loadCompletionParty(c *gin.Context, db *gorm.DB, requestID uint, requester bool) (*ServiceRequest, uint, bool) {
+	if c.Request.Method != http.MethodPost {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"message": "Method not allowed"})
+		return nil, 0, false
+	}
+
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return nil, 0, false
+	}
+
+	var request ServiceRequest
+	if err := db.First(&request, requestID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Service request not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service request"})
+		}
+		return nil, 0, false
+	}
+
+	if requester {
+		if request.RequesterID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only the requester can do this"})
+			return nil, 0, false
+		}
+		return &request, userID, true
+	}
+
+	providerID, err := acceptedProviderID(db, &request)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch accepted offer"})
+		return nil, 0, false
+	}
+	if providerID == 0 || providerID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Only the accepted provider can do this"})
+		return nil, 0, false
+	}
+	return &request, userID, true
+}
+
+// markServiceRequestDone handles POST /api/service-requests/:id/mark-done
+func markServiceRequestDone(c *gin.Context, db *gorm.DB, requestID uint) {
+	request, userID, ok := loadCompletionParty(c, db, requestID, false)
+	if !ok {
+		return
+	}
+
+	if !checkMilestonesApproved(c, db, request) {
+		return
+	}
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		return transitionServiceRequest(tx, request, requestStatusAwaitingConfirmation, &userID, "work marked done")
+	})
+	if err != nil {
+		respondTransitionError(c, err, "Failed to mark work done")
+		return
+	}
+
+	getServiceRequest(c, db, requestID)
+}
+
+// confirmServiceRequest handles POST /api/service-requests/:id/confirm
+func confirmServiceRequest(c *gin.Context, db *gorm.DB, requestID uint) {
+	request, userID, ok := loadCompletionParty(c, db, requestID, true)
+	if !ok {
+		return
+	}
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		return transitionServiceRequest(tx, request, requestStatusCompleted, &userID, "completion confirmed")
+	})
+	if err != nil {
+		respondTransitionError(c, err, "Failed to confirm completion")
+		return
+	}
+
+	getServiceRequest(c, db, requestID)
+}
+
+// disputeServiceRequest handles POST /api/service-requests/:id/dispute
+func disputeServiceRequest(c *gin.Context, db *gorm.DB, requestID uint) {
+	request, userID, ok := loadCompletionParty(c, db, requestID, true)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Reason string `json:"reason"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+
+	input.Reason = strings.TrimSpace(input.Reason)
+	if input.Reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Reason is required"})
+		return
+	}
+
+	if request.Status == requestStatusAwaitingConfirmation && request.ConfirmationDueAt != nil &&
+		time.Now().After(*request.ConfirmationDueAt) {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "The confirmation window has closed",
+			"code":    errorCodeWindowClosed,
+		})
+		return
+	}
+
+	dispute := Dispute{
+		ServiceRequestID: request.ID,
+		RaisedByID:       userID,
+		Reason:           input.Reason,
+		Status:           disputeStatusOpen,
+	}
+
+	err := transaction(db, func(tx *gorm.DB) error {
+		if err := transitionServiceRequest(tx, request, requestStatusDisputed, &userID, input.Reason); err != nil {
+			return err
+		}
+		return tx.Create(&dispute).Error
+	})
+	if err != nil {
+		respondTransitionError(c, err, "Failed to open dispute")
+		return
+	}
+
+	db.Preload("RaisedBy", publicUserColumns).First(&dispute, dispute.ID)
+
+	c.JSON(http.StatusCreated, dispute)
+}
+
+// autoConfirmServiceRequests completes the requests whose confirmation window has passed
+func autoConfirmServiceRequests(db *gorm.DB) error {
+	var requests []ServiceRequest
+	if err := db.Where("status = ? AND confirmation_due_at <= ?", requestStatusAwaitingConfirmation, time.Now()).
+		Find(&requests).Error; err != nil {
+		return err
+	}
+
+	for i := range requests {
+		err := transaction(db, func(tx *gorm.DB) error {
+			return transitionServiceRequest(tx, &requests[i], requestStatusCompleted, nil, "confirmation window elapsed")
+		})
+		if err != nil {
+			// Confirmed or disputed in the meantime
+			if _, ok := err.(*transitionError); ok {
+				continue
+			}
+			return err
+		}
+		log.Printf("Auto-confirmed completion of service request %d", requests[i].ID)
+	}
+	return nil
+}
@@ -0,0 +1,633 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Direct messages
+//
+// Members of a community can message each other privately, for example so a
+// provider can ask a clarifying question before making an offer. A
+// conversation belongs to the community its participants share and can be
+// about one of its service requests. Each participant's LastReadMessageID is
+// their read receipt: every message up to it has been read. Messages can only
+// be sent while all participants are still members of the community.
+
+// maxMessageLength is the longest message body, in bytes
+const maxMessageLength = 5000
+
+// conversationResponse is a conversation with its latest message and how many
+// messages the current user hasn't read
+type conversationResponse struct {
+	Conversation
+	LastMessage *Message `json:"last_message"`
+	UnreadCount int64    `json:"unread_count"`
+}
+
+// preloadConversation loads what conversation responses show
+func preloadConversation(query *gorm.DB) *gorm.DB {
+	return query.Preload("ServiceRequest").Preload("Participants.User", publicUserColumns)
+}
+
+// unreadMessages selects the messages in conversations of userID that they
+// haven't read. Their own messages count as read.
+func unreadMessages(db *gorm.DB, userID uint) *gorm.DB {
+	return db.Model(&Message{}).
+		Joins("JOIN conversation_participants ON conversation_participants.conversation_id = messages.conversation_id AND conversation_participants.user_id = ?", userID).
+		Where("messages.id > conversation_participants.last_read_message_id AND messages.sender_id != ?", userID)
+}
+
+// conversationViews adds the latest message and unread count to conversations
+func conversationViews(db *gorm.DB, userID uint, conversations []Conversation) ([]conversationResponse, error) {
+	views := make([]conversationResponse, len(conversations))
+	if len(conversations) == 0 {
+		return views, nil
+	}
+
+	ids := make([]uint, len(conversations))
+	for i := range conversations {
+		ids[i] = conversations[i].ID
+	}
+
+	var unread []struct {
+		ConversationID uint
+		Count          int64
+	}
+	if err := unreadMessages(db, userID).
+		Select("messages.conversation_id, COUNT(*) AS count").
+		Where("messages.conversation_id IN ?", ids).
+		Group("messages.conversation_id").
+		Scan(&unread).Error; err != nil {
+		return nil, err
+	}
+	unreadByID := make(map[uint]int64, len(unread))
+	for _, row := range unread {
+		unreadByID[row.ConversationID] = row.Count
+	}
+
+	var latest []Message
+	if err := db.Preload("Sender", publicUserColumns).Where("id IN (?)", db.Model(&Message{}).
+		Select("MAX(id)").
+		Where("conversation_id IN ?", ids).
+		Group("conversation_id")).
+		Find(&latest).Error; err != nil {
+		return nil, err
+	}
+	latestByID := make(map[uint]*Message, len(latest))
+	for i := range latest {
+		latestByID[latest[i].ConversationID] = &latest[i]
+	}
+
+	for i := range conversations {
+		views[i] = conversationResponse{
+			Conversation: conversations[i],
+			LastMessage:  latestByID[conversations[i].ID],
+			UnreadCount:  unreadByID[conversations[i].ID],
+		}
+	}
+	return views, nil
+}
+
+// writeConversation reloads a conversation for a response
+func writeConversation(c *gin.Context, db *gorm.DB, status int, userID, conversationID uint) {
+	var conversation Conversation
+	if err := preloadConversation(db).First(&conversation, conversationID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to load conversation"})
+		return
+	}
+
+	views, err := conversationViews(db, userID, []Conversation{conversation})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to load conversation"})
+		return
+	}
+
+	c.JSON(status, views[0])
+}
+
+// getConversationsHandler handles GET /api/conversations
+// The current user's conversations, most recently active first.
+func getConversationsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		page, pageSize := parsePagination(c)
+
+		query := db.Model(&Conversation{}).
+			Joins("JOIN conversation_participants ON conversation_participants.conversation_id = conversations.id AND conversation_participants.user_id = ?", userID)
+		if tenant := getTenant(c); tenant != nil {
+			query = query.Where("conversations.community_id = ?", tenant.ID)
+		}
+		if requestID := c.Query("service_request_id"); requestID != "" {
+			query = query.Where("conversations.service_request_id = ?", requestID)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count conversations"})
+			return
+		}
+
+		var conversations []Conversation
+		if err := preloadConversation(query).
+			Order("conversations.last_message_at DESC").
+			Offset((page - 1) * pageSize).
+			Limit(pageSize).
+			Find(&conversations).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch conversations"})
+			return
+		}
+
+		views, err := conversationViews(db, userID, conversations)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch conversations"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"conversations": views,
+			"page":          page,
+			"page_size":     pageSize,
+			"total":         total,
+		})
+	}
+}
+
+// getUnreadMessagesHandler handles GET /api/conversations/unread
+// The current user's unread messages, and how many conversations they are in.
+func getUnreadMessagesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		var totals struct {
+			Messages      int64
+			Conversations int64
+		}
+		if err := unreadMessages(db, userID).
+			Select("COUNT(*) AS messages, COUNT(DISTINCT messages.conversation_id) AS conversations").
+			Scan(&totals).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count unread messages"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"unread_messages":      totals.Messages,
+			"unread_conversations": totals.Conversations,
+		})
+	}
+}
+
+// createConversationHandler handles POST /api/conversations
+// Starts a conversation with another member of a community, or returns the
+// existing one between the two about the same service request. The community
+// is the service request's when one is given.
+func createConversationHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		var input struct {
+			RecipientID      uint   `json:"recipient_id"`
+			CommunityID      uint   `json:"community_id"`
+			ServiceRequestID *uint  `json:"service_request_id"`
+			Body             string `json:"body"` // Optional first message
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+		input.Body = strings.TrimSpace(input.Body)
+
+		if input.RecipientID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "recipient_id is required"})
+			return
+		}
+		if len(input.Body) > maxMessageLength {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Message is too long"})
+			return
+		}
+
+		var request *ServiceRequest
+		if input.ServiceRequestID != nil {
+			request = &ServiceRequest{}
+			if err := db.First(request, *input.ServiceRequestID).Error; err != nil {
+				if err == gorm.ErrRecordNotFound {
+					c.JSON(http.StatusNotFound, gin.H{"message": "Service request not found"})
+				} else {
+					c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service request"})
+				}
+				return
+			}
+			input.CommunityID = request.CommunityID
+		} else if tenant := getTenant(c); tenant != nil && input.CommunityID == 0 {
+			input.CommunityID = tenant.ID
+		}
+		if input.CommunityID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "community_id or service_request_id is required"})
+			return
+		}
+
+		access, ok := checkCommunityAccess(c, db, input.CommunityID)
+		if !ok {
+			return
+		}
+		userID := access.UserID
+
+		if input.RecipientID == userID {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "You can't message yourself"})
+			return
+		}
+		if request != nil && request.RequesterID != userID && request.RequesterID != input.RecipientID {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Conversations about a service request must include its requester"})
+			return
+		}
+		if ok, err := shareCommunity(db, input.CommunityID, userID, input.RecipientID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch membership"})
+			return
+		} else if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"message": "You can only message members of your communities"})
+			return
+		}
+
+		var conversation Conversation
+		status := http.StatusOK
+		err := transaction(db, func(tx *gorm.DB) error {
+			// Serialize on one of the pair so two requests can't both start a conversation
+			first := userID
+			if input.RecipientID < first {
+				first = input.RecipientID
+			}
+			if err := lockRow(tx, "users", first); err != nil {
+				return err
+			}
+
+			// Reuse the conversation the two already have about the same thing
+			existing := tx.Model(&Conversation{}).
+				Joins("JOIN conversation_participants sender ON sender.conversation_id = conversations.id AND sender.user_id = ?", userID).
+				Joins("JOIN conversation_participants recipient ON recipient.conversation_id = conversations.id AND recipient.user_id = ?", input.RecipientID).
+				Where("conversations.community_id = ?", input.CommunityID)
+			if input.ServiceRequestID != nil {
+				existing = existing.Where("conversations.service_request_id = ?", *input.ServiceRequestID)
+			} else {
+				existing = existing.Where("conversations.service_request_id IS NULL")
+			}
+			if err := existing.First(&conversation).Error; err != nil && err != gorm.ErrRecordNotFound {
+				return err
+			}
+
+			if conversation.ID == 0 {
+				status = http.StatusCreated
+				conversation = Conversation{
+					CommunityID:      input.CommunityID,
+					ServiceRequestID: input.ServiceRequestID,
+					LastMessageAt:    time.Now(),
+				}
+				if err := tx.Create(&conversation).Error; err != nil {
+					return err
+				}
+				for _, participantID := range []uint{userID, input.RecipientID} {
+					if err := tx.Create(&ConversationParticipant{ConversationID: conversation.ID, UserID: participantID}).Error; err != nil {
+						return err
+					}
+				}
+			}
+			if input.Body != "" {
+				_, err := sendMessage(tx, conversation.ID, userID, input.Body)
+				return err
+			}
+			return nil
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to start conversation"})
+			return
+		}
+
+		writeConversation(c, db, status, userID, conversation.ID)
+	}
+}
+
+// shareCommunity reports whether all the users are active members of the community
+func shareCommunity(db *gorm.DB, communityID uint, userIDs ...uint) (bool, error) {
+	var members int64
+	if err := db.Model(&UserCommunity{}).
+		Where("community_id = ? AND user_id IN ? AND is_active = ?", communityID, userIDs, true).
+		Count(&members).Error; err != nil {
+		return false, err
+	}
+	return members == int64(len(userIDs)), nil
+}
+
+// sendMessage adds a message to a conversation. The sender has read everything up to it.
+func sendMessage(tx *gorm.DB, conversationID, senderID uint, body string) (*Message, error) {
+	message := Message{ConversationID: conversationID, SenderID: senderID, Body: body}
+	if err := tx.Create(&message).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&Conversation{}).Where("id = ?", conversationID).
+		Update("last_message_at", message.CreatedAt).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&ConversationParticipant{}).
+		Where("conversation_id = ? AND user_id = ?", conversationID, senderID).
+		Updates(map[string]interface{}{"last_read_message_id": message.ID, "last_read_at": message.CreatedAt}).Error; err != nil {
+		return nil, err
+	}
+
+	var participantIDs []uint
+	if err := tx.Model(&ConversationParticipant{}).Where("conversation_id = ?", conversationID).
+		Pluck("user_id", &participantIDs).Error; err != nil {
+		return nil, err
+	}
+	queueEvent(tx, eventMessageCreated, participantIDs, map[string]interface{}{
+		"conversation_id": conversationID,
+		"message_id":      message.ID,
+		"sender_id":       senderID,
+	})
+
+	for _, userID := range participantIDs {
+		if userID == senderID {
+			continue
+		}
+		if err := notifyMessage(tx, userID, &message); err != nil {
+			return nil, err
+		}
+	}
+	return &message, nil
+}
+
+// notifyMessage notifies a participant of a new message, unless they already
+// have an unread notification for the conversation
+func notifyMessage(tx *gorm.DB, userID uint, message *Message) error {
+	link := fmt.Sprintf("/messages?conversation=%d", message.ConversationID)
+
+	var unread int64
+	if err := tx.Model(&Notification{}).
+		Where("user_id = ? AND type = ? AND link = ? AND read_at IS NULL", userID, notificationMessageReceived, link).
+		Count(&unread).Error; err != nil {
+		return err
+	}
+	if unread > 0 {
+		return nil
+	}
+
+	var sender User
+	if err := tx.Select("id", "name").First(&sender, message.SenderID).Error; err != nil {
+		return err
+	}
+	return notify(tx, userID, notificationMessageReceived, "New message from "+sender.Name, message.Body, link)
+}
+
+// loadConversation loads the :id conversation for one of its participants. It
+// writes an error response and returns false otherwise.
+func loadConversation(c *gin.Context, db *gorm.DB) (*Conversation, uint, bool) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return nil, 0, false
+	}
+
+	conversationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid conversation ID"})
+		return nil, 0, false
+	}
+
+	var conversation Conversation
+	if err := db.Preload("Participants").First(&conversation, conversationID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Conversation not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch conversation"})
+		}
+		return nil, 0, false
+	}
+
+	if !checkTenantCommunity(c, conversation.CommunityID) {
+		return nil, 0, false
+	}
+	for _, participant := range conversation.Participants {
+		if participant.UserID == userID {
+			return &conversation, userID, true
+		}
+	}
+
+	// Don't reveal other people's conversations
+	c.JSON(http.StatusNotFound, gin.H{"message": "Conversation not found"})
+	return nil, 0, false
+}
+
+// getConversationHandler handles GET /api/conversations/:id (participants)
+// Participants include their read receipts.
+func getConversationHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		conversation, userID, ok := loadConversation(c, db)
+		if !ok {
+			return
+		}
+
+		writeConversation(c, db, http.StatusOK, userID, conversation.ID)
+	}
+}
+
+// getMessagesHandler handles GET /api/conversations/:id/messages (participants)
+// Newest first.
+func getMessagesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		conversation, _, ok := loadConversation(c, db)
+		if !ok {
+			return
+		}
+
+		page, pageSize := parsePagination(c)
+		query := db.Model(&Message{}).Where("conversation_id = ?", conversation.ID)
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count messages"})
+			return
+		}
+
+		var messages []Message
+		if err := query.Preload("Sender", publicUserColumns).
+			Order("id DESC").
+			Offset((page - 1) * pageSize).
+			Limit(pageSize).
+			Find(&messages).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch messages"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"messages":  messages,
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		})
+	}
+}
+
+// sendMessageHandler handles POST /api/conversations/:id/messages (participants)
+func sendMessageHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		conversation, userID, ok := loadConversation(c, db)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			Body string `json:"body"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+		input.Body = strings.TrimSpace(input.Body)
+
+		if input.Body == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Message body is required"})
+			return
+		}
+		if len(input.Body) > maxMessageLength {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Message is too long"})
+			return
+		}
+
+		participantIDs := make([]uint, len(conversation.Participants))
+		for i, participant := range conversation.Participants {
+			participantIDs[i] = participant.UserID
+		}
+		if ok, err := shareCommunity(db, conversation.CommunityID, participantIDs...); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch membership"})
+			return
+		} else if !ok {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Messages can only be sent while everyone in the conversation is a member of its community"})
+			return
+		}
+
+		var message *Message
+		err := transaction(db, func(tx *gorm.DB) error {
+			var err error
+			message, err = sendMessage(tx, conversation.ID, userID, input.Body)
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to send message"})
+			return
+		}
+
+		db.Preload("Sender", publicUserColumns).First(message, message.ID)
+
+		c.JSON(http.StatusCreated, message)
+	}
+}
+
+// markConversationReadHandler handles POST /api/conversations/:id/read (participants)
+// Marks messages up to message_id as read, or all of them when it is omitted.
+// Read receipts only move forward.
+func markConversationReadHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		conversation, userID, ok := loadConversation(c, db)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			MessageID uint `json:"message_id"`
+		}
+		// Everything is read when there's no body
+		_ = c.ShouldBindJSON(&input)
+
+		var latestID uint
+		if err := db.Model(&Message{}).
+			Where("conversation_id = ?", conversation.ID).
+			Select("COALESCE(MAX(id), 0)").
+			Scan(&latestID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch messages"})
+			return
+		}
+		readID := latestID
+		if input.MessageID != 0 && input.MessageID < latestID {
+			readID = input.MessageID
+		}
+
+		result := db.Model(&ConversationParticipant{}).
+			Where("conversation_id = ? AND user_id = ? AND last_read_message_id < ?", conversation.ID, userID, readID).
+			Updates(map[string]interface{}{"last_read_message_id": readID, "last_read_at": time.Now()})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to mark conversation read"})
+			return
+		}
+
+		// Reading the conversation reads its message notification too
+		if readID == latestID {
+			db.Model(&Notification{}).
+				Where("user_id = ? AND type = ? AND link = ? AND read_at IS NULL", userID, notificationMessageReceived,
+					fmt.Sprintf("/messages?conversation=%d", conversation.ID)).
+				Update("read_at", time.Now())
+		}
+
+		// Update the other participants' read receipts
+		if result.RowsAffected > 0 {
+			participantIDs := make([]uint, len(conversation.Participants))
+			for i, participant := range conversation.Participants {
+				participantIDs[i] = participant.UserID
+			}
+			publishEvent(eventConversationRead, participantIDs, map[string]interface{}{
+				"conversation_id":      conversation.ID,
+				"user_id":              userID,
+				"last_read_message_id": readID,
+			})
+		}
+
+		writeConversation(c, db, http.StatusOK, userID, conversation.ID)
+	}
+}
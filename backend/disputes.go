@@ -0,0 +1,298 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Disputes
+//
+// A requester disputes work marked done instead of confirming it. A community
+// moderator who isn't a party takes the dispute on as its mediator and
+// resolves it: the work is accepted as complete, sent back for rework, or the
+// request is cancelled.
+
+// Dispute statuses
+const (
+	disputeStatusOpen      = "open"
+	disputeStatusMediating = "mediating"
+	disputeStatusResolved  = "resolved"
+)
+
+// disputeResolutions maps each resolution to the status it moves the request to
+var disputeResolutions = map[string]string{
+	"completed": requestStatusCompleted,
+	"rework":    requestStatusInProgress,
+	"cancelled": requestStatusCancelled,
+}
+
+// errorCodeDisputeResolved is returned when acting on a dispute that is already resolved
+const errorCodeDisputeResolved = "dispute_resolved"
+
+// preloadDispute loads a dispute's request and people for a response
+func preloadDispute(db *gorm.DB) *gorm.DB {
+	return db.Preload("ServiceRequest").
+		Preload("RaisedBy", publicUserColumns).
+		Preload("Mediator", publicUserColumns)
+}
+
+// loadModeratedDispute loads the :id dispute for a moderator of its community
+// who isn't a party to it. It writes an error response and returns false otherwise.
+func loadModeratedDispute(c *gin.Context, db *gorm.DB) (*Dispute, uint, bool) {
+	disputeID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid dispute ID"})
+		return nil, 0, false
+	}
+
+	var dispute Dispute
+	if err := db.Preload("ServiceRequest").First(&dispute, disputeID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Dispute not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch dispute"})
+		}
+		return nil, 0, false
+	}
+
+	access, ok := authorizeCommunityRole(c, db, dispute.ServiceRequest.CommunityID, RoleAdmin, RoleModerator)
+	if !ok {
+		return nil, 0, false
+	}
+
+	providerID, err := acceptedProviderID(db, &dispute.ServiceRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch accepted offer"})
+		return nil, 0, false
+	}
+	if access.UserID == dispute.ServiceRequest.RequesterID || access.UserID == providerID {
+		c.JSON(http.StatusForbidden, gin.H{"message": "You can't mediate a dispute you are a party to"})
+		return nil, 0, false
+	}
+
+	if dispute.Status == disputeStatusResolved {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "This dispute has already been resolved",
+			"code":    errorCodeDisputeResolved,
+		})
+		return nil, 0, false
+	}
+
+	return &dispute, access.UserID, true
+}
+
+// getServiceRequestDisputesHandler handles GET /api/service-requests/:id/disputes
+// The requester, the accepted provider and community moderators can see them.
+func getServiceRequestDisputesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid service request ID"})
+			return
+		}
+
+		var request ServiceRequest
+		if err := db.First(&request, requestID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Service request not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service request"})
+			}
+			return
+		}
+
+		access, ok := checkCommunityAccess(c, db, request.CommunityID)
+		if !ok {
+			return
+		}
+
+		providerID, err := acceptedProviderID(db, &request)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch accepted offer"})
+			return
+		}
+		if access.UserID != request.RequesterID && access.UserID != providerID && !access.CanModerate {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only the parties and community moderators can see disputes"})
+			return
+		}
+
+		var disputes []Dispute
+		if err := db.Preload("RaisedBy", publicUserColumns).
+			Preload("Mediator", publicUserColumns).
+			Where("service_request_id = ?", request.ID).
+			Order("created_at ASC").
+			Find(&disputes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch disputes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, disputes)
+	}
+}
+
+// getCommunityDisputesHandler handles GET /api/communities/:id/disputes
+// Lists a community's disputes for its moderators, optionally filtered by status.
+func getCommunityDisputesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin, RoleModerator)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		communityID := getCommunityAccess(c).CommunityID
+		page, pageSize := parsePagination(c)
+
+		query := db.Model(&Dispute{}).
+			Joins("JOIN service_requests ON service_requests.id = disputes.service_request_id").
+			Where("service_requests.community_id = ?", communityID)
+		if status := c.Query("status"); status != "" {
+			query = query.Where("disputes.status = ?", status)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count disputes"})
+			return
+		}
+
+		var disputes []Dispute
+		if err := preloadDispute(query).
+			Order("disputes.created_at DESC").
+			Offset((page - 1) * pageSize).
+			Limit(pageSize).
+			Find(&disputes).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch disputes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"disputes":  disputes,
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		})
+	}
+}
+
+// mediateDisputeHandler handles POST /api/disputes/:id/mediate
+// The moderator takes the dispute on; only they can resolve it afterwards.
+func mediateDisputeHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		dispute, userID, ok := loadModeratedDispute(c, db)
+		if !ok {
+			return
+		}
+
+		if dispute.MediatorID != nil && *dispute.MediatorID != userID {
+			c.JSON(http.StatusConflict, gin.H{"message": "Another moderator is already mediating this dispute"})
+			return
+		}
+
+		if dispute.MediatorID == nil {
+			result := db.Model(&Dispute{}).
+				Where("id = ? AND mediator_id IS NULL", dispute.ID).
+				Updates(map[string]interface{}{"mediator_id": userID, "status": disputeStatusMediating})
+			if result.Error != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update dispute"})
+				return
+			}
+			if result.RowsAffected == 0 {
+				c.JSON(http.StatusConflict, gin.H{"message": "Another moderator is already mediating this dispute"})
+				return
+			}
+		}
+
+		preloadDispute(db).First(dispute, dispute.ID)
+
+		c.JSON(http.StatusOK, dispute)
+	}
+}
+
+// resolveDisputeHandler handles POST /api/disputes/:id/resolve
+// Moves the request on according to the resolution and closes the dispute.
+func resolveDisputeHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		dispute, userID, ok := loadModeratedDispute(c, db)
+		if !ok {
+			return
+		}
+
+		if dispute.MediatorID != nil && *dispute.MediatorID != userID {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only the mediator can resolve this dispute"})
+			return
+		}
+
+		var input struct {
+			Resolution string `json:"resolution"`
+			Note       string `json:"note"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		to, valid := disputeResolutions[input.Resolution]
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Resolution must be completed, rework or cancelled"})
+			return
+		}
+		input.Note = strings.TrimSpace(input.Note)
+		if input.Note == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Note is required"})
+			return
+		}
+
+		now := time.Now()
+		request := dispute.ServiceRequest
+		err := transaction(db, func(tx *gorm.DB) error {
+			if err := transitionServiceRequest(tx, &request, to, &userID, "dispute resolved: "+input.Note); err != nil {
+				return err
+			}
+			return tx.Model(dispute).Updates(map[string]interface{}{
+				"status":          disputeStatusResolved,
+				"mediator_id":     userID,
+				"resolution":      input.Resolution,
+				"resolution_note": input.Note,
+				"resolved_at":     now,
+			}).Error
+		})
+		if err != nil {
+			respondTransitionError(c, err, "Failed to resolve dispute")
+			return
+		}
+
+		recordAudit(db, c, auditEntry{
+			Action:      auditDisputeResolve,
+			TargetType:  "dispute",
+			TargetID:    dispute.ID,
+			CommunityID: &request.CommunityID,
+			Before:      map[string]interface{}{"status": dispute.Status},
+			After:       map[string]interface{}{"status": disputeStatusResolved, "resolution": input.Resolution},
+		})
+
+		preloadDispute(db).First(dispute, dispute.ID)
+
+		c.JSON(http.StatusOK, dispute)
+	}
+}
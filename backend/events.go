@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+// Real-time events
+//
+// Handlers publish domain events (a new offer, a status change, a message)
+// addressed to the users they concern, and GET /api/events streams each
+// user's events to them as Server-Sent Events. EVENT_BUS selects how events
+// reach the streams: "memory" (default) delivers them within this process,
+// "redis" publishes them on a Redis channel so every instance delivers them.
+//
+// Events raised inside a transaction are queued with queueEvent and only
+// published once it commits, so clients never hear about changes that were
+// rolled back. Events carry IDs and statuses, not the records themselves;
+// clients fetch what they need through the API, with the usual access checks.
+
+// Event types
+const (
+	eventServiceRequestStatus = "service_request.status_changed"
+	eventOfferCreated         = "offer.created"
+	eventOfferCountered       = "offer.countered"
+	eventOfferStatus          = "offer.status_changed"
+	eventJoinRequestCreated   = "join_request.created"
+	eventJoinRequestApproved  = "join_request.approved"
+	eventJoinRequestRejected  = "join_request.rejected"
+	eventMessageCreated       = "message.created"
+	eventConversationRead     = "conversation.read"
+	eventNotificationCreated  = "notification.created"
+)
+
+const (
+	// eventBufferSize is how many events a stream can fall behind before it is closed
+	eventBufferSize = 64
+	// eventHeartbeatInterval keeps idle streams open through proxies, and is
+	// how often a stream's session is checked
+	eventHeartbeatInterval = 25 * time.Second
+	// eventRedisChannel is the Redis channel events are fanned out on
+	eventRedisChannel = "commune:events"
+)
+
+// Event is something that happened, addressed to the users it concerns
+type Event struct {
+	Type    string                 `json:"type"`
+	UserIDs []uint                 `json:"user_ids"`
+	Data    map[string]interface{} `json:"data"`
+}
+
+// EventBus delivers published events to the subscribed users
+type EventBus interface {
+	// Publish delivers the event to its users' subscriptions
+	Publish(event Event)
+	// Subscribe returns a channel of the user's events. The channel is closed
+	// when unsubscribe is called or when the subscriber falls too far behind.
+	Subscribe(userID uint) (events <-chan Event, unsubscribe func())
+}
+
+// eventBus is the EventBus used by the application, set up in main
+var eventBus EventBus = newMemoryEventBus()
+
+// eventStreamsClosed is closed when the server shuts down, ending open streams
+// (which would otherwise hold up the shutdown); clients reconnect elsewhere
+var eventStreamsClosed = make(chan struct{})
+
+var closeEventStreamsOnce sync.Once
+
+// closeEventStreams ends every open event stream
+func closeEventStreams() {
+	closeEventStreamsOnce.Do(func() { close(eventStreamsClosed) })
+}
+
+// loadEventBus builds the EventBus configured in the environment
+func loadEventBus() EventBus {
+	if os.Getenv("EVENT_BUS") != "redis" {
+		return newMemoryEventBus()
+	}
+
+	client, err := connectRedis()
+	if err != nil {
+		log.Printf("Redis unavailable for the event bus, using in-memory bus: %v", err)
+		return newMemoryEventBus()
+	}
+
+	log.Println("Using Redis event bus")
+	bus := &redisEventBus{local: newMemoryEventBus(), client: client}
+	go bus.receive()
+	return bus
+}
+
+// memoryEventBus delivers events to subscribers in this process
+type memoryEventBus struct {
+	mu          sync.Mutex
+	subscribers map[uint]map[chan Event]struct{}
+}
+
+func newMemoryEventBus() *memoryEventBus {
+	return &memoryEventBus{subscribers: make(map[uint]map[chan Event]struct{})}
+}
+
+func (b *memoryEventBus) Publish(event Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, userID := range event.UserIDs {
+		for ch := range b.subscribers[userID] {
+			select {
+			case ch <- event:
+			default:
+				// Too far behind; closing makes the client reconnect and refetch
+				b.remove(userID, ch)
+			}
+		}
+	}
+}
+
+func (b *memoryEventBus) Subscribe(userID uint) (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+
+	b.mu.Lock()
+	if b.subscribers[userID] == nil {
+		b.subscribers[userID] = make(map[chan Event]struct{})
+	}
+	b.subscribers[userID][ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.remove(userID, ch)
+	}
+}
+
+// remove closes and forgets a subscription; the caller holds mu
+func (b *memoryEventBus) remove(userID uint, ch chan Event) {
+	if _, ok := b.subscribers[userID][ch]; !ok {
+		return
+	}
+	delete(b.subscribers[userID], ch)
+	if len(b.subscribers[userID]) == 0 {
+		delete(b.subscribers, userID)
+	}
+	close(ch)
+}
+
+// redisEventBus publishes events on a Redis channel and delivers what it
+// receives from the channel to this instance's subscribers, so an event
+// published on any instance reaches every instance's streams
+type redisEventBus struct {
+	local  *memoryEventBus
+	client *redis.Client
+}
+
+func (b *redisEventBus) Publish(event Event) {
+	payload, err := json.Marshal(event)
+	if err == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		err = b.client.Publish(ctx, eventRedisChannel, payload).Err()
+	}
+	if err != nil {
+		// Other instances miss it, but this one can still deliver it
+		log.Printf("Failed to publish %s event to Redis: %v", event.Type, err)
+		b.local.Publish(event)
+	}
+}
+
+func (b *redisEventBus) Subscribe(userID uint) (<-chan Event, func()) {
+	return b.local.Subscribe(userID)
+}
+
+// receive delivers events from the Redis channel for the life of the process.
+// The subscription reconnects by itself if Redis goes away.
+func (b *redisEventBus) receive() {
+	pubsub := b.client.Subscribe(context.Background(), eventRedisChannel)
+	for msg := range pubsub.Channel() {
+		var event Event
+		if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+			log.Printf("Ignoring malformed event from Redis: %v", err)
+			continue
+		}
+		b.local.Publish(event)
+	}
+}
+
+// pendingEventsKey is the context key of the events queued in a transaction
+type pendingEventsKey struct{}
+
+// transaction runs fn in a transaction like db.Transaction, and publishes
+// the events fn queued with queueEvent once it commits. Inside another
+// transaction it runs fn in a savepoint, and the events are passed up to
+// the outer transaction unless the savepoint is rolled back.
+func transaction(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+	var pending []Event
+	ctx := context.WithValue(db.Statement.Context, pendingEventsKey{}, &pending)
+	if err := db.WithContext(ctx).Transaction(fn); err != nil {
+		return err
+	}
+
+	if outer, ok := db.Statement.Context.Value(pendingEventsKey{}).(*[]Event); ok {
+		*outer = append(*outer, pending...)
+		return nil
+	}
+	for _, event := range pending {
+		eventBus.Publish(event)
+	}
+	return nil
+}
+
+// newEvent builds an event for the given users, leaving out duplicates and
+// the zero ID that stands for nobody (e.g. a request without a provider yet)
+func newEvent(eventType string, userIDs []uint, data map[string]interface{}) Event {
+	recipients := make([]uint, 0, len(userIDs))
+	seen := make(map[uint]bool)
+	for _, userID := range userIDs {
+		if userID != 0 && !seen[userID] {
+			seen[userID] = true
+			recipients = append(recipients, userID)
+		}
+	}
+	return Event{Type: eventType, UserIDs: recipients, Data: data}
+}
+
+// queueEvent publishes an event once the transaction tx belongs to commits,
+// or straight away when tx isn't a transaction started by transaction
+func queueEvent(tx *gorm.DB, eventType string, userIDs []uint, data map[string]interface{}) {
+	event := newEvent(eventType, userIDs, data)
+	if pending, ok := tx.Statement.Context.Value(pendingEventsKey{}).(*[]Event); ok {
+		*pending = append(*pending, event)
+		return
+	}
+	eventBus.Publish(event)
+}
+
+// publishEvent publishes an event straight away, for changes already saved
+func publishEvent(eventType string, userIDs []uint, data map[string]interface{}) {
+	eventBus.Publish(newEvent(eventType, userIDs, data))
+}
+
+// getEventsHandler handles GET /api/events, streaming the current user's
+// events as Server-Sent Events until the client disconnects, its session
+// ends or the server shuts down. Each event's SSE name is its type and its data is the Event as JSON.
+func getEventsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		events, unsubscribe := eventBus.Subscribe(userID)
+		defer unsubscribe()
+
+		c.Header("Content-Type", "text/event-stream")
+		c.Header("Cache-Control", "no-cache")
+		c.Header("Connection", "keep-alive")
+		// Stop nginx buffering the stream
+		c.Header("X-Accel-Buffering", "no")
+		c.Status(http.StatusOK)
+		c.Writer.WriteString("retry: 5000\n\n")
+		c.Writer.Flush()
+
+		heartbeat := time.NewTicker(eventHeartbeatInterval)
+		defer heartbeat.Stop()
+
+		for {
+			select {
+			case <-c.Request.Context().Done():
+				return
+
+			case <-eventStreamsClosed:
+				return
+
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				c.SSEvent(event.Type, event)
+				c.Writer.Flush()
+
+			case <-heartbeat.C:
+				if !eventStreamAuthorized(c, db, userID) {
+					return
+				}
+				c.Writer.WriteString(": ping\n\n")
+				c.Writer.Flush()
+			}
+		}
+	}
+}
+
+// eventStreamAuthorized reports whether the credentials a stream was opened
+// with are still good, so revoked sessions and tokens stop receiving events
+func eventStreamAuthorized(c *gin.Context, db *gorm.DB, userID uint) bool {
+	if value, ok := c.Get("personalAccessToken"); ok {
+		pat := value.(*PersonalAccessToken)
+		var current PersonalAccessToken
+		if err := db.Select("id", "revoked_at", "expires_at").First(&current, pat.ID).Error; err != nil {
+			return false
+		}
+		return current.RevokedAt == nil && time.Now().Before(current.ExpiresAt)
+	}
+
+	sessionID, _ := c.Get("sessionID")
+	id, _ := sessionID.(uint)
+	_, err := validateSession(db, id, userID)
+	return err == nil
+}
@@ -360,7 +360,7 @@ func ExampleCreateCommunityWithCustomDomain(db *gorm.DB) (*Community, error) {
 func ExampleRouteByDomain(db *gorm.DB, requestDomain string) (*Community, error) {
 	// This would be called in HTTP middleware to determine which community
 	// the request is for based on the domain
-	return GetCommunityByDomain(db, requestDomain)
+	return GetCommunityByDomain(db, requestDomain, "commune.com")
 }
 
 // Example 23: Route request based on slug (for shared domain)
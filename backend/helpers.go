@@ -1,9 +1,20 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/redis/go-redis/v9"
 	"gorm.io/gorm"
 )
 
@@ -23,29 +34,42 @@ func GenerateSlug(s string) string {
 	return s
 }
 
-// GetCommunityByDomain finds a community by custom domain or subdomain
+// GetCommunityByDomain finds a community by custom domain, or by subdomain
+// when the host is directly under baseDomain (e.g. "sunset.commune.com" with
+// base domain "commune.com"). Any other host is only matched as a whole
+// against custom domains, so "sunset.org" doesn't resolve to "sunset".
 // This will be used to route requests to the correct community
-func GetCommunityByDomain(db *gorm.DB, domain string) (*Community, error) {
+func GetCommunityByDomain(db *gorm.DB, domain, baseDomain string) (*Community, error) {
 	var community Community
 
+	// Communities without a custom domain or subdomain store an empty string
+	if domain == "" {
+		return nil, gorm.ErrRecordNotFound
+	}
+
 	// Check if it's a custom domain
 	err := db.Where("custom_domain = ? AND is_active = ?", domain, true).First(&community).Error
-	if err == nil {
+	if err != gorm.ErrRecordNotFound {
+		if err != nil {
+			return nil, err
+		}
 		return &community, nil
 	}
 
-	// Check if it's a subdomain (extract subdomain part)
+	// Check if it's a subdomain of the base domain
 	// Example: "sunset.commune.com" -> "sunset"
-	parts := strings.Split(domain, ".")
-	if len(parts) > 0 {
-		subdomain := parts[0]
-		err = db.Where("subdomain = ? AND is_active = ?", subdomain, true).First(&community).Error
-		if err == nil {
-			return &community, nil
-		}
+	if baseDomain == "" {
+		return nil, gorm.ErrRecordNotFound
 	}
-
-	return nil, err
+	subdomain, ok := strings.CutSuffix(domain, "."+baseDomain)
+	if !ok || subdomain == "" || strings.Contains(subdomain, ".") {
+		return nil, gorm.ErrRecordNotFound
+	}
+	err = db.Where("subdomain = ? AND is_active = ?", subdomain, true).First(&community).Error
+	if err != nil {
+		return nil, err
+	}
+	return &community, nil
 }
 
 // GetCommunityBySlug finds a community by its slug
@@ -54,3 +78,165 @@ func GetCommunityBySlug(db *gorm.DB, slug string) (*Community, error) {
 	err := db.Where("slug = ? AND is_active = ?", slug, true).First(&community).Error
 	return &community, err
 }
+
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// parsePagination reads the page and page_size query parameters
+// Missing or invalid values fall back to page 1 and the default page size
+func parsePagination(c *gin.Context) (page, pageSize int) {
+	page, err := strconv.Atoi(c.Query("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	pageSize, err = strconv.Atoi(c.Query("page_size"))
+	if err != nil || pageSize < 1 {
+		pageSize = defaultPageSize
+	}
+	if pageSize > maxPageSize {
+		pageSize = maxPageSize
+	}
+
+	return page, pageSize
+}
+
+// getCommunityMembership returns the active membership of a user in a community
+func getCommunityMembership(db *gorm.DB, userID, communityID uint) (*UserCommunity, error) {
+	var membership UserCommunity
+	err := db.Where("user_id = ? AND community_id = ? AND is_active = ?", userID, communityID, true).
+		First(&membership).Error
+	if err != nil {
+		return nil, err
+	}
+	return &membership, nil
+}
+
+// publicUserColumns restricts a preloaded user to the fields exposed by sanitizeUser
+// Use it as Preload("Author", publicUserColumns) so password hashes are never serialized
+func publicUserColumns(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "created_at", "updated_at", "deleted_at", "name", "email", "role", "is_active")
+}
+
+// lockRow locks a row until the transaction ends, so concurrent changes to it
+// are serialized. SQLite already allows one writer at a time.
+func lockRow(tx *gorm.DB, table string, id uint) error {
+	if tx.Dialector.Name() != "postgres" {
+		return nil
+	}
+	return tx.Exec(fmt.Sprintf("SELECT id FROM %s WHERE id = ? FOR UPDATE", table), id).Error
+}
+
+// createAppendOnlyTriggers adds triggers that reject UPDATE and DELETE on a table
+func createAppendOnlyTriggers(tx *gorm.DB, table string) error {
+	switch tx.Dialector.Name() {
+	case "sqlite":
+		for suffix, op := range map[string]string{"no_update": "UPDATE", "no_delete": "DELETE"} {
+			if err := tx.Exec(fmt.Sprintf(`CREATE TRIGGER IF NOT EXISTS %s_%s
+				BEFORE %s ON %s
+				BEGIN SELECT RAISE(ABORT, '%s is append-only'); END`, table, suffix, op, table, table)).Error; err != nil {
+				return err
+			}
+		}
+	case "postgres":
+		if err := tx.Exec(fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s_append_only() RETURNS trigger AS $$
+			BEGIN RAISE EXCEPTION '%s is append-only'; END;
+			$$ LANGUAGE plpgsql`, table, table)).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec(fmt.Sprintf(`CREATE TRIGGER %s_append_only
+			BEFORE UPDATE OR DELETE ON %s
+			FOR EACH ROW EXECUTE FUNCTION %s_append_only()`, table, table, table)).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// dropAppendOnlyTriggers removes the triggers added by createAppendOnlyTriggers
+func dropAppendOnlyTriggers(tx *gorm.DB, table string) {
+	switch tx.Dialector.Name() {
+	case "sqlite":
+		tx.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s_no_update", table))
+		tx.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s_no_delete", table))
+	case "postgres":
+		tx.Exec(fmt.Sprintf("DROP TRIGGER IF EXISTS %s_append_only ON %s", table, table))
+		tx.Exec(fmt.Sprintf("DROP FUNCTION IF EXISTS %s_append_only()", table))
+	}
+}
+
+// authorizeSelfOrAdmin parses the :id user and checks that it is the current user or that
+// the current user is an admin. It writes an error response and returns false otherwise.
+func authorizeSelfOrAdmin(c *gin.Context) (uint, bool) {
+	currentUserID, err := getCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return 0, false
+	}
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user ID"})
+		return 0, false
+	}
+
+	currentUserRole, _ := c.Get("userRole")
+	if uint(id) != currentUserID && currentUserRole != RoleSuperAdmin && currentUserRole != RoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Insufficient permissions"})
+		return 0, false
+	}
+
+	return uint(id), true
+}
+
+// connectRedis connects to the Redis server at REDIS_HOST and REDIS_PORT and
+// checks that it answers
+func connectRedis() (*redis.Client, error) {
+	host := os.Getenv("REDIS_HOST")
+	if host == "" {
+		host = "localhost"
+	}
+	port := os.Getenv("REDIS_PORT")
+	if port == "" {
+		port = "6379"
+	}
+
+	client := redis.NewClient(&redis.Options{
+		Addr:     net.JoinHostPort(host, port),
+		Password: os.Getenv("REDIS_PASSWORD"),
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, err
+	}
+	return client, nil
+}
+
+// isUniqueViolation reports whether err is a unique index violation, on
+// Postgres or SQLite
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		return pgErr.Code == "23505"
+	}
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+// loadTrustedProxies reads TRUSTED_PROXIES, a comma-separated list of proxy
+// addresses or CIDR ranges allowed to report the client IP in
+// X-Forwarded-For. By default no proxy is trusted and the client IP is the
+// connecting peer, so clients can't pick their own address.
+func loadTrustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
@@ -0,0 +1,475 @@
+package main
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Invoices and receipts
+//
+// When a service request with an accepted offer completes, the provider's
+// invoice and the requester's receipt are rendered as PDFs and stored with
+// the invoice, so neither document changes once issued. Invoice numbers run
+// sequentially per provider, allocated from a counter row per provider so
+// concurrent completions don't collide. Agreed prices include tax: the community's tax
+// rate splits the total into a subtotal and a tax line.
+
+// Documents served for an invoice
+const (
+	invoiceDocumentInvoice = "invoice"
+	invoiceDocumentReceipt = "receipt"
+)
+
+// errorCodeInvoiceUnavailable is returned for requests that haven't completed
+const errorCodeInvoiceUnavailable = "invoice_unavailable"
+
+// maxInvoiceNumberAttempts bounds the numbers tried for one invoice
+const maxInvoiceNumberAttempts = 3
+
+// invoiceLine is one line item on an invoice
+type invoiceLine struct {
+	Description string
+	Amount      int64
+}
+
+// invoiceDetails is everything printed on an invoice besides the Invoice itself
+type invoiceDetails struct {
+	Request          *ServiceRequest
+	Provider         User
+	Requester        User
+	Community        Community
+	Lines            []invoiceLine
+	PaidAt           time.Time
+	PaymentReference string
+}
+
+// formatMinorUnits formats an amount of minor units, e.g. "1,234.50 EUR"
+func formatMinorUnits(amount int64, currency string) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+
+	units, cents := amount/100, amount%100
+	if zeroDecimalCurrencies[currency] {
+		units, cents = amount, -1
+	}
+
+	digits := strconv.FormatInt(units, 10)
+	var grouped strings.Builder
+	for i, d := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			grouped.WriteByte(',')
+		}
+		grouped.WriteRune(d)
+	}
+
+	if cents < 0 {
+		return fmt.Sprintf("%s%s %s", sign, grouped.String(), currency)
+	}
+	return fmt.Sprintf("%s%s.%02d %s", sign, grouped.String(), cents, currency)
+}
+
+// formatBps formats basis points as a percentage, e.g. 1250 as "12.5%"
+func formatBps(bps int) string {
+	return strconv.FormatFloat(float64(bps)/100, 'f', -1, 64) + "%"
+}
+
+// communityAddressLines returns the non-empty lines of a community's address
+func communityAddressLines(community *Community) []string {
+	var lines []string
+	if community.Address != "" {
+		lines = append(lines, community.Address)
+	}
+	cityLine := strings.TrimSpace(strings.Join(nonEmpty(community.City, community.State), ", ") + " " + community.ZipCode)
+	if cityLine != "" {
+		lines = append(lines, cityLine)
+	}
+	if community.Country != "" {
+		lines = append(lines, community.Country)
+	}
+	return lines
+}
+
+func nonEmpty(values ...string) []string {
+	var out []string
+	for _, v := range values {
+		if v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// issueInvoice creates the invoice for a completed request. It returns the
+// existing invoice when there is one, and nil when the request has no
+// accepted offer.
+func issueInvoice(tx *gorm.DB, request *ServiceRequest) (*Invoice, error) {
+	if request.AcceptedOfferID == nil {
+		return nil, nil
+	}
+
+	var existing Invoice
+	if err := tx.Where("service_request_id = ?", request.ID).First(&existing).Error; err == nil {
+		return &existing, nil
+	} else if err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+
+	var offer ServiceOffer
+	if err := tx.Preload("AgreedRevision").
+		Preload("Milestones", orderedMilestones).
+		First(&offer, *request.AcceptedOfferID).Error; err != nil {
+		return nil, err
+	}
+
+	details := invoiceDetails{Request: request, PaidAt: time.Now()}
+	if request.CompletedAt != nil {
+		details.PaidAt = *request.CompletedAt
+	}
+	if err := tx.First(&details.Provider, offer.ProviderID).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.First(&details.Requester, request.RequesterID).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.First(&details.Community, request.CommunityID).Error; err != nil {
+		return nil, err
+	}
+
+	// Bill in the currency the escrow was held in, which may predate a
+	// change to the community's currency
+	currency := details.Community.Currency
+	escrow, _, err := findEscrow(tx, request.ID)
+	if err != nil {
+		return nil, err
+	}
+	if escrow != nil {
+		currency = escrow.Currency
+
+		var hold LedgerTransaction
+		if err := tx.Where("service_request_id = ? AND kind = ?", request.ID, ledgerEscrowHold).
+			Order("id DESC").First(&hold).Error; err == nil {
+			details.PaymentReference = hold.PaymentReference
+		}
+	}
+
+	total := toMinorUnits(agreedPrice(&offer), currency)
+
+	// Itemize milestones. Plans must add up to the agreed price, but ones
+	// planned before that was checked may not.
+	var milestoneTotal int64
+	for _, m := range offer.Milestones {
+		details.Lines = append(details.Lines, invoiceLine{Description: m.Description, Amount: m.Amount})
+		if m.Currency != currency {
+			milestoneTotal = -1
+			break
+		}
+		milestoneTotal += m.Amount
+	}
+	if len(offer.Milestones) == 0 || milestoneTotal != total {
+		description := offer.Description
+		if offer.AgreedRevision != nil {
+			description = offer.AgreedRevision.Description
+		}
+		details.Lines = []invoiceLine{{Description: description, Amount: total}}
+	}
+
+	taxRate := details.Community.TaxRateBps
+	subtotal := (total*10000 + int64(10000+taxRate)/2) / int64(10000+taxRate)
+
+	invoice := Invoice{
+		CreatedAt:        time.Now(),
+		ServiceRequestID: request.ID,
+		ServiceOfferID:   offer.ID,
+		CommunityID:      request.CommunityID,
+		ProviderID:       offer.ProviderID,
+		RequesterID:      request.RequesterID,
+		Currency:         currency,
+		Subtotal:         subtotal,
+		TaxLabel:         details.Community.TaxLabel,
+		TaxRateBps:       taxRate,
+		Tax:              total - subtotal,
+		Total:            total,
+	}
+
+	// A number can still be taken when the counter is behind the invoices,
+	// so catch the counter up and try again rather than failing the completion
+	for attempt := 1; ; attempt++ {
+		sequence, err := nextInvoiceSequence(tx, offer.ProviderID)
+		if err != nil {
+			return nil, err
+		}
+
+		invoice.Sequence = sequence
+		invoice.Number = fmt.Sprintf("INV-%d-%05d", offer.ProviderID, sequence)
+		invoice.InvoicePDF = renderInvoicePDF(&invoice, &details, invoiceDocumentInvoice)
+		invoice.ReceiptPDF = renderInvoicePDF(&invoice, &details, invoiceDocumentReceipt)
+
+		if err := tx.SavePoint("issue_invoice").Error; err != nil {
+			return nil, err
+		}
+		err = tx.Create(&invoice).Error
+		if err == nil {
+			return &invoice, nil
+		}
+		if !isUniqueViolation(err) || attempt == maxInvoiceNumberAttempts {
+			return nil, err
+		}
+		if err := tx.RollbackTo("issue_invoice").Error; err != nil {
+			return nil, err
+		}
+
+		// The request may have been invoiced by a concurrent completion
+		if err := tx.Where("service_request_id = ?", request.ID).First(&existing).Error; err == nil {
+			return &existing, nil
+		} else if err != gorm.ErrRecordNotFound {
+			return nil, err
+		}
+
+		var lastSequence int
+		if err := tx.Model(&Invoice{}).
+			Where("provider_id = ?", offer.ProviderID).
+			Select("COALESCE(MAX(sequence), 0)").
+			Scan(&lastSequence).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&InvoiceCounter{}).
+			Where("provider_id = ? AND last_sequence < ?", offer.ProviderID, lastSequence).
+			UpdateColumn("last_sequence", lastSequence).Error; err != nil {
+			return nil, err
+		}
+	}
+}
+
+// nextInvoiceSequence allocates the provider's next invoice sequence from
+// their counter row. The update keeps the row locked until the transaction
+// ends, so completions for the same provider take numbers one at a time.
+func nextInvoiceSequence(tx *gorm.DB, providerID uint) (int, error) {
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&InvoiceCounter{ProviderID: providerID}).Error; err != nil {
+		return 0, err
+	}
+
+	counter := InvoiceCounter{ProviderID: providerID}
+	if err := tx.Model(&counter).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "last_sequence"}}}).
+		UpdateColumn("last_sequence", gorm.Expr("last_sequence + 1")).Error; err != nil {
+		return 0, err
+	}
+	return counter.LastSequence, nil
+}
+
+// renderInvoicePDF lays out the invoice or the receipt for an invoice
+func renderInvoicePDF(invoice *Invoice, details *invoiceDetails, document string) []byte {
+	const (
+		left      = 50.0
+		right     = pdfPageWidth - 50
+		middle    = 300.0
+		top       = pdfPageHeight - 60
+		bottom    = 70.0
+		lineGap   = 14.0
+		bodySize  = 10.0
+		smallSize = 8.0
+	)
+
+	title := "Invoice"
+	fromLabel, toLabel := "From", "Bill to"
+	if document == invoiceDocumentReceipt {
+		title = "Receipt"
+		fromLabel, toLabel = "Paid to", "Paid by"
+	}
+
+	doc := newPDFDocument(fmt.Sprintf("%s %s", title, invoice.Number))
+	y := top
+
+	// Starts a new page when fewer than space points are left
+	ensure := func(space float64) {
+		if y-space < bottom {
+			doc.AddPage()
+			y = top
+		}
+	}
+
+	doc.Text(left, y, 22, true, title)
+	doc.TextRight(right, y, 11, true, invoice.Number)
+	doc.TextRight(right, y-16, bodySize, false, "Issued "+invoice.CreatedAt.Format("2 January 2006"))
+	if document == invoiceDocumentReceipt {
+		doc.TextRight(right, y-30, bodySize, true, "Paid "+details.PaidAt.Format("2 January 2006"))
+	}
+	y -= 70
+
+	// Both parties live in the community, so both get its address
+	address := communityAddressLines(&details.Community)
+	parties := []struct {
+		x     float64
+		label string
+		user  User
+	}{
+		{left, fromLabel, details.Provider},
+		{middle, toLabel, details.Requester},
+	}
+	partyY := y
+	for _, party := range parties {
+		py := y
+		doc.Text(party.x, py, bodySize, true, party.label)
+		py -= lineGap
+		for _, line := range append([]string{party.user.Name, party.user.Email, details.Community.Name}, address...) {
+			doc.Text(party.x, py, bodySize, false, line)
+			py -= lineGap
+		}
+		if py < partyY {
+			partyY = py
+		}
+	}
+	y = partyY - 20
+
+	doc.Text(left, y, 11, true, fmt.Sprintf("Service request #%d: %s", details.Request.ID, details.Request.Title))
+	y -= 28
+
+	// Line items
+	doc.Text(left, y, bodySize, true, "Description")
+	doc.TextRight(right, y, bodySize, true, "Amount")
+	y -= 6
+	doc.Line(left, y, right, y)
+	y -= lineGap + 2
+	for _, item := range details.Lines {
+		lines := pdfWrapText(item.Description, bodySize, middle+80-left)
+		ensure(float64(len(lines)) * lineGap)
+		doc.TextRight(right, y, bodySize, false, formatMinorUnits(item.Amount, invoice.Currency))
+		for _, line := range lines {
+			doc.Text(left, y, bodySize, false, line)
+			y -= lineGap
+		}
+		y -= 4
+	}
+	doc.Line(left, y+8, right, y+8)
+	y -= 8
+
+	// Totals
+	ensure(4 * lineGap)
+	totals := []struct {
+		label  string
+		amount int64
+	}{
+		{"Subtotal", invoice.Subtotal},
+		{fmt.Sprintf("%s (%s)", invoice.TaxLabel, formatBps(invoice.TaxRateBps)), invoice.Tax},
+	}
+	for _, t := range totals {
+		doc.Text(middle+80, y, bodySize, false, t.label)
+		doc.TextRight(right, y, bodySize, false, formatMinorUnits(t.amount, invoice.Currency))
+		y -= lineGap
+	}
+	doc.Text(middle+80, y, 11, true, "Total")
+	doc.TextRight(right, y, 11, true, formatMinorUnits(invoice.Total, invoice.Currency))
+	y -= 2 * lineGap
+
+	// Payment note
+	ensure(2 * lineGap)
+	note := fmt.Sprintf("Paid in full through %s escrow on %s.", details.Community.Name, details.PaidAt.Format("2 January 2006"))
+	if document == invoiceDocumentReceipt && details.PaymentReference != "" {
+		note += " Payment reference: " + details.PaymentReference
+	}
+	for _, line := range pdfWrapText(note, smallSize, right-left) {
+		doc.Text(left, y, smallSize, false, line)
+		y -= smallSize + 3
+	}
+
+	return doc.Bytes()
+}
+
+// serviceRequestInvoiceHandler handles GET /api/service-requests/:id/invoice
+// The requester gets the receipt and everyone else the invoice, unless
+// document asks for the other. The requester, the accepted provider and
+// community admins can download them.
+func serviceRequestInvoiceHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid service request ID"})
+			return
+		}
+
+		var request ServiceRequest
+		if err := db.First(&request, requestID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Service request not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service request"})
+			}
+			return
+		}
+
+		access, ok := checkCommunityAccess(c, db, request.CommunityID)
+		if !ok {
+			return
+		}
+
+		providerID, err := acceptedProviderID(db, &request)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch accepted offer"})
+			return
+		}
+		if access.UserID != request.RequesterID && access.UserID != providerID && !access.hasRole(RoleAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only the requester, the provider and community admins can see invoices"})
+			return
+		}
+
+		document := c.Query("document")
+		switch document {
+		case "":
+			document = invoiceDocumentInvoice
+			if access.UserID == request.RequesterID {
+				document = invoiceDocumentReceipt
+			}
+		case invoiceDocumentInvoice, invoiceDocumentReceipt:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Document must be invoice or receipt"})
+			return
+		}
+
+		if request.Status != requestStatusCompleted || providerID == 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "Invoices are issued once a request with an accepted offer is completed",
+				"code":    errorCodeInvoiceUnavailable,
+			})
+			return
+		}
+
+		// Requests completed before invoicing get theirs on first download
+		var invoice *Invoice
+		err = db.Transaction(func(tx *gorm.DB) error {
+			var err error
+			invoice, err = issueInvoice(tx, &request)
+			return err
+		})
+		if err != nil || invoice == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to issue invoice"})
+			return
+		}
+
+		pdf, filename := invoice.InvoicePDF, invoice.Number+".pdf"
+		if document == invoiceDocumentReceipt {
+			pdf, filename = invoice.ReceiptPDF, "receipt-"+invoice.Number+".pdf"
+		}
+
+		c.Header("Content-Disposition", fmt.Sprintf(`inline; filename="%s"`, filename))
+		c.Header("ETag", fmt.Sprintf(`"%x"`, sha256.Sum256(pdf)))
+		c.Header("X-Invoice-Number", invoice.Number)
+		c.Data(http.StatusOK, "application/pdf", pdf)
+	}
+}
@@ -0,0 +1,799 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Background jobs
+//
+// Work that shouldn't hold up a request (sending email) or that runs on a
+// schedule (auto-confirming completions, posting recurring requests) goes
+// through a job queue kept in the jobs table, so it works the same on SQLite
+// and Postgres and survives restarts. Jobs are queued with enqueueJob, in the
+// caller's transaction when there is one, and run by the workers of every
+// instance. A job that returns an error is retried with exponential backoff;
+// once it has used up its attempts it is dead, and stays in the table for
+// admins to inspect, retry or discard.
+//
+// Scheduled jobs are listed in jobSchedules with a cron expression. Every
+// instance's scheduler queues them as they come due; job_schedules records
+// when each is next due, so only one instance queues each run.
+
+// Job kinds
+const (
+	jobSendEmail             = "send_email"
+	jobEmailVerification     = "email_verification"
+	jobPasswordResetEmail    = "password_reset_email"
+	jobAutoConfirmRequests   = "auto_confirm_requests"
+	jobPostRecurringRequests = "post_recurring_requests"
+	jobNotificationEmails    = "notification_emails"
+	jobDeliverWebhooks       = "deliver_webhooks"
+	jobExpireStaleOffers     = "expire_stale_offers"
+	jobPruneJobs             = "prune_jobs"
+)
+
+// Job statuses
+const (
+	jobStatusQueued    = "queued"
+	jobStatusRunning   = "running"
+	jobStatusSucceeded = "succeeded"
+	jobStatusDead      = "dead" // Out of attempts; kept until an admin retries or deletes it
+)
+
+const (
+	// defaultJobWorkers is how many jobs an instance runs at once unless JOB_WORKERS says otherwise
+	defaultJobWorkers = 4
+	// jobPollInterval is how often workers look for due jobs and schedules
+	jobPollInterval = time.Second
+	// defaultJobMaxAttempts is how many times a job is tried unless its kind says otherwise
+	defaultJobMaxAttempts = 5
+	// defaultJobTimeout is how long a job may run unless its kind says otherwise
+	defaultJobTimeout = 5 * time.Minute
+	// jobRetryBase is the wait after a job's first failure; it doubles after each one, up to jobRetryMax
+	jobRetryBase = 30 * time.Second
+	jobRetryMax  = time.Hour
+	// jobStaleAfter is how long a job can be running before its worker is
+	// assumed to have died and the job is queued again
+	jobStaleAfter = 30 * time.Minute
+	// jobRetention is how long finished jobs are kept before prune_jobs deletes them
+	jobRetention = 7 * 24 * time.Hour
+)
+
+// jobHandler runs a job with its JSON payload. db carries the job's context,
+// which is cancelled if the job times out or the server stops before it ends.
+type jobHandler func(db *gorm.DB, payload []byte) error
+
+// jobDefinition is how a kind of job is run
+type jobDefinition struct {
+	Handler     jobHandler
+	MaxAttempts int           // 0 for defaultJobMaxAttempts
+	Timeout     time.Duration // 0 for defaultJobTimeout
+}
+
+// jobDefinitions maps each job kind to how it is run. It is filled in by
+// registerJobs, as the handlers themselves queue jobs.
+var jobDefinitions map[string]jobDefinition
+
+// registerJobs sets up jobDefinitions
+func registerJobs() {
+	jobDefinitions = map[string]jobDefinition{
+		jobSendEmail:          {Handler: runSendEmailJob, MaxAttempts: 8},
+		jobEmailVerification:  {Handler: runEmailVerificationJob},
+		jobPasswordResetEmail: {Handler: runPasswordResetEmailJob},
+		jobAutoConfirmRequests: {Handler: func(db *gorm.DB, _ []byte) error {
+			return autoConfirmServiceRequests(db)
+		}},
+		jobPostRecurringRequests: {Handler: func(db *gorm.DB, _ []byte) error {
+			return postRecurringRequests(db)
+		}},
+		jobNotificationEmails: {Handler: func(db *gorm.DB, _ []byte) error {
+			return deliverNotificationEmails(db)
+		}},
+		jobDeliverWebhooks: {Handler: func(db *gorm.DB, _ []byte) error {
+			return deliverWebhooks(db)
+		}},
+		jobExpireStaleOffers: {Handler: func(db *gorm.DB, _ []byte) error {
+			return expireStaleOffers(db)
+		}},
+		jobPruneJobs: {Handler: func(db *gorm.DB, _ []byte) error {
+			return pruneJobs(db)
+		}},
+	}
+}
+
+// jobScheduleDefinition queues a job of the kind on a cron schedule
+type jobScheduleDefinition struct {
+	Kind string
+	Spec string // Five-field cron expression (UTC), @hourly/@daily/..., or @every <duration>
+}
+
+// jobSchedules are the jobs run on a schedule. A run is skipped while the
+// previous one is still queued or running.
+var jobSchedules = []jobScheduleDefinition{
+	{jobAutoConfirmRequests, "@every " + autoConfirmInterval.String()},
+	{jobPostRecurringRequests, "@every " + recurrenceInterval.String()},
+	{jobNotificationEmails, "@every " + notificationEmailInterval.String()},
+	{jobDeliverWebhooks, "@every " + webhookDeliveryInterval.String()},
+	{jobExpireStaleOffers, "15 * * * *"},
+	{jobPruneJobs, "30 3 * * *"},
+}
+
+// jobOptions adjusts how a job is queued
+type jobOptions struct {
+	RunAt     time.Time // Zero to run as soon as possible
+	UniqueKey string    // When set, nothing is queued while an unfinished job has the same key
+}
+
+// enqueueJob queues a job of the kind with the payload as its JSON arguments.
+// Called with a transaction, the job only exists once it commits. It returns
+// the job, or nil when an unfinished job already has the unique key.
+func enqueueJob(db *gorm.DB, kind string, payload interface{}, opts jobOptions) (*Job, error) {
+	definition, ok := jobDefinitions[kind]
+	if !ok {
+		return nil, fmt.Errorf("unknown job kind %q", kind)
+	}
+
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	job := Job{
+		Kind:        kind,
+		Payload:     string(encoded),
+		Status:      jobStatusQueued,
+		RunAt:       opts.RunAt,
+		MaxAttempts: definition.MaxAttempts,
+	}
+	if job.RunAt.IsZero() {
+		job.RunAt = time.Now()
+	}
+	if job.MaxAttempts == 0 {
+		job.MaxAttempts = defaultJobMaxAttempts
+	}
+	if opts.UniqueKey != "" {
+		job.UniqueKey = &opts.UniqueKey
+	}
+
+	result := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&job)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &job, nil
+}
+
+// jobRetryDelay is the wait before the next attempt after the given number of failed ones
+func jobRetryDelay(attempts int) time.Duration {
+	delay := jobRetryBase
+	for i := 1; i < attempts && delay < jobRetryMax; i++ {
+		delay *= 2
+	}
+	if delay > jobRetryMax {
+		return jobRetryMax
+	}
+	return delay
+}
+
+// jobQueue runs queued jobs and queues scheduled ones, for the life of the process
+type jobQueue struct {
+	db       *gorm.DB
+	workers  int
+	instance string // Identifies this process in Job.LockedBy
+
+	running atomic.Int32
+	wg      sync.WaitGroup
+
+	stopPolling context.CancelFunc // Stops claiming new jobs
+	cancelJobs  context.CancelFunc // Cancels the jobs still running
+	jobCtx      context.Context
+	done        chan struct{} // Closed once the poller has stopped
+}
+
+// jobs is the job queue of this process, set up in main
+var jobs *jobQueue
+
+// startJobQueue syncs the schedules and starts running jobs with the number
+// of workers set by JOB_WORKERS. With JOB_WORKERS=0 this instance only
+// queues jobs, for other instances to run.
+func startJobQueue(db *gorm.DB) *jobQueue {
+	workers := defaultJobWorkers
+	if value := os.Getenv("JOB_WORKERS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			log.Printf("Invalid JOB_WORKERS %q, using %d", value, defaultJobWorkers)
+		} else {
+			workers = parsed
+		}
+	}
+
+	hostname, _ := os.Hostname()
+	q := &jobQueue{
+		db:       db,
+		workers:  workers,
+		instance: fmt.Sprintf("%s:%d", hostname, os.Getpid()),
+		done:     make(chan struct{}),
+	}
+	q.jobCtx, q.cancelJobs = context.WithCancel(context.Background())
+
+	if workers == 0 {
+		log.Println("JOB_WORKERS=0: not running background jobs on this instance")
+		close(q.done)
+		return q
+	}
+
+	if err := syncJobSchedules(db); err != nil {
+		log.Printf("Failed to sync job schedules: %v", err)
+	}
+
+	pollCtx, stopPolling := context.WithCancel(context.Background())
+	q.stopPolling = stopPolling
+	go q.poll(pollCtx)
+
+	log.Printf("Running background jobs with %d workers", workers)
+	return q
+}
+
+// Shutdown stops claiming jobs and waits for the running ones to finish. If
+// ctx ends first, the running jobs are cancelled; they are retried later.
+func (q *jobQueue) Shutdown(ctx context.Context) error {
+	if q.stopPolling != nil {
+		q.stopPolling()
+	}
+	<-q.done
+
+	finished := make(chan struct{})
+	go func() {
+		q.wg.Wait()
+		close(finished)
+	}()
+
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		q.cancelJobs()
+		<-finished
+		return ctx.Err()
+	}
+}
+
+// poll queues due scheduled jobs, requeues abandoned ones and starts due jobs
+// while there are free workers, every jobPollInterval
+func (q *jobQueue) poll(ctx context.Context) {
+	defer close(q.done)
+
+	ticker := time.NewTicker(jobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := queueDueSchedules(q.db); err != nil {
+			log.Printf("Failed to queue scheduled jobs: %v", err)
+		}
+		if err := requeueStaleJobs(q.db); err != nil {
+			log.Printf("Failed to requeue stale jobs: %v", err)
+		}
+		if err := q.startDueJobs(); err != nil {
+			log.Printf("Failed to start jobs: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// startDueJobs claims due jobs for the free workers and starts them
+func (q *jobQueue) startDueJobs() error {
+	free := q.workers - int(q.running.Load())
+	if free <= 0 {
+		return nil
+	}
+
+	var due []Job
+	if err := q.db.Where("status = ? AND run_at <= ?", jobStatusQueued, time.Now()).
+		Order("run_at, id").
+		Limit(free).
+		Find(&due).Error; err != nil {
+		return err
+	}
+
+	for i := range due {
+		job := due[i]
+
+		// Another instance may claim it first
+		now := time.Now()
+		result := q.db.Model(&Job{}).
+			Where("id = ? AND status = ?", job.ID, jobStatusQueued).
+			Updates(map[string]interface{}{
+				"status":    jobStatusRunning,
+				"locked_by": q.instance,
+				"locked_at": now,
+				"attempts":  gorm.Expr("attempts + 1"),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			continue
+		}
+		job.Status = jobStatusRunning
+		job.Attempts++
+
+		q.running.Add(1)
+		q.wg.Add(1)
+		go q.run(&job)
+	}
+	return nil
+}
+
+// run runs a claimed job and records the outcome
+func (q *jobQueue) run(job *Job) {
+	defer q.wg.Done()
+	defer q.running.Add(-1)
+
+	err := q.execute(job)
+
+	now := time.Now()
+	updates := map[string]interface{}{
+		"locked_by": "",
+		"locked_at": nil,
+	}
+	switch {
+	case err == nil:
+		updates["status"] = jobStatusSucceeded
+		updates["finished_at"] = now
+		updates["unique_key"] = nil
+		updates["last_error"] = ""
+	case job.Attempts >= job.MaxAttempts:
+		log.Printf("Job %d (%s) failed for the last time: %v", job.ID, job.Kind, err)
+		updates["status"] = jobStatusDead
+		updates["finished_at"] = now
+		updates["unique_key"] = nil
+		updates["last_error"] = err.Error()
+	default:
+		log.Printf("Job %d (%s) failed, retrying: %v", job.ID, job.Kind, err)
+		updates["status"] = jobStatusQueued
+		updates["run_at"] = now.Add(jobRetryDelay(job.Attempts))
+		updates["last_error"] = err.Error()
+	}
+
+	// Unless it was requeued as stale in the meantime
+	if err := q.db.Model(&Job{}).
+		Where("id = ? AND status = ? AND locked_by = ?", job.ID, jobStatusRunning, q.instance).
+		Updates(updates).Error; err != nil {
+		log.Printf("Failed to record the outcome of job %d: %v", job.ID, err)
+	}
+}
+
+// execute calls the job's handler with its timeout, turning panics into errors
+func (q *jobQueue) execute(job *Job) (err error) {
+	definition, ok := jobDefinitions[job.Kind]
+	if !ok {
+		return fmt.Errorf("unknown job kind %q", job.Kind)
+	}
+
+	timeout := definition.Timeout
+	if timeout == 0 {
+		timeout = defaultJobTimeout
+	}
+	ctx, cancel := context.WithTimeout(q.jobCtx, timeout)
+	defer cancel()
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return definition.Handler(q.db.WithContext(ctx), []byte(job.Payload))
+}
+
+// requeueStaleJobs queues again the jobs whose worker seems to have died
+// while running them, or marks them dead if that was their last attempt
+func requeueStaleJobs(db *gorm.DB) error {
+	cutoff := time.Now().Add(-jobStaleAfter)
+	stale := "worker stopped while running the job"
+
+	if err := db.Model(&Job{}).
+		Where("status = ? AND locked_at < ? AND attempts >= max_attempts", jobStatusRunning, cutoff).
+		Updates(map[string]interface{}{
+			"status":      jobStatusDead,
+			"finished_at": time.Now(),
+			"unique_key":  nil,
+			"locked_by":   "",
+			"locked_at":   nil,
+			"last_error":  stale,
+		}).Error; err != nil {
+		return err
+	}
+
+	return db.Model(&Job{}).
+		Where("status = ? AND locked_at < ?", jobStatusRunning, cutoff).
+		Updates(map[string]interface{}{
+			"status":     jobStatusQueued,
+			"run_at":     time.Now(),
+			"locked_by":  "",
+			"locked_at":  nil,
+			"last_error": stale,
+		}).Error
+}
+
+// pruneJobs deletes finished jobs older than jobRetention. Dead jobs are kept
+// for admins to deal with.
+func pruneJobs(db *gorm.DB) error {
+	return db.Where("status = ? AND finished_at < ?", jobStatusSucceeded, time.Now().Add(-jobRetention)).
+		Delete(&Job{}).Error
+}
+
+// parseJobSchedule parses a schedule spec
+func parseJobSchedule(spec string) (cron.Schedule, error) {
+	return cron.ParseStandard(spec)
+}
+
+// syncJobSchedules adds the schedules in jobSchedules to job_schedules, due
+// straight away, and updates the ones whose spec has changed
+func syncJobSchedules(db *gorm.DB) error {
+	now := time.Now().UTC()
+	for _, definition := range jobSchedules {
+		schedule, err := parseJobSchedule(definition.Spec)
+		if err != nil {
+			return fmt.Errorf("schedule %s: %w", definition.Kind, err)
+		}
+
+		row := JobSchedule{Name: definition.Kind, Spec: definition.Spec, NextRunAt: now}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&row).Error; err != nil {
+			return err
+		}
+		if err := db.Model(&JobSchedule{}).
+			Where("name = ? AND spec <> ?", definition.Kind, definition.Spec).
+			Updates(map[string]interface{}{"spec": definition.Spec, "next_run_at": schedule.Next(now)}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// queueDueSchedules queues a job for each schedule that has come due and
+// moves it on to its next run. Runs missed while no instance was up are
+// skipped rather than made up.
+func queueDueSchedules(db *gorm.DB) error {
+	now := time.Now().UTC()
+
+	var due []JobSchedule
+	if err := db.Where("next_run_at <= ?", now).Find(&due).Error; err != nil {
+		return err
+	}
+
+	for _, row := range due {
+		schedule, err := parseJobSchedule(row.Spec)
+		if err != nil {
+			log.Printf("Invalid spec %q for scheduled job %s: %v", row.Spec, row.Name, err)
+			continue
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			// Only one instance gets to move it on
+			result := tx.Model(&JobSchedule{}).
+				Where("name = ? AND next_run_at <= ?", row.Name, now).
+				Updates(map[string]interface{}{"next_run_at": schedule.Next(now), "last_run_at": now})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error
+			}
+
+			job, err := enqueueJob(tx, row.Name, struct{}{}, jobOptions{UniqueKey: "schedule:" + row.Name})
+			if err != nil || job == nil {
+				return err
+			}
+			return tx.Model(&JobSchedule{}).Where("name = ?", row.Name).Update("last_job_id", job.ID).Error
+		})
+		if err != nil {
+			log.Printf("Failed to queue scheduled job %s: %v", row.Name, err)
+		}
+	}
+	return nil
+}
+
+// jobKindCounts is the number of a kind's jobs in each status
+type jobKindCounts struct {
+	Kind      string `json:"kind"`
+	Queued    int64  `json:"queued"`
+	Running   int64  `json:"running"`
+	Succeeded int64  `json:"succeeded"`
+	Dead      int64  `json:"dead"`
+}
+
+// getJobStatsHandler handles GET /api/admin/jobs/stats
+// Queue depth and failures, overall and by kind.
+func getJobStatsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		var rows []struct {
+			Kind   string
+			Status string
+			Count  int64
+		}
+		if err := db.Model(&Job{}).
+			Select("kind, status, COUNT(*) AS count").
+			Group("kind, status").
+			Scan(&rows).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count jobs"})
+			return
+		}
+
+		totals := map[string]int64{}
+		byKind := []*jobKindCounts{}
+		kinds := map[string]*jobKindCounts{}
+		for _, row := range rows {
+			counts, ok := kinds[row.Kind]
+			if !ok {
+				counts = &jobKindCounts{Kind: row.Kind}
+				kinds[row.Kind] = counts
+				byKind = append(byKind, counts)
+			}
+			switch row.Status {
+			case jobStatusQueued:
+				counts.Queued += row.Count
+			case jobStatusRunning:
+				counts.Running += row.Count
+			case jobStatusSucceeded:
+				counts.Succeeded += row.Count
+			case jobStatusDead:
+				counts.Dead += row.Count
+			}
+			totals[row.Status] += row.Count
+		}
+
+		now := time.Now()
+		var due, retrying int64
+		if err := db.Model(&Job{}).Where("status = ? AND run_at <= ?", jobStatusQueued, now).Count(&due).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count jobs"})
+			return
+		}
+		if err := db.Model(&Job{}).Where("status = ? AND attempts > 0", jobStatusQueued).Count(&retrying).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count jobs"})
+			return
+		}
+
+		// How long the longest-waiting due job has waited
+		var oldestDue Job
+		var oldestDueAt *time.Time
+		if err := db.Where("status = ? AND run_at <= ?", jobStatusQueued, now).
+			Order("run_at").
+			Limit(1).
+			Find(&oldestDue).Error; err == nil && oldestDue.ID != 0 {
+			oldestDueAt = &oldestDue.RunAt
+		}
+
+		workers := 0
+		if jobs != nil {
+			workers = jobs.workers
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"queued":        totals[jobStatusQueued],
+			"due":           due,
+			"retrying":      retrying,
+			"running":       totals[jobStatusRunning],
+			"succeeded":     totals[jobStatusSucceeded],
+			"dead":          totals[jobStatusDead],
+			"oldest_due_at": oldestDueAt,
+			"by_kind":       byKind,
+			"workers":       workers,
+		})
+	}
+}
+
+// getJobsHandler handles GET /api/admin/jobs
+// Jobs, newest first (status, kind, paginated).
+func getJobsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		page, pageSize := parsePagination(c)
+
+		query := db.Model(&Job{})
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if kind := c.Query("kind"); kind != "" {
+			query = query.Where("kind = ?", kind)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count jobs"})
+			return
+		}
+
+		var list []Job
+		if err := query.
+			Order("id DESC").
+			Offset((page - 1) * pageSize).
+			Limit(pageSize).
+			Find(&list).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch jobs"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"jobs":      list,
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		})
+	}
+}
+
+// findJob loads the :id job, writing an error response if there isn't one
+func findJob(c *gin.Context, db *gorm.DB) (*Job, bool) {
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid job ID"})
+		return nil, false
+	}
+
+	var job Job
+	if err := db.First(&job, jobID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Job not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch job"})
+		}
+		return nil, false
+	}
+
+	return &job, true
+}
+
+// getJobHandler handles GET /api/admin/jobs/:id
+func getJobHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		job, ok := findJob(c, db)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// retryJobHandler handles POST /api/admin/jobs/:id/retry
+// Queues a dead job to run now, with a fresh set of attempts.
+func retryJobHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		job, ok := findJob(c, db)
+		if !ok {
+			return
+		}
+
+		result := db.Model(&Job{}).
+			Where("id = ? AND status = ?", job.ID, jobStatusDead).
+			Updates(map[string]interface{}{
+				"status":      jobStatusQueued,
+				"run_at":      time.Now(),
+				"attempts":    0,
+				"finished_at": nil,
+			})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to retry job"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"message": "Only dead jobs can be retried"})
+			return
+		}
+
+		recordAudit(db, c, auditEntry{
+			Action:     auditJobRetry,
+			TargetType: "job",
+			TargetID:   job.ID,
+			Before:     map[string]interface{}{"status": job.Status},
+			After:      map[string]interface{}{"status": jobStatusQueued},
+		})
+
+		var updated Job
+		db.First(&updated, job.ID)
+		c.JSON(http.StatusOK, updated)
+	}
+}
+
+// deleteJobHandler handles DELETE /api/admin/jobs/:id
+// Discards a job that isn't running, e.g. a dead job that will never succeed.
+func deleteJobHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		job, ok := findJob(c, db)
+		if !ok {
+			return
+		}
+
+		result := db.Where("status <> ?", jobStatusRunning).Delete(&Job{}, job.ID)
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete job"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{"message": "Running jobs can't be deleted"})
+			return
+		}
+
+		recordAudit(db, c, auditEntry{
+			Action:     auditJobDelete,
+			TargetType: "job",
+			TargetID:   job.ID,
+			Before:     map[string]interface{}{"kind": job.Kind, "status": job.Status, "last_error": job.LastError},
+		})
+
+		c.JSON(http.StatusOK, gin.H{"message": "Job deleted"})
+	}
+}
+
+// getJobSchedulesHandler handles GET /api/admin/jobs/schedules
+// Each scheduled job with when it last ran and is next due.
+func getJobSchedulesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		var schedules []JobSchedule
+		if err := db.Order("name").Find(&schedules).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch job schedules"})
+			return
+		}
+
+		c.JSON(http.StatusOK, schedules)
+	}
+}
+
+// errJobPayload wraps payloads a handler can't decode
+var errJobPayload = errors.New("invalid job payload")
+
+// decodeJobPayload unmarshals a job's payload into v
+func decodeJobPayload(payload []byte, v interface{}) error {
+	if err := json.Unmarshal(payload, v); err != nil {
+		return fmt.Errorf("%w: %v", errJobPayload, err)
+	}
+	return nil
+}
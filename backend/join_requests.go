@@ -1,6 +1,8 @@
 package main
 
 import (
+	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 
@@ -10,15 +12,37 @@ import (
 
 // Join Request handlers
 
+// getJoinRequestsHandler lists pending join requests across every community the
+// current user can review (all communities for super admins)
 func getJoinRequestsHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		authMiddleware(db)(c)
 		if c.IsAborted() {
 			return
 		}
 
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		query := db.Preload("User", publicUserColumns).Preload("Community").Where("status = ?", "pending")
+
+		if tenant := getTenant(c); tenant != nil {
+			query = query.Where("community_id = ?", tenant.ID)
+		}
+
+		userRole, _ := c.Get("userRole")
+		if userRole != RoleSuperAdmin {
+			reviewable := db.Model(&UserCommunity{}).
+				Select("community_id").
+				Where("user_id = ? AND is_active = ? AND role IN ?", userID, true, []UserRole{RoleAdmin, RoleModerator})
+			query = query.Where("community_id IN (?)", reviewable)
+		}
+
 		var joinRequests []JoinRequest
-		if err := db.Preload("User").Preload("Community").Where("status = ?", "pending").Find(&joinRequests).Error; err != nil {
+		if err := query.Find(&joinRequests).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch join requests"})
 			return
 		}
@@ -29,20 +53,15 @@ func getJoinRequestsHandler(db *gorm.DB) gin.HandlerFunc {
 
 func getCommunityJoinRequestsHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		authMiddleware(db)(c)
+		requireCommunityRole(db, RoleAdmin, RoleModerator)(c)
 		if c.IsAborted() {
 			return
 		}
 
-		idStr := c.Param("id")
-		communityID, err := strconv.ParseUint(idStr, 10, 32)
-		if err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid community ID"})
-			return
-		}
+		communityID := getCommunityAccess(c).CommunityID
 
 		var joinRequests []JoinRequest
-		if err := db.Preload("User").Preload("Community").Where("community_id = ? AND status = ?", communityID, "pending").Find(&joinRequests).Error; err != nil {
+		if err := db.Preload("User", publicUserColumns).Preload("Community").Where("community_id = ? AND status = ?", communityID, "pending").Find(&joinRequests).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch join requests"})
 			return
 		}
@@ -114,8 +133,23 @@ func createJoinRequestHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Let the community's moderators know there is a request to review
+		if moderatorIDs, err := communityModeratorIDs(db, joinRequest.CommunityID); err == nil {
+			publishEvent(eventJoinRequestCreated, moderatorIDs, map[string]interface{}{
+				"join_request_id": joinRequest.ID,
+				"community_id":    joinRequest.CommunityID,
+			})
+			for _, moderatorID := range moderatorIDs {
+				if err := notify(db, moderatorID, notificationJoinRequestReceived,
+					fmt.Sprintf("%s asked to join %s", currentUserName(c), community.Name),
+					joinRequest.Message, "/join-requests"); err != nil {
+					log.Printf("Failed to notify user %d of join request %d: %v", moderatorID, joinRequest.ID, err)
+				}
+			}
+		}
+
 		// Preload relationships
-		db.Preload("User").Preload("Community").First(&joinRequest, joinRequest.ID)
+		db.Preload("User", publicUserColumns).Preload("Community").First(&joinRequest, joinRequest.ID)
 
 		c.JSON(http.StatusCreated, joinRequest)
 	}
@@ -123,7 +157,7 @@ func createJoinRequestHandler(db *gorm.DB) gin.HandlerFunc {
 
 func approveJoinRequestHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		authMiddleware(db)(c)
 		if c.IsAborted() {
 			return
 		}
@@ -148,6 +182,11 @@ func approveJoinRequestHandler(db *gorm.DB) gin.HandlerFunc {
 			req.Role = RoleUser
 		}
 
+		if !isValidCommunityRole(req.Role) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid community role"})
+			return
+		}
+
 		var joinRequest JoinRequest
 		if err := db.First(&joinRequest, requestID).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
@@ -158,6 +197,17 @@ func approveJoinRequestHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		access, ok := authorizeCommunityRole(c, db, joinRequest.CommunityID, RoleAdmin, RoleModerator)
+		if !ok {
+			return
+		}
+
+		// Only community admins can let someone in with elevated privileges
+		if (req.Role == RoleAdmin || req.Role == RoleModerator) && !access.hasRole(RoleAdmin) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only community admins can grant admin or moderator roles"})
+			return
+		}
+
 		if joinRequest.Status != "pending" {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "This request has already been processed"})
 			return
@@ -186,8 +236,23 @@ func approveJoinRequestHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(db, c, auditEntry{
+			Action:      auditJoinRequestApprove,
+			TargetType:  "join_request",
+			TargetID:    joinRequest.ID,
+			CommunityID: &joinRequest.CommunityID,
+			Before:      map[string]interface{}{"status": "pending"},
+			After:       map[string]interface{}{"status": "approved", "user_id": joinRequest.UserID, "role": string(req.Role)},
+		})
+
+		publishEvent(eventJoinRequestApproved, []uint{joinRequest.UserID}, map[string]interface{}{
+			"join_request_id": joinRequest.ID,
+			"community_id":    joinRequest.CommunityID,
+		})
+		notifyJoinRequestDecision(db, &joinRequest, true)
+
 		// Reload with relationships
-		db.Preload("User").Preload("Community").First(&joinRequest, requestID)
+		db.Preload("User", publicUserColumns).Preload("Community").First(&joinRequest, requestID)
 
 		c.JSON(http.StatusOK, joinRequest)
 	}
@@ -195,7 +260,7 @@ func approveJoinRequestHandler(db *gorm.DB) gin.HandlerFunc {
 
 func rejectJoinRequestHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		authMiddleware(db)(c)
 		if c.IsAborted() {
 			return
 		}
@@ -217,6 +282,10 @@ func rejectJoinRequestHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if _, ok := authorizeCommunityRole(c, db, joinRequest.CommunityID, RoleAdmin, RoleModerator); !ok {
+			return
+		}
+
 		if joinRequest.Status != "pending" {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "This request has already been processed"})
 			return
@@ -228,9 +297,46 @@ func rejectJoinRequestHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		recordAudit(db, c, auditEntry{
+			Action:      auditJoinRequestReject,
+			TargetType:  "join_request",
+			TargetID:    joinRequest.ID,
+			CommunityID: &joinRequest.CommunityID,
+			Before:      map[string]interface{}{"status": "pending"},
+			After:       map[string]interface{}{"status": "rejected", "user_id": joinRequest.UserID},
+		})
+
+		publishEvent(eventJoinRequestRejected, []uint{joinRequest.UserID}, map[string]interface{}{
+			"join_request_id": joinRequest.ID,
+			"community_id":    joinRequest.CommunityID,
+		})
+		notifyJoinRequestDecision(db, &joinRequest, false)
+
 		// Reload with relationships
-		db.Preload("User").Preload("Community").First(&joinRequest, requestID)
+		db.Preload("User", publicUserColumns).Preload("Community").First(&joinRequest, requestID)
 
 		c.JSON(http.StatusOK, joinRequest)
 	}
 }
+
+// notifyJoinRequestDecision tells the applicant whether they were let in
+func notifyJoinRequestDecision(db *gorm.DB, joinRequest *JoinRequest, approved bool) {
+	var community Community
+	if err := db.Select("id", "name").First(&community, joinRequest.CommunityID).Error; err != nil {
+		log.Printf("Failed to notify user %d of join request %d: %v", joinRequest.UserID, joinRequest.ID, err)
+		return
+	}
+
+	notificationType := notificationJoinRequestRejected
+	title := fmt.Sprintf("Your request to join %s was declined", community.Name)
+	link := "/communities"
+	if approved {
+		notificationType = notificationJoinRequestApproved
+		title = fmt.Sprintf("You're now a member of %s", community.Name)
+		link = fmt.Sprintf("/communities/%d", community.ID)
+	}
+
+	if err := notify(db, joinRequest.UserID, notificationType, title, "", link); err != nil {
+		log.Printf("Failed to notify user %d of join request %d: %v", joinRequest.UserID, joinRequest.ID, err)
+	}
+}
@@ -0,0 +1,530 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Payments ledger
+//
+// Money is tracked with double-entry bookkeeping in integer minor units
+// (cents) of the community's currency. Accepting an offer charges the
+// requester and holds the agreed price in the request's escrow account.
+// Completing the request releases the escrow to the provider, less the
+// community's platform fee. Cancelling it refunds the requester. Every
+// transaction's entries sum to zero, so a balance is the sum of an account's
+// entries.
+
+// Ledger account kinds
+const (
+	ledgerAccountUser         = "user"
+	ledgerAccountEscrow       = "escrow"
+	ledgerAccountPlatformFees = "platform_fees"
+	ledgerAccountExternal     = "external"
+)
+
+// Ledger transaction kinds
+const (
+	ledgerEscrowHold    = "escrow_hold"
+	ledgerEscrowRelease = "escrow_release"
+	ledgerEscrowRefund  = "escrow_refund"
+)
+
+// errorCodePaymentFailed is returned when the payment provider refuses a charge or refund
+const errorCodePaymentFailed = "payment_failed"
+
+var errUnbalancedTransaction = errors.New("ledger transaction entries don't sum to zero")
+
+// zeroDecimalCurrencies have no minor unit
+var zeroDecimalCurrencies = map[string]bool{
+	"CLP": true, "ISK": true, "JPY": true, "KRW": true, "UGX": true, "VND": true, "XAF": true, "XOF": true,
+}
+
+// toMinorUnits converts a price to integer minor units of a currency
+func toMinorUnits(amount float64, currency string) int64 {
+	if zeroDecimalCurrencies[currency] {
+		return int64(math.Round(amount))
+	}
+	return int64(math.Round(amount * 100))
+}
+
+// isCurrencyCode reports whether code looks like an ISO 4217 code
+func isCurrencyCode(code string) bool {
+	if len(code) != 3 {
+		return false
+	}
+	for _, r := range code {
+		if r < 'A' || r > 'Z' {
+			return false
+		}
+	}
+	return true
+}
+
+// ledgerPosting is one entry of a transaction before it is stored
+type ledgerPosting struct {
+	AccountID uint
+	Amount    int64
+	Memo      string
+}
+
+// getLedgerAccount returns an account, creating it on first use
+func getLedgerAccount(tx *gorm.DB, kind string, ownerID uint, currency string) (*LedgerAccount, error) {
+	account := LedgerAccount{Kind: kind, OwnerID: ownerID, Currency: currency}
+	if err := tx.Where("kind = ? AND owner_id = ? AND currency = ?", kind, ownerID, currency).
+		FirstOrCreate(&account).Error; err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// ledgerAccountBalance sums an account's entries
+func ledgerAccountBalance(tx *gorm.DB, accountID uint) (int64, error) {
+	var balance int64
+	err := tx.Model(&LedgerEntry{}).
+		Where("account_id = ?", accountID).
+		Select("COALESCE(SUM(amount), 0)").
+		Scan(&balance).Error
+	return balance, err
+}
+
+// postLedgerTransaction stores a transaction and its entries. Zero postings
+// are dropped, and the rest must sum to zero.
+func postLedgerTransaction(tx *gorm.DB, txn *LedgerTransaction, currency string, postings ...ledgerPosting) error {
+	var sum int64
+	for _, posting := range postings {
+		sum += posting.Amount
+	}
+	if sum != 0 {
+		return errUnbalancedTransaction
+	}
+
+	if err := tx.Create(txn).Error; err != nil {
+		return err
+	}
+
+	for _, posting := range postings {
+		if posting.Amount == 0 {
+			continue
+		}
+		entry := LedgerEntry{
+			TransactionID: txn.ID,
+			AccountID:     posting.AccountID,
+			Amount:        posting.Amount,
+			Currency:      currency,
+			Memo:          posting.Memo,
+		}
+		if err := tx.Create(&entry).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// findEscrow returns a request's escrow account and what it holds, or nil
+// when nothing was ever held for the request
+func findEscrow(tx *gorm.DB, requestID uint) (*LedgerAccount, int64, error) {
+	var escrow LedgerAccount
+	if err := tx.Where("kind = ? AND owner_id = ?", ledgerAccountEscrow, requestID).First(&escrow).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, 0, nil
+		}
+		return nil, 0, err
+	}
+
+	balance, err := ledgerAccountBalance(tx, escrow.ID)
+	if err != nil {
+		return nil, 0, err
+	}
+	return &escrow, balance, nil
+}
+
+// requestCurrency returns the currency a request is billed in: its escrow's
+// when something was held, which may predate a change to the community's
+// currency, and the community's otherwise
+func requestCurrency(tx *gorm.DB, request *ServiceRequest) (string, error) {
+	escrow, _, err := findEscrow(tx, request.ID)
+	if err != nil {
+		return "", err
+	}
+	if escrow != nil {
+		return escrow.Currency, nil
+	}
+
+	var community Community
+	if err := tx.Select("id", "currency").First(&community, request.CommunityID).Error; err != nil {
+		return "", err
+	}
+	return community.Currency, nil
+}
+
+// holdEscrow charges the requester the agreed price and holds it in the
+// request's escrow. Free work isn't charged.
+func holdEscrow(tx *gorm.DB, request *ServiceRequest, price float64, actorID *uint) error {
+	var community Community
+	if err := tx.Select("id", "currency").First(&community, request.CommunityID).Error; err != nil {
+		return err
+	}
+
+	currency := community.Currency
+	amount := toMinorUnits(price, currency)
+	if amount <= 0 {
+		return nil
+	}
+
+	external, err := getLedgerAccount(tx, ledgerAccountExternal, 0, currency)
+	if err != nil {
+		return err
+	}
+	requester, err := getLedgerAccount(tx, ledgerAccountUser, request.RequesterID, currency)
+	if err != nil {
+		return err
+	}
+	escrow, err := getLedgerAccount(tx, ledgerAccountEscrow, request.ID, currency)
+	if err != nil {
+		return err
+	}
+
+	chargeID, err := paymentProvider.Charge(ChargeRequest{
+		UserID:    request.RequesterID,
+		Amount:    amount,
+		Currency:  currency,
+		Reference: fmt.Sprintf("service_request:%d", request.ID),
+	})
+	if err != nil {
+		return &paymentError{Err: err}
+	}
+
+	return postLedgerTransaction(tx, &LedgerTransaction{
+		Kind:             ledgerEscrowHold,
+		ServiceRequestID: &request.ID,
+		CommunityID:      &request.CommunityID,
+		ActorID:          actorID,
+		PaymentReference: chargeID,
+		Description:      fmt.Sprintf("Payment for service request #%d held in escrow", request.ID),
+	}, currency,
+		ledgerPosting{AccountID: external.ID, Amount: -amount, Memo: "Charged"},
+		ledgerPosting{AccountID: requester.ID, Amount: amount, Memo: "Payment received"},
+		ledgerPosting{AccountID: requester.ID, Amount: -amount, Memo: "Held in escrow"},
+		ledgerPosting{AccountID: escrow.ID, Amount: amount, Memo: "Held in escrow"},
+	)
+}
+
+// releaseEscrow pays what a request's escrow holds to the accepted provider,
+// keeping the community's platform fee
+func releaseEscrow(tx *gorm.DB, request *ServiceRequest, actorID *uint) error {
+	escrow, balance, err := findEscrow(tx, request.ID)
+	if err != nil || escrow == nil || balance <= 0 {
+		return err
+	}
+
+	providerID, err := acceptedProviderID(tx, request)
+	if err != nil {
+		return err
+	}
+	if providerID == 0 {
+		return fmt.Errorf("service request %d holds escrow but has no accepted provider", request.ID)
+	}
+
+	var community Community
+	if err := tx.Select("id", "platform_fee_bps").First(&community, request.CommunityID).Error; err != nil {
+		return err
+	}
+	fee := balance * int64(community.PlatformFeeBps) / 10000
+
+	provider, err := getLedgerAccount(tx, ledgerAccountUser, providerID, escrow.Currency)
+	if err != nil {
+		return err
+	}
+	fees, err := getLedgerAccount(tx, ledgerAccountPlatformFees, request.CommunityID, escrow.Currency)
+	if err != nil {
+		return err
+	}
+
+	return postLedgerTransaction(tx, &LedgerTransaction{
+		Kind:             ledgerEscrowRelease,
+		ServiceRequestID: &request.ID,
+		CommunityID:      &request.CommunityID,
+		ActorID:          actorID,
+		Description:      fmt.Sprintf("Escrow for service request #%d released to the provider", request.ID),
+	}, escrow.Currency,
+		ledgerPosting{AccountID: escrow.ID, Amount: -balance, Memo: "Released"},
+		ledgerPosting{AccountID: provider.ID, Amount: balance - fee, Memo: "Payout"},
+		ledgerPosting{AccountID: fees.ID, Amount: fee, Memo: "Platform fee"},
+	)
+}
+
+// refundEscrow returns what a request's escrow holds to the requester through
+// the payment provider
+func refundEscrow(tx *gorm.DB, request *ServiceRequest, actorID *uint) error {
+	escrow, balance, err := findEscrow(tx, request.ID)
+	if err != nil || escrow == nil || balance <= 0 {
+		return err
+	}
+
+	var hold LedgerTransaction
+	if err := tx.Where("service_request_id = ? AND kind = ?", request.ID, ledgerEscrowHold).
+		Order("id DESC").First(&hold).Error; err != nil {
+		return err
+	}
+
+	requester, err := getLedgerAccount(tx, ledgerAccountUser, request.RequesterID, escrow.Currency)
+	if err != nil {
+		return err
+	}
+	external, err := getLedgerAccount(tx, ledgerAccountExternal, 0, escrow.Currency)
+	if err != nil {
+		return err
+	}
+
+	refundID, err := paymentProvider.Refund(hold.PaymentReference, balance)
+	if err != nil {
+		return &paymentError{Err: err}
+	}
+
+	return postLedgerTransaction(tx, &LedgerTransaction{
+		Kind:             ledgerEscrowRefund,
+		ServiceRequestID: &request.ID,
+		CommunityID:      &request.CommunityID,
+		ActorID:          actorID,
+		PaymentReference: refundID,
+		Description:      fmt.Sprintf("Escrow for service request #%d refunded", request.ID),
+	}, escrow.Currency,
+		ledgerPosting{AccountID: escrow.ID, Amount: -balance, Memo: "Returned from escrow"},
+		ledgerPosting{AccountID: requester.ID, Amount: balance, Memo: "Returned from escrow"},
+		ledgerPosting{AccountID: requester.ID, Amount: -balance, Memo: "Refunded"},
+		ledgerPosting{AccountID: external.ID, Amount: balance, Memo: "Refunded"},
+	)
+}
+
+// ledgerBalance is a user's position in one currency
+type ledgerBalance struct {
+	Currency  string `json:"currency"`
+	Available int64  `json:"available"` // earned and not paid out
+	Held      int64  `json:"held"`      // paid into escrow on the user's requests
+	Incoming  int64  `json:"incoming"`  // in escrow for requests the user is providing
+}
+
+// ledgerAmount is a sum in one currency
+type ledgerAmount struct {
+	Currency string `json:"currency"`
+	Amount   int64  `json:"amount"`
+}
+
+// statementLine is one entry on a user's statement
+type statementLine struct {
+	ID               uint   `json:"id"`
+	At               string `json:"at"`
+	Kind             string `json:"kind"`
+	Description      string `json:"description"`
+	Memo             string `json:"memo"`
+	ServiceRequestID *uint  `json:"service_request_id"`
+	PaymentReference string `json:"payment_reference,omitempty"`
+	Amount           int64  `json:"amount"`
+	Currency         string `json:"currency"`
+	Balance          *int64 `json:"balance,omitempty"` // running balance after the entry
+}
+
+// sumEscrow adds up escrow balances per currency for the requests matched by the extra join and condition
+func sumEscrow(db *gorm.DB, join, condition string, args ...interface{}) ([]ledgerAmount, error) {
+	var amounts []ledgerAmount
+	query := db.Table("ledger_entries").
+		Select("ledger_accounts.currency AS currency, COALESCE(SUM(ledger_entries.amount), 0) AS amount").
+		Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+		Joins("JOIN service_requests ON service_requests.id = ledger_accounts.owner_id").
+		Where("ledger_accounts.kind = ?", ledgerAccountEscrow)
+	if join != "" {
+		query = query.Joins(join)
+	}
+	err := query.Where(condition, args...).
+		Group("ledger_accounts.currency").
+		Scan(&amounts).Error
+	return amounts, err
+}
+
+// getUserBalancesHandler handles GET /api/users/:id/balances (the user or an admin)
+func getUserBalancesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, ok := authorizeSelfOrAdmin(c)
+		if !ok {
+			return
+		}
+
+		var available []ledgerAmount
+		if err := db.Table("ledger_accounts").
+			Select("ledger_accounts.currency AS currency, COALESCE(SUM(ledger_entries.amount), 0) AS amount").
+			Joins("LEFT JOIN ledger_entries ON ledger_entries.account_id = ledger_accounts.id").
+			Where("ledger_accounts.kind = ? AND ledger_accounts.owner_id = ?", ledgerAccountUser, userID).
+			Group("ledger_accounts.currency").
+			Scan(&available).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch balances"})
+			return
+		}
+
+		held, err := sumEscrow(db, "", "service_requests.requester_id = ?", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch balances"})
+			return
+		}
+
+		incoming, err := sumEscrow(db, "JOIN service_offers ON service_offers.id = service_requests.accepted_offer_id",
+			"service_offers.provider_id = ?", userID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch balances"})
+			return
+		}
+
+		// One row per currency the user has touched
+		byCurrency := make(map[string]*ledgerBalance)
+		balances := []*ledgerBalance{}
+		row := func(currency string) *ledgerBalance {
+			if b, ok := byCurrency[currency]; ok {
+				return b
+			}
+			b := &ledgerBalance{Currency: currency}
+			byCurrency[currency] = b
+			balances = append(balances, b)
+			return b
+		}
+		for _, a := range available {
+			row(a.Currency).Available = a.Amount
+		}
+		for _, a := range held {
+			row(a.Currency).Held = a.Amount
+		}
+		for _, a := range incoming {
+			row(a.Currency).Incoming = a.Amount
+		}
+
+		c.JSON(http.StatusOK, balances)
+	}
+}
+
+// getUserStatementHandler handles GET /api/users/:id/statement (the user or an admin)
+// Entries on the user's account, newest first. With a currency, each line also
+// carries the running balance.
+func getUserStatementHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, ok := authorizeSelfOrAdmin(c)
+		if !ok {
+			return
+		}
+
+		currency := strings.ToUpper(c.Query("currency"))
+		page, pageSize := parsePagination(c)
+
+		query := db.Table("ledger_entries").
+			Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+			Joins("JOIN ledger_transactions ON ledger_transactions.id = ledger_entries.transaction_id").
+			Where("ledger_accounts.kind = ? AND ledger_accounts.owner_id = ?", ledgerAccountUser, userID)
+		if currency != "" {
+			query = query.Where("ledger_entries.currency = ?", currency)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count statement entries"})
+			return
+		}
+
+		lines := []statementLine{}
+		if err := query.Select(`ledger_entries.id, ledger_entries.created_at AS at, ledger_transactions.kind,
+				ledger_transactions.description, ledger_entries.memo, ledger_transactions.service_request_id,
+				ledger_transactions.payment_reference, ledger_entries.amount, ledger_entries.currency`).
+			Order("ledger_entries.id DESC").
+			Offset((page - 1) * pageSize).
+			Limit(pageSize).
+			Scan(&lines).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch statement"})
+			return
+		}
+
+		// Running balances count back from the balance after the newest line on the page
+		if currency != "" && len(lines) > 0 {
+			var balance int64
+			if err := db.Table("ledger_entries").
+				Joins("JOIN ledger_accounts ON ledger_accounts.id = ledger_entries.account_id").
+				Where("ledger_accounts.kind = ? AND ledger_accounts.owner_id = ?", ledgerAccountUser, userID).
+				Where("ledger_entries.currency = ? AND ledger_entries.id <= ?", currency, lines[0].ID).
+				Select("COALESCE(SUM(ledger_entries.amount), 0)").
+				Scan(&balance).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch statement"})
+				return
+			}
+			for i := range lines {
+				after := balance
+				lines[i].Balance = &after
+				balance -= lines[i].Amount
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"entries":   lines,
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		})
+	}
+}
+
+// getCommunityLedgerHandler handles GET /api/communities/:id/ledger (community admins)
+// The platform fees the community has earned and what its requests hold in escrow.
+func getCommunityLedgerHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		communityID := getCommunityAccess(c).CommunityID
+
+		var community Community
+		if err := db.First(&community, communityID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch community"})
+			return
+		}
+
+		fees := []ledgerAmount{}
+		if err := db.Table("ledger_accounts").
+			Select("ledger_accounts.currency AS currency, COALESCE(SUM(ledger_entries.amount), 0) AS amount").
+			Joins("LEFT JOIN ledger_entries ON ledger_entries.account_id = ledger_accounts.id").
+			Where("ledger_accounts.kind = ? AND ledger_accounts.owner_id = ?", ledgerAccountPlatformFees, communityID).
+			Group("ledger_accounts.currency").
+			Scan(&fees).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch platform fees"})
+			return
+		}
+
+		escrow, err := sumEscrow(db, "", "service_requests.community_id = ?", communityID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch escrow"})
+			return
+		}
+		if escrow == nil {
+			escrow = []ledgerAmount{}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"currency":         community.Currency,
+			"platform_fee_bps": community.PlatformFeeBps,
+			"fees":             fees,
+			"escrow":           escrow,
+		})
+	}
+}
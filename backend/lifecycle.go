@@ -0,0 +1,368 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Service request and offer lifecycles
+//
+// Every status change goes through a lifecycle so the rules live in one place,
+// CompletedAt is set consistently and each change is recorded as a
+// StatusTransition.
+
+// Service request statuses
+const (
+	requestStatusOpen                 = "open"
+	requestStatusInProgress           = "in_progress"
+	requestStatusAwaitingConfirmation = "awaiting_confirmation"
+	requestStatusDisputed             = "disputed"
+	requestStatusCompleted            = "completed"
+	requestStatusCancelled            = "cancelled"
+)
+
+// Service offer statuses
+const (
+	offerStatusPending   = "pending"
+	offerStatusAccepted  = "accepted"
+	offerStatusRejected  = "rejected"
+	offerStatusWithdrawn = "withdrawn"
+	offerStatusExpired   = "expired"
+)
+
+// Milestone statuses
+const (
+	milestoneStatusPending   = "pending"
+	milestoneStatusSubmitted = "submitted"
+	milestoneStatusApproved  = "approved"
+	milestoneStatusRejected  = "rejected"
+)
+
+// Appointment statuses
+const (
+	appointmentStatusScheduled = "scheduled"
+	appointmentStatusCancelled = "cancelled"
+)
+
+// Recurring request statuses
+const (
+	recurringStatusActive = "active"
+	recurringStatusPaused = "paused"
+	recurringStatusEnded  = "ended"
+)
+
+// Entity types recorded in status_transitions
+const (
+	entityServiceRequest   = "service_request"
+	entityServiceOffer     = "service_offer"
+	entityMilestone        = "milestone"
+	entityAppointment      = "appointment"
+	entityRecurringRequest = "recurring_request"
+)
+
+// errorCodeIllegalTransition is returned in the "code" field when a status change isn't allowed
+const errorCodeIllegalTransition = "illegal_transition"
+
+// lifecycle is the set of statuses an entity can move between
+type lifecycle struct {
+	entity      string
+	transitions map[string][]string // status -> statuses it may move to
+}
+
+var serviceRequestLifecycle = lifecycle{
+	entity: entityServiceRequest,
+	transitions: map[string][]string{
+		requestStatusOpen:                 {requestStatusInProgress, requestStatusCancelled},
+		requestStatusInProgress:           {requestStatusAwaitingConfirmation, requestStatusCancelled},
+		requestStatusAwaitingConfirmation: {requestStatusCompleted, requestStatusDisputed},
+		requestStatusDisputed:             {requestStatusCompleted, requestStatusInProgress, requestStatusCancelled},
+		requestStatusCompleted:            {},
+		requestStatusCancelled:            {},
+	},
+}
+
+var serviceOfferLifecycle = lifecycle{
+	entity: entityServiceOffer,
+	transitions: map[string][]string{
+		offerStatusPending:   {offerStatusAccepted, offerStatusRejected, offerStatusWithdrawn, offerStatusExpired},
+		offerStatusAccepted:  {},
+		offerStatusRejected:  {},
+		offerStatusWithdrawn: {},
+		offerStatusExpired:   {},
+	},
+}
+
+var milestoneLifecycle = lifecycle{
+	entity: entityMilestone,
+	transitions: map[string][]string{
+		milestoneStatusPending:   {milestoneStatusSubmitted},
+		milestoneStatusSubmitted: {milestoneStatusApproved, milestoneStatusRejected},
+		milestoneStatusRejected:  {milestoneStatusSubmitted},
+		milestoneStatusApproved:  {},
+	},
+}
+
+var appointmentLifecycle = lifecycle{
+	entity: entityAppointment,
+	transitions: map[string][]string{
+		appointmentStatusScheduled: {appointmentStatusCancelled},
+		appointmentStatusCancelled: {},
+	},
+}
+
+var recurringRequestLifecycle = lifecycle{
+	entity: entityRecurringRequest,
+	transitions: map[string][]string{
+		recurringStatusActive: {recurringStatusPaused, recurringStatusEnded},
+		recurringStatusPaused: {recurringStatusActive, recurringStatusEnded},
+		recurringStatusEnded:  {},
+	},
+}
+
+// transitionError is returned for a status change the lifecycle doesn't allow
+type transitionError struct {
+	Entity  string
+	From    string
+	To      string
+	Allowed []string
+}
+
+func (e *transitionError) Error() string {
+	return fmt.Sprintf("%s cannot move from %s to %s", e.Entity, e.From, e.To)
+}
+
+// isStatus reports whether status belongs to the lifecycle
+func (l lifecycle) isStatus(status string) bool {
+	_, ok := l.transitions[status]
+	return ok
+}
+
+// check returns a *transitionError unless from may move to to
+func (l lifecycle) check(from, to string) error {
+	for _, allowed := range l.transitions[from] {
+		if allowed == to {
+			return nil
+		}
+	}
+	return &transitionError{Entity: l.entity, From: from, To: to, Allowed: l.transitions[from]}
+}
+
+// apply moves the row with the given id from one status to another and records
+// the transition. The update only matches while the row is still in from, so
+// two concurrent changes can't both succeed.
+func (l lifecycle) apply(tx *gorm.DB, model interface{}, id uint, from, to string, actorID *uint, reason string, updates map[string]interface{}) error {
+	if err := l.check(from, to); err != nil {
+		return err
+	}
+
+	if updates == nil {
+		updates = make(map[string]interface{})
+	}
+	updates["status"] = to
+
+	result := tx.Model(model).Where("id = ? AND status = ?", id, from).Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		// Someone else changed the status first
+		var current string
+		tx.Model(model).Where("id = ?", id).Pluck("status", &current)
+		return &transitionError{Entity: l.entity, From: current, To: to, Allowed: l.transitions[current]}
+	}
+
+	return tx.Create(&StatusTransition{
+		EntityType: l.entity,
+		EntityID:   id,
+		FromStatus: from,
+		ToStatus:   to,
+		ActorID:    actorID,
+		Reason:     reason,
+	}).Error
+}
+
+// transitionServiceRequest changes a request's status. Marking the work done
+// starts the confirmation window, going back to work clears it, completing
+// sets CompletedAt (and is sent to the community's webhooks) and cancelling
+// rejects the offers still pending. The requester and the accepted provider
+// are told of the change, and notified unless they made it.
+func transitionServiceRequest(tx *gorm.DB, request *ServiceRequest, to string, actorID *uint, reason string) error {
+	now := time.Now()
+	dueAt := now.Add(confirmationWindow)
+	updates := make(map[string]interface{})
+	switch to {
+	case requestStatusAwaitingConfirmation:
+		updates["work_done_at"] = now
+		updates["confirmation_due_at"] = dueAt
+	case requestStatusInProgress:
+		updates["work_done_at"] = nil
+		updates["confirmation_due_at"] = nil
+	case requestStatusCompleted:
+		updates["completed_at"] = now
+	}
+
+	from := request.Status
+	if err := serviceRequestLifecycle.apply(tx, &ServiceRequest{}, request.ID, from, to, actorID, reason, updates); err != nil {
+		return err
+	}
+
+	providerID, err := acceptedProviderID(tx, request)
+	if err != nil {
+		return err
+	}
+	queueEvent(tx, eventServiceRequestStatus, []uint{request.RequesterID, providerID}, map[string]interface{}{
+		"service_request_id": request.ID,
+		"from":               from,
+		"to":                 to,
+	})
+	if err := notifyServiceRequestStatus(tx, request, to, providerID, actorID); err != nil {
+		return err
+	}
+
+	request.Status = to
+	switch to {
+	case requestStatusAwaitingConfirmation:
+		request.WorkDoneAt = &now
+		request.ConfirmationDueAt = &dueAt
+	case requestStatusInProgress:
+		request.WorkDoneAt = nil
+		request.ConfirmationDueAt = nil
+	case requestStatusCompleted:
+		request.CompletedAt = &now
+	}
+
+	switch to {
+	case requestStatusCompleted:
+		if err := queueServiceRequestWebhook(tx, request, webhookServiceRequestCompleted); err != nil {
+			return err
+		}
+		if err := releaseEscrow(tx, request, actorID); err != nil {
+			return err
+		}
+		_, err := issueInvoice(tx, request)
+		return err
+	case requestStatusCancelled:
+		if err := rejectPendingOffers(tx, request.ID, 0, actorID, "request cancelled"); err != nil {
+			return err
+		}
+		if err := cancelRequestAppointments(tx, request.ID, actorID, "request cancelled"); err != nil {
+			return err
+		}
+		return refundEscrow(tx, request, actorID)
+	}
+	return nil
+}
+
+// transitionServiceOffer changes an offer's status, letting the provider and
+// the requester know. Providers are notified when their offer is accepted,
+// rejected or expires.
+func transitionServiceOffer(tx *gorm.DB, offer *ServiceOffer, to string, actorID *uint, reason string) error {
+	from := offer.Status
+	if err := serviceOfferLifecycle.apply(tx, &ServiceOffer{}, offer.ID, from, to, actorID, reason, nil); err != nil {
+		return err
+	}
+	offer.Status = to
+
+	var request ServiceRequest
+	if err := tx.Unscoped().Select("id", "requester_id", "title").First(&request, offer.ServiceRequestID).Error; err != nil {
+		return err
+	}
+	queueEvent(tx, eventOfferStatus, []uint{offer.ProviderID, request.RequesterID}, map[string]interface{}{
+		"offer_id":           offer.ID,
+		"service_request_id": offer.ServiceRequestID,
+		"from":               from,
+		"to":                 to,
+	})
+	return notifyOfferStatus(tx, offer, &request, actorID)
+}
+
+// transitionMilestone changes a milestone's status. Submitting sets
+// SubmittedAt and approving sets ApprovedAt.
+func transitionMilestone(tx *gorm.DB, milestone *Milestone, to string, actorID *uint, reason string) error {
+	now := time.Now()
+	updates := make(map[string]interface{})
+	switch to {
+	case milestoneStatusSubmitted:
+		updates["submitted_at"] = now
+	case milestoneStatusApproved:
+		updates["approved_at"] = now
+	}
+
+	if err := milestoneLifecycle.apply(tx, &Milestone{}, milestone.ID, milestone.Status, to, actorID, reason, updates); err != nil {
+		return err
+	}
+
+	milestone.Status = to
+	switch to {
+	case milestoneStatusSubmitted:
+		milestone.SubmittedAt = &now
+	case milestoneStatusApproved:
+		milestone.ApprovedAt = &now
+	}
+	return nil
+}
+
+// transitionAppointment changes an appointment's status. Cancelling sets
+// CancelledAt.
+func transitionAppointment(tx *gorm.DB, appointment *Appointment, to string, actorID *uint, reason string) error {
+	now := time.Now()
+	updates := make(map[string]interface{})
+	if to == appointmentStatusCancelled {
+		updates["cancelled_at"] = now
+	}
+
+	if err := appointmentLifecycle.apply(tx, &Appointment{}, appointment.ID, appointment.Status, to, actorID, reason, updates); err != nil {
+		return err
+	}
+
+	appointment.Status = to
+	if to == appointmentStatusCancelled {
+		appointment.CancelledAt = &now
+	}
+	return nil
+}
+
+// rejectPendingOffers rejects a request's pending offers, except exceptID
+func rejectPendingOffers(tx *gorm.DB, requestID, exceptID uint, actorID *uint, reason string) error {
+	var offers []ServiceOffer
+	if err := tx.Where("service_request_id = ? AND id != ? AND status = ?", requestID, exceptID, offerStatusPending).
+		Find(&offers).Error; err != nil {
+		return err
+	}
+
+	for i := range offers {
+		if err := transitionServiceOffer(tx, &offers[i], offerStatusRejected, actorID, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// respondTransitionError writes the response for an error from a transition.
+// Illegal transitions get a 409 with the illegal_transition code.
+func respondTransitionError(c *gin.Context, err error, fallback string) {
+	if te, ok := err.(*transitionError); ok {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": fmt.Sprintf("Invalid status transition from %s to %s", te.From, te.To),
+			"code":    errorCodeIllegalTransition,
+			"from":    te.From,
+			"to":      te.To,
+			"allowed": te.Allowed,
+		})
+		return
+	}
+	var pe *paymentError
+	if errors.As(err, &pe) {
+		c.JSON(http.StatusPaymentRequired, gin.H{
+			"message": pe.Error(),
+			"code":    errorCodePaymentFailed,
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"message": fallback})
+}
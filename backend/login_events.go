@@ -0,0 +1,191 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Login events and throttling glue for the auth handlers
+
+// LoginEvent reasons
+const (
+	loginReasonSuccess            = "success"
+	loginReasonInvalidCredentials = "invalid_credentials"
+	loginReasonInactive           = "inactive"
+	loginReasonThrottled          = "throttled"
+	loginReasonMFAPending         = "mfa_pending"
+	loginReasonMFAFailed          = "mfa_failed"
+)
+
+// recordLoginEvent stores the outcome of a login attempt
+func recordLoginEvent(db *gorm.DB, c *gin.Context, userID *uint, email string, success bool, reason string) {
+	event := LoginEvent{
+		UserID:    userID,
+		Email:     strings.ToLower(strings.TrimSpace(email)),
+		IPAddress: c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Success:   success,
+		Reason:    reason,
+	}
+	if err := db.Create(&event).Error; err != nil {
+		log.Printf("Failed to record login event: %v", err)
+	}
+}
+
+// checkLoginThrottle writes a 429 and returns false when the account or the
+// client IP is blocked. Limiter errors let the attempt through.
+func checkLoginThrottle(db *gorm.DB, c *gin.Context, email string) bool {
+	var retryAfter time.Duration
+	for _, key := range []string{accountLimiterKey(email), ipLimiterKey(c.ClientIP())} {
+		remaining, err := loginLimiter.Check(key)
+		if err != nil {
+			log.Printf("Login limiter check failed: %v", err)
+			continue
+		}
+		if remaining > retryAfter {
+			retryAfter = remaining
+		}
+	}
+
+	if retryAfter == 0 {
+		return true
+	}
+
+	recordLoginEvent(db, c, nil, email, false, loginReasonThrottled)
+	respondThrottled(c, retryAfter)
+	return false
+}
+
+// recordLoginFailure counts a failed attempt against the account and the client IP
+func recordLoginFailure(c *gin.Context, email string) {
+	for _, key := range []string{accountLimiterKey(email), ipLimiterKey(c.ClientIP())} {
+		if _, err := loginLimiter.Fail(key); err != nil {
+			log.Printf("Login limiter update failed: %v", err)
+		}
+	}
+}
+
+// recordLoginSuccess clears the account's failures; the IP keeps its count
+// so one valid account can't be used to reset a password spray
+func recordLoginSuccess(c *gin.Context, email string) {
+	if err := loginLimiter.Reset(accountLimiterKey(email)); err != nil {
+		log.Printf("Login limiter reset failed: %v", err)
+	}
+}
+
+func respondThrottled(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", fmt.Sprint(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"message":     "Too many failed login attempts, try again later",
+		"retry_after": seconds,
+	})
+}
+
+// Admin handlers
+
+func getLockoutsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		lockouts, err := loginLimiter.Lockouts()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch lockouts"})
+			return
+		}
+
+		c.JSON(http.StatusOK, lockouts)
+	}
+}
+
+// clearLockoutHandler handles DELETE /api/admin/lockouts/:type/:value
+func clearLockoutHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		var key string
+		switch c.Param("type") {
+		case limiterKeyAccount:
+			key = accountLimiterKey(c.Param("value"))
+		case limiterKeyIP:
+			key = ipLimiterKey(c.Param("value"))
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Lockout type must be account or ip"})
+			return
+		}
+
+		if err := loginLimiter.Reset(key); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to clear lockout"})
+			return
+		}
+
+		recordAudit(db, c, auditEntry{
+			Action:     auditLockoutClear,
+			TargetType: "lockout",
+			Before:     map[string]interface{}{"key": key},
+		})
+
+		c.JSON(http.StatusOK, gin.H{"message": "Lockout cleared"})
+	}
+}
+
+// getLoginEventsHandler handles GET /api/admin/login-events?email=&ip=&user_id=&success=
+func getLoginEventsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		query := db.Model(&LoginEvent{})
+		if email := c.Query("email"); email != "" {
+			query = query.Where("email = ?", strings.ToLower(strings.TrimSpace(email)))
+		}
+		if ip := c.Query("ip"); ip != "" {
+			query = query.Where("ip_address = ?", ip)
+		}
+		if userID := c.Query("user_id"); userID != "" {
+			query = query.Where("user_id = ?", userID)
+		}
+		if success := c.Query("success"); success != "" {
+			query = query.Where("success = ?", success == "true")
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count login events"})
+			return
+		}
+
+		page, pageSize := parsePagination(c)
+
+		var events []LoginEvent
+		if err := query.Order("created_at DESC").
+			Offset((page - 1) * pageSize).
+			Limit(pageSize).
+			Find(&events).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch login events"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"events":    events,
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		})
+	}
+}
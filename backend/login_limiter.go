@@ -0,0 +1,316 @@
+package main
+
+import (
+	"context"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Login throttling
+//
+// Failed logins are counted per account and per IP address. After a few free
+// attempts each failure blocks the key for an exponentially growing delay, and
+// enough failures lock it out for a fixed period. Counters are forgotten after
+// a quiet window. LOGIN_LIMITER selects the backend: "memory" (default) keeps
+// state in-process, "redis" shares it between instances through REDIS_HOST.
+
+// limiterPolicy describes how quickly one kind of key is throttled
+type limiterPolicy struct {
+	FreeAttempts    int           // failures allowed before any delay
+	BaseDelay       time.Duration // delay after the first throttled failure, doubled each time
+	MaxDelay        time.Duration
+	LockoutAfter    int // failures that trigger a lockout
+	LockoutDuration time.Duration
+	Window          time.Duration // failures are forgotten after this long without one
+}
+
+var (
+	// Accounts are throttled quickly; one person rarely mistypes a password ten times
+	accountLimiterPolicy = limiterPolicy{
+		FreeAttempts:    3,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    10,
+		LockoutDuration: 15 * time.Minute,
+		Window:          time.Hour,
+	}
+
+	// IP addresses can be shared (offices, NAT), so they get more room
+	ipLimiterPolicy = limiterPolicy{
+		FreeAttempts:    20,
+		BaseDelay:       time.Second,
+		MaxDelay:        5 * time.Minute,
+		LockoutAfter:    100,
+		LockoutDuration: time.Hour,
+		Window:          time.Hour,
+	}
+)
+
+// blockFor returns how long a key is blocked after its nth failure
+func (p limiterPolicy) blockFor(failures int) time.Duration {
+	if failures >= p.LockoutAfter {
+		return p.LockoutDuration
+	}
+	if failures <= p.FreeAttempts {
+		return 0
+	}
+
+	delay := p.BaseDelay
+	for i := p.FreeAttempts + 1; i < failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	return delay
+}
+
+// Limiter key kinds
+const (
+	limiterKeyAccount = "account"
+	limiterKeyIP      = "ip"
+)
+
+func accountLimiterKey(email string) string {
+	return limiterKeyAccount + ":" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipLimiterKey(ip string) string {
+	return limiterKeyIP + ":" + ip
+}
+
+// policyForKey picks the policy from the key's kind
+func policyForKey(key string) limiterPolicy {
+	if strings.HasPrefix(key, limiterKeyIP+":") {
+		return ipLimiterPolicy
+	}
+	return accountLimiterPolicy
+}
+
+// Lockout is a key that is currently blocked
+type Lockout struct {
+	Key          string    `json:"key"`
+	Type         string    `json:"type"`  // account or ip
+	Value        string    `json:"value"` // email or IP address
+	Failures     int       `json:"failures"`
+	BlockedUntil time.Time `json:"blocked_until"`
+	LockedOut    bool      `json:"locked_out"` // true for a full lockout rather than a backoff delay
+}
+
+func newLockout(key string, failures int, blockedUntil time.Time) Lockout {
+	kind, value, _ := strings.Cut(key, ":")
+	return Lockout{
+		Key:          key,
+		Type:         kind,
+		Value:        value,
+		Failures:     failures,
+		BlockedUntil: blockedUntil,
+		LockedOut:    failures >= policyForKey(key).LockoutAfter,
+	}
+}
+
+// LoginLimiter tracks failed logins
+type LoginLimiter interface {
+	// Check returns how long the key is still blocked (0 when it may try again)
+	Check(key string) (time.Duration, error)
+	// Fail records a failure and returns how long the key is now blocked
+	Fail(key string) (time.Duration, error)
+	// Reset forgets the key's failures
+	Reset(key string) error
+	// Lockouts lists the keys that are currently blocked
+	Lockouts() ([]Lockout, error)
+}
+
+// loginLimiter is the LoginLimiter used by the application, set up in main
+var loginLimiter LoginLimiter = newMemoryLoginLimiter()
+
+// loadLoginLimiter builds the LoginLimiter configured in the environment
+func loadLoginLimiter() LoginLimiter {
+	if os.Getenv("LOGIN_LIMITER") != "redis" {
+		return newMemoryLoginLimiter()
+	}
+
+	client, err := connectRedis()
+	if err != nil {
+		log.Printf("Redis unavailable for the login limiter, using in-memory limiter: %v", err)
+		return newMemoryLoginLimiter()
+	}
+
+	log.Println("Using Redis login limiter")
+	return &redisLoginLimiter{client: client, prefix: "commune:login:"}
+}
+
+// maxMemoryLimiterEntries is the size above which the in-memory limiter sweeps expired keys
+const maxMemoryLimiterEntries = 10000
+
+// memoryLoginLimiter keeps failures in-process
+type memoryLoginLimiter struct {
+	mu      sync.Mutex
+	entries map[string]*limiterEntry
+}
+
+type limiterEntry struct {
+	Failures     int
+	LastFailure  time.Time
+	BlockedUntil time.Time
+}
+
+func newMemoryLoginLimiter() *memoryLoginLimiter {
+	return &memoryLoginLimiter{entries: make(map[string]*limiterEntry)}
+}
+
+// entry returns the live entry for a key, dropping it when its window has passed
+func (l *memoryLoginLimiter) entry(key string, now time.Time) *limiterEntry {
+	e, ok := l.entries[key]
+	if !ok {
+		return nil
+	}
+	if now.Sub(e.LastFailure) > policyForKey(key).Window && now.After(e.BlockedUntil) {
+		delete(l.entries, key)
+		return nil
+	}
+	return e
+}
+
+func (l *memoryLoginLimiter) Check(key string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if e := l.entry(key, now); e != nil && now.Before(e.BlockedUntil) {
+		return e.BlockedUntil.Sub(now), nil
+	}
+	return 0, nil
+}
+
+func (l *memoryLoginLimiter) Fail(key string) (time.Duration, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if len(l.entries) > maxMemoryLimiterEntries {
+		// Sweep expired entries so a spray of keys can't grow the map forever
+		for k := range l.entries {
+			l.entry(k, now)
+		}
+	}
+
+	e := l.entry(key, now)
+	if e == nil {
+		e = &limiterEntry{}
+		l.entries[key] = e
+	}
+
+	e.Failures++
+	e.LastFailure = now
+	block := policyForKey(key).blockFor(e.Failures)
+	e.BlockedUntil = now.Add(block)
+
+	return block, nil
+}
+
+func (l *memoryLoginLimiter) Reset(key string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	delete(l.entries, key)
+	return nil
+}
+
+func (l *memoryLoginLimiter) Lockouts() ([]Lockout, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	lockouts := []Lockout{}
+	for key := range l.entries {
+		if e := l.entry(key, now); e != nil && now.Before(e.BlockedUntil) {
+			lockouts = append(lockouts, newLockout(key, e.Failures, e.BlockedUntil))
+		}
+	}
+	return lockouts, nil
+}
+
+// redisLoginLimiter keeps failures in Redis so every instance shares them.
+// Each key is a hash with "failures" and "blocked_until" (unix milliseconds).
+type redisLoginLimiter struct {
+	client *redis.Client
+	prefix string
+}
+
+func (l *redisLoginLimiter) Check(key string) (time.Duration, error) {
+	ctx := context.Background()
+	blockedUntil, err := l.client.HGet(ctx, l.prefix+key, "blocked_until").Int64()
+	if err == redis.Nil {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	if remaining := time.Until(time.UnixMilli(blockedUntil)); remaining > 0 {
+		return remaining, nil
+	}
+	return 0, nil
+}
+
+func (l *redisLoginLimiter) Fail(key string) (time.Duration, error) {
+	ctx := context.Background()
+	policy := policyForKey(key)
+
+	failures, err := l.client.HIncrBy(ctx, l.prefix+key, "failures", 1).Result()
+	if err != nil {
+		return 0, err
+	}
+
+	block := policy.blockFor(int(failures))
+	ttl := policy.Window
+	if block > ttl {
+		ttl = block
+	}
+
+	pipe := l.client.TxPipeline()
+	pipe.HSet(ctx, l.prefix+key, "blocked_until", time.Now().Add(block).UnixMilli())
+	pipe.Expire(ctx, l.prefix+key, ttl)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return 0, err
+	}
+
+	return block, nil
+}
+
+func (l *redisLoginLimiter) Reset(key string) error {
+	return l.client.Del(context.Background(), l.prefix+key).Err()
+}
+
+func (l *redisLoginLimiter) Lockouts() ([]Lockout, error) {
+	ctx := context.Background()
+	now := time.Now()
+	lockouts := []Lockout{}
+
+	iter := l.client.Scan(ctx, 0, l.prefix+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		values, err := l.client.HGetAll(ctx, iter.Val()).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		failures, _ := strconv.Atoi(values["failures"])
+		blockedUntilMs, _ := strconv.ParseInt(values["blocked_until"], 10, 64)
+		blockedUntil := time.UnixMilli(blockedUntilMs)
+		if now.Before(blockedUntil) {
+			lockouts = append(lockouts, newLockout(strings.TrimPrefix(iter.Val(), l.prefix), failures, blockedUntil))
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return nil, err
+	}
+
+	return lockouts, nil
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Outgoing email
+//
+// MAILER selects the implementation: "smtp" sends through SMTP_HOST, "file"
+// appends messages to MAIL_FILE and "log" (the default) writes them to the
+// server log. The file and log mailers are meant for development and tests.
+
+// EmailMessage is a plain-text email
+type EmailMessage struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer sends email
+type Mailer interface {
+	Send(msg EmailMessage) error
+}
+
+// mailer is the Mailer used by the application, set up in main
+var mailer Mailer = &logMailer{}
+
+// loadMailer builds the Mailer configured in the environment
+func loadMailer() Mailer {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "Commune <no-reply@localhost>"
+	}
+
+	switch os.Getenv("MAILER") {
+	case "smtp":
+		port := os.Getenv("SMTP_PORT")
+		if port == "" {
+			port = "587"
+		}
+		return &smtpMailer{
+			Addr:     net.JoinHostPort(os.Getenv("SMTP_HOST"), port),
+			Host:     os.Getenv("SMTP_HOST"),
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     from,
+		}
+	case "file":
+		path := os.Getenv("MAIL_FILE")
+		if path == "" {
+			path = "mail.log"
+		}
+		return &fileMailer{Path: path, From: from}
+	default:
+		return &logMailer{}
+	}
+}
+
+// formatEmail renders a message with the headers needed by SMTP servers and mail clients
+func formatEmail(from string, msg EmailMessage) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(msg.Body, "\n", "\r\n"))
+	return []byte(b.String())
+}
+
+// smtpMailer sends email through an SMTP server
+type smtpMailer struct {
+	Addr     string
+	Host     string
+	Username string
+	Password string
+	From     string
+}
+
+func (m *smtpMailer) Send(msg EmailMessage) error {
+	var auth smtp.Auth
+	if m.Username != "" {
+		auth = smtp.PlainAuth("", m.Username, m.Password, m.Host)
+	}
+
+	// net/smtp wants the bare address for the envelope sender
+	sender := m.From
+	if start := strings.Index(sender, "<"); start >= 0 {
+		sender = strings.TrimSuffix(sender[start+1:], ">")
+	}
+
+	return smtp.SendMail(m.Addr, auth, sender, []string{msg.To}, formatEmail(m.From, msg))
+}
+
+// fileMailer appends every message to a file
+type fileMailer struct {
+	Path string
+	From string
+	mu   sync.Mutex
+}
+
+func (m *fileMailer) Send(msg EmailMessage) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	f, err := os.OpenFile(m.Path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(formatEmail(m.From, msg)); err != nil {
+		return err
+	}
+	_, err = f.WriteString("\r\n.\r\n")
+	return err
+}
+
+// logMailer writes every message to the server log
+type logMailer struct{}
+
+func (m *logMailer) Send(msg EmailMessage) error {
+	log.Printf("Email to %s: %s\n%s", msg.To, msg.Subject, msg.Body)
+	return nil
+}
+
+// sendEmail queues a message for the job queue to deliver, so handlers don't
+// wait on the mail server (and response times don't reveal whether an email
+// was sent). Failed sends are retried.
+func sendEmail(db *gorm.DB, msg EmailMessage) error {
+	_, err := enqueueJob(db, jobSendEmail, msg, jobOptions{})
+	return err
+}
+
+// runSendEmailJob sends a message queued by sendEmail
+func runSendEmailJob(db *gorm.DB, payload []byte) error {
+	var msg EmailMessage
+	if err := decodeJobPayload(payload, &msg); err != nil {
+		return err
+	}
+	return mailer.Send(msg)
+}
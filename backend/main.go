@@ -1,10 +1,14 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	gormigrate "github.com/go-gormigrate/gormigrate/v2"
@@ -28,6 +32,29 @@ func main() {
 		log.Fatal("Failed to run migrations:", err)
 	}
 
+	// Outgoing email
+	mailer = loadMailer()
+
+	// Failed login tracking
+	loginLimiter = loadLoginLimiter()
+
+	// Webhook endpoints on internal networks, for local development only
+	webhookAllowPrivateNetworks = os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true"
+
+	// Real-time events, shared between instances through Redis when configured
+	eventBus = loadEventBus()
+
+	// Completion confirmation, auto-confirmed once the window passes
+	confirmationWindow = loadConfirmationWindow()
+	paymentProvider = loadPaymentProvider()
+
+	// Pending offers expire once nobody has acted on them for a while
+	offerExpiry = loadOfferExpiry()
+
+	// Background jobs: emails, scheduled work and webhook deliveries
+	registerJobs()
+	jobs = startJobQueue(db)
+
 	// Get mode from environment (default to development)
 	mode := os.Getenv("MODE")
 	if mode == "" {
@@ -43,21 +70,50 @@ func main() {
 
 	// Create Gin router
 	router := gin.Default()
+	if err := router.SetTrustedProxies(loadTrustedProxies()); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 
-	// API routes
-	api := router.Group("/api")
+	// Health check, answered whatever the Host header (load balancer probes
+	// use IP addresses)
+	router.GET("/api/health", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	// API routes, scoped to the community resolved from the Host header
+	api := router.Group("/api", tenantMiddleware(db, loadTenantConfig()))
 	{
-		// Health check
-		api.GET("/health", func(c *gin.Context) {
-			c.JSON(http.StatusOK, gin.H{"status": "ok"})
-		})
+		// Current tenant community (null on the shared domain)
+		api.GET("/tenant", getTenantHandler())
+
+		// Real-time events for the current user (Server-Sent Events)
+		api.GET("/events", getEventsHandler(db))
 
 		// Auth routes
 		auth := api.Group("/auth")
 		{
 			auth.POST("/login", loginHandler(db))
-			auth.POST("/logout", logoutHandler())
+			auth.POST("/logout", logoutHandler(db))
+			auth.POST("/logout-all", logoutAllHandler(db))
+			auth.POST("/refresh", refreshTokenHandler(db))
+			auth.GET("/sessions", getSessionsHandler(db))
+			auth.DELETE("/sessions/:id", revokeSessionHandler(db))
+			auth.GET("/tokens", getPersonalAccessTokensHandler(db))
+			auth.POST("/tokens", createPersonalAccessTokenHandler(db))
+			auth.DELETE("/tokens/:id", revokePersonalAccessTokenHandler(db))
+			auth.POST("/forgot-password", forgotPasswordHandler(db))
+			auth.POST("/reset-password", resetPasswordHandler(db))
+			auth.POST("/verify-email", verifyEmailHandler(db))
+			auth.POST("/resend-verification", resendVerificationHandler(db))
 			auth.GET("/me", getCurrentUserHandler(db))
+
+			// Two-factor authentication
+			auth.GET("/mfa", getMFAStatusHandler(db))
+			auth.POST("/mfa/enroll", enrollMFAHandler(db))
+			auth.POST("/mfa/confirm", confirmMFAHandler(db))
+			auth.POST("/mfa/verify", verifyMFAHandler(db))
+			auth.POST("/mfa/disable", disableMFAHandler(db))
+			auth.POST("/mfa/recovery-codes", regenerateRecoveryCodesHandler(db))
 			auth.GET("/first-boot", checkFirstBootHandler(db))
 			auth.POST("/setup-super-user", setupSuperUserHandler(db))
 		}
@@ -65,6 +121,9 @@ func main() {
 		// User routes
 		setupUserRoutes(api, db)
 
+		// Admin routes
+		setupAdminRoutes(api, db)
+
 		// Community routes
 		setupCommunityRoutes(api, db)
 
@@ -76,6 +135,15 @@ func main() {
 
 		// Service request and offer routes (marketplace)
 		setupServiceRequestRoutes(api, db)
+
+		// Comment routes (posts, service requests and offers)
+		setupCommentRoutes(api, db)
+
+		// Direct messages between community members
+		setupConversationRoutes(api, db)
+
+		// Notification inbox and preferences
+		setupNotificationRoutes(api, db)
 	}
 
 	// Vite integration for serving frontend
@@ -113,12 +181,43 @@ func main() {
 		port = "8080"
 	}
 
-	log.Printf("Server starting on http://localhost:%s\n", port)
-	if err := router.Run(":" + port); err != nil {
-		log.Fatal("Server failed to start:", err)
+	srv := &http.Server{
+		Addr:    ":" + port,
+		Handler: router,
+	}
+	srv.RegisterOnShutdown(closeEventStreams)
+
+	// Stop on SIGINT or SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	go func() {
+		log.Printf("Server starting on http://localhost:%s\n", port)
+		if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			log.Fatal("Server failed to start:", err)
+		}
+	}()
+
+	<-ctx.Done()
+	stop()
+	log.Println("Shutting down...")
+
+	// Finish the requests and jobs in progress, up to shutdownTimeout
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown: %v", err)
+	}
+	if err := jobs.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Background jobs still running were cancelled: %v", err)
 	}
+
+	log.Println("Server stopped")
 }
 
+// shutdownTimeout is how long in-flight requests and jobs get to finish on shutdown
+const shutdownTimeout = 30 * time.Second
+
 func runMigrations(db *gorm.DB) error {
 	m := gormigrate.New(db, gormigrate.DefaultOptions, []*gormigrate.Migration{
 		{
@@ -166,6 +265,503 @@ func runMigrations(db *gorm.DB) error {
 				)
 			},
 		},
+		{
+			ID: "202402041302",
+			Migrate: func(tx *gorm.DB) error {
+				// Tombstone columns for moderated comments
+				return tx.AutoMigrate(&Comment{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, column := range []string{"removed_at", "removed_by_id", "is_removed"} {
+					if err := tx.Migrator().DropColumn(&Comment{}, column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "202402041303",
+			Migrate: func(tx *gorm.DB) error {
+				// One rating per service request
+				return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_ratings_service_request_unique ON ratings (service_request_id) WHERE deleted_at IS NULL").Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Exec("DROP INDEX IF EXISTS idx_ratings_service_request_unique").Error
+			},
+		},
+		{
+			ID: "202402041304",
+			Migrate: func(tx *gorm.DB) error {
+				// Communities without a subdomain or custom domain store "", so the
+				// unique indexes only apply to non-empty values
+				statements := []string{
+					"DROP INDEX IF EXISTS idx_communities_subdomain",
+					"DROP INDEX IF EXISTS idx_communities_custom_domain",
+					"CREATE UNIQUE INDEX IF NOT EXISTS idx_communities_subdomain_unique ON communities (subdomain) WHERE subdomain <> ''",
+					"CREATE UNIQUE INDEX IF NOT EXISTS idx_communities_custom_domain_unique ON communities (custom_domain) WHERE custom_domain <> ''",
+				}
+				for _, statement := range statements {
+					if err := tx.Exec(statement).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Exec("DROP INDEX IF EXISTS idx_communities_subdomain_unique").Error; err != nil {
+					return err
+				}
+				return tx.Exec("DROP INDEX IF EXISTS idx_communities_custom_domain_unique").Error
+			},
+		},
+		{
+			ID: "202402041305",
+			Migrate: func(tx *gorm.DB) error {
+				// Server-side sessions and rotating refresh tokens
+				return tx.AutoMigrate(&Session{}, &RefreshToken{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("refresh_tokens", "sessions")
+			},
+		},
+		{
+			ID: "202402041306",
+			Migrate: func(tx *gorm.DB) error {
+				// Email verification and password reset tokens
+				if err := tx.AutoMigrate(&User{}, &UserToken{}); err != nil {
+					return err
+				}
+				// Accounts created before verification existed are trusted as-is
+				return tx.Exec("UPDATE users SET email_verified_at = created_at WHERE email_verified_at IS NULL").Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("user_tokens"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&User{}, "EmailVerifiedAt")
+			},
+		},
+		{
+			ID: "202402041307",
+			Migrate: func(tx *gorm.DB) error {
+				// TOTP two-factor authentication
+				return tx.AutoMigrate(&User{}, &RecoveryCode{}, &MFAPolicy{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("mfa_policies", "recovery_codes"); err != nil {
+					return err
+				}
+				for _, column := range []string{"TOTPSecret", "TOTPEnabledAt", "TOTPLastStep"} {
+					if err := tx.Migrator().DropColumn(&User{}, column); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			ID: "202402041308",
+			Migrate: func(tx *gorm.DB) error {
+				// Personal access tokens
+				return tx.AutoMigrate(&PersonalAccessToken{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("personal_access_tokens")
+			},
+		},
+		{
+			ID: "202402041309",
+			Migrate: func(tx *gorm.DB) error {
+				// Login attempt history
+				return tx.AutoMigrate(&LoginEvent{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("login_events")
+			},
+		},
+		{
+			ID: "202402041310",
+			Migrate: func(tx *gorm.DB) error {
+				// Append-only audit log
+				if err := tx.AutoMigrate(&AuditEvent{}); err != nil {
+					return err
+				}
+				return createAppendOnlyTriggers(tx, "audit_events")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				dropAppendOnlyTriggers(tx, "audit_events")
+				return tx.Migrator().DropTable("audit_events")
+			},
+		},
+		{
+			ID: "202402041311",
+			Migrate: func(tx *gorm.DB) error {
+				// Service request and offer status history
+				return tx.AutoMigrate(&StatusTransition{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("status_transitions")
+			},
+		},
+		{
+			ID: "202402041312",
+			Migrate: func(tx *gorm.DB) error {
+				// Backfill status history for requests and offers that changed
+				// before it was recorded, using the timestamps we have
+				const reason = "recorded before status history"
+
+				var offers []ServiceOffer
+				if err := tx.Where("status != ?", offerStatusPending).Find(&offers).Error; err != nil {
+					return err
+				}
+				acceptedAt := make(map[uint]time.Time)
+				for _, offer := range offers {
+					if offer.Status == offerStatusAccepted {
+						acceptedAt[offer.ID] = offer.UpdatedAt
+					}
+					if err := tx.Create(&StatusTransition{
+						CreatedAt:  offer.UpdatedAt,
+						EntityType: entityServiceOffer,
+						EntityID:   offer.ID,
+						FromStatus: offerStatusPending,
+						ToStatus:   offer.Status,
+						Reason:     reason,
+					}).Error; err != nil {
+						return err
+					}
+				}
+
+				var requests []ServiceRequest
+				if err := tx.Where("status != ?", requestStatusOpen).Find(&requests).Error; err != nil {
+					return err
+				}
+				for _, request := range requests {
+					from := requestStatusOpen
+					if request.AcceptedOfferID != nil {
+						at, ok := acceptedAt[*request.AcceptedOfferID]
+						if !ok {
+							at = request.UpdatedAt
+						}
+						if err := tx.Create(&StatusTransition{
+							CreatedAt:  at,
+							EntityType: entityServiceRequest,
+							EntityID:   request.ID,
+							FromStatus: requestStatusOpen,
+							ToStatus:   requestStatusInProgress,
+							Reason:     reason,
+						}).Error; err != nil {
+							return err
+						}
+						from = requestStatusInProgress
+					}
+					if request.Status == from {
+						continue
+					}
+
+					at := request.UpdatedAt
+					if request.CompletedAt != nil {
+						at = *request.CompletedAt
+					}
+					if err := tx.Create(&StatusTransition{
+						CreatedAt:  at,
+						EntityType: entityServiceRequest,
+						EntityID:   request.ID,
+						FromStatus: from,
+						ToStatus:   request.Status,
+						Reason:     reason,
+					}).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Where("reason = ?", "recorded before status history").Delete(&StatusTransition{}).Error
+			},
+		},
+		{
+			ID: "202402041313",
+			Migrate: func(tx *gorm.DB) error {
+				// Offer negotiation: revisions and the agreed terms
+				if err := tx.AutoMigrate(&OfferRevision{}, &ServiceOffer{}); err != nil {
+					return err
+				}
+
+				// Existing offers become their own first revision
+				var offers []ServiceOffer
+				if err := tx.Find(&offers).Error; err != nil {
+					return err
+				}
+				for _, offer := range offers {
+					revision := OfferRevision{
+						CreatedAt:         offer.CreatedAt,
+						ServiceOfferID:    offer.ID,
+						Version:           1,
+						AuthorID:          offer.ProviderID,
+						Price:             offer.ProposedPrice,
+						Description:       offer.Description,
+						EstimatedDuration: offer.EstimatedDuration,
+					}
+					if err := tx.Create(&revision).Error; err != nil {
+						return err
+					}
+					if offer.Status == offerStatusAccepted {
+						if err := tx.Model(&offer).Update("agreed_revision_id", revision.ID).Error; err != nil {
+							return err
+						}
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropColumn(&ServiceOffer{}, "agreed_revision_id"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropTable("offer_revisions")
+			},
+		},
+		{
+			ID: "202402041314",
+			Migrate: func(tx *gorm.DB) error {
+				// Completion confirmation and disputes
+				return tx.AutoMigrate(&ServiceRequest{}, &Dispute{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("disputes"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(&ServiceRequest{}, "work_done_at"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&ServiceRequest{}, "confirmation_due_at")
+			},
+		},
+		{
+			ID: "202402041315",
+			Migrate: func(tx *gorm.DB) error {
+				// Milestones on accepted offers, with their own comment threads
+				return tx.AutoMigrate(&Milestone{}, &Comment{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropColumn(&Comment{}, "milestone_id"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropTable("milestones")
+			},
+		},
+		{
+			ID: "202402041316",
+			Migrate: func(tx *gorm.DB) error {
+				// Double-entry payments ledger with per-community currency and fees.
+				// Transactions and entries are append-only like the audit log.
+				if err := tx.AutoMigrate(&Community{}, &LedgerAccount{}, &LedgerTransaction{}, &LedgerEntry{}); err != nil {
+					return err
+				}
+
+				for _, table := range []string{"ledger_transactions", "ledger_entries"} {
+					if err := createAppendOnlyTriggers(tx, table); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+			Rollback: func(tx *gorm.DB) error {
+				for _, table := range []string{"ledger_transactions", "ledger_entries"} {
+					dropAppendOnlyTriggers(tx, table)
+				}
+				if err := tx.Migrator().DropTable("ledger_entries", "ledger_transactions", "ledger_accounts"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(&Community{}, "platform_fee_bps"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&Community{}, "currency")
+			},
+		},
+		{
+			ID: "202402041317",
+			Migrate: func(tx *gorm.DB) error {
+				// Invoices with their rendered PDFs, and community tax settings.
+				// Issued invoices are append-only.
+				if err := tx.AutoMigrate(&Community{}, &Invoice{}); err != nil {
+					return err
+				}
+				return createAppendOnlyTriggers(tx, "invoices")
+			},
+			Rollback: func(tx *gorm.DB) error {
+				dropAppendOnlyTriggers(tx, "invoices")
+				if err := tx.Migrator().DropTable("invoices"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(&Community{}, "tax_label"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&Community{}, "tax_rate_bps")
+			},
+		},
+		{
+			ID: "202402041318",
+			Migrate: func(tx *gorm.DB) error {
+				// Provider availability, appointments and calendar feed tokens
+				return tx.AutoMigrate(&User{}, &AvailabilityWindow{}, &AvailabilityBlackout{}, &Appointment{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropTable("appointments", "availability_blackouts", "availability_windows"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(&User{}, "calendar_token_hash"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropColumn(&User{}, "time_zone")
+			},
+		},
+		{
+			ID: "202402041319",
+			Migrate: func(tx *gorm.DB) error {
+				// Recurring requests, and the requests they post
+				return tx.AutoMigrate(&RecurringRequest{}, &ServiceRequest{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropColumn(&ServiceRequest{}, "scheduled_for"); err != nil {
+					return err
+				}
+				if err := tx.Migrator().DropColumn(&ServiceRequest{}, "recurring_request_id"); err != nil {
+					return err
+				}
+				return tx.Migrator().DropTable("recurring_requests")
+			},
+		},
+		{
+			ID: "202402041320",
+			Migrate: func(tx *gorm.DB) error {
+				// Direct messages
+				return tx.AutoMigrate(&Conversation{}, &ConversationParticipant{}, &Message{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("messages", "conversation_participants", "conversations")
+			},
+		},
+		{
+			ID: "202402041321",
+			Migrate: func(tx *gorm.DB) error {
+				// Notification inbox and per-user preferences
+				return tx.AutoMigrate(&Notification{}, &NotificationPreference{}, &NotificationSettings{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("notification_settings", "notification_preferences", "notifications")
+			},
+		},
+		{
+			ID: "202402041322",
+			Migrate: func(tx *gorm.DB) error {
+				// Community webhook endpoints and their delivery log
+				return tx.AutoMigrate(&WebhookEndpoint{}, &WebhookDelivery{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("webhook_deliveries", "webhook_endpoints")
+			},
+		},
+		{
+			ID: "202402041323",
+			Migrate: func(tx *gorm.DB) error {
+				// Background job queue and scheduled jobs
+				return tx.AutoMigrate(&Job{}, &JobSchedule{})
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("job_schedules", "jobs")
+			},
+		},
+		{
+			ID: "202402041324",
+			Migrate: func(tx *gorm.DB) error {
+				// Milestone amounts in integer minor units of the request's
+				// currency, and requester approval of milestone plans
+				type milestoneAmount struct {
+					ID       uint
+					Amount   float64
+					Currency string
+				}
+				var amounts []milestoneAmount
+				if err := tx.Table("milestones").
+					Select("milestones.id, milestones.amount, COALESCE(ledger_accounts.currency, communities.currency) AS currency").
+					Joins("JOIN service_offers ON service_offers.id = milestones.service_offer_id").
+					Joins("JOIN service_requests ON service_requests.id = service_offers.service_request_id").
+					Joins("JOIN communities ON communities.id = service_requests.community_id").
+					Joins("LEFT JOIN ledger_accounts ON ledger_accounts.kind = ? AND ledger_accounts.owner_id = service_requests.id", ledgerAccountEscrow).
+					Scan(&amounts).Error; err != nil {
+					return err
+				}
+
+				if err := tx.Migrator().AlterColumn(&Milestone{}, "Amount"); err != nil {
+					return err
+				}
+				if err := tx.AutoMigrate(&ServiceOffer{}, &Milestone{}); err != nil {
+					return err
+				}
+
+				for _, a := range amounts {
+					if err := tx.Model(&Milestone{}).Unscoped().Where("id = ?", a.ID).UpdateColumns(map[string]interface{}{
+						"amount":   toMinorUnits(a.Amount, a.Currency),
+						"currency": a.Currency,
+					}).Error; err != nil {
+						return err
+					}
+				}
+
+				// Plans already under way were agreed to in practice
+				return tx.Model(&ServiceOffer{}).
+					Where("id IN (?)", tx.Model(&Milestone{}).Select("service_offer_id").Where("status != ?", milestoneStatusPending)).
+					UpdateColumn("milestones_approved_at", time.Now()).Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				if err := tx.Migrator().DropColumn(&ServiceOffer{}, "milestones_approved_at"); err != nil {
+					return err
+				}
+
+				type milestoneAmount struct {
+					ID       uint
+					Amount   int64
+					Currency string
+				}
+				var amounts []milestoneAmount
+				if err := tx.Table("milestones").Select("id, amount, currency").Scan(&amounts).Error; err != nil {
+					return err
+				}
+
+				// The previous schema kept amounts as floats in major units
+				type Milestone struct {
+					Amount float64
+				}
+				if err := tx.Migrator().AlterColumn(&Milestone{}, "Amount"); err != nil {
+					return err
+				}
+				for _, a := range amounts {
+					amount := float64(a.Amount)
+					if !zeroDecimalCurrencies[a.Currency] {
+						amount /= 100
+					}
+					if err := tx.Table("milestones").Where("id = ?", a.ID).UpdateColumn("amount", amount).Error; err != nil {
+						return err
+					}
+				}
+				return tx.Migrator().DropColumn(&Milestone{}, "currency")
+			},
+		},
+		{
+			ID: "202402041325",
+			Migrate: func(tx *gorm.DB) error {
+				// Per-provider invoice counters, starting from the invoices issued so far
+				if err := tx.AutoMigrate(&InvoiceCounter{}); err != nil {
+					return err
+				}
+				return tx.Exec("INSERT INTO invoice_counters (provider_id, last_sequence) " +
+					"SELECT provider_id, MAX(sequence) FROM invoices GROUP BY provider_id").Error
+			},
+			Rollback: func(tx *gorm.DB) error {
+				return tx.Migrator().DropTable("invoice_counters")
+			},
+		},
 	})
 
 	if err := m.Migrate(); err != nil {
@@ -212,6 +808,36 @@ func setupUserRoutes(api *gin.RouterGroup, db *gorm.DB) {
 		users.PUT("/:id", updateUserHandler(db))
 		users.DELETE("/:id", deleteUserHandler(db))
 		users.GET("/:id/communities", getUserCommunitiesHandler(db))
+		users.GET("/:id/reviews", getProviderReviewsHandler(db))
+		users.GET("/:id/balances", getUserBalancesHandler(db))
+		users.GET("/:id/statement", getUserStatementHandler(db))
+		users.GET("/:id/availability", getAvailabilityHandler(db))
+		users.PUT("/:id/availability", updateAvailabilityHandler(db))
+		users.POST("/:id/blackouts", createBlackoutHandler(db))
+		users.DELETE("/:id/blackouts/:blackoutId", deleteBlackoutHandler(db))
+		users.GET("/:id/slots", getAvailableSlotsHandler(db))
+		users.POST("/:id/calendar-token", createCalendarTokenHandler(db))
+		users.GET("/:id/calendar.ics", calendarFeedHandler(db))
+	}
+}
+
+func setupAdminRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	admin := api.Group("/admin")
+	{
+		admin.GET("/mfa-policies", getMFAPoliciesHandler(db))
+		admin.PUT("/mfa-policies/:role", updateMFAPolicyHandler(db))
+		admin.DELETE("/users/:id/mfa", resetUserMFAHandler(db))
+		admin.GET("/lockouts", getLockoutsHandler(db))
+		admin.DELETE("/lockouts/:type/:value", clearLockoutHandler(db))
+		admin.GET("/login-events", getLoginEventsHandler(db))
+		admin.GET("/audit", getAuditEventsHandler(db))
+		admin.GET("/audit/export", exportAuditEventsHandler(db))
+		admin.GET("/jobs", getJobsHandler(db))
+		admin.GET("/jobs/stats", getJobStatsHandler(db))
+		admin.GET("/jobs/schedules", getJobSchedulesHandler(db))
+		admin.GET("/jobs/:id", getJobHandler(db))
+		admin.POST("/jobs/:id/retry", retryJobHandler(db))
+		admin.DELETE("/jobs/:id", deleteJobHandler(db))
 	}
 }
 
@@ -232,6 +858,36 @@ func setupCommunityRoutes(api *gin.RouterGroup, db *gorm.DB) {
 
 		// Join requests
 		communities.GET("/:id/join-requests", getCommunityJoinRequestsHandler(db))
+
+		// Service request disputes
+		communities.GET("/:id/disputes", getCommunityDisputesHandler(db))
+
+		// Payments ledger
+		communities.GET("/:id/ledger", getCommunityLedgerHandler(db))
+
+		// Audit trail
+		communities.GET("/:id/audit", getCommunityAuditEventsHandler(db))
+		communities.GET("/:id/audit/export", exportCommunityAuditEventsHandler(db))
+
+		// Webhooks
+		communities.GET("/:id/webhooks", getWebhooksHandler(db))
+		communities.POST("/:id/webhooks", createWebhookHandler(db))
+		communities.GET("/:id/webhooks/:webhookId", getWebhookHandler(db))
+		communities.PUT("/:id/webhooks/:webhookId", updateWebhookHandler(db))
+		communities.DELETE("/:id/webhooks/:webhookId", deleteWebhookHandler(db))
+		communities.POST("/:id/webhooks/:webhookId/rotate-secret", rotateWebhookSecretHandler(db))
+		communities.POST("/:id/webhooks/:webhookId/ping", pingWebhookHandler(db))
+		communities.GET("/:id/webhooks/:webhookId/deliveries", getWebhookDeliveriesHandler(db))
+		communities.POST("/:id/webhooks/:webhookId/deliveries/:deliveryId/redeliver", redeliverWebhookHandler(db))
+
+		// Posts
+		communities.GET("/:id/posts", getCommunityPostsHandler(db))
+		communities.POST("/:id/posts", createPostHandler(db))
+		communities.GET("/:id/posts/:postId", getPostHandler(db))
+		communities.PUT("/:id/posts/:postId", updatePostHandler(db))
+		communities.DELETE("/:id/posts/:postId", deletePostHandler(db))
+		communities.POST("/:id/posts/:postId/publish", setPostPublishedHandler(db, true))
+		communities.POST("/:id/posts/:postId/unpublish", setPostPublishedHandler(db, false))
 	}
 }
 
@@ -263,6 +919,8 @@ func setupServiceRequestRoutes(api *gin.RouterGroup, db *gorm.DB) {
 	api.GET("/service-requests/:id", serviceRequestDetailHandler(db))
 	api.PUT("/service-requests/:id", serviceRequestDetailHandler(db))
 	api.DELETE("/service-requests/:id", serviceRequestDetailHandler(db))
+	api.POST("/service-requests/:id/:action", serviceRequestDetailHandler(db))
+	api.PUT("/service-requests/:id/:action", serviceRequestDetailHandler(db))
 
 	// Service offers - using the compound handlers
 	api.GET("/service-offers", serviceOffersHandler(db))
@@ -270,4 +928,89 @@ func setupServiceRequestRoutes(api *gin.RouterGroup, db *gorm.DB) {
 	api.GET("/service-offers/:id", serviceOfferDetailHandler(db))
 	api.PUT("/service-offers/:id", serviceOfferDetailHandler(db))
 	api.DELETE("/service-offers/:id", serviceOfferDetailHandler(db))
+	api.GET("/service-offers/:id/:action", serviceOfferDetailHandler(db))
+	api.POST("/service-offers/:id/:action", serviceOfferDetailHandler(db))
+	api.PUT("/service-offers/:id/:action", serviceOfferDetailHandler(db))
+
+	// Milestones - planned on the accepted offer, submitted by the provider and reviewed by the requester
+	api.POST("/milestones/:id/submit", submitMilestoneHandler(db))
+	api.POST("/milestones/:id/approve", approveMilestoneHandler(db))
+	api.POST("/milestones/:id/reject", rejectMilestoneHandler(db))
+
+	// Timeline - status changes, offers and comments in one feed
+	api.GET("/service-requests/:id/timeline", serviceRequestTimelineHandler(db))
+
+	// Invoices - issued when a request completes, with a receipt for the requester
+	api.GET("/service-requests/:id/invoice", serviceRequestInvoiceHandler(db))
+
+	// Appointments - booked by the requester inside the provider's availability
+	api.GET("/service-requests/:id/appointments", serviceRequestAppointmentsHandler(db))
+	api.POST("/service-requests/:id/appointments", serviceRequestAppointmentsHandler(db))
+	api.POST("/appointments/:id/reschedule", rescheduleAppointmentHandler(db))
+	api.POST("/appointments/:id/cancel", cancelAppointmentHandler(db))
+
+	// Recurring requests - post a new request on every occurrence of a schedule
+	recurring := api.Group("/recurring-requests")
+	{
+		recurring.GET("", getRecurringRequestsHandler(db))
+		recurring.POST("", createRecurringRequestHandler(db))
+		recurring.GET("/:id", getRecurringRequestHandler(db))
+		recurring.PUT("/:id", updateRecurringRequestHandler(db))
+		recurring.DELETE("/:id", endRecurringRequestHandler(db))
+		recurring.POST("/:id/pause", pauseRecurringRequestHandler(db))
+		recurring.POST("/:id/resume", resumeRecurringRequestHandler(db))
+		recurring.POST("/:id/skip", skipRecurringRequestHandler(db))
+	}
+
+	// Disputes - raised by requesters, mediated and resolved by community moderators
+	api.GET("/service-requests/:id/disputes", getServiceRequestDisputesHandler(db))
+	api.POST("/disputes/:id/mediate", mediateDisputeHandler(db))
+	api.POST("/disputes/:id/resolve", resolveDisputeHandler(db))
+
+	// Ratings - requesters rate the accepted provider once the request is completed
+	api.GET("/service-requests/:id/rating", getServiceRequestRatingHandler(db))
+	api.POST("/service-requests/:id/rating", createRatingHandler(db))
+}
+
+func setupConversationRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	conversations := api.Group("/conversations")
+	{
+		conversations.GET("", getConversationsHandler(db))
+		conversations.POST("", createConversationHandler(db))
+		conversations.GET("/unread", getUnreadMessagesHandler(db))
+		conversations.GET("/:id", getConversationHandler(db))
+		conversations.GET("/:id/messages", getMessagesHandler(db))
+		conversations.POST("/:id/messages", sendMessageHandler(db))
+		conversations.POST("/:id/read", markConversationReadHandler(db))
+	}
+}
+
+func setupNotificationRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	notifications := api.Group("/notifications")
+	{
+		notifications.GET("", getNotificationsHandler(db))
+		notifications.GET("/unread-count", getUnreadNotificationsHandler(db))
+		notifications.POST("/read-all", markAllNotificationsReadHandler(db))
+		notifications.GET("/preferences", getNotificationPreferencesHandler(db))
+		notifications.PUT("/preferences", updateNotificationPreferencesHandler(db))
+		notifications.POST("/:id/read", markNotificationReadHandler(db))
+	}
+}
+
+func setupCommentRoutes(api *gin.RouterGroup, db *gorm.DB) {
+	api.GET("/communities/:id/posts/:postId/comments", listCommentsHandler(db, postCommentTarget))
+	api.POST("/communities/:id/posts/:postId/comments", createCommentHandler(db, postCommentTarget))
+	api.GET("/service-requests/:id/comments", listCommentsHandler(db, serviceRequestCommentTarget))
+	api.POST("/service-requests/:id/comments", createCommentHandler(db, serviceRequestCommentTarget))
+	api.GET("/service-offers/:id/comments", listCommentsHandler(db, serviceOfferCommentTarget))
+	api.POST("/service-offers/:id/comments", createCommentHandler(db, serviceOfferCommentTarget))
+	api.GET("/milestones/:id/comments", listCommentsHandler(db, milestoneCommentTarget))
+	api.POST("/milestones/:id/comments", createCommentHandler(db, milestoneCommentTarget))
+
+	comments := api.Group("/comments")
+	{
+		comments.PUT("/:id", updateCommentHandler(db))
+		comments.DELETE("/:id", deleteCommentHandler(db))
+		comments.POST("/:id/remove", removeCommentHandler(db))
+	}
 }
@@ -0,0 +1,650 @@
+package main
+
+import (
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// Two-factor authentication
+//
+// Users with TOTP enabled log in in two steps: the password check returns a
+// short-lived mfa_token instead of a session, and POST /auth/mfa/verify
+// exchanges it plus a TOTP or recovery code for a session. When an MFAPolicy
+// makes 2FA mandatory for a role, users without it get an mfa_token that can
+// only be used to enroll.
+
+const (
+	mfaTokenTTL       = 5 * time.Minute
+	mfaTokenAudience  = "mfa"
+	recoveryCodeCount = 10
+)
+
+// mfa_token purposes
+const (
+	mfaPurposeVerify = "verify" // second login step
+	mfaPurposeEnroll = "enroll" // 2FA is mandatory and must be set up first
+)
+
+var errInvalidMFACode = errors.New("invalid authentication code")
+
+// mfaPolicyRoles are the global roles an MFAPolicy can be set for
+var mfaPolicyRoles = []UserRole{RoleSuperAdmin, RoleAdmin, RoleModerator, RoleServiceProvider, RoleUser}
+
+// mfaClaims are carried by the mfa_token returned from the password step
+type mfaClaims struct {
+	UserID  uint   `json:"user_id"`
+	Purpose string `json:"purpose"`
+	jwt.RegisteredClaims
+}
+
+func generateMFAToken(user *User, purpose string) (string, error) {
+	claims := &mfaClaims{
+		UserID:  user.ID,
+		Purpose: purpose,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Audience:  jwt.ClaimStrings{mfaTokenAudience},
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(mfaTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	return token.SignedString(jwtSecret)
+}
+
+// validateMFAToken checks an mfa_token and returns the active user it was issued to
+func validateMFAToken(db *gorm.DB, tokenString, purpose string) (*User, error) {
+	claims := &mfaClaims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret, nil
+	}, jwt.WithAudience(mfaTokenAudience))
+	if err != nil {
+		return nil, err
+	}
+
+	if !token.Valid || claims.Purpose != purpose {
+		return nil, errors.New("invalid token")
+	}
+
+	var user User
+	if err := db.First(&user, claims.UserID).Error; err != nil {
+		return nil, err
+	}
+
+	if !user.IsActive {
+		return nil, errors.New("user is inactive")
+	}
+
+	return &user, nil
+}
+
+// isMFARequired reports whether a policy makes 2FA mandatory for the role
+func isMFARequired(db *gorm.DB, role UserRole) (bool, error) {
+	var policy MFAPolicy
+	if err := db.Where("role = ?", role).First(&policy).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	return policy.Required, nil
+}
+
+// checkTOTP validates a code for a user with TOTP set up and records its time
+// step so the same code can't be used twice
+func checkTOTP(db *gorm.DB, user *User, code string) error {
+	if user.TOTPSecret == "" {
+		return errInvalidMFACode
+	}
+
+	step, ok := matchTOTP(user.TOTPSecret, code, time.Now())
+	if !ok {
+		return errInvalidMFACode
+	}
+
+	result := db.Model(&User{}).
+		Where("id = ? AND totp_last_step < ?", user.ID, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidMFACode
+	}
+
+	user.TOTPLastStep = step
+	return nil
+}
+
+// normalizeRecoveryCode lowercases a code and strips separators
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	code = strings.ReplaceAll(code, "-", "")
+	return strings.ReplaceAll(code, " ", "")
+}
+
+// useRecoveryCode marks one of the user's recovery codes as used
+func useRecoveryCode(db *gorm.DB, userID uint, code string) error {
+	result := db.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, hashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errInvalidMFACode
+	}
+	return nil
+}
+
+// checkSecondFactor accepts either a TOTP code or a recovery code
+func checkSecondFactor(db *gorm.DB, user *User, code, recoveryCode string) error {
+	if recoveryCode != "" {
+		return useRecoveryCode(db, user.ID, recoveryCode)
+	}
+	return checkTOTP(db, user, code)
+}
+
+// replaceRecoveryCodes deletes the user's recovery codes and issues a new set.
+// The plain codes are returned once and never stored.
+func replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	records := make([]RecoveryCode, recoveryCodeCount)
+	for i := range codes {
+		b := make([]byte, 10)
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+		code := strings.ToLower(totpEncoding.EncodeToString(b))[:10]
+		codes[i] = code[:5] + "-" + code[5:]
+		records[i] = RecoveryCode{UserID: userID, CodeHash: hashToken(code)}
+	}
+
+	if err := tx.Create(&records).Error; err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// respondWithSession starts a session and writes the same response as a successful login
+func respondWithSession(c *gin.Context, db *gorm.DB, user *User, extra gin.H) {
+	token, refreshToken, err := startSession(c, db, user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate token"})
+		return
+	}
+
+	response := gin.H{
+		"user":          sanitizeUser(user),
+		"token":         token,
+		"refresh_token": refreshToken,
+		"expires_in":    int(accessTokenTTL.Seconds()),
+	}
+	for k, v := range extra {
+		response[k] = v
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// resolveMFAUser returns the user an enrollment request is for: the holder of
+// an enroll mfa_token, or otherwise the logged-in user
+func resolveMFAUser(c *gin.Context, db *gorm.DB, mfaToken string) (*User, bool) {
+	if mfaToken != "" {
+		user, err := validateMFAToken(db, mfaToken, mfaPurposeEnroll)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid or expired MFA token"})
+			return nil, false
+		}
+		return user, true
+	}
+
+	authMiddleware(db)(c)
+	if c.IsAborted() {
+		return nil, false
+	}
+
+	user, _ := c.Get("user")
+	return user.(*User), true
+}
+
+// MFA handlers
+
+func getMFAStatusHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		user, _ := c.Get("user")
+		currentUser := user.(*User)
+
+		required, err := isMFARequired(db, currentUser.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch MFA policy"})
+			return
+		}
+
+		var remaining int64
+		if err := db.Model(&RecoveryCode{}).
+			Where("user_id = ? AND used_at IS NULL", currentUser.ID).
+			Count(&remaining).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count recovery codes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"enabled":                  currentUser.TOTPEnabledAt != nil,
+			"enabled_at":               currentUser.TOTPEnabledAt,
+			"required":                 required,
+			"recovery_codes_remaining": remaining,
+		})
+	}
+}
+
+func enrollMFAHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			MFAToken string `json:"mfa_token"`
+		}
+		c.ShouldBindJSON(&req)
+
+		user, ok := resolveMFAUser(c, db, req.MFAToken)
+		if !ok {
+			return
+		}
+
+		if user.TOTPEnabledAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"message": "Two-factor authentication is already enabled"})
+			return
+		}
+
+		// The secret stays pending until confirmed with a valid code
+		secret, err := generateTOTPSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate secret"})
+			return
+		}
+
+		if err := db.Model(user).Updates(map[string]interface{}{
+			"totp_secret":    secret,
+			"totp_last_step": 0,
+		}).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to start enrollment"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"secret":      secret,
+			"otpauth_uri": totpProvisioningURI(secret, user.Email),
+		})
+	}
+}
+
+func confirmMFAHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			Code     string `json:"code"`
+			MFAToken string `json:"mfa_token"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Code is required"})
+			return
+		}
+
+		user, ok := resolveMFAUser(c, db, req.MFAToken)
+		if !ok {
+			return
+		}
+
+		if user.TOTPEnabledAt != nil {
+			c.JSON(http.StatusConflict, gin.H{"message": "Two-factor authentication is already enabled"})
+			return
+		}
+
+		if user.TOTPSecret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Start enrollment first"})
+			return
+		}
+
+		if err := checkTOTP(db, user, req.Code); err != nil {
+			if err == errInvalidMFACode {
+				c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid authentication code"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to verify code"})
+			}
+			return
+		}
+
+		var codes []string
+		now := time.Now()
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Model(user).Update("totp_enabled_at", now).Error; err != nil {
+				return err
+			}
+
+			var err error
+			codes, err = replaceRecoveryCodes(tx, user.ID)
+			return err
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to enable two-factor authentication"})
+			return
+		}
+
+		// Mandatory enrollment during login finishes the login
+		if req.MFAToken != "" {
+			recordLoginSuccess(c, user.Email)
+			respondWithSession(c, db, user, gin.H{"recovery_codes": codes})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":        "Two-factor authentication enabled",
+			"recovery_codes": codes,
+		})
+	}
+}
+
+func verifyMFAHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req struct {
+			MFAToken     string `json:"mfa_token"`
+			Code         string `json:"code"`
+			RecoveryCode string `json:"recovery_code"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		if req.MFAToken == "" || (req.Code == "" && req.RecoveryCode == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "MFA token and code are required"})
+			return
+		}
+
+		user, err := validateMFAToken(db, req.MFAToken, mfaPurposeVerify)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid or expired MFA token"})
+			return
+		}
+
+		// Codes are only 6 digits, so guesses count towards the same limits as passwords
+		if !checkLoginThrottle(db, c, user.Email) {
+			return
+		}
+
+		if err := checkSecondFactor(db, user, req.Code, req.RecoveryCode); err != nil {
+			if err == errInvalidMFACode {
+				recordLoginFailure(c, user.Email)
+				recordLoginEvent(db, c, &user.ID, user.Email, false, loginReasonMFAFailed)
+				c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid authentication code"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to verify code"})
+			}
+			return
+		}
+
+		recordLoginSuccess(c, user.Email)
+		recordLoginEvent(db, c, &user.ID, user.Email, true, loginReasonSuccess)
+		respondWithSession(c, db, user, nil)
+	}
+}
+
+func disableMFAHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		var req struct {
+			Password     string `json:"password"`
+			Code         string `json:"code"`
+			RecoveryCode string `json:"recovery_code"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		user, _ := c.Get("user")
+		currentUser := user.(*User)
+
+		if currentUser.TOTPEnabledAt == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Two-factor authentication is not enabled"})
+			return
+		}
+
+		required, err := isMFARequired(db, currentUser.Role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch MFA policy"})
+			return
+		}
+		if required {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Two-factor authentication is mandatory for your role"})
+			return
+		}
+
+		if !checkPasswordHash(req.Password, currentUser.PasswordHash) {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Password is incorrect"})
+			return
+		}
+
+		if err := checkSecondFactor(db, currentUser, req.Code, req.RecoveryCode); err != nil {
+			if err == errInvalidMFACode {
+				c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid authentication code"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to verify code"})
+			}
+			return
+		}
+
+		if err := resetMFA(db, currentUser.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to disable two-factor authentication"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication disabled"})
+	}
+}
+
+func regenerateRecoveryCodesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		var req struct {
+			Code string `json:"code"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Code is required"})
+			return
+		}
+
+		user, _ := c.Get("user")
+		currentUser := user.(*User)
+
+		if currentUser.TOTPEnabledAt == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Two-factor authentication is not enabled"})
+			return
+		}
+
+		if err := checkTOTP(db, currentUser, req.Code); err != nil {
+			if err == errInvalidMFACode {
+				c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid authentication code"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to verify code"})
+			}
+			return
+		}
+
+		codes, err := replaceRecoveryCodes(db, currentUser.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate recovery codes"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+	}
+}
+
+// resetMFA turns 2FA off for a user and deletes their recovery codes
+func resetMFA(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error
+	})
+}
+
+// Admin handlers
+
+func getMFAPoliciesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		var policies []MFAPolicy
+		if err := db.Find(&policies).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch MFA policies"})
+			return
+		}
+
+		required := make(map[UserRole]bool)
+		for _, policy := range policies {
+			required[policy.Role] = policy.Required
+		}
+
+		result := make([]gin.H, len(mfaPolicyRoles))
+		for i, role := range mfaPolicyRoles {
+			result[i] = gin.H{"role": role, "required": required[role]}
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func updateMFAPolicyHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		role := UserRole(c.Param("role"))
+		valid := false
+		for _, r := range mfaPolicyRoles {
+			if r == role {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid role"})
+			return
+		}
+
+		var req struct {
+			Required *bool `json:"required"`
+		}
+
+		if err := c.ShouldBindJSON(&req); err != nil || req.Required == nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "required must be true or false"})
+			return
+		}
+
+		var policy MFAPolicy
+		err := db.Where("role = ?", role).First(&policy).Error
+		previous := policy.Required
+		switch err {
+		case nil:
+			err = db.Model(&policy).Update("required", *req.Required).Error
+		case gorm.ErrRecordNotFound:
+			policy = MFAPolicy{Role: role, Required: *req.Required}
+			err = db.Create(&policy).Error
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update MFA policy"})
+			return
+		}
+
+		recordAudit(db, c, auditEntry{
+			Action:     auditMFAPolicyUpdate,
+			TargetType: "mfa_policy",
+			TargetID:   policy.ID,
+			Before:     map[string]interface{}{"role": string(role), "required": previous},
+			After:      map[string]interface{}{"role": string(role), "required": *req.Required},
+		})
+
+		c.JSON(http.StatusOK, gin.H{"role": role, "required": *req.Required})
+	}
+}
+
+// resetUserMFAHandler lets a super admin turn off 2FA for a user who lost their device
+func resetUserMFAHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireRole(db, RoleSuperAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user ID"})
+			return
+		}
+
+		var user User
+		if err := db.First(&user, id).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch user"})
+			}
+			return
+		}
+
+		if err := resetMFA(db, user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to reset two-factor authentication"})
+			return
+		}
+
+		recordAudit(db, c, auditEntry{
+			Action:     auditUserMFAReset,
+			TargetType: "user",
+			TargetID:   user.ID,
+			Before:     map[string]interface{}{"mfa_enabled": user.TOTPEnabledAt != nil},
+			After:      map[string]interface{}{"mfa_enabled": false},
+		})
+
+		c.JSON(http.StatusOK, gin.H{"message": "Two-factor authentication reset"})
+	}
+}
@@ -0,0 +1,509 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Milestones
+//
+// Larger jobs are split into ordered milestones on the accepted offer, each
+// with its own amount, due date and comment thread. The provider plans the
+// milestones, splitting the agreed price between them, and the requester
+// approves the plan, which locks it. The provider then submits a milestone
+// when its phase is done and the requester approves it or rejects it for
+// more work. The provider can't mark the whole job done until every
+// milestone is approved.
+
+// Error codes for milestone conflicts
+const (
+	errorCodeMilestonesLocked     = "milestones_locked"
+	errorCodeMilestonesIncomplete = "milestones_incomplete"
+	errorCodeMilestonesUnapproved = "milestones_unapproved"
+	errorCodeMilestonesChanged    = "milestones_changed"
+)
+
+var (
+	errMilestonePlanApproved = errors.New("milestone plan already approved")
+	errMilestonePlanChanged  = errors.New("milestone plan changed")
+)
+
+// milestoneInput is one milestone in a plan sent by the client
+type milestoneInput struct {
+	Description string     `json:"description"`
+	Amount      int64      `json:"amount"` // In integer minor units of the request's currency
+	DueDate     *time.Time `json:"due_date"`
+}
+
+// orderedMilestones preloads milestones in plan order
+func orderedMilestones(db *gorm.DB) *gorm.DB {
+	return db.Order("position ASC")
+}
+
+// findMilestone loads the :id milestone with its offer and request
+func findMilestone(c *gin.Context, db *gorm.DB) (*Milestone, bool) {
+	milestoneID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid milestone ID"})
+		return nil, false
+	}
+
+	var milestone Milestone
+	if err := db.Preload("ServiceOffer.ServiceRequest").First(&milestone, milestoneID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Milestone not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch milestone"})
+		}
+		return nil, false
+	}
+
+	return &milestone, true
+}
+
+// checkMilestonesApproved writes a 409 and returns false when the request's
+// accepted offer has milestones that aren't approved yet
+func checkMilestonesApproved(c *gin.Context, db *gorm.DB, request *ServiceRequest) bool {
+	if request.AcceptedOfferID == nil {
+		return true
+	}
+
+	var outstanding int64
+	if err := db.Model(&Milestone{}).
+		Where("service_offer_id = ? AND status != ?", *request.AcceptedOfferID, milestoneStatusApproved).
+		Count(&outstanding).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to check milestones"})
+		return false
+	}
+
+	if outstanding > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Every milestone must be approved before the work is marked done",
+			"code":    errorCodeMilestonesIncomplete,
+		})
+		return false
+	}
+	return true
+}
+
+// offerMilestones handles GET and PUT /api/service-offers/:id/milestones
+func offerMilestones(c *gin.Context, db *gorm.DB, offerID uint) {
+	switch c.Request.Method {
+	case http.MethodGet:
+		getOfferMilestones(c, db, offerID)
+	case http.MethodPut:
+		replaceOfferMilestones(c, db, offerID)
+	default:
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"message": "Method not allowed"})
+	}
+}
+
+// getOfferMilestones handles GET /api/service-offers/:id/milestones (community members)
+func getOfferMilestones(c *gin.Context, db *gorm.DB, offerID uint) {
+	var offer ServiceOffer
+	if err := db.Preload("ServiceRequest").First(&offer, offerID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Service offer not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service offer"})
+		}
+		return
+	}
+
+	if _, ok := checkCommunityAccess(c, db, offer.ServiceRequest.CommunityID); !ok {
+		return
+	}
+
+	var milestones []Milestone
+	if err := orderedMilestones(db).Where("service_offer_id = ?", offer.ID).Find(&milestones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch milestones"})
+		return
+	}
+
+	c.JSON(http.StatusOK, milestones)
+}
+
+// lockMilestonePlan touches the offer while its plan is unapproved, so plan
+// changes and approvals of the same offer are applied one at a time
+func lockMilestonePlan(tx *gorm.DB, offerID uint, updates map[string]interface{}) error {
+	result := tx.Model(&ServiceOffer{}).
+		Where("id = ? AND milestones_approved_at IS NULL", offerID).
+		Updates(updates)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errMilestonePlanApproved
+	}
+	return nil
+}
+
+// respondMilestonePlanError writes the response for a failed plan change or approval
+func respondMilestonePlanError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, errMilestonePlanApproved):
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "The plan can't change once the requester has approved it",
+			"code":    errorCodeMilestonesLocked,
+		})
+	case errors.Is(err, errMilestonePlanChanged):
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "The plan has changed since you last saw it",
+			"code":    errorCodeMilestonesChanged,
+		})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"message": fallback})
+	}
+}
+
+// replaceOfferMilestones handles PUT /api/service-offers/:id/milestones
+// The provider replaces the whole plan until the requester approves it; an
+// empty list removes it. The amounts must add up to the agreed price.
+func replaceOfferMilestones(c *gin.Context, db *gorm.DB, offerID uint) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return
+	}
+
+	var offer ServiceOffer
+	if err := db.Preload("ServiceRequest").Preload("AgreedRevision").First(&offer, offerID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Service offer not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service offer"})
+		}
+		return
+	}
+
+	if userID != offer.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Only the provider can plan milestones"})
+		return
+	}
+
+	var input struct {
+		Milestones []milestoneInput `json:"milestones"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+
+	for i := range input.Milestones {
+		input.Milestones[i].Description = strings.TrimSpace(input.Milestones[i].Description)
+		if input.Milestones[i].Description == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Every milestone needs a description"})
+			return
+		}
+		if input.Milestones[i].Amount < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Amount cannot be negative"})
+			return
+		}
+	}
+
+	if offer.Status != offerStatusAccepted || offer.ServiceRequest.Status != requestStatusInProgress {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Milestones can only be planned on the accepted offer of a request in progress",
+			"code":    errorCodeMilestonesLocked,
+		})
+		return
+	}
+
+	if offer.MilestonesApprovedAt != nil {
+		respondMilestonePlanError(c, errMilestonePlanApproved, "")
+		return
+	}
+
+	var started int64
+	if err := db.Model(&Milestone{}).
+		Where("service_offer_id = ? AND status != ?", offer.ID, milestoneStatusPending).
+		Count(&started).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to check milestones"})
+		return
+	}
+	if started > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "The plan can't change once a milestone has been submitted",
+			"code":    errorCodeMilestonesLocked,
+		})
+		return
+	}
+
+	currency, err := requestCurrency(db, &offer.ServiceRequest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch currency"})
+		return
+	}
+
+	var total int64
+	milestones := make([]Milestone, len(input.Milestones))
+	for i, m := range input.Milestones {
+		total += m.Amount
+		milestones[i] = Milestone{
+			ServiceOfferID: offer.ID,
+			Position:       i + 1,
+			Description:    m.Description,
+			Amount:         m.Amount,
+			Currency:       currency,
+			DueDate:        m.DueDate,
+			Status:         milestoneStatusPending,
+		}
+	}
+
+	price := toMinorUnits(agreedPrice(&offer), currency)
+	if len(milestones) > 0 && total != price {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"message":  "Milestone amounts must add up to the agreed price",
+			"total":    price,
+			"currency": currency,
+		})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockMilestonePlan(tx, offer.ID, map[string]interface{}{"updated_at": time.Now()}); err != nil {
+			return err
+		}
+		if err := tx.Where("service_offer_id = ?", offer.ID).Delete(&Milestone{}).Error; err != nil {
+			return err
+		}
+		if len(milestones) == 0 {
+			return nil
+		}
+		return tx.Create(&milestones).Error
+	})
+	if err != nil {
+		respondMilestonePlanError(c, err, "Failed to save milestones")
+		return
+	}
+
+	c.JSON(http.StatusOK, milestones)
+}
+
+// approveOfferMilestones handles POST /api/service-offers/:id/approve-milestones
+// The requester approves the plan they saw, named by its milestone IDs in
+// order, which locks it.
+func approveOfferMilestones(c *gin.Context, db *gorm.DB, offerID uint) {
+	if c.Request.Method != http.MethodPost {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"message": "Method not allowed"})
+		return
+	}
+
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return
+	}
+
+	var offer ServiceOffer
+	if err := db.Preload("ServiceRequest").First(&offer, offerID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Service offer not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service offer"})
+		}
+		return
+	}
+
+	if userID != offer.ServiceRequest.RequesterID {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Only the requester can approve the milestone plan"})
+		return
+	}
+
+	var input struct {
+		MilestoneIDs []uint `json:"milestone_ids"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+
+	if len(input.MilestoneIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "milestone_ids is required"})
+		return
+	}
+
+	if offer.Status != offerStatusAccepted || offer.ServiceRequest.Status != requestStatusInProgress {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Milestones can only be planned on the accepted offer of a request in progress",
+			"code":    errorCodeMilestonesLocked,
+		})
+		return
+	}
+
+	if offer.MilestonesApprovedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "The milestone plan is already approved",
+			"code":    errorCodeMilestonesLocked,
+		})
+		return
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := lockMilestonePlan(tx, offer.ID, map[string]interface{}{"milestones_approved_at": time.Now()}); err != nil {
+			return err
+		}
+
+		var ids []uint
+		if err := orderedMilestones(tx).Model(&Milestone{}).
+			Where("service_offer_id = ?", offer.ID).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if !slices.Equal(ids, input.MilestoneIDs) {
+			return errMilestonePlanChanged
+		}
+		return nil
+	})
+	if err != nil {
+		respondMilestonePlanError(c, err, "Failed to approve milestones")
+		return
+	}
+
+	var milestones []Milestone
+	if err := orderedMilestones(db).Where("service_offer_id = ?", offer.ID).Find(&milestones).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch milestones"})
+		return
+	}
+
+	c.JSON(http.StatusOK, milestones)
+}
+
+// loadMilestoneParty loads the :id milestone for the provider (provider true)
+// or the requester of an in-progress request. It writes an error response and
+// returns false otherwise.
+func loadMilestoneParty(c *gin.Context, db *gorm.DB, provider bool) (*Milestone, uint, bool) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return nil, 0, false
+	}
+
+	milestone, ok := findMilestone(c, db)
+	if !ok {
+		return nil, 0, false
+	}
+
+	offer := milestone.ServiceOffer
+	if provider && userID != offer.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Only the provider can submit milestones"})
+		return nil, 0, false
+	}
+	if !provider && userID != offer.ServiceRequest.RequesterID {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Only the requester can review milestones"})
+		return nil, 0, false
+	}
+
+	if offer.ServiceRequest.Status != requestStatusInProgress {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Milestones can only change while the request is in progress",
+			"code":    errorCodeMilestonesLocked,
+		})
+		return nil, 0, false
+	}
+
+	return milestone, userID, true
+}
+
+// moveMilestone applies a transition for a milestone handler and writes the response
+func moveMilestone(c *gin.Context, db *gorm.DB, milestone *Milestone, to string, userID uint, reason, fallback string) {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		return transitionMilestone(tx, milestone, to, &userID, reason)
+	})
+	if err != nil {
+		respondTransitionError(c, err, fallback)
+		return
+	}
+
+	var updated Milestone
+	if err := db.First(&updated, milestone.ID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to load updated milestone"})
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// submitMilestoneHandler handles POST /api/milestones/:id/submit (provider)
+func submitMilestoneHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		milestone, userID, ok := loadMilestoneParty(c, db, true)
+		if !ok {
+			return
+		}
+
+		if milestone.ServiceOffer.MilestonesApprovedAt == nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "The requester hasn't approved the milestone plan yet",
+				"code":    errorCodeMilestonesUnapproved,
+			})
+			return
+		}
+
+		moveMilestone(c, db, milestone, milestoneStatusSubmitted, userID, "", "Failed to submit milestone")
+	}
+}
+
+// approveMilestoneHandler handles POST /api/milestones/:id/approve (requester)
+func approveMilestoneHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		milestone, userID, ok := loadMilestoneParty(c, db, false)
+		if !ok {
+			return
+		}
+
+		moveMilestone(c, db, milestone, milestoneStatusApproved, userID, "", "Failed to approve milestone")
+	}
+}
+
+// rejectMilestoneHandler handles POST /api/milestones/:id/reject (requester)
+// The provider can resubmit after more work.
+func rejectMilestoneHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		milestone, userID, ok := loadMilestoneParty(c, db, false)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			Reason string `json:"reason"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		input.Reason = strings.TrimSpace(input.Reason)
+		if input.Reason == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Reason is required"})
+			return
+		}
+
+		moveMilestone(c, db, milestone, milestoneStatusRejected, userID, input.Reason, "Failed to reject milestone")
+	}
+}
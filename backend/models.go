@@ -25,6 +25,16 @@ type User struct {
 	PasswordHash string `gorm:"not null"`
 	Role         UserRole `gorm:"type:varchar(50);default:'user';not null"`
 	IsActive     bool     `gorm:"default:true;not null"`
+	EmailVerifiedAt *time.Time // Set once the user follows the verification link
+
+	// Two-factor authentication (TOTP)
+	TOTPSecret    string     `json:"-"` // Base32 secret; set at enrollment, active once TOTPEnabledAt is set
+	TOTPEnabledAt *time.Time
+	TOTPLastStep  int64      `json:"-"` // Last accepted time step, so a code can't be replayed
+
+	// Scheduling
+	TimeZone          string `gorm:"default:'UTC';not null"` // IANA zone availability is expressed in (e.g. "Europe/Lisbon")
+	CalendarTokenHash string `json:"-"` // SHA-256 of the token that opens the provider's iCalendar feed
 
 	// Relationships
 	Communities     []Community      `gorm:"many2many:user_communities;"`
@@ -45,8 +55,9 @@ type Community struct {
 	Description string `gorm:"type:text"`
 
 	// Domain configuration for multi-tenancy
-	Subdomain   string `gorm:"uniqueIndex"` // Subdomain for community (e.g., "sunset" -> sunset.commune.com)
-	CustomDomain string `gorm:"uniqueIndex"` // Custom domain (e.g., "sunset-apts.com")
+	// Both are unique when set; the partial unique indexes are created in migration 202402041304
+	Subdomain   string // Subdomain for community (e.g., "sunset" -> sunset.commune.com)
+	CustomDomain string // Custom domain (e.g., "sunset-apts.com")
 
 	// Location information
 	Address     string
@@ -57,6 +68,12 @@ type Community struct {
 
 	IsActive    bool   `gorm:"default:true;not null"`
 
+	// Payments
+	Currency       string `gorm:"type:varchar(3);default:'USD';not null"` // ISO 4217 code for the community's service requests
+	PlatformFeeBps int    `gorm:"default:0;not null"` // Fee kept from each payout, in basis points (100 = 1%)
+	TaxRateBps     int    `gorm:"default:0;not null"` // Tax included in prices, in basis points, shown on invoices
+	TaxLabel       string `gorm:"default:'Tax';not null"` // Name of the tax line on invoices (e.g. "VAT")
+
 	// Relationships
 	Users           []User           `gorm:"many2many:user_communities;"`
 	Posts           []Post           `gorm:"foreignKey:CommunityID"`
@@ -104,10 +121,14 @@ type ServiceRequest struct {
 	Category       string `gorm:"index"`
 	RequesterID    uint   `gorm:"not null;index"`
 	CommunityID    uint   `gorm:"not null;index"`
-	Status         string `gorm:"type:varchar(50);default:'open';not null;index"` // open, in_progress, completed, cancelled
+	Status         string `gorm:"type:varchar(50);default:'open';not null;index"` // open, in_progress, awaiting_confirmation, disputed, completed, cancelled
 	Budget         float64
 	AcceptedOfferID *uint  `gorm:"index"` // References ServiceOffer.ID - nullable until offer is accepted
-	CompletedAt    *time.Time
+	WorkDoneAt     *time.Time // When the provider marked the work done
+	ConfirmationDueAt *time.Time `gorm:"index"` // Auto-confirmed after this unless confirmed or disputed
+	CompletedAt    *time.Time // Set once completion is confirmed
+	RecurringRequestID *uint  `gorm:"index"` // Set when posted by a recurring request
+	ScheduledFor   *time.Time // The occurrence of the recurring request it was posted for
 
 	// Relationships
 	Requester      User           `gorm:"foreignKey:RequesterID"`
@@ -115,6 +136,7 @@ type ServiceRequest struct {
 	ServiceOffers  []ServiceOffer `gorm:"foreignKey:ServiceRequestID"`
 	Comments       []Comment      `gorm:"foreignKey:ServiceRequestID"`
 	AcceptedOffer  *ServiceOffer  `gorm:"foreignKey:AcceptedOfferID;constraint:OnDelete:SET NULL"` // Set to NULL if offer is deleted
+	Disputes       []Dispute      `gorm:"foreignKey:ServiceRequestID"`
 }
 
 // ServiceOffer represents an offer by a service provider for a service request
@@ -126,11 +148,360 @@ type ServiceOffer struct {
 	ProposedPrice    float64
 	EstimatedDuration string
 	Status           string `gorm:"type:varchar(50);default:'pending';not null"` // pending, accepted, rejected, withdrawn
+	AgreedRevisionID *uint  // The revision whose terms were accepted - nil until the offer is accepted
+	MilestonesApprovedAt *time.Time // When the requester approved the milestone plan - the plan is locked from then on
 
 	// Relationships
 	ServiceRequest ServiceRequest `gorm:"foreignKey:ServiceRequestID"`
 	Provider       User           `gorm:"foreignKey:ProviderID"`
 	Comments       []Comment      `gorm:"foreignKey:ServiceOfferID"`
+	Revisions      []OfferRevision `gorm:"foreignKey:ServiceOfferID"`
+	AgreedRevision *OfferRevision  `gorm:"foreignKey:AgreedRevisionID"`
+	Milestones     []Milestone     `gorm:"foreignKey:ServiceOfferID"`
+}
+
+// Milestone is one phase of the work agreed in an accepted offer, for jobs
+// paid and signed off in stages
+type Milestone struct {
+	gorm.Model
+	ServiceOfferID uint   `gorm:"not null;index"`
+	Position       int    `gorm:"not null"` // Order within the offer, starting at 1
+	Description    string `gorm:"type:text;not null"`
+	Amount         int64  `gorm:"not null;default:0"`                     // In integer minor units of Currency
+	Currency       string `gorm:"type:varchar(3);default:'USD';not null"` // Currency of the request's escrow, or of its community
+	DueDate        *time.Time
+	Status         string `gorm:"type:varchar(20);default:'pending';not null;index"` // pending, submitted, approved, rejected
+	SubmittedAt    *time.Time
+	ApprovedAt     *time.Time
+
+	// Relationships
+	ServiceOffer ServiceOffer `gorm:"foreignKey:ServiceOfferID"`
+	Comments     []Comment    `gorm:"foreignKey:MilestoneID"`
+}
+
+// OfferRevision is one version of an offer's terms during negotiation.
+// Revisions are never changed; a counter-offer adds the next version.
+type OfferRevision struct {
+	ID                uint      `gorm:"primaryKey"`
+	CreatedAt         time.Time
+	ServiceOfferID    uint    `gorm:"not null;uniqueIndex:idx_offer_revisions_version"`
+	Version           int     `gorm:"not null;uniqueIndex:idx_offer_revisions_version"` // 1 is the provider's original offer
+	AuthorID          uint    `gorm:"not null;index"`
+	Price             float64
+	Description       string  `gorm:"type:text;not null"` // Scope of work
+	EstimatedDuration string
+	Message           string  `gorm:"type:text"` // Note sent with a counter-offer
+
+	// Relationships
+	Author User `gorm:"foreignKey:AuthorID"`
+}
+
+// Dispute is raised by a requester who doesn't accept work marked done.
+// A community moderator mediates it and decides the outcome.
+type Dispute struct {
+	gorm.Model
+	ServiceRequestID uint   `gorm:"not null;index"`
+	RaisedByID       uint   `gorm:"not null;index"`
+	Reason           string `gorm:"type:text;not null"`
+	Status           string `gorm:"type:varchar(20);default:'open';not null;index"` // open, mediating, resolved
+	MediatorID       *uint  `gorm:"index"` // Moderator handling the dispute
+	Resolution       string `gorm:"type:varchar(20)"` // completed, rework, cancelled
+	ResolutionNote   string `gorm:"type:text"`
+	ResolvedAt       *time.Time
+
+	// Relationships
+	ServiceRequest ServiceRequest `gorm:"foreignKey:ServiceRequestID"`
+	RaisedBy       User           `gorm:"foreignKey:RaisedByID"`
+	Mediator       *User          `gorm:"foreignKey:MediatorID"`
+}
+
+// LedgerAccount holds money in one currency: a user's balance, the escrow of
+// a service request, a community's platform fees, or the outside world
+// (money that came in or went out through the payment provider)
+type LedgerAccount struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time
+	Kind      string `gorm:"type:varchar(20);not null;uniqueIndex:idx_ledger_accounts_owner"` // user, escrow, platform_fees, external
+	OwnerID   uint   `gorm:"not null;uniqueIndex:idx_ledger_accounts_owner"` // User, ServiceRequest or Community ID; 0 for external
+	Currency  string `gorm:"type:varchar(3);not null;uniqueIndex:idx_ledger_accounts_owner"`
+}
+
+// LedgerTransaction is one movement of money. Its entries always sum to zero.
+// Transactions and entries are append-only; migration 202402041316 adds
+// triggers that reject updates and deletes.
+type LedgerTransaction struct {
+	ID               uint      `gorm:"primaryKey"`
+	CreatedAt        time.Time `gorm:"index"`
+	Kind             string    `gorm:"type:varchar(20);not null;index"` // escrow_hold, escrow_release, escrow_refund
+	ServiceRequestID *uint     `gorm:"index"`
+	CommunityID      *uint     `gorm:"index"`
+	ActorID          *uint     // nil for automatic movements
+	PaymentReference string    // Charge or refund ID at the payment provider
+	Description      string
+
+	// Relationships
+	Entries []LedgerEntry `gorm:"foreignKey:TransactionID"`
+}
+
+// LedgerEntry adds an amount to (positive) or takes it from (negative) one
+// account. Amounts are integer minor units of the account's currency.
+type LedgerEntry struct {
+	ID            uint      `gorm:"primaryKey"`
+	CreatedAt     time.Time
+	TransactionID uint      `gorm:"not null;index"`
+	AccountID     uint      `gorm:"not null;index"`
+	Amount        int64     `gorm:"not null"`
+	Currency      string    `gorm:"type:varchar(3);not null"`
+	Memo          string
+
+	// Relationships
+	Transaction LedgerTransaction `gorm:"foreignKey:TransactionID"`
+	Account     LedgerAccount     `gorm:"foreignKey:AccountID"`
+}
+
+// Invoice is issued when a service request with an accepted offer completes.
+// The invoice and receipt PDFs are rendered once and kept; migration
+// 202402041317 adds triggers that reject updates and deletes. Amounts are
+// integer minor units of Currency.
+type Invoice struct {
+	ID               uint      `gorm:"primaryKey"`
+	CreatedAt        time.Time
+	ServiceRequestID uint      `gorm:"not null;uniqueIndex"`
+	ServiceOfferID   uint      `gorm:"not null"`
+	CommunityID      uint      `gorm:"not null;index"`
+	ProviderID       uint      `gorm:"not null;uniqueIndex:idx_invoices_provider_sequence"`
+	RequesterID      uint      `gorm:"not null;index"`
+	Sequence         int       `gorm:"not null;uniqueIndex:idx_invoices_provider_sequence"` // Counts up from 1 per provider
+	Number           string    `gorm:"not null"` // e.g. INV-12-00001
+	Currency         string    `gorm:"type:varchar(3);not null"`
+	Subtotal         int64     `gorm:"not null"`
+	TaxLabel         string
+	TaxRateBps       int       `gorm:"not null"`
+	Tax              int64     `gorm:"not null"`
+	Total            int64     `gorm:"not null"`
+	InvoicePDF       []byte    `gorm:"not null" json:"-"`
+	ReceiptPDF       []byte    `gorm:"not null" json:"-"`
+
+	// Relationships
+	ServiceRequest ServiceRequest `gorm:"foreignKey:ServiceRequestID"`
+	Provider       User           `gorm:"foreignKey:ProviderID"`
+	Requester      User           `gorm:"foreignKey:RequesterID"`
+}
+
+// InvoiceCounter is the last invoice sequence used for a provider
+type InvoiceCounter struct {
+	ProviderID   uint `gorm:"primaryKey;autoIncrement:false"`
+	LastSequence int  `gorm:"not null;default:0"`
+}
+
+// AvailabilityWindow is a weekly period when a provider takes appointments,
+// in the provider's time zone
+type AvailabilityWindow struct {
+	ID          uint `gorm:"primaryKey"`
+	ProviderID  uint `gorm:"not null;index"`
+	Weekday     int  `gorm:"not null"` // 0 = Sunday
+	StartMinute int  `gorm:"not null"` // Minutes after local midnight
+	EndMinute   int  `gorm:"not null"` // Up to 1440; windows don't cross midnight
+}
+
+// AvailabilityBlackout is a run of days a provider takes no appointments,
+// e.g. a holiday. Dates are YYYY-MM-DD in the provider's time zone.
+type AvailabilityBlackout struct {
+	gorm.Model
+	ProviderID uint   `gorm:"not null;index"`
+	StartsOn   string `gorm:"type:varchar(10);not null"`
+	EndsOn     string `gorm:"type:varchar(10);not null"` // Inclusive
+	Reason     string
+}
+
+// Appointment is a booked visit for a service request in progress. Times are
+// stored in UTC; TimeZone is the provider's zone when it was booked.
+type Appointment struct {
+	gorm.Model
+	ServiceRequestID uint       `gorm:"not null;index"`
+	ProviderID       uint       `gorm:"not null;index:idx_appointments_provider_time"`
+	RequesterID      uint       `gorm:"not null;index"`
+	StartsAt         time.Time  `gorm:"not null;index:idx_appointments_provider_time"`
+	EndsAt           time.Time  `gorm:"not null"`
+	TimeZone         string     `gorm:"not null"`
+	Status           string     `gorm:"type:varchar(50);default:'scheduled';not null"` // scheduled, cancelled
+	Sequence         int        `gorm:"default:0;not null"` // Bumped on each reschedule, for calendar clients
+	CancelledAt      *time.Time
+
+	// Relationships
+	ServiceRequest ServiceRequest `gorm:"foreignKey:ServiceRequestID"`
+	Provider       User           `gorm:"foreignKey:ProviderID"`
+	Requester      User           `gorm:"foreignKey:RequesterID"`
+}
+
+// RecurringRequest is a template that posts a new service request on every
+// occurrence of its recurrence rule. Occurrences are computed from StartsAt in
+// TimeZone; NextRunAt is the next one to post, or nil once ended.
+type RecurringRequest struct {
+	gorm.Model
+	RequesterID    uint       `gorm:"not null;index"`
+	CommunityID    uint       `gorm:"not null;index"`
+	Title          string     `gorm:"not null"`
+	Description    string     `gorm:"type:text;not null"`
+	Category       string
+	Budget         float64
+	RRule          string     `gorm:"column:rrule;not null"` // Subset of RFC 5545, e.g. FREQ=WEEKLY;BYDAY=MO
+	TimeZone       string     `gorm:"not null"`
+	StartsAt       time.Time  `gorm:"not null"` // First occurrence
+	NextRunAt      *time.Time `gorm:"index"`
+	Status         string     `gorm:"type:varchar(50);default:'active';not null;index"` // active, paused, ended
+	RebookProvider bool       `gorm:"default:false;not null"` // Re-book the provider of the last accepted occurrence
+
+	// Relationships
+	Requester User      `gorm:"foreignKey:RequesterID"`
+	Community Community `gorm:"foreignKey:CommunityID"`
+}
+
+// Conversation is a private message thread between members of a community,
+// optionally about a service request
+type Conversation struct {
+	gorm.Model
+	CommunityID      uint      `gorm:"not null;index"`
+	ServiceRequestID *uint     `gorm:"index"`
+	LastMessageAt    time.Time `gorm:"not null;index"` // When the last message was sent, or when it was started
+
+	// Relationships
+	ServiceRequest *ServiceRequest           `gorm:"foreignKey:ServiceRequestID"`
+	Participants   []ConversationParticipant `gorm:"foreignKey:ConversationID"`
+}
+
+// ConversationParticipant is a member of a conversation and how far they have read
+type ConversationParticipant struct {
+	ConversationID    uint      `gorm:"primaryKey"`
+	UserID            uint      `gorm:"primaryKey;index"`
+	LastReadMessageID uint      `gorm:"default:0;not null"` // Read receipt: every message up to this ID has been read
+	LastReadAt        *time.Time
+	JoinedAt          time.Time `gorm:"autoCreateTime"`
+
+	// Foreign keys
+	User User `gorm:"foreignKey:UserID"`
+}
+
+// Message is one message in a conversation
+type Message struct {
+	ID             uint      `gorm:"primaryKey"`
+	CreatedAt      time.Time `gorm:"index"`
+	ConversationID uint      `gorm:"not null;index"`
+	SenderID       uint      `gorm:"not null;index"`
+	Body           string    `gorm:"type:text;not null"`
+
+	// Relationships
+	Sender User `gorm:"foreignKey:SenderID"`
+}
+
+// Notification is an entry in a user's inbox
+type Notification struct {
+	ID          uint       `gorm:"primaryKey"`
+	CreatedAt   time.Time  `gorm:"index"`
+	UserID      uint       `gorm:"not null;index"`
+	Type        string     `gorm:"type:varchar(50);not null;index"` // offer_received, join_request_approved, message_received, ...
+	Title       string     `gorm:"not null"`
+	Body        string     `gorm:"type:text"`
+	Link        string     // Frontend path to open, e.g. /service-requests/12
+	ReadAt      *time.Time `gorm:"index"`
+	EmailStatus string     `gorm:"type:varchar(20);default:'none';not null;index"` // none, pending, sent, skipped (read before it was emailed)
+	EmailedAt   *time.Time
+}
+
+// NotificationPreference is the channel a user chose for one notification type
+type NotificationPreference struct {
+	UserID  uint   `gorm:"primaryKey"`
+	Type    string `gorm:"primaryKey;type:varchar(50)"`
+	Channel string `gorm:"type:varchar(20);not null"` // in_app, email, off
+}
+
+// NotificationSettings holds a user's quiet hours and digest choice, in their time zone
+type NotificationSettings struct {
+	UserID          uint `gorm:"primaryKey"`
+	QuietHoursStart *int // Minutes after local midnight; nil for no quiet hours
+	QuietHoursEnd   *int // May be before the start, for quiet hours over midnight
+	DailyDigest     bool `gorm:"default:false;not null"` // Email one summary a day instead of each notification
+	DigestHour      int  `gorm:"default:8;not null"`     // Local hour the digest is sent
+	LastDigestAt    *time.Time
+	UpdatedAt       time.Time
+}
+
+// WebhookEndpoint is a URL a community's events are posted to
+type WebhookEndpoint struct {
+	ID          uint      `gorm:"primaryKey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	CommunityID uint   `gorm:"not null;index"`
+	URL         string `gorm:"not null"`
+	Description string
+	EventTypes  string `gorm:"not null"`           // Comma-separated: service_request.created, service_request.accepted, ...
+	Secret      string `gorm:"not null" json:"-"` // Key of the HMAC signature on each delivery
+	IsActive    bool   `gorm:"default:true;not null"`
+	CreatedByID uint   `gorm:"not null"`
+}
+
+// WebhookDelivery is one event sent, or to be sent, to a webhook endpoint
+type WebhookDelivery struct {
+	ID             uint      `gorm:"primaryKey"`
+	CreatedAt      time.Time `gorm:"index"`
+	EndpointID     uint      `gorm:"not null;index"`
+	EventID        string    `gorm:"type:varchar(64);not null;index"` // Same on redeliveries, so receivers can drop duplicates
+	EventType      string    `gorm:"type:varchar(50);not null"`
+	Payload        string    `gorm:"type:text;not null"`
+	Status         string    `gorm:"type:varchar(20);default:'pending';not null;index"` // pending, succeeded, failed
+	Attempts       int       `gorm:"default:0;not null"`
+	NextAttemptAt  *time.Time `gorm:"index"` // nil once it has succeeded or failed
+	LastAttemptAt  *time.Time
+	ResponseStatus int    // HTTP status of the last attempt; 0 if there was no response
+	ResponseBody   string `gorm:"type:text"` // Start of the last response's body
+	Error          string // Why the last attempt failed
+	DeliveredAt    *time.Time
+	RedeliveryOfID *uint // The delivery this one resends
+}
+
+// Job is a unit of background work in the job queue
+type Job struct {
+	ID          uint      `gorm:"primaryKey"`
+	CreatedAt   time.Time
+	UpdatedAt   time.Time
+	Kind        string    `gorm:"type:varchar(100);not null;index"` // Which handler runs it, e.g. send_email
+	Payload     string    `gorm:"type:text;not null"`               // JSON arguments for the handler
+	Status      string    `gorm:"type:varchar(20);default:'queued';not null;index:idx_jobs_status_run_at"` // queued, running, succeeded, dead
+	RunAt       time.Time `gorm:"not null;index:idx_jobs_status_run_at"` // Not run before this; pushed back between retries
+	Attempts    int       `gorm:"default:0;not null"`
+	MaxAttempts int       `gorm:"not null"`
+	UniqueKey   *string   `gorm:"type:varchar(191);uniqueIndex"` // While set, no other job with the key can be queued; cleared when the job finishes
+	LockedBy    string    // Worker running it
+	LockedAt    *time.Time
+	LastError   string    `gorm:"type:text"`
+	FinishedAt  *time.Time
+}
+
+// JobSchedule is a job queued on a cron schedule. The schedules themselves are
+// defined in code; the table records when each is next due, so instances
+// agree on it and it survives restarts.
+type JobSchedule struct {
+	Name      string     `gorm:"primaryKey;type:varchar(100)"`
+	Spec      string     `gorm:"not null"` // Cron expression or @every <duration>
+	NextRunAt time.Time  `gorm:"not null"`
+	LastRunAt *time.Time
+	LastJobID *uint
+	UpdatedAt time.Time
+}
+
+// StatusTransition records one status change of a service request or offer
+type StatusTransition struct {
+	ID         uint      `gorm:"primaryKey"`
+	CreatedAt  time.Time `gorm:"index"`
+	EntityType string    `gorm:"type:varchar(50);not null;index:idx_status_transitions_entity"` // service_request, service_offer
+	EntityID   uint      `gorm:"not null;index:idx_status_transitions_entity"`
+	FromStatus string    `gorm:"type:varchar(50);not null"`
+	ToStatus   string    `gorm:"type:varchar(50);not null"`
+	ActorID    *uint     `gorm:"index"` // nil for automatic transitions
+	Reason     string
+
+	// Relationships
+	Actor *User `gorm:"foreignKey:ActorID"`
 }
 
 // Comment represents a comment on a post, service request, or service offer
@@ -141,13 +512,20 @@ type Comment struct {
 	PostID           *uint  `gorm:"index"`
 	ServiceRequestID *uint  `gorm:"index"`
 	ServiceOfferID   *uint  `gorm:"index"`
+	MilestoneID      *uint  `gorm:"index"`
 	ParentCommentID  *uint  `gorm:"index"` // For nested comments/replies
 
+	// Tombstone fields - removed comments stay in the tree so replies keep their parent
+	IsRemoved   bool `gorm:"default:false;not null"`
+	RemovedByID *uint
+	RemovedAt   *time.Time
+
 	// Relationships
 	Author         User            `gorm:"foreignKey:AuthorID"`
 	Post           *Post           `gorm:"foreignKey:PostID"`
 	ServiceRequest *ServiceRequest `gorm:"foreignKey:ServiceRequestID"`
 	ServiceOffer   *ServiceOffer   `gorm:"foreignKey:ServiceOfferID"`
+	Milestone      *Milestone      `gorm:"foreignKey:MilestoneID"`
 	ParentComment  *Comment        `gorm:"foreignKey:ParentCommentID"`
 	Replies        []Comment       `gorm:"foreignKey:ParentCommentID"`
 }
@@ -167,6 +545,107 @@ type Rating struct {
 	ServiceRequest ServiceRequest `gorm:"foreignKey:ServiceRequestID"`
 }
 
+// Session represents a signed-in device or browser
+// Access tokens carry the session ID and stop working as soon as the session is revoked.
+type Session struct {
+	gorm.Model
+	UserID       uint      `gorm:"not null;index"`
+	UserAgent    string
+	IPAddress    string
+	LastUsedAt   time.Time
+	ExpiresAt    time.Time `gorm:"not null"`
+	RevokedAt    *time.Time `gorm:"index"`
+	RevokeReason string
+
+	// Relationships
+	User          User           `gorm:"foreignKey:UserID"`
+	RefreshTokens []RefreshToken `gorm:"foreignKey:SessionID"`
+}
+
+// RefreshToken is a single-use token in a session's rotation chain
+// Only the SHA-256 hash of the token is stored.
+type RefreshToken struct {
+	gorm.Model
+	SessionID uint      `gorm:"not null;index"`
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+
+	// Relationships
+	Session Session `gorm:"foreignKey:SessionID"`
+}
+
+// UserToken is a single-use token emailed to a user (password reset, email verification)
+// Only the SHA-256 hash of the token is stored
+type UserToken struct {
+	gorm.Model
+	UserID    uint      `gorm:"not null;index"`
+	Purpose   string    `gorm:"type:varchar(50);not null;index"` // password_reset, verify_email
+	TokenHash string    `gorm:"uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"not null"`
+	UsedAt    *time.Time
+
+	// Relationships
+	User User `gorm:"foreignKey:UserID"`
+}
+
+// RecoveryCode is a single-use 2FA backup code; only its hash is stored
+type RecoveryCode struct {
+	gorm.Model
+	UserID   uint   `gorm:"not null;index"`
+	CodeHash string `gorm:"not null;index"`
+	UsedAt   *time.Time
+}
+
+// MFAPolicy makes two-factor authentication mandatory for a global role
+type MFAPolicy struct {
+	gorm.Model
+	Role     UserRole `gorm:"type:varchar(50);uniqueIndex;not null"`
+	Required bool     `gorm:"default:false;not null"`
+}
+
+// PersonalAccessToken is a long-lived API token for scripts and integrations
+// Only the SHA-256 hash is stored; Prefix is kept so users can tell tokens apart
+type PersonalAccessToken struct {
+	gorm.Model
+	UserID     uint      `gorm:"not null;index"`
+	Name       string    `gorm:"not null"`
+	TokenHash  string    `gorm:"uniqueIndex;not null" json:"-"`
+	Prefix     string    `gorm:"not null"`
+	Scopes     string    `gorm:"not null"` // Comma-separated: read_only, marketplace, admin
+	ExpiresAt  time.Time `gorm:"not null"`
+	LastUsedAt *time.Time
+	LastUsedIP string
+	RevokedAt  *time.Time `gorm:"index"`
+}
+
+// LoginEvent records a login attempt, successful or not
+type LoginEvent struct {
+	gorm.Model
+	UserID    *uint  `gorm:"index"` // nil when the email matched no user
+	Email     string `gorm:"index;not null"`
+	IPAddress string `gorm:"index"`
+	UserAgent string
+	Success   bool   `gorm:"not null"`
+	Reason    string `gorm:"type:varchar(50);not null"` // success, invalid_credentials, inactive, throttled, mfa_pending, mfa_failed
+}
+
+// AuditEvent records an administrative action. Rows are append-only:
+// there is no UpdatedAt or DeletedAt, and database triggers reject changes.
+type AuditEvent struct {
+	ID          uint      `gorm:"primaryKey"`
+	CreatedAt   time.Time `gorm:"index"`
+	ActorID     *uint     `gorm:"index"` // nil for system actions
+	ActorEmail  string
+	Action      string    `gorm:"type:varchar(100);not null;index"` // e.g. user.update, community.member_role_change
+	TargetType  string    `gorm:"type:varchar(50);not null;index"`  // user, community, membership, join_request, mfa_policy, lockout
+	TargetID    uint      `gorm:"index"`
+	CommunityID *uint     `gorm:"index"`
+	Changes     string    `gorm:"type:text"` // JSON object of field -> {from, to}
+	IPAddress   string
+	UserAgent   string
+}
+
 // JoinRequest represents a request to join a community
 type JoinRequest struct {
 	gorm.Model
@@ -0,0 +1,660 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Notifications
+//
+// Things that need a user's attention (an offer on their request, being let
+// into a community) are recorded in their inbox. Each notification type goes
+// to a channel the user picks: in_app (the inbox only), email (the inbox and
+// an email) or off. Emails are sent by a background job, which holds them
+// back during the user's quiet hours and, when the user asked for a daily
+// digest, gathers them into one email a day. Notifications read in the app
+// before their email goes out aren't emailed.
+
+// Notification channels
+const (
+	notificationChannelInApp = "in_app"
+	notificationChannelEmail = "email"
+	notificationChannelOff   = "off"
+)
+
+// Notification types
+const (
+	notificationOfferReceived       = "offer_received"
+	notificationOfferCountered      = "offer_countered"
+	notificationOfferAccepted       = "offer_accepted"
+	notificationOfferRejected       = "offer_rejected"
+	notificationOfferExpired        = "offer_expired"
+	notificationRequestStatus       = "request_status_changed"
+	notificationJoinRequestReceived = "join_request_received"
+	notificationJoinRequestApproved = "join_request_approved"
+	notificationJoinRequestRejected = "join_request_rejected"
+	notificationMessageReceived     = "message_received"
+)
+
+// Email delivery states of a notification
+const (
+	notificationEmailNone    = "none"
+	notificationEmailPending = "pending"
+	notificationEmailSent    = "sent"
+	notificationEmailSkipped = "skipped"
+)
+
+// notificationType describes a kind of notification and where it goes by default
+type notificationType struct {
+	Type           string `json:"type"`
+	Description    string `json:"description"`
+	DefaultChannel string `json:"default_channel"`
+}
+
+// notificationTypes lists every notification type, in the order settings show them
+var notificationTypes = []notificationType{
+	{notificationOfferReceived, "Someone makes an offer on your service request", notificationChannelEmail},
+	{notificationOfferCountered, "The other side of an offer proposes new terms", notificationChannelInApp},
+	{notificationOfferAccepted, "Your offer is accepted", notificationChannelEmail},
+	{notificationOfferRejected, "Your offer is rejected", notificationChannelInApp},
+	{notificationOfferExpired, "Your offer expires without an answer", notificationChannelInApp},
+	{notificationRequestStatus, "A service request you're part of changes status", notificationChannelInApp},
+	{notificationJoinRequestReceived, "Someone asks to join a community you moderate", notificationChannelInApp},
+	{notificationJoinRequestApproved, "You're let into a community", notificationChannelEmail},
+	{notificationJoinRequestRejected, "Your request to join a community is declined", notificationChannelInApp},
+	{notificationMessageReceived, "You receive a direct message", notificationChannelInApp},
+}
+
+const (
+	// notificationEmailInterval is how often pending notification emails are sent
+	notificationEmailInterval = time.Minute
+	// defaultDigestHour is the local hour daily digests go out unless the user picks another
+	defaultDigestHour = 8
+	// maxNotificationBody is the longest body kept, in bytes; message previews are cut to it
+	maxNotificationBody = 280
+)
+
+// errNotificationsChanged means pending notifications were emailed by someone else
+var errNotificationsChanged = errors.New("notifications changed while being emailed")
+
+// findNotificationType returns the notification type with the given name
+func findNotificationType(name string) (notificationType, bool) {
+	for _, t := range notificationTypes {
+		if t.Type == name {
+			return t, true
+		}
+	}
+	return notificationType{}, false
+}
+
+// isValidNotificationChannel reports whether channel can be chosen for a type
+func isValidNotificationChannel(channel string) bool {
+	return channel == notificationChannelInApp || channel == notificationChannelEmail || channel == notificationChannelOff
+}
+
+// notificationChannel returns the channel the user chose for a type, or its default
+func notificationChannel(db *gorm.DB, userID uint, notificationType string) (string, error) {
+	var preference NotificationPreference
+	err := db.Where("user_id = ? AND type = ?", userID, notificationType).First(&preference).Error
+	if err == nil {
+		return preference.Channel, nil
+	}
+	if err != gorm.ErrRecordNotFound {
+		return "", err
+	}
+
+	t, _ := findNotificationType(notificationType)
+	return t.DefaultChannel, nil
+}
+
+// notify puts a notification in the user's inbox, unless they turned the type
+// off, and marks it for email when that's the channel they chose. Inside a
+// transaction started by transaction, the user hears of it once it commits.
+func notify(tx *gorm.DB, userID uint, notificationType, title, body, link string) error {
+	if userID == 0 {
+		return nil
+	}
+
+	channel, err := notificationChannel(tx, userID, notificationType)
+	if err != nil || channel == notificationChannelOff {
+		return err
+	}
+
+	if len(body) > maxNotificationBody {
+		body = strings.ToValidUTF8(body[:maxNotificationBody-3], "") + "..."
+	}
+
+	notification := Notification{
+		UserID:      userID,
+		Type:        notificationType,
+		Title:       title,
+		Body:        body,
+		Link:        link,
+		EmailStatus: notificationEmailNone,
+	}
+	if channel == notificationChannelEmail {
+		notification.EmailStatus = notificationEmailPending
+	}
+	if err := tx.Create(&notification).Error; err != nil {
+		return err
+	}
+
+	queueEvent(tx, eventNotificationCreated, []uint{userID}, map[string]interface{}{
+		"notification_id":   notification.ID,
+		"notification_type": notificationType,
+	})
+	return nil
+}
+
+// statusLabel turns a status such as awaiting_confirmation into words
+func statusLabel(status string) string {
+	return strings.ReplaceAll(status, "_", " ")
+}
+
+// notifyServiceRequestStatus tells the requester and the accepted provider,
+// other than whoever made the change, that a request changed status
+func notifyServiceRequestStatus(tx *gorm.DB, request *ServiceRequest, to string, providerID uint, actorID *uint) error {
+	for _, userID := range []uint{request.RequesterID, providerID} {
+		if actorID != nil && userID == *actorID {
+			continue
+		}
+		if err := notify(tx, userID, notificationRequestStatus,
+			fmt.Sprintf("%q is now %s", request.Title, statusLabel(to)), "",
+			fmt.Sprintf("/service-requests/%d", request.ID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// notifyOfferStatus tells a provider their offer was accepted, rejected or
+// expired, unless they made the change themselves
+func notifyOfferStatus(tx *gorm.DB, offer *ServiceOffer, request *ServiceRequest, actorID *uint) error {
+	if actorID != nil && *actorID == offer.ProviderID {
+		return nil
+	}
+
+	link := fmt.Sprintf("/service-requests/%d", request.ID)
+	switch offer.Status {
+	case offerStatusAccepted:
+		return notify(tx, offer.ProviderID, notificationOfferAccepted,
+			fmt.Sprintf("Your offer on %q was accepted", request.Title), "", link)
+	case offerStatusRejected:
+		return notify(tx, offer.ProviderID, notificationOfferRejected,
+			fmt.Sprintf("Your offer on %q was rejected", request.Title), "", link)
+	case offerStatusExpired:
+		return notify(tx, offer.ProviderID, notificationOfferExpired,
+			fmt.Sprintf("Your offer on %q expired", request.Title), "", link)
+	}
+	return nil
+}
+
+// loadNotificationSettings returns the user's settings, or the defaults when they have none
+func loadNotificationSettings(db *gorm.DB, userID uint) (*NotificationSettings, error) {
+	settings := NotificationSettings{UserID: userID, DigestHour: defaultDigestHour}
+	err := db.Where("user_id = ?", userID).First(&settings).Error
+	if err != nil && err != gorm.ErrRecordNotFound {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// inQuietHours reports whether local (in the user's time zone) falls in their quiet hours
+func (s *NotificationSettings) inQuietHours(local time.Time) bool {
+	if s.QuietHoursStart == nil || s.QuietHoursEnd == nil || *s.QuietHoursStart == *s.QuietHoursEnd {
+		return false
+	}
+
+	minute := local.Hour()*60 + local.Minute()
+	start, end := *s.QuietHoursStart, *s.QuietHoursEnd
+	if start < end {
+		return minute >= start && minute < end
+	}
+	// Over midnight, e.g. 22:00 to 07:00
+	return minute >= start || minute < end
+}
+
+// digestDue reports whether today's digest hasn't been sent and its hour has come
+func (s *NotificationSettings) digestDue(local time.Time) bool {
+	if local.Hour() < s.DigestHour {
+		return false
+	}
+	if s.LastDigestAt == nil {
+		return true
+	}
+	last := s.LastDigestAt.In(local.Location())
+	return last.Year() != local.Year() || last.YearDay() != local.YearDay()
+}
+
+// deliverNotificationEmails sends the notification emails that are due. Each
+// user gets one email for what's pending: the notification itself, a list
+// when there are several, or their daily digest.
+func deliverNotificationEmails(db *gorm.DB) error {
+	// Read in the app in the meantime, so no need to email
+	if err := db.Model(&Notification{}).
+		Where("email_status = ? AND read_at IS NOT NULL", notificationEmailPending).
+		Update("email_status", notificationEmailSkipped).Error; err != nil {
+		return err
+	}
+
+	var userIDs []uint
+	if err := db.Model(&Notification{}).
+		Where("email_status = ?", notificationEmailPending).
+		Distinct("user_id").
+		Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+
+	for _, userID := range userIDs {
+		if err := deliverUserNotificationEmails(db, userID); err != nil {
+			log.Printf("Failed to send notification emails to user %d: %v", userID, err)
+		}
+	}
+	return nil
+}
+
+// deliverUserNotificationEmails sends one user's pending notification emails, if they are due
+func deliverUserNotificationEmails(db *gorm.DB, userID uint) error {
+	var user User
+	if err := db.First(&user, userID).Error; err != nil {
+		return err
+	}
+	settings, err := loadNotificationSettings(db, userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	local := now.In(userLocation(&user))
+	if settings.inQuietHours(local) || (settings.DailyDigest && !settings.digestDue(local)) {
+		return nil
+	}
+
+	var pending []Notification
+	if err := db.Where("user_id = ? AND email_status = ?", userID, notificationEmailPending).
+		Order("id").
+		Find(&pending).Error; err != nil {
+		return err
+	}
+	if len(pending) == 0 {
+		return nil
+	}
+
+	ids := make([]uint, len(pending))
+	for i := range pending {
+		ids[i] = pending[i].ID
+	}
+
+	// Mark them as sent as the email is queued, so they can't be sent twice
+	err = db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&Notification{}).
+			Where("id IN ? AND email_status = ?", ids, notificationEmailPending).
+			Updates(map[string]interface{}{"email_status": notificationEmailSent, "emailed_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected != int64(len(ids)) {
+			return errNotificationsChanged
+		}
+		if settings.DailyDigest {
+			if err := tx.Model(&NotificationSettings{}).Where("user_id = ?", userID).Update("last_digest_at", now).Error; err != nil {
+				return err
+			}
+		}
+		return sendEmail(tx, notificationEmail(&user, pending, settings.DailyDigest))
+	})
+	if err == errNotificationsChanged {
+		// Another instance got there first
+		return nil
+	}
+	return err
+}
+
+// notificationEmail writes the email for a user's pending notifications
+func notificationEmail(user *User, notifications []Notification, digest bool) EmailMessage {
+	var b strings.Builder
+	b.WriteString("Hi " + user.Name + ",\n\n")
+
+	subject := notifications[0].Title
+	if len(notifications) == 1 && !digest {
+		n := notifications[0]
+		if n.Body != "" {
+			b.WriteString(n.Body + "\n\n")
+		}
+		if n.Link != "" {
+			b.WriteString(appURL(n.Link, nil) + "\n\n")
+		}
+	} else {
+		subject = fmt.Sprintf("You have %d new notifications", len(notifications))
+		if digest {
+			subject = "Your daily Commune digest"
+		}
+		b.WriteString("Here's what happened since we last wrote:\n\n")
+		for _, n := range notifications {
+			b.WriteString("- " + n.Title + "\n")
+			if n.Link != "" {
+				b.WriteString("  " + appURL(n.Link, nil) + "\n")
+			}
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("You can choose which notifications you get by email in your notification settings:\n")
+	b.WriteString(appURL("/notifications", nil) + "\n")
+
+	return EmailMessage{To: user.Email, Subject: subject, Body: b.String()}
+}
+
+// Handlers
+
+// getNotificationsHandler handles GET /api/notifications
+// The current user's notifications, newest first (unread=true for unread ones only).
+func getNotificationsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		page, pageSize := parsePagination(c)
+
+		query := db.Model(&Notification{}).Where("user_id = ?", userID)
+		if c.Query("unread") == "true" {
+			query = query.Where("read_at IS NULL")
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count notifications"})
+			return
+		}
+
+		var unread int64
+		if err := db.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count notifications"})
+			return
+		}
+
+		var notifications []Notification
+		if err := query.Order("id DESC").
+			Offset((page - 1) * pageSize).
+			Limit(pageSize).
+			Find(&notifications).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch notifications"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"notifications": notifications,
+			"unread_count":  unread,
+			"page":          page,
+			"page_size":     pageSize,
+			"total":         total,
+		})
+	}
+}
+
+// getUnreadNotificationsHandler handles GET /api/notifications/unread-count
+func getUnreadNotificationsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		var unread int64
+		if err := db.Model(&Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&unread).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count notifications"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"unread_count": unread})
+	}
+}
+
+// markNotificationReadHandler handles POST /api/notifications/:id/read
+func markNotificationReadHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		notificationID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid notification ID"})
+			return
+		}
+
+		var notification Notification
+		if err := db.Where("id = ? AND user_id = ?", notificationID, userID).First(&notification).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Notification not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch notification"})
+			}
+			return
+		}
+
+		if notification.ReadAt == nil {
+			now := time.Now()
+			if err := db.Model(&notification).Update("read_at", now).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to mark notification read"})
+				return
+			}
+			notification.ReadAt = &now
+		}
+
+		c.JSON(http.StatusOK, notification)
+	}
+}
+
+// markAllNotificationsReadHandler handles POST /api/notifications/read-all
+func markAllNotificationsReadHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		result := db.Model(&Notification{}).
+			Where("user_id = ? AND read_at IS NULL", userID).
+			Update("read_at", time.Now())
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to mark notifications read"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"marked_read": result.RowsAffected})
+	}
+}
+
+// notificationPreferencesJSON is a user's notification settings as the client sees them
+type notificationPreferencesJSON struct {
+	Types []struct {
+		notificationType
+		Channel string `json:"channel"`
+	} `json:"types"`
+	QuietHoursStart string `json:"quiet_hours_start"` // HH:MM, empty for no quiet hours
+	QuietHoursEnd   string `json:"quiet_hours_end"`
+	DailyDigest     bool   `json:"daily_digest"`
+	DigestHour      int    `json:"digest_hour"`
+	TimeZone        string `json:"time_zone"`
+}
+
+// writeNotificationPreferences responds with the user's notification settings
+func writeNotificationPreferences(c *gin.Context, db *gorm.DB, user *User) {
+	var preferences []NotificationPreference
+	if err := db.Where("user_id = ?", user.ID).Find(&preferences).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch notification preferences"})
+		return
+	}
+	settings, err := loadNotificationSettings(db, user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch notification preferences"})
+		return
+	}
+
+	chosen := make(map[string]string)
+	for _, preference := range preferences {
+		chosen[preference.Type] = preference.Channel
+	}
+
+	response := notificationPreferencesJSON{
+		DailyDigest: settings.DailyDigest,
+		DigestHour:  settings.DigestHour,
+		TimeZone:    userLocation(user).String(),
+	}
+	if settings.QuietHoursStart != nil && settings.QuietHoursEnd != nil {
+		response.QuietHoursStart = formatClock(*settings.QuietHoursStart)
+		response.QuietHoursEnd = formatClock(*settings.QuietHoursEnd)
+	}
+	for _, t := range notificationTypes {
+		channel, ok := chosen[t.Type]
+		if !ok {
+			channel = t.DefaultChannel
+		}
+		response.Types = append(response.Types, struct {
+			notificationType
+			Channel string `json:"channel"`
+		}{t, channel})
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// getNotificationPreferencesHandler handles GET /api/notifications/preferences
+func getNotificationPreferencesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		user, _ := c.Get("user")
+		writeNotificationPreferences(c, db, user.(*User))
+	}
+}
+
+// updateNotificationPreferencesHandler handles PUT /api/notifications/preferences
+// Only the fields given change; channels maps notification types to channels.
+func updateNotificationPreferencesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		value, _ := c.Get("user")
+		user := value.(*User)
+
+		var input struct {
+			Channels        map[string]string `json:"channels"`
+			QuietHoursStart *string           `json:"quiet_hours_start"`
+			QuietHoursEnd   *string           `json:"quiet_hours_end"`
+			DailyDigest     *bool             `json:"daily_digest"`
+			DigestHour      *int              `json:"digest_hour"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		for name, channel := range input.Channels {
+			if _, ok := findNotificationType(name); !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Unknown notification type %q", name)})
+				return
+			}
+			if !isValidNotificationChannel(channel) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Channel must be in_app, email or off"})
+				return
+			}
+		}
+
+		settings, err := loadNotificationSettings(db, user.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch notification preferences"})
+			return
+		}
+
+		// Quiet hours are set or cleared together
+		if input.QuietHoursStart != nil || input.QuietHoursEnd != nil {
+			if input.QuietHoursStart == nil || input.QuietHoursEnd == nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "quiet_hours_start and quiet_hours_end must be given together"})
+				return
+			}
+			if *input.QuietHoursStart == "" && *input.QuietHoursEnd == "" {
+				settings.QuietHoursStart, settings.QuietHoursEnd = nil, nil
+			} else {
+				start, ok1 := parseClock(*input.QuietHoursStart)
+				end, ok2 := parseClock(*input.QuietHoursEnd)
+				if !ok1 || !ok2 || start >= 24*60 || end >= 24*60 || start == end {
+					c.JSON(http.StatusBadRequest, gin.H{"message": "Quiet hours must be two different times between 00:00 and 23:59, or both empty"})
+					return
+				}
+				settings.QuietHoursStart, settings.QuietHoursEnd = &start, &end
+			}
+		}
+		if input.DigestHour != nil {
+			if *input.DigestHour < 0 || *input.DigestHour > 23 {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "digest_hour must be between 0 and 23"})
+				return
+			}
+			settings.DigestHour = *input.DigestHour
+		}
+		if input.DailyDigest != nil {
+			settings.DailyDigest = *input.DailyDigest
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			for name, channel := range input.Channels {
+				if err := tx.Where(NotificationPreference{UserID: user.ID, Type: name}).
+					Assign(NotificationPreference{Channel: channel}).
+					FirstOrCreate(&NotificationPreference{}).Error; err != nil {
+					return err
+				}
+			}
+			// Save writes the nil quiet hours too, clearing them
+			return tx.Save(settings).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save notification preferences"})
+			return
+		}
+
+		writeNotificationPreferences(c, db, user)
+	}
+}
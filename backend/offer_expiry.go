@@ -0,0 +1,65 @@
+package main
+
+import (
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Offer expiry
+//
+// Pending offers nobody has acted on are expired after a while, so requesters
+// aren't left with stale offers and providers know where they stand. An offer
+// counts as untouched from its last change (a new revision resets the clock).
+// OFFER_EXPIRY_DAYS sets the period (default 30 days; 0 turns expiry off).
+
+const defaultOfferExpiryDays = 30
+
+// offerExpiry is how long a pending offer lasts without changes, set up in
+// main; 0 means offers don't expire
+var offerExpiry = defaultOfferExpiryDays * 24 * time.Hour
+
+// loadOfferExpiry reads the offer expiry period from the environment
+func loadOfferExpiry() time.Duration {
+	days := defaultOfferExpiryDays
+	if value := os.Getenv("OFFER_EXPIRY_DAYS"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			log.Printf("Invalid OFFER_EXPIRY_DAYS %q, using %d", value, defaultOfferExpiryDays)
+		} else {
+			days = parsed
+		}
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// expireStaleOffers expires the pending offers that haven't changed within offerExpiry
+func expireStaleOffers(db *gorm.DB) error {
+	if offerExpiry == 0 {
+		return nil
+	}
+
+	var offers []ServiceOffer
+	if err := db.Where("status = ? AND updated_at <= ?", offerStatusPending, time.Now().Add(-offerExpiry)).
+		Find(&offers).Error; err != nil {
+		return err
+	}
+
+	for i := range offers {
+		err := transaction(db, func(tx *gorm.DB) error {
+			return transitionServiceOffer(tx, &offers[i], offerStatusExpired, nil, "offer expired")
+		})
+		if err != nil {
+			// Accepted, rejected or withdrawn in the meantime
+			if _, ok := err.(*transitionError); ok {
+				continue
+			}
+			return err
+		}
+		log.Printf("Expired service offer %d", offers[i].ID)
+	}
+	return nil
+}
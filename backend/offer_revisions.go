@@ -0,0 +1,357 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Offer negotiation
+//
+// Every set of terms on an offer (price, scope and duration) is kept as a
+// numbered OfferRevision. The provider's original offer is version 1; after
+// that the requester and the provider counter each other. Either side can
+// accept the other side's latest revision, which locks it in as the offer's
+// agreed terms and accepts the offer. Terms can't change once accepted.
+
+// Error codes for negotiation conflicts
+const (
+	errorCodeNotNegotiable    = "offer_not_negotiable"
+	errorCodeAwaitingResponse = "awaiting_response"
+	errorCodeTermsChanged     = "terms_changed"
+)
+
+// offerTerms are the negotiable parts of an offer
+type offerTerms struct {
+	Price             float64
+	Description       string
+	EstimatedDuration string
+}
+
+// createOfferRevision stores the next revision of an offer and makes its terms
+// the offer's current terms
+func createOfferRevision(tx *gorm.DB, offer *ServiceOffer, authorID uint, terms offerTerms, message string) (*OfferRevision, error) {
+	var version int
+	if err := tx.Model(&OfferRevision{}).
+		Where("service_offer_id = ?", offer.ID).
+		Select("COALESCE(MAX(version), 0)").
+		Scan(&version).Error; err != nil {
+		return nil, err
+	}
+
+	revision := OfferRevision{
+		ServiceOfferID:    offer.ID,
+		Version:           version + 1,
+		AuthorID:          authorID,
+		Price:             terms.Price,
+		Description:       terms.Description,
+		EstimatedDuration: terms.EstimatedDuration,
+		Message:           message,
+	}
+	if err := tx.Create(&revision).Error; err != nil {
+		return nil, err
+	}
+
+	if err := tx.Model(offer).Updates(map[string]interface{}{
+		"proposed_price":     terms.Price,
+		"description":        terms.Description,
+		"estimated_duration": terms.EstimatedDuration,
+	}).Error; err != nil {
+		return nil, err
+	}
+	offer.ProposedPrice = terms.Price
+	offer.Description = terms.Description
+	offer.EstimatedDuration = terms.EstimatedDuration
+
+	return &revision, nil
+}
+
+// latestOfferRevision returns the offer's current terms
+func latestOfferRevision(db *gorm.DB, offerID uint) (*OfferRevision, error) {
+	var revision OfferRevision
+	if err := db.Where("service_offer_id = ?", offerID).Order("version DESC").First(&revision).Error; err != nil {
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// agreedPrice returns the price of an accepted offer's agreed revision, or
+// its proposed price when it has none. AgreedRevision must be preloaded.
+func agreedPrice(offer *ServiceOffer) float64 {
+	if offer.AgreedRevision != nil {
+		return offer.AgreedRevision.Price
+	}
+	return offer.ProposedPrice
+}
+
+// acceptOfferRevision accepts an offer on the terms of one of its revisions:
+// the request starts, the offer is accepted with the revision as its agreed
+// terms, the request's other pending offers are rejected, the community's
+// webhooks are told and the agreed price is held in escrow
+func acceptOfferRevision(tx *gorm.DB, request *ServiceRequest, offer *ServiceOffer, revision *OfferRevision, actorID uint) error {
+	if err := transitionServiceRequest(tx, request, requestStatusInProgress, &actorID, "offer accepted"); err != nil {
+		return err
+	}
+	if err := tx.Model(request).Update("accepted_offer_id", offer.ID).Error; err != nil {
+		return err
+	}
+	request.AcceptedOfferID = &offer.ID
+
+	if err := transitionServiceOffer(tx, offer, offerStatusAccepted, &actorID, ""); err != nil {
+		return err
+	}
+	if err := tx.Model(offer).Update("agreed_revision_id", revision.ID).Error; err != nil {
+		return err
+	}
+	offer.AgreedRevisionID = &revision.ID
+
+	if err := rejectPendingOffers(tx, request.ID, offer.ID, &actorID, "another offer accepted"); err != nil {
+		return err
+	}
+	if err := queueServiceRequestWebhook(tx, request, webhookServiceRequestAccepted); err != nil {
+		return err
+	}
+
+	// Charge last, so a failure above doesn't leave a charge to undo
+	return holdEscrow(tx, request, revision.Price, &actorID)
+}
+
+// checkAcceptableRevision writes a 409 and returns false unless the latest
+// revision came from the other party and, when the client names the version
+// it saw, is still that version
+func checkAcceptableRevision(c *gin.Context, revision *OfferRevision, userID uint, version int) bool {
+	if revision.AuthorID == userID {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "These are your own terms; wait for the other party to accept or counter",
+			"code":    errorCodeAwaitingResponse,
+		})
+		return false
+	}
+	if version != 0 && version != revision.Version {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "The terms have changed since you last saw them",
+			"code":    errorCodeTermsChanged,
+			"version": revision.Version,
+		})
+		return false
+	}
+	return true
+}
+
+// loadNegotiation loads an offer and its request for the requester or the
+// provider. It writes an error response and returns false when the current
+// user isn't one of them.
+func loadNegotiation(c *gin.Context, db *gorm.DB, offerID uint) (*ServiceOffer, *ServiceRequest, uint, bool) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return nil, nil, 0, false
+	}
+
+	var offer ServiceOffer
+	if err := db.Preload("ServiceRequest").First(&offer, offerID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Service offer not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service offer"})
+		}
+		return nil, nil, 0, false
+	}
+
+	request := offer.ServiceRequest
+	if userID != offer.ProviderID && userID != request.RequesterID {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Only the requester and the provider can negotiate an offer"})
+		return nil, nil, 0, false
+	}
+
+	return &offer, &request, userID, true
+}
+
+// checkNegotiable writes a 409 and returns false unless the offer is still open to negotiation
+func checkNegotiable(c *gin.Context, offer *ServiceOffer, request *ServiceRequest) bool {
+	if offer.Status != offerStatusPending || request.Status != requestStatusOpen {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Only pending offers on open requests can be negotiated",
+			"code":    errorCodeNotNegotiable,
+		})
+		return false
+	}
+	return true
+}
+
+// getOfferRevisions handles GET /api/service-offers/:id/revisions
+func getOfferRevisions(c *gin.Context, db *gorm.DB, offerID uint) {
+	if c.Request.Method != http.MethodGet {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"message": "Method not allowed"})
+		return
+	}
+
+	if _, _, _, ok := loadNegotiation(c, db, offerID); !ok {
+		return
+	}
+
+	var revisions []OfferRevision
+	if err := db.Preload("Author", publicUserColumns).
+		Where("service_offer_id = ?", offerID).
+		Order("version ASC").
+		Find(&revisions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch revisions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// counterServiceOffer handles POST /api/service-offers/:id/counter
+// Omitted terms are carried over from the latest revision.
+func counterServiceOffer(c *gin.Context, db *gorm.DB, offerID uint) {
+	if c.Request.Method != http.MethodPost {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"message": "Method not allowed"})
+		return
+	}
+
+	offer, request, userID, ok := loadNegotiation(c, db, offerID)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Price             *float64 `json:"price"`
+		Description       *string  `json:"description"`
+		EstimatedDuration *string  `json:"estimated_duration"`
+		Message           string   `json:"message"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+
+	if !checkNegotiable(c, offer, request) {
+		return
+	}
+
+	latest, err := latestOfferRevision(db, offer.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch current terms"})
+		return
+	}
+
+	terms := offerTerms{Price: latest.Price, Description: latest.Description, EstimatedDuration: latest.EstimatedDuration}
+	if input.Price != nil {
+		if *input.Price < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Price cannot be negative"})
+			return
+		}
+		terms.Price = *input.Price
+	}
+	if input.Description != nil {
+		terms.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.EstimatedDuration != nil {
+		terms.EstimatedDuration = *input.EstimatedDuration
+	}
+
+	if terms.Description == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Description cannot be empty"})
+		return
+	}
+	if terms.Price == latest.Price && terms.Description == latest.Description && terms.EstimatedDuration == latest.EstimatedDuration {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "A counter-offer must change the price, scope or duration"})
+		return
+	}
+
+	var revision *OfferRevision
+	err = transaction(db, func(tx *gorm.DB) error {
+		revision, err = createOfferRevision(tx, offer, userID, terms, strings.TrimSpace(input.Message))
+		if err != nil {
+			return err
+		}
+
+		// The other party has terms to answer
+		otherID := offer.ProviderID
+		if userID == offer.ProviderID {
+			otherID = request.RequesterID
+		}
+		queueEvent(tx, eventOfferCountered, []uint{otherID}, map[string]interface{}{
+			"offer_id":           offer.ID,
+			"service_request_id": request.ID,
+			"version":            revision.Version,
+		})
+		return notify(tx, otherID, notificationOfferCountered,
+			fmt.Sprintf("Counter-offer on %q", request.Title),
+			strings.TrimSpace(currentUserName(c)+" proposed new terms. "+revision.Message),
+			fmt.Sprintf("/service-requests/%d", request.ID))
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save counter-offer"})
+		return
+	}
+
+	db.Preload("Author", publicUserColumns).First(revision, revision.ID)
+
+	c.JSON(http.StatusCreated, revision)
+}
+
+// acceptOfferTerms handles POST /api/service-offers/:id/accept-terms
+// The provider accepts the requester's counter-offer, which accepts the offer.
+func acceptOfferTerms(c *gin.Context, db *gorm.DB, offerID uint) {
+	if c.Request.Method != http.MethodPost {
+		c.JSON(http.StatusMethodNotAllowed, gin.H{"message": "Method not allowed"})
+		return
+	}
+
+	offer, request, userID, ok := loadNegotiation(c, db, offerID)
+	if !ok {
+		return
+	}
+
+	if userID != offer.ProviderID {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Requesters accept offers through accept-offer"})
+		return
+	}
+
+	var input struct {
+		Version int `json:"version"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+		return
+	}
+
+	if !checkNegotiable(c, offer, request) {
+		return
+	}
+
+	latest, err := latestOfferRevision(db, offer.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch current terms"})
+		return
+	}
+
+	if !checkAcceptableRevision(c, latest, userID, input.Version) {
+		return
+	}
+
+	err = transaction(db, func(tx *gorm.DB) error {
+		return acceptOfferRevision(tx, request, offer, latest, userID)
+	})
+	if err != nil {
+		respondTransitionError(c, err, "Failed to accept terms")
+		return
+	}
+
+	if err := db.Preload("Provider").
+		Preload("ServiceRequest").
+		Preload("AgreedRevision").
+		First(offer, offerID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to load updated offer"})
+		return
+	}
+
+	c.JSON(http.StatusOK, offer)
+}
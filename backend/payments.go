@@ -0,0 +1,136 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Payment provider
+//
+// Money enters and leaves the platform through a PaymentProvider: requesters
+// are charged when an offer is accepted and refunded when a request is
+// cancelled. PAYMENT_PROVIDER selects the implementation; "fake" (the default
+// and currently the only one) keeps charges in memory so everything works
+// offline and in tests.
+
+// ChargeRequest asks the provider to take money from a user
+type ChargeRequest struct {
+	UserID    uint
+	Amount    int64 // minor units
+	Currency  string
+	Reference string // our own reference, e.g. the service request
+}
+
+// PaymentProvider moves money between users and the platform
+type PaymentProvider interface {
+	// Charge takes money from a user and returns the provider's charge ID
+	Charge(req ChargeRequest) (string, error)
+	// Refund returns part or all of a charge and returns the provider's refund ID
+	Refund(chargeID string, amount int64) (string, error)
+}
+
+// paymentProvider is the PaymentProvider used by the application, set up in main
+var paymentProvider PaymentProvider = newFakePaymentProvider()
+
+// loadPaymentProvider builds the PaymentProvider configured in the environment
+func loadPaymentProvider() PaymentProvider {
+	switch name := os.Getenv("PAYMENT_PROVIDER"); name {
+	case "", "fake":
+	default:
+		log.Printf("Unknown PAYMENT_PROVIDER %q, using the fake payment provider", name)
+	}
+	return newFakePaymentProvider()
+}
+
+var (
+	errChargeNotFound  = errors.New("charge not found")
+	errRefundTooLarge  = errors.New("refund exceeds the amount left on the charge")
+	errInvalidAmount   = errors.New("amount must be positive")
+	errPaymentDeclined = errors.New("payment declined")
+)
+
+// fakeCharge is a charge held by the fake provider
+type fakeCharge struct {
+	ChargeRequest
+	Refunded int64
+}
+
+// fakePaymentProvider accepts every charge and keeps them in memory
+type fakePaymentProvider struct {
+	mu      sync.Mutex
+	epoch   int64 // keeps IDs unique across restarts
+	next    int
+	charges map[string]*fakeCharge
+	// Decline makes charges fail for these users, to exercise the failure path
+	Decline map[uint]bool
+}
+
+func newFakePaymentProvider() *fakePaymentProvider {
+	return &fakePaymentProvider{
+		epoch:   time.Now().UnixNano(),
+		charges: make(map[string]*fakeCharge),
+		Decline: make(map[uint]bool),
+	}
+}
+
+func (p *fakePaymentProvider) Charge(req ChargeRequest) (string, error) {
+	if req.Amount <= 0 {
+		return "", errInvalidAmount
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.Decline[req.UserID] {
+		return "", errPaymentDeclined
+	}
+
+	p.next++
+	id := fmt.Sprintf("fake_ch_%x_%d", p.epoch, p.next)
+	p.charges[id] = &fakeCharge{ChargeRequest: req}
+	return id, nil
+}
+
+func (p *fakePaymentProvider) Refund(chargeID string, amount int64) (string, error) {
+	if amount <= 0 {
+		return "", errInvalidAmount
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	// Charges from before a restart are gone; refund them as long as the ID
+	// looks like one of ours
+	charge, ok := p.charges[chargeID]
+	if !ok && !strings.HasPrefix(chargeID, "fake_ch_") {
+		return "", errChargeNotFound
+	}
+	if ok {
+		if charge.Refunded+amount > charge.Amount {
+			return "", errRefundTooLarge
+		}
+		charge.Refunded += amount
+	}
+
+	p.next++
+	return fmt.Sprintf("fake_re_%x_%d", p.epoch, p.next), nil
+}
+
+// paymentError wraps a failure from the payment provider so handlers can tell
+// it apart from database errors
+type paymentError struct {
+	Err error
+}
+
+func (e *paymentError) Error() string {
+	return "payment failed: " + e.Err.Error()
+}
+
+func (e *paymentError) Unwrap() error {
+	return e.Err
+}
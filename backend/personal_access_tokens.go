@@ -0,0 +1,256 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Personal access tokens
+//
+// Long-lived tokens for scripts and integrations, sent as
+// "Authorization: Bearer cmn_pat_...". A token acts as its owner, limited
+// by its scopes. Only the hash is stored; the token is shown once.
+
+const personalAccessTokenPrefix = "cmn_pat_"
+
+const (
+	defaultTokenExpiryDays = 90
+	maxTokenExpiryDays     = 365
+)
+
+// Token scopes
+const (
+	scopeReadOnly    = "read_only"   // GET requests only
+	scopeMarketplace = "marketplace" // read everything, write to the marketplace
+	scopeAdmin       = "admin"       // everything the owner can do
+)
+
+var tokenScopes = []string{scopeReadOnly, scopeMarketplace, scopeAdmin}
+
+// marketplaceRoutes are the route prefixes the marketplace scope may write to
+var marketplaceRoutes = []string{
+	"/api/services",
+	"/api/service-requests",
+	"/api/service-offers",
+	"/api/comments",
+	"/api/recurring-requests",
+	"/api/appointments",
+	"/api/milestones",
+	"/api/disputes",
+}
+
+func isValidTokenScope(scope string) bool {
+	for _, s := range tokenScopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// scopeList splits the stored comma-separated scopes
+func (t *PersonalAccessToken) scopeList() []string {
+	if t.Scopes == "" {
+		return []string{}
+	}
+	return strings.Split(t.Scopes, ",")
+}
+
+// tokenScopeAllows reports whether the scopes permit a request to a route
+func tokenScopeAllows(scopes []string, method, route string) bool {
+	// Token management, sessions and credentials always need an interactive login
+	if strings.HasPrefix(route, "/api/auth/") && route != "/api/auth/me" {
+		return false
+	}
+	if route == "/api/users/change-password" {
+		return false
+	}
+
+	readOnly := method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+
+	for _, scope := range scopes {
+		switch scope {
+		case scopeAdmin:
+			return true
+		case scopeReadOnly:
+			if readOnly {
+				return true
+			}
+		case scopeMarketplace:
+			if readOnly {
+				return true
+			}
+			for _, prefix := range marketplaceRoutes {
+				if strings.HasPrefix(route, prefix) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// authenticatePersonalAccessToken looks up an active token and records its use
+func authenticatePersonalAccessToken(db *gorm.DB, token, ip string) (*PersonalAccessToken, error) {
+	var pat PersonalAccessToken
+	if err := db.Where("token_hash = ?", hashToken(token)).First(&pat).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if pat.RevokedAt != nil || now.After(pat.ExpiresAt) {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) > lastUsedResolution || pat.LastUsedIP != ip {
+		db.Model(&pat).UpdateColumns(map[string]interface{}{"last_used_at": now, "last_used_ip": ip})
+	}
+
+	return &pat, nil
+}
+
+// Handlers
+
+func getPersonalAccessTokensHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		var tokens []PersonalAccessToken
+		if err := db.Where("user_id = ? AND revoked_at IS NULL", userID).
+			Order("created_at DESC").
+			Find(&tokens).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch tokens"})
+			return
+		}
+
+		c.JSON(http.StatusOK, tokens)
+	}
+}
+
+func createPersonalAccessTokenHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		var input struct {
+			Name          string   `json:"name"`
+			Scopes        []string `json:"scopes"`
+			ExpiresInDays *int     `json:"expires_in_days"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		input.Name = strings.TrimSpace(input.Name)
+		if input.Name == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Name is required"})
+			return
+		}
+
+		if len(input.Scopes) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "At least one scope is required"})
+			return
+		}
+		for _, scope := range input.Scopes {
+			if !isValidTokenScope(scope) {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid scope: " + scope})
+				return
+			}
+		}
+
+		expiresInDays := defaultTokenExpiryDays
+		if input.ExpiresInDays != nil {
+			expiresInDays = *input.ExpiresInDays
+		}
+		if expiresInDays < 1 || expiresInDays > maxTokenExpiryDays {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "expires_in_days must be between 1 and 365"})
+			return
+		}
+
+		secret, err := generateSecureToken()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate token"})
+			return
+		}
+		token := personalAccessTokenPrefix + secret
+
+		pat := PersonalAccessToken{
+			UserID:    userID,
+			Name:      input.Name,
+			TokenHash: hashToken(token),
+			Prefix:    token[:len(personalAccessTokenPrefix)+6],
+			Scopes:    strings.Join(input.Scopes, ","),
+			ExpiresAt: time.Now().AddDate(0, 0, expiresInDays),
+		}
+
+		if err := db.Create(&pat).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create token"})
+			return
+		}
+
+		// The token itself is only ever returned here
+		c.JSON(http.StatusCreated, gin.H{
+			"token":                 token,
+			"personal_access_token": pat,
+		})
+	}
+}
+
+func revokePersonalAccessTokenHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		tokenID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid token ID"})
+			return
+		}
+
+		result := db.Model(&PersonalAccessToken{}).
+			Where("id = ? AND user_id = ? AND revoked_at IS NULL", tokenID, userID).
+			Update("revoked_at", time.Now())
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to revoke token"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Token not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Token revoked"})
+	}
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Post handlers
+
+// findCommunityPost loads the :postId post and checks it belongs to the community
+func findCommunityPost(c *gin.Context, db *gorm.DB, communityID uint) (*Post, bool) {
+	postID, err := strconv.ParseUint(c.Param("postId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid post ID"})
+		return nil, false
+	}
+
+	var post Post
+	if err := db.Where("community_id = ?", communityID).First(&post, postID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Post not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch post"})
+		}
+		return nil, false
+	}
+
+	return &post, true
+}
+
+// canEditPost reports whether the user is the post author or a community moderator
+func (a *communityAccess) canEditPost(post *Post) bool {
+	return post.AuthorID == a.UserID || a.CanModerate
+}
+
+// getCommunityPostsHandler handles GET /api/communities/:id/posts
+// Members see published posts and their own drafts; moderators see everything.
+func getCommunityPostsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		access, ok := resolveCommunityAccess(c, db)
+		if !ok {
+			return
+		}
+
+		page, pageSize := parsePagination(c)
+
+		query := db.Model(&Post{}).Where("community_id = ?", access.CommunityID)
+		if !access.CanModerate {
+			query = query.Where("is_published = ? OR author_id = ?", true, access.UserID)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count posts"})
+			return
+		}
+
+		var posts []Post
+		if err := query.
+			Preload("Author", publicUserColumns).
+			Order("created_at DESC").
+			Offset((page - 1) * pageSize).
+			Limit(pageSize).
+			Find(&posts).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch posts"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"posts":     posts,
+			"page":      page,
+			"page_size": pageSize,
+			"total":     total,
+		})
+	}
+}
+
+// createPostHandler handles POST /api/communities/:id/posts
+func createPostHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		access, ok := resolveCommunityAccess(c, db)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			Title       string `json:"title"`
+			Content     string `json:"content"`
+			IsPublished *bool  `json:"is_published"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		if input.Title == "" || input.Content == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Title and content are required"})
+			return
+		}
+
+		post := Post{
+			Title:       input.Title,
+			Content:     input.Content,
+			AuthorID:    access.UserID,
+			CommunityID: access.CommunityID,
+			IsPublished: true,
+		}
+
+		if err := db.Create(&post).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create post"})
+			return
+		}
+
+		// GORM replaces a false bool with the column default on create, so drafts are saved in a second step
+		if input.IsPublished != nil && !*input.IsPublished {
+			if err := db.Model(&post).Update("is_published", false).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to save draft"})
+				return
+			}
+		}
+
+		db.Preload("Author", publicUserColumns).First(&post, post.ID)
+
+		c.JSON(http.StatusCreated, post)
+	}
+}
+
+// getPostHandler handles GET /api/communities/:id/posts/:postId
+func getPostHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		access, ok := resolveCommunityAccess(c, db)
+		if !ok {
+			return
+		}
+
+		post, ok := findCommunityPost(c, db, access.CommunityID)
+		if !ok {
+			return
+		}
+
+		// Drafts are only visible to their author and moderators
+		if !post.IsPublished && !access.canEditPost(post) {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Post not found"})
+			return
+		}
+
+		db.Model(post).UpdateColumn("view_count", gorm.Expr("view_count + ?", 1))
+
+		if err := db.Preload("Author", publicUserColumns).First(post, post.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch post"})
+			return
+		}
+
+		c.JSON(http.StatusOK, post)
+	}
+}
+
+// updatePostHandler handles PUT /api/communities/:id/posts/:postId
+func updatePostHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		access, ok := resolveCommunityAccess(c, db)
+		if !ok {
+			return
+		}
+
+		post, ok := findCommunityPost(c, db, access.CommunityID)
+		if !ok {
+			return
+		}
+
+		if !access.canEditPost(post) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only the author or a moderator can edit this post"})
+			return
+		}
+
+		var input struct {
+			Title   *string `json:"title"`
+			Content *string `json:"content"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		updates := make(map[string]interface{})
+		if input.Title != nil {
+			if *input.Title == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Title cannot be empty"})
+				return
+			}
+			updates["title"] = *input.Title
+		}
+		if input.Content != nil {
+			if *input.Content == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"message": "Content cannot be empty"})
+				return
+			}
+			updates["content"] = *input.Content
+		}
+
+		if len(updates) > 0 {
+			if err := db.Model(post).Updates(updates).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update post"})
+				return
+			}
+		}
+
+		if err := db.Preload("Author", publicUserColumns).First(post, post.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch updated post"})
+			return
+		}
+
+		c.JSON(http.StatusOK, post)
+	}
+}
+
+// deletePostHandler handles DELETE /api/communities/:id/posts/:postId
+func deletePostHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		access, ok := resolveCommunityAccess(c, db)
+		if !ok {
+			return
+		}
+
+		post, ok := findCommunityPost(c, db, access.CommunityID)
+		if !ok {
+			return
+		}
+
+		if !access.canEditPost(post) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only the author or a moderator can delete this post"})
+			return
+		}
+
+		// Soft delete
+		if err := db.Delete(post).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete post"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Post deleted successfully"})
+	}
+}
+
+// setPostPublishedHandler handles POST /api/communities/:id/posts/:postId/publish and /unpublish
+func setPostPublishedHandler(db *gorm.DB, published bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		access, ok := resolveCommunityAccess(c, db)
+		if !ok {
+			return
+		}
+
+		post, ok := findCommunityPost(c, db, access.CommunityID)
+		if !ok {
+			return
+		}
+
+		if !access.canEditPost(post) {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only the author or a moderator can publish this post"})
+			return
+		}
+
+		if err := db.Model(post).Update("is_published", published).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update post"})
+			return
+		}
+
+		if err := db.Preload("Author", publicUserColumns).First(post, post.ID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch updated post"})
+			return
+		}
+
+		c.JSON(http.StatusOK, post)
+	}
+}
@@ -0,0 +1,287 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Rating handlers
+
+var (
+	errRatingNotRequester = errors.New("only the requester can rate this service")
+	errRatingNotCompleted = errors.New("service request must be confirmed as completed before it can be rated")
+	errRatingNoProvider   = errors.New("service request has no accepted provider to rate")
+)
+
+// checkRatingEligibility decides whether a user may rate the provider of a request
+// and returns the provider being rated. Requests only reach completed once the
+// requester confirms, the confirmation window passes or a dispute is resolved.
+func checkRatingEligibility(db *gorm.DB, request *ServiceRequest, userID uint) (uint, error) {
+	if request.RequesterID != userID {
+		return 0, errRatingNotRequester
+	}
+
+	if request.Status != requestStatusCompleted {
+		return 0, errRatingNotCompleted
+	}
+
+	if request.AcceptedOfferID == nil {
+		return 0, errRatingNoProvider
+	}
+
+	var offer ServiceOffer
+	if err := db.First(&offer, *request.AcceptedOfferID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, errRatingNoProvider
+		}
+		return 0, err
+	}
+
+	return offer.ProviderID, nil
+}
+
+// createRatingHandler handles POST /api/service-requests/:id/rating
+func createRatingHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid service request ID"})
+			return
+		}
+
+		var input struct {
+			Score  int    `json:"score"`
+			Review string `json:"review"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		if input.Score < 1 || input.Score > 5 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Score must be between 1 and 5"})
+			return
+		}
+
+		var request ServiceRequest
+		if err := db.First(&request, requestID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Service request not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service request"})
+			}
+			return
+		}
+
+		providerID, err := checkRatingEligibility(db, &request, userID)
+		switch err {
+		case nil:
+		case errRatingNotRequester:
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only the requester can rate this service"})
+			return
+		case errRatingNotCompleted:
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Service request must be confirmed as completed before it can be rated"})
+			return
+		case errRatingNoProvider:
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Service request has no accepted provider to rate"})
+			return
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to check rating eligibility"})
+			return
+		}
+
+		var existing Rating
+		if err := db.Where("service_request_id = ?", request.ID).First(&existing).Error; err == nil {
+			c.JSON(http.StatusConflict, gin.H{"message": "This service request has already been rated"})
+			return
+		}
+
+		rating := Rating{
+			ProviderID:       providerID,
+			RaterID:          userID,
+			ServiceRequestID: request.ID,
+			Score:            input.Score,
+			Review:           input.Review,
+		}
+
+		if err := db.Create(&rating).Error; err != nil {
+			// Rated by a concurrent request since the check above
+			if isUniqueViolation(err) {
+				c.JSON(http.StatusConflict, gin.H{"message": "This service request has already been rated"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create rating"})
+			return
+		}
+
+		db.Preload("Rater", publicUserColumns).First(&rating, rating.ID)
+
+		c.JSON(http.StatusCreated, rating)
+	}
+}
+
+// getServiceRequestRatingHandler handles GET /api/service-requests/:id/rating
+func getServiceRequestRatingHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid service request ID"})
+			return
+		}
+
+		var request ServiceRequest
+		if err := db.Select("id", "community_id").First(&request, requestID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Service request not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service request"})
+			}
+			return
+		}
+
+		if _, ok := checkCommunityAccess(c, db, request.CommunityID); !ok {
+			return
+		}
+
+		var rating Rating
+		if err := db.Preload("Rater", publicUserColumns).
+			Where("service_request_id = ?", requestID).
+			First(&rating).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Rating not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch rating"})
+			}
+			return
+		}
+
+		c.JSON(http.StatusOK, rating)
+	}
+}
+
+// ProviderRatingSummary is the aggregate reputation of a service provider
+type ProviderRatingSummary struct {
+	Average   float64     `json:"average"`
+	Count     int64       `json:"count"`
+	Histogram map[int]int `json:"histogram"` // stars (1-5) -> number of ratings
+}
+
+// getProviderRatingSummary computes the average, count and star histogram for a provider
+func getProviderRatingSummary(db *gorm.DB, providerID uint) (*ProviderRatingSummary, error) {
+	var rows []struct {
+		Score int
+		Count int
+	}
+
+	if err := db.Model(&Rating{}).
+		Select("score, COUNT(*) as count").
+		Where("provider_id = ?", providerID).
+		Group("score").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	summary := &ProviderRatingSummary{Histogram: map[int]int{1: 0, 2: 0, 3: 0, 4: 0, 5: 0}}
+	total := 0
+	for _, row := range rows {
+		summary.Histogram[row.Score] = row.Count
+		summary.Count += int64(row.Count)
+		total += row.Score * row.Count
+	}
+
+	if summary.Count > 0 {
+		summary.Average = float64(total) / float64(summary.Count)
+	}
+
+	return summary, nil
+}
+
+// getProviderReviewsHandler handles GET /api/users/:id/reviews
+// Returns the provider's rating summary plus a page of reviews, newest first.
+func getProviderReviewsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		providerID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid user ID"})
+			return
+		}
+
+		var provider User
+		if err := db.First(&provider, providerID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "User not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch user"})
+			}
+			return
+		}
+
+		summary, err := getProviderRatingSummary(db, provider.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to compute rating summary"})
+			return
+		}
+
+		// Reviews show which request they were for (its title only), when the
+		// request is in one of the caller's communities
+		userID, _ := getCurrentUser(c)
+		userRole, _ := c.Get("userRole")
+		reviewedRequests := func(tx *gorm.DB) *gorm.DB {
+			tx = tx.Select("id", "title")
+			if userRole == RoleSuperAdmin {
+				return tx
+			}
+			return tx.Where("community_id IN (?)", db.Model(&UserCommunity{}).
+				Select("community_id").
+				Where("user_id = ? AND is_active = ?", userID, true))
+		}
+
+		page, pageSize := parsePagination(c)
+
+		var reviews []Rating
+		if err := db.Preload("Rater", publicUserColumns).
+			Preload("ServiceRequest", reviewedRequests).
+			Where("provider_id = ?", provider.ID).
+			Order("created_at DESC").
+			Offset((page - 1) * pageSize).
+			Limit(pageSize).
+			Find(&reviews).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch reviews"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"provider":  sanitizeUser(&provider),
+			"summary":   summary,
+			"reviews":   reviews,
+			"page":      page,
+			"page_size": pageSize,
+		})
+	}
+}
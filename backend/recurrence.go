@@ -0,0 +1,266 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Recurrence rules
+//
+// Recurring requests use a subset of the iCalendar RRULE (RFC 5545):
+//
+//	FREQ=DAILY|WEEKLY|MONTHLY   required
+//	INTERVAL=n                  every n days, weeks or months (default 1)
+//	BYDAY=MO,WE,FR              weekly only, without ordinals
+//	BYMONTHDAY=1,15,-1          monthly only; negative days count from the end
+//	COUNT=n or UNTIL=date       optional end, but not both
+//
+// Occurrences keep the wall-clock time of the first one in the series' time
+// zone, so a 9:00 visit stays at 9:00 across daylight saving changes. Months
+// without a given day (the 31st in April) are skipped, as the RFC does.
+
+// maxRecurrencePeriods bounds how many days, weeks or months are searched for an occurrence
+const maxRecurrencePeriods = 20000
+
+// Recurrence frequencies
+const (
+	frequencyDaily   = "DAILY"
+	frequencyWeekly  = "WEEKLY"
+	frequencyMonthly = "MONTHLY"
+)
+
+var recurrenceWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// recurrenceRule is a parsed RRULE
+type recurrenceRule struct {
+	Freq       string
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay []int
+	Count      int
+	Until      *time.Time
+}
+
+// parseRecurrenceRule parses an RRULE value, with or without the "RRULE:"
+// prefix. A date-only UNTIL is the end of that day in loc.
+func parseRecurrenceRule(value string, loc *time.Location) (*recurrenceRule, error) {
+	value = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "RRULE:")
+	if value == "" {
+		return nil, fmt.Errorf("rule is empty")
+	}
+
+	rule := &recurrenceRule{Interval: 1}
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ";") {
+		name, val, ok := strings.Cut(part, "=")
+		if !ok || val == "" {
+			return nil, fmt.Errorf("%q is not NAME=VALUE", part)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("%s is given twice", name)
+		}
+		seen[name] = true
+
+		switch name {
+		case "FREQ":
+			if val != frequencyDaily && val != frequencyWeekly && val != frequencyMonthly {
+				return nil, fmt.Errorf("FREQ must be DAILY, WEEKLY or MONTHLY")
+			}
+			rule.Freq = val
+		case "INTERVAL":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 || n > 366 {
+				return nil, fmt.Errorf("INTERVAL must be between 1 and 366")
+			}
+			rule.Interval = n
+		case "COUNT":
+			n, err := strconv.Atoi(val)
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("COUNT must be a positive number")
+			}
+			rule.Count = n
+		case "UNTIL":
+			until, err := parseRecurrenceUntil(val, loc)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := recurrenceWeekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("BYDAY %q must be one of MO, TU, WE, TH, FR, SA, SU (ordinals aren't supported)", day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(val, ",") {
+				n, err := strconv.Atoi(day)
+				if err != nil || n == 0 || n < -31 || n > 31 {
+					return nil, fmt.Errorf("BYMONTHDAY %q must be 1 to 31 or -1 to -31", day)
+				}
+				rule.ByMonthDay = append(rule.ByMonthDay, n)
+			}
+		default:
+			return nil, fmt.Errorf("%s isn't supported", name)
+		}
+	}
+
+	switch {
+	case rule.Freq == "":
+		return nil, fmt.Errorf("FREQ is required")
+	case rule.Count > 0 && rule.Until != nil:
+		return nil, fmt.Errorf("COUNT and UNTIL can't both be given")
+	case len(rule.ByDay) > 0 && rule.Freq != frequencyWeekly:
+		return nil, fmt.Errorf("BYDAY is only supported with FREQ=WEEKLY")
+	case len(rule.ByMonthDay) > 0 && rule.Freq != frequencyMonthly:
+		return nil, fmt.Errorf("BYMONTHDAY is only supported with FREQ=MONTHLY")
+	}
+	return rule, nil
+}
+
+// parseRecurrenceUntil parses UNTIL as a UTC date-time or a date
+func parseRecurrenceUntil(value string, loc *time.Location) (time.Time, error) {
+	if until, err := time.Parse(icsTimeLayout, value); err == nil {
+		return until, nil
+	}
+	day, err := time.ParseInLocation("20060102", value, loc)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("UNTIL must be YYYYMMDD or YYYYMMDDTHHMMSSZ")
+	}
+	return day.AddDate(0, 0, 1).Add(-time.Nanosecond), nil
+}
+
+// String writes the rule back out in a canonical form
+func (r *recurrenceRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, weekday := range r.ByDay {
+			days[i] = strings.ToUpper(weekday.String()[:2])
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, day := range r.ByMonthDay {
+			days[i] = strconv.Itoa(day)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if r.Count > 0 {
+		parts = append(parts, "COUNT="+strconv.Itoa(r.Count))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format(icsTimeLayout))
+	}
+	return strings.Join(parts, ";")
+}
+
+// period returns the candidate occurrences in the nth day, week or month of
+// the series, in order. Some may fall before start.
+func (r *recurrenceRule) period(start time.Time, n int) []time.Time {
+	loc := start.Location()
+	hour, minute, second := start.Clock()
+	at := func(year int, month time.Month, day int) time.Time {
+		return time.Date(year, month, day, hour, minute, second, 0, loc)
+	}
+
+	switch r.Freq {
+	case frequencyDaily:
+		return []time.Time{at(start.Year(), start.Month(), start.Day()+n*r.Interval)}
+
+	case frequencyWeekly:
+		days := r.ByDay
+		if len(days) == 0 {
+			days = []time.Weekday{start.Weekday()}
+		}
+		// Weeks start on Monday
+		monday := start.Day() - (int(start.Weekday())+6)%7 + 7*n*r.Interval
+		times := make([]time.Time, 0, len(days))
+		for _, weekday := range days {
+			times = append(times, at(start.Year(), start.Month(), monday+(int(weekday)+6)%7))
+		}
+		sort.Slice(times, func(i, j int) bool { return times[i].Before(times[j]) })
+		return times
+
+	case frequencyMonthly:
+		first := at(start.Year(), start.Month()+time.Month(n*r.Interval), 1)
+		daysInMonth := time.Date(first.Year(), first.Month()+1, 0, 0, 0, 0, 0, loc).Day()
+		days := r.ByMonthDay
+		if len(days) == 0 {
+			days = []int{start.Day()}
+		}
+		var monthDays []int
+		for _, day := range days {
+			if day < 0 {
+				day = daysInMonth + day + 1
+			}
+			if day >= 1 && day <= daysInMonth {
+				monthDays = append(monthDays, day)
+			}
+		}
+		sort.Ints(monthDays)
+		times := make([]time.Time, 0, len(monthDays))
+		for i, day := range monthDays {
+			if i > 0 && day == monthDays[i-1] {
+				continue
+			}
+			times = append(times, at(first.Year(), first.Month(), day))
+		}
+		return times
+	}
+	return nil
+}
+
+// next returns the first occurrence of the series starting at start that is
+// after after, and false once the series has ended
+func (r *recurrenceRule) next(start, after time.Time) (time.Time, bool) {
+	count := 0
+	for n := 0; n < maxRecurrencePeriods; n++ {
+		for _, t := range r.period(start, n) {
+			if t.Before(start) {
+				continue
+			}
+			if r.Until != nil && t.After(*r.Until) {
+				return time.Time{}, false
+			}
+			count++
+			if r.Count > 0 && count > r.Count {
+				return time.Time{}, false
+			}
+			if t.After(after) {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
+
+// upcoming returns up to limit occurrences after after
+func (r *recurrenceRule) upcoming(start, after time.Time, limit int) []time.Time {
+	times := make([]time.Time, 0, limit)
+	for len(times) < limit {
+		t, ok := r.next(start, after)
+		if !ok {
+			break
+		}
+		times = append(times, t.UTC())
+		after = t
+	}
+	return times
+}
@@ -0,0 +1,705 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Recurring service requests
+//
+// Cleaning, pest control and lawn care come round on a schedule. A recurring
+// request is a template with a recurrence rule; on every occurrence the
+// scheduler posts a new open service request from it. With RebookProvider, the
+// provider of the last accepted occurrence is booked again on the same terms,
+// so the new request starts in progress with the price held in escrow. If that
+// isn't possible (the provider left the community, or the charge failed) the
+// request is left open for offers.
+//
+// Occurrences missed while the server was down are skipped rather than posted
+// all at once.
+
+// recurrenceInterval is how often due recurring requests are posted
+const recurrenceInterval = 5 * time.Minute
+
+// recurrenceGrace lets a series start now: an occurrence up to this far in the
+// past still counts as the first
+const recurrenceGrace = time.Minute
+
+// upcomingOccurrences is how many upcoming occurrences responses include
+const upcomingOccurrences = 5
+
+// errorCodeRecurrenceInactive is returned when a paused or ended series is changed
+const errorCodeRecurrenceInactive = "recurrence_inactive"
+
+// errRecurrenceChanged means a series was paused, skipped or ended while an occurrence was being posted
+var errRecurrenceChanged = errors.New("recurring request changed")
+
+// recurringRequestResponse is a recurring request with its next occurrences
+type recurringRequestResponse struct {
+	RecurringRequest
+	Upcoming []time.Time `json:"upcoming"`
+}
+
+// seriesRule parses a recurring request's rule in its time zone and returns
+// the rule and its first occurrence in that zone
+func seriesRule(series *RecurringRequest) (*recurrenceRule, time.Time, error) {
+	loc, err := time.LoadLocation(series.TimeZone)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	rule, err := parseRecurrenceRule(series.RRule, loc)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+	return rule, series.StartsAt.In(loc), nil
+}
+
+// nextOccurrence returns the series' next occurrence after after, or nil once it has ended
+func nextOccurrence(series *RecurringRequest, after time.Time) (*time.Time, error) {
+	rule, start, err := seriesRule(series)
+	if err != nil {
+		return nil, err
+	}
+	next, ok := rule.next(start, after)
+	if !ok {
+		return nil, nil
+	}
+	next = next.UTC()
+	return &next, nil
+}
+
+// recurringRequestInput is the template and schedule of a recurring request.
+// Omitted fields are left unchanged on update.
+type recurringRequestInput struct {
+	Title          *string    `json:"title"`
+	Description    *string    `json:"description"`
+	Category       *string    `json:"category"`
+	CommunityID    uint       `json:"community_id"` // Create only
+	Budget         *float64   `json:"budget"`
+	RRule          *string    `json:"rrule"`
+	StartsAt       *time.Time `json:"starts_at"` // RFC 3339; the first occurrence
+	TimeZone       *string    `json:"time_zone"` // Defaults to the requester's time zone
+	RebookProvider *bool      `json:"rebook_provider"`
+}
+
+// apply copies the input onto a series and validates it. It writes an error
+// response and returns false when the result isn't usable.
+func (input *recurringRequestInput) apply(c *gin.Context, series *RecurringRequest) bool {
+	if input.Title != nil {
+		series.Title = strings.TrimSpace(*input.Title)
+	}
+	if input.Description != nil {
+		series.Description = strings.TrimSpace(*input.Description)
+	}
+	if input.Category != nil {
+		series.Category = *input.Category
+	}
+	if input.Budget != nil {
+		series.Budget = *input.Budget
+	}
+	if input.RebookProvider != nil {
+		series.RebookProvider = *input.RebookProvider
+	}
+	if input.StartsAt != nil {
+		series.StartsAt = input.StartsAt.UTC().Truncate(time.Minute)
+	}
+	if input.TimeZone != nil {
+		series.TimeZone = *input.TimeZone
+	}
+	if input.RRule != nil {
+		series.RRule = *input.RRule
+	}
+
+	if series.Title == "" || series.Description == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Title and description are required"})
+		return false
+	}
+	if series.RRule == "" || series.StartsAt.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "rrule and starts_at are required"})
+		return false
+	}
+
+	loc, err := time.LoadLocation(series.TimeZone)
+	if err != nil || series.TimeZone == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Unknown time_zone"})
+		return false
+	}
+	rule, err := parseRecurrenceRule(series.RRule, loc)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Invalid rrule: %v", err)})
+		return false
+	}
+	series.RRule = rule.String()
+	return true
+}
+
+// scheduleFromNow sets NextRunAt to the first occurrence from now. It writes
+// an error response and returns false when there are none.
+func scheduleFromNow(c *gin.Context, series *RecurringRequest) bool {
+	next, err := nextOccurrence(series, time.Now().Add(-recurrenceGrace))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Invalid rrule: %v", err)})
+		return false
+	}
+	if next == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "The rule has no occurrences after now"})
+		return false
+	}
+	series.NextRunAt = next
+	return true
+}
+
+// recurringRequestView adds the upcoming occurrences of an active series
+func recurringRequestView(series RecurringRequest) recurringRequestResponse {
+	view := recurringRequestResponse{RecurringRequest: series, Upcoming: []time.Time{}}
+	if series.Status == recurringStatusActive && series.NextRunAt != nil {
+		if rule, start, err := seriesRule(&series); err == nil {
+			view.Upcoming = rule.upcoming(start, series.NextRunAt.Add(-time.Nanosecond), upcomingOccurrences)
+		}
+	}
+	return view
+}
+
+// writeRecurringRequest reloads a recurring request for a response
+func writeRecurringRequest(c *gin.Context, db *gorm.DB, status int, seriesID uint) {
+	var series RecurringRequest
+	if err := db.Preload("Requester", publicUserColumns).Preload("Community").First(&series, seriesID).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to load recurring request"})
+		return
+	}
+
+	c.JSON(status, recurringRequestView(series))
+}
+
+// getRecurringRequestsHandler handles GET /api/recurring-requests
+// The current user's recurring requests.
+func getRecurringRequestsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		query := db.Preload("Requester", publicUserColumns).Preload("Community").Where("requester_id = ?", userID)
+		if tenant := getTenant(c); tenant != nil {
+			query = query.Where("community_id = ?", tenant.ID)
+		}
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+
+		var series []RecurringRequest
+		if err := query.Order("created_at DESC").Find(&series).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch recurring requests"})
+			return
+		}
+
+		views := make([]recurringRequestResponse, len(series))
+		for i := range series {
+			views[i] = recurringRequestView(series[i])
+		}
+		c.JSON(http.StatusOK, views)
+	}
+}
+
+// createRecurringRequestHandler handles POST /api/recurring-requests
+func createRecurringRequestHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		var input recurringRequestInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		// Requests made on a community's own domain belong to that community
+		if tenant := getTenant(c); tenant != nil && input.CommunityID == 0 {
+			input.CommunityID = tenant.ID
+		}
+		if input.CommunityID == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "community_id is required"})
+			return
+		}
+
+		access, ok := checkCommunityAccess(c, db, input.CommunityID)
+		if !ok {
+			return
+		}
+
+		var requester User
+		if err := db.First(&requester, access.UserID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch user"})
+			return
+		}
+
+		series := RecurringRequest{
+			RequesterID: requester.ID,
+			CommunityID: input.CommunityID,
+			TimeZone:    userLocation(&requester).String(),
+			Status:      recurringStatusActive,
+		}
+		if !input.apply(c, &series) || !scheduleFromNow(c, &series) {
+			return
+		}
+
+		if err := db.Create(&series).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create recurring request"})
+			return
+		}
+
+		writeRecurringRequest(c, db, http.StatusCreated, series.ID)
+	}
+}
+
+// loadRecurringRequest loads the :id recurring request for its requester. It
+// writes an error response and returns false otherwise.
+func loadRecurringRequest(c *gin.Context, db *gorm.DB) (*RecurringRequest, uint, bool) {
+	userID, err := getCurrentUser(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		return nil, 0, false
+	}
+
+	seriesID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid recurring request ID"})
+		return nil, 0, false
+	}
+
+	var series RecurringRequest
+	if err := db.First(&series, seriesID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Recurring request not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch recurring request"})
+		}
+		return nil, 0, false
+	}
+
+	if !checkTenantCommunity(c, series.CommunityID) {
+		return nil, 0, false
+	}
+	if series.RequesterID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"message": "Only the requester can manage a recurring request"})
+		return nil, 0, false
+	}
+
+	return &series, userID, true
+}
+
+// getRecurringRequestHandler handles GET /api/recurring-requests/:id (requester)
+// Requests posted for it are listed by GET /api/service-requests?recurring_request_id=.
+func getRecurringRequestHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		series, _, ok := loadRecurringRequest(c, db)
+		if !ok {
+			return
+		}
+
+		writeRecurringRequest(c, db, http.StatusOK, series.ID)
+	}
+}
+
+// updateRecurringRequestHandler handles PUT /api/recurring-requests/:id (requester)
+// Changing the schedule moves the next occurrence to the first one from now
+// under the new schedule.
+func updateRecurringRequestHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		series, _, ok := loadRecurringRequest(c, db)
+		if !ok {
+			return
+		}
+
+		var input recurringRequestInput
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		if series.Status == recurringStatusEnded {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "Ended recurring requests can't be changed",
+				"code":    errorCodeRecurrenceInactive,
+			})
+			return
+		}
+
+		status := series.Status
+		if !input.apply(c, series) {
+			return
+		}
+		rescheduled := input.RRule != nil || input.StartsAt != nil || input.TimeZone != nil
+		if rescheduled && !scheduleFromNow(c, series) {
+			return
+		}
+
+		result := db.Model(&RecurringRequest{}).
+			Where("id = ? AND status = ?", series.ID, status).
+			Updates(map[string]interface{}{
+				"title":           series.Title,
+				"description":     series.Description,
+				"category":        series.Category,
+				"budget":          series.Budget,
+				"rrule":           series.RRule,
+				"starts_at":       series.StartsAt,
+				"time_zone":       series.TimeZone,
+				"rebook_provider": series.RebookProvider,
+				"next_run_at":     series.NextRunAt,
+			})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update recurring request"})
+			return
+		}
+		if result.RowsAffected == 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "The recurring request was paused, resumed or ended in the meantime",
+				"code":    errorCodeRecurrenceInactive,
+			})
+			return
+		}
+
+		writeRecurringRequest(c, db, http.StatusOK, series.ID)
+	}
+}
+
+// pauseRecurringRequestHandler handles POST /api/recurring-requests/:id/pause (requester)
+func pauseRecurringRequestHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		series, userID, ok := loadRecurringRequest(c, db)
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return recurringRequestLifecycle.apply(tx, &RecurringRequest{}, series.ID, series.Status, recurringStatusPaused, &userID, "", nil)
+		})
+		if err != nil {
+			respondTransitionError(c, err, "Failed to pause recurring request")
+			return
+		}
+
+		writeRecurringRequest(c, db, http.StatusOK, series.ID)
+	}
+}
+
+// resumeRecurringRequestHandler handles POST /api/recurring-requests/:id/resume (requester)
+// The series picks up where it was paused, or at its first occurrence from now
+// when that has passed; occurrences missed while paused aren't posted. A
+// series with none left ends instead.
+func resumeRecurringRequestHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		series, userID, ok := loadRecurringRequest(c, db)
+		if !ok {
+			return
+		}
+
+		next := series.NextRunAt
+		if next == nil || !next.After(time.Now()) {
+			var err error
+			if next, err = nextOccurrence(series, time.Now()); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to schedule recurring request"})
+				return
+			}
+		}
+
+		to, reason := recurringStatusActive, ""
+		if next == nil {
+			to, reason = recurringStatusEnded, "no occurrences left"
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if series.Status != recurringStatusPaused {
+				return recurringRequestLifecycle.check(series.Status, recurringStatusActive)
+			}
+			return recurringRequestLifecycle.apply(tx, &RecurringRequest{}, series.ID, series.Status, to, &userID, reason,
+				map[string]interface{}{"next_run_at": next})
+		})
+		if err != nil {
+			respondTransitionError(c, err, "Failed to resume recurring request")
+			return
+		}
+
+		writeRecurringRequest(c, db, http.StatusOK, series.ID)
+	}
+}
+
+// skipRecurringRequestHandler handles POST /api/recurring-requests/:id/skip (requester)
+// Skips the next occurrence. Skipping the last one ends the series.
+func skipRecurringRequestHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		series, userID, ok := loadRecurringRequest(c, db)
+		if !ok {
+			return
+		}
+
+		if series.Status != recurringStatusActive || series.NextRunAt == nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "Only active recurring requests have an occurrence to skip",
+				"code":    errorCodeRecurrenceInactive,
+			})
+			return
+		}
+
+		next, err := nextOccurrence(series, *series.NextRunAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to schedule recurring request"})
+			return
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := lockRow(tx, "recurring_requests", series.ID); err != nil {
+				return err
+			}
+			var current RecurringRequest
+			if err := tx.First(&current, series.ID).Error; err != nil {
+				return err
+			}
+			if current.Status != recurringStatusActive || current.NextRunAt == nil || !current.NextRunAt.Equal(*series.NextRunAt) {
+				return errRecurrenceChanged
+			}
+
+			if next == nil {
+				return recurringRequestLifecycle.apply(tx, &RecurringRequest{}, series.ID, current.Status, recurringStatusEnded, &userID,
+					"last occurrence skipped", map[string]interface{}{"next_run_at": nil})
+			}
+			return tx.Model(&current).Update("next_run_at", *next).Error
+		})
+		if errors.Is(err, errRecurrenceChanged) {
+			c.JSON(http.StatusConflict, gin.H{
+				"message": "The recurring request changed in the meantime; reload and try again",
+				"code":    errorCodeRecurrenceInactive,
+			})
+			return
+		}
+		if err != nil {
+			respondTransitionError(c, err, "Failed to skip occurrence")
+			return
+		}
+
+		writeRecurringRequest(c, db, http.StatusOK, series.ID)
+	}
+}
+
+// endRecurringRequestHandler handles DELETE /api/recurring-requests/:id (requester)
+// Ends the series. Requests already posted are kept.
+func endRecurringRequestHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		series, userID, ok := loadRecurringRequest(c, db)
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			return recurringRequestLifecycle.apply(tx, &RecurringRequest{}, series.ID, series.Status, recurringStatusEnded, &userID, "",
+				map[string]interface{}{"next_run_at": nil})
+		})
+		if err != nil {
+			respondTransitionError(c, err, "Failed to end recurring request")
+			return
+		}
+
+		writeRecurringRequest(c, db, http.StatusOK, series.ID)
+	}
+}
+
+// postRecurringRequests posts a service request for every active series whose
+// next occurrence is due
+func postRecurringRequests(db *gorm.DB) error {
+	var due []RecurringRequest
+	if err := db.Where("status = ? AND next_run_at <= ?", recurringStatusActive, time.Now().UTC()).
+		Find(&due).Error; err != nil {
+		return err
+	}
+
+	for i := range due {
+		request, err := postOccurrence(db, &due[i])
+		if err != nil {
+			if errors.Is(err, errRecurrenceChanged) {
+				continue
+			}
+			// Keep going so one broken series doesn't hold up the others
+			log.Printf("Failed to post recurring request %d: %v", due[i].ID, err)
+			continue
+		}
+		log.Printf("Posted service request %d for recurring request %d", request.ID, due[i].ID)
+	}
+	return nil
+}
+
+// postOccurrence posts the service request for a series' due occurrence and
+// moves the series on to its next occurrence after now, ending it after the last
+func postOccurrence(db *gorm.DB, series *RecurringRequest) (*ServiceRequest, error) {
+	occurrence := *series.NextRunAt
+	after := time.Now()
+	if occurrence.After(after) {
+		after = occurrence
+	}
+	next, err := nextOccurrence(series, after)
+	if err != nil {
+		return nil, err
+	}
+
+	request := ServiceRequest{
+		Title:              series.Title,
+		Description:        series.Description,
+		Category:           series.Category,
+		RequesterID:        series.RequesterID,
+		CommunityID:        series.CommunityID,
+		Status:             requestStatusOpen,
+		Budget:             series.Budget,
+		RecurringRequestID: &series.ID,
+		ScheduledFor:       &occurrence,
+	}
+
+	err = transaction(db, func(tx *gorm.DB) error {
+		// Paused, skipped or edited since it was found due
+		if err := lockRow(tx, "recurring_requests", series.ID); err != nil {
+			return err
+		}
+		var current RecurringRequest
+		if err := tx.First(&current, series.ID).Error; err != nil {
+			return err
+		}
+		if current.Status != recurringStatusActive || current.NextRunAt == nil || !current.NextRunAt.Equal(occurrence) {
+			return errRecurrenceChanged
+		}
+
+		if err := tx.Create(&request).Error; err != nil {
+			return err
+		}
+		if err := queueServiceRequestWebhook(tx, &request, webhookServiceRequestCreated); err != nil {
+			return err
+		}
+
+		if next == nil {
+			if err := recurringRequestLifecycle.apply(tx, &RecurringRequest{}, series.ID, current.Status, recurringStatusEnded, nil,
+				"last occurrence posted", map[string]interface{}{"next_run_at": nil}); err != nil {
+				return err
+			}
+		} else if err := tx.Model(&current).Update("next_run_at", *next).Error; err != nil {
+			return err
+		}
+
+		if series.RebookProvider {
+			return rebookProvider(tx, series, &request)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// rebookProvider accepts an offer on a newly posted request from the provider
+// of the series' last accepted occurrence, on the terms agreed then. When that
+// fails the request is left open and the error is only logged.
+func rebookProvider(tx *gorm.DB, series *RecurringRequest, request *ServiceRequest) error {
+	var previous ServiceRequest
+	err := tx.Preload("AcceptedOffer.AgreedRevision").
+		Where("recurring_request_id = ? AND id != ? AND accepted_offer_id IS NOT NULL AND status != ?",
+			series.ID, request.ID, requestStatusCancelled).
+		Order("id DESC").
+		First(&previous).Error
+	if err == gorm.ErrRecordNotFound || (err == nil && previous.AcceptedOffer == nil) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	accepted := previous.AcceptedOffer
+	terms := offerTerms{
+		Price:             accepted.ProposedPrice,
+		Description:       accepted.Description,
+		EstimatedDuration: accepted.EstimatedDuration,
+	}
+	if agreed := accepted.AgreedRevision; agreed != nil {
+		terms = offerTerms{Price: agreed.Price, Description: agreed.Description, EstimatedDuration: agreed.EstimatedDuration}
+	}
+
+	if _, err := getCommunityMembership(tx, accepted.ProviderID, series.CommunityID); err != nil {
+		if err == gorm.ErrRecordNotFound {
+			log.Printf("Recurring request %d: provider %d left the community, service request %d left open",
+				series.ID, accepted.ProviderID, request.ID)
+			return nil
+		}
+		return err
+	}
+
+	// In a savepoint, so a failed charge only undoes the re-booking
+	err = transaction(tx, func(tx *gorm.DB) error {
+		offer := ServiceOffer{
+			ServiceRequestID:  request.ID,
+			ProviderID:        accepted.ProviderID,
+			Description:       terms.Description,
+			ProposedPrice:     terms.Price,
+			EstimatedDuration: terms.EstimatedDuration,
+			Status:            offerStatusPending,
+		}
+		if err := tx.Create(&offer).Error; err != nil {
+			return err
+		}
+		revision, err := createOfferRevision(tx, &offer, accepted.ProviderID, terms,
+			fmt.Sprintf("Re-booked on the terms of service request #%d", previous.ID))
+		if err != nil {
+			return err
+		}
+		return acceptOfferRevision(tx, request, &offer, revision, series.RequesterID)
+	})
+	if err != nil {
+		request.Status = requestStatusOpen
+		request.AcceptedOfferID = nil
+		log.Printf("Recurring request %d: failed to re-book provider %d, service request %d left open: %v",
+			series.ID, accepted.ProviderID, request.ID, err)
+	}
+	return nil
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
 
@@ -41,14 +43,19 @@ func listServiceRequests(c *gin.Context, db *gorm.DB) {
 		Preload("ServiceOffers").
 		Preload("ServiceOffers.Provider")
 
-	// Filter by community if specified
+	// Filter by community if specified, defaulting to the tenant community
 	if communityIDStr != "" {
 		communityID, err := strconv.ParseUint(communityIDStr, 10, 32)
 		if err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid community_id"})
 			return
 		}
+		if !checkTenantCommunity(c, uint(communityID)) {
+			return
+		}
 		query = query.Where("community_id = ?", communityID)
+	} else if tenant := getTenant(c); tenant != nil {
+		query = query.Where("community_id = ?", tenant.ID)
 	}
 
 	// Filter by status if specified
@@ -61,6 +68,11 @@ func listServiceRequests(c *gin.Context, db *gorm.DB) {
 		query = query.Where("category = ?", category)
 	}
 
+	// Filter by the recurring request that posted them
+	if recurringID := c.Query("recurring_request_id"); recurringID != "" {
+		query = query.Where("recurring_request_id = ?", recurringID)
+	}
+
 	var requests []ServiceRequest
 	if err := query.Order("created_at DESC").Find(&requests).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service requests"})
@@ -91,6 +103,15 @@ func createServiceRequest(c *gin.Context, db *gorm.DB) {
 		return
 	}
 
+	// Requests made on a community's own domain belong to that community
+	if tenant := getTenant(c); tenant != nil {
+		if input.CommunityID == 0 {
+			input.CommunityID = tenant.ID
+		} else if !checkTenantCommunity(c, input.CommunityID) {
+			return
+		}
+	}
+
 	// Validate required fields
 	if input.Title == "" || input.Description == "" || input.CommunityID == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Title, description, and community_id are required"})
@@ -104,11 +125,17 @@ func createServiceRequest(c *gin.Context, db *gorm.DB) {
 		Category:    input.Category,
 		RequesterID: userID,
 		CommunityID: input.CommunityID,
-		Status:      "open",
+		Status:      requestStatusOpen,
 		Budget:      input.Budget,
 	}
 
-	if err := db.Create(&request).Error; err != nil {
+	err = transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Create(&request).Error; err != nil {
+			return err
+		}
+		return queueServiceRequestWebhook(tx, &request, webhookServiceRequestCreated)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create service request"})
 		return
 	}
@@ -137,10 +164,24 @@ func serviceRequestDetailHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Handle accept offer endpoint - check if this is the accept-offer sub-route
-		if c.Param("action") == "accept-offer" {
+		// Handle sub-routes such as /service-requests/:id/accept-offer
+		switch c.Param("action") {
+		case "":
+		case "accept-offer":
 			acceptServiceOffer(c, db, uint(requestID))
 			return
+		case "mark-done":
+			markServiceRequestDone(c, db, uint(requestID))
+			return
+		case "confirm":
+			confirmServiceRequest(c, db, uint(requestID))
+			return
+		case "dispute":
+			disputeServiceRequest(c, db, uint(requestID))
+			return
+		default:
+			c.JSON(http.StatusNotFound, gin.H{"message": "Not found"})
+			return
 		}
 
 		switch c.Request.Method {
@@ -165,6 +206,8 @@ func getServiceRequest(c *gin.Context, db *gorm.DB, requestID uint) {
 		Preload("ServiceOffers.Provider").
 		Preload("AcceptedOffer").
 		Preload("AcceptedOffer.Provider").
+		Preload("AcceptedOffer.AgreedRevision").
+		Preload("AcceptedOffer.Milestones", orderedMilestones).
 		First(&request, requestID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusNotFound, gin.H{"message": "Service request not found"})
@@ -185,8 +228,6 @@ func updateServiceRequest(c *gin.Context, db *gorm.DB, requestID uint) {
 		return
 	}
 
-	userRole, _ := c.Get("userRole")
-
 	var request ServiceRequest
 	if err := db.First(&request, requestID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -197,10 +238,11 @@ func updateServiceRequest(c *gin.Context, db *gorm.DB, requestID uint) {
 		return
 	}
 
-	// Only requester can update
-	if request.RequesterID != userID && userRole != RoleSuperAdmin && userRole != RoleAdmin {
-		c.JSON(http.StatusForbidden, gin.H{"message": "Unauthorized"})
-		return
+	// Only the requester or a community moderator can update
+	if request.RequesterID != userID {
+		if _, ok := authorizeCommunityRole(c, db, request.CommunityID, RoleAdmin, RoleModerator); !ok {
+			return
+		}
 	}
 
 	var input struct {
@@ -230,12 +272,28 @@ func updateServiceRequest(c *gin.Context, db *gorm.DB, requestID uint) {
 	if input.Budget != nil {
 		updates["budget"] = *input.Budget
 	}
-	if input.Status != nil {
-		updates["status"] = *input.Status
+
+	if input.Status != nil && !serviceRequestLifecycle.isStatus(*input.Status) {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid status"})
+		return
+	}
+	if input.Status != nil && !checkDirectStatusChange(c, request.Status, *input.Status) {
+		return
 	}
 
-	if err := db.Model(&request).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update service request"})
+	err = transaction(db, func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&request).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if input.Status != nil && *input.Status != request.Status {
+			return transitionServiceRequest(tx, &request, *input.Status, &userID, "")
+		}
+		return nil
+	})
+	if err != nil {
+		respondTransitionError(c, err, "Failed to update service request")
 		return
 	}
 
@@ -256,8 +314,6 @@ func deleteServiceRequest(c *gin.Context, db *gorm.DB, requestID uint) {
 		return
 	}
 
-	userRole, _ := c.Get("userRole")
-
 	var request ServiceRequest
 	if err := db.First(&request, requestID).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -268,21 +324,58 @@ func deleteServiceRequest(c *gin.Context, db *gorm.DB, requestID uint) {
 		return
 	}
 
-	// Only requester or admin can delete
-	if request.RequesterID != userID && userRole != RoleSuperAdmin && userRole != RoleAdmin {
-		c.JSON(http.StatusForbidden, gin.H{"message": "Unauthorized"})
-		return
+	// Only the requester or a community moderator can delete
+	if request.RequesterID != userID {
+		if _, ok := authorizeCommunityRole(c, db, request.CommunityID, RoleAdmin, RoleModerator); !ok {
+			return
+		}
 	}
 
-	// Soft delete
-	if err := db.Delete(&request).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete service request"})
+	if err := removeServiceRequest(db, &request, &userID); err != nil {
+		respondRemoveServiceRequestError(c, err)
 		return
 	}
 
 	c.Status(http.StatusNoContent)
 }
 
+// errorCodeRequestNotDeletable is returned in the "code" field when a request
+// is too far through completion to be deleted
+const errorCodeRequestNotDeletable = "request_not_deletable"
+
+var errRequestNotDeletable = errors.New("service request can't be deleted")
+
+// removeServiceRequest soft-deletes a request. Open and in-progress requests
+// are cancelled first, so pending offers are rejected, appointments cancelled
+// and any escrow refunded; requests awaiting confirmation, disputed or
+// completed can't be deleted.
+func removeServiceRequest(db *gorm.DB, request *ServiceRequest, actorID *uint) error {
+	return transaction(db, func(tx *gorm.DB) error {
+		switch request.Status {
+		case requestStatusOpen, requestStatusInProgress:
+			if err := transitionServiceRequest(tx, request, requestStatusCancelled, actorID, "request deleted"); err != nil {
+				return err
+			}
+		case requestStatusCancelled:
+		default:
+			return errRequestNotDeletable
+		}
+		return tx.Delete(request).Error
+	})
+}
+
+// respondRemoveServiceRequestError writes the response for an error from removeServiceRequest
+func respondRemoveServiceRequestError(c *gin.Context, err error) {
+	if err == errRequestNotDeletable {
+		c.JSON(http.StatusConflict, gin.H{
+			"message": "Only open, in progress or cancelled service requests can be deleted",
+			"code":    errorCodeRequestNotDeletable,
+		})
+		return
+	}
+	respondTransitionError(c, err, "Failed to delete service request")
+}
+
 // acceptServiceOffer handles PUT /api/service-requests/:id/accept-offer
 func acceptServiceOffer(c *gin.Context, db *gorm.DB, requestID uint) {
 	if c.Request.Method != http.MethodPost && c.Request.Method != http.MethodPut {
@@ -298,6 +391,7 @@ func acceptServiceOffer(c *gin.Context, db *gorm.DB, requestID uint) {
 
 	var input struct {
 		OfferID uint `json:"offer_id"`
+		Version int  `json:"version"` // Optional: the revision the requester is accepting
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -342,33 +436,23 @@ func acceptServiceOffer(c *gin.Context, db *gorm.DB, requestID uint) {
 		return
 	}
 
-	// Update request and offer in transaction
-	err = db.Transaction(func(tx *gorm.DB) error {
-		// Update service request
-		if err := tx.Model(&request).Updates(map[string]interface{}{
-			"accepted_offer_id": input.OfferID,
-			"status":            "in_progress",
-		}).Error; err != nil {
-			return err
-		}
-
-		// Update accepted offer status
-		if err := tx.Model(&offer).Update("status", "accepted").Error; err != nil {
-			return err
-		}
-
-		// Reject other offers
-		if err := tx.Model(&ServiceOffer{}).
-			Where("service_request_id = ? AND id != ?", requestID, input.OfferID).
-			Update("status", "rejected").Error; err != nil {
-			return err
-		}
+	// The requester can only accept terms the provider has put forward
+	revision, err := latestOfferRevision(db, offer.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch current terms"})
+		return
+	}
+	if !checkAcceptableRevision(c, revision, userID, input.Version) {
+		return
+	}
 
-		return nil
+	// Update request and offer in transaction
+	err = transaction(db, func(tx *gorm.DB) error {
+		return acceptOfferRevision(tx, &request, &offer, revision, userID)
 	})
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to accept offer"})
+		respondTransitionError(c, err, "Failed to accept offer")
 		return
 	}
 
@@ -490,7 +574,7 @@ func createServiceOffer(c *gin.Context, db *gorm.DB) {
 		return
 	}
 
-	if request.Status != "open" {
+	if request.Status != requestStatusOpen {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Cannot create offer for non-open requests"})
 		return
 	}
@@ -502,10 +586,32 @@ func createServiceOffer(c *gin.Context, db *gorm.DB) {
 		Description:       input.Description,
 		ProposedPrice:     input.ProposedPrice,
 		EstimatedDuration: input.EstimatedDuration,
-		Status:            "pending",
+		Status:            offerStatusPending,
 	}
 
-	if err := db.Create(&offer).Error; err != nil {
+	// The original terms are the first revision of the negotiation
+	err = transaction(db, func(tx *gorm.DB) error {
+		if err := tx.Create(&offer).Error; err != nil {
+			return err
+		}
+		if _, err := createOfferRevision(tx, &offer, userID, offerTerms{
+			Price:             offer.ProposedPrice,
+			Description:       offer.Description,
+			EstimatedDuration: offer.EstimatedDuration,
+		}, ""); err != nil {
+			return err
+		}
+
+		queueEvent(tx, eventOfferCreated, []uint{request.RequesterID}, map[string]interface{}{
+			"offer_id":           offer.ID,
+			"service_request_id": request.ID,
+		})
+		return notify(tx, request.RequesterID, notificationOfferReceived,
+			fmt.Sprintf("New offer on %q", request.Title),
+			currentUserName(c)+": "+offer.Description,
+			fmt.Sprintf("/service-requests/%d", request.ID))
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create service offer"})
 		return
 	}
@@ -534,10 +640,30 @@ func serviceOfferDetailHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Handle withdraw endpoint
-		if c.Param("action") == "withdraw" {
+		// Handle sub-routes such as /service-offers/:id/withdraw
+		switch c.Param("action") {
+		case "":
+		case "withdraw":
 			withdrawServiceOffer(c, db, uint(offerID))
 			return
+		case "revisions":
+			getOfferRevisions(c, db, uint(offerID))
+			return
+		case "counter":
+			counterServiceOffer(c, db, uint(offerID))
+			return
+		case "accept-terms":
+			acceptOfferTerms(c, db, uint(offerID))
+			return
+		case "milestones":
+			offerMilestones(c, db, uint(offerID))
+			return
+		case "approve-milestones":
+			approveOfferMilestones(c, db, uint(offerID))
+			return
+		default:
+			c.JSON(http.StatusNotFound, gin.H{"message": "Not found"})
+			return
 		}
 
 		switch c.Request.Method {
@@ -582,15 +708,12 @@ func withdrawServiceOffer(c *gin.Context, db *gorm.DB, offerID uint) {
 		return
 	}
 
-	// Cannot withdraw accepted offers
-	if offer.Status == "accepted" {
-		c.JSON(http.StatusBadRequest, gin.H{"message": "Cannot withdraw accepted offers"})
-		return
-	}
-
-	// Update status to withdrawn
-	if err := db.Model(&offer).Update("status", "withdrawn").Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to withdraw service offer"})
+	// Only pending offers can be withdrawn
+	err = transaction(db, func(tx *gorm.DB) error {
+		return transitionServiceOffer(tx, &offer, offerStatusWithdrawn, &userID, "")
+	})
+	if err != nil {
+		respondTransitionError(c, err, "Failed to withdraw service offer")
 		return
 	}
 
@@ -657,23 +780,57 @@ func updateServiceOffer(c *gin.Context, db *gorm.DB, offerID uint) {
 		return
 	}
 
-	// Update fields if provided
-	updates := make(map[string]interface{})
+	// Changed terms are recorded as a new revision of the offer
+	terms := offerTerms{Price: offer.ProposedPrice, Description: offer.Description, EstimatedDuration: offer.EstimatedDuration}
 	if input.Description != nil {
-		updates["description"] = *input.Description
+		terms.Description = *input.Description
 	}
 	if input.ProposedPrice != nil {
-		updates["proposed_price"] = *input.ProposedPrice
+		terms.Price = *input.ProposedPrice
 	}
 	if input.EstimatedDuration != nil {
-		updates["estimated_duration"] = *input.EstimatedDuration
+		terms.EstimatedDuration = *input.EstimatedDuration
+	}
+	termsChanged := terms.Price != offer.ProposedPrice ||
+		terms.Description != offer.Description ||
+		terms.EstimatedDuration != offer.EstimatedDuration
+
+	if termsChanged {
+		var request ServiceRequest
+		if err := db.First(&request, offer.ServiceRequestID).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service request"})
+			return
+		}
+		if !checkNegotiable(c, &offer, &request) {
+			return
+		}
 	}
-	if input.Status != nil {
-		updates["status"] = *input.Status
+
+	// Providers can only withdraw; accepting and rejecting belong to the requester
+	if input.Status != nil && *input.Status != offer.Status {
+		if !serviceOfferLifecycle.isStatus(*input.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid status"})
+			return
+		}
+		if *input.Status != offerStatusWithdrawn {
+			c.JSON(http.StatusForbidden, gin.H{"message": "Only the requester can accept or reject offers"})
+			return
+		}
 	}
 
-	if err := db.Model(&offer).Updates(updates).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update service offer"})
+	err = transaction(db, func(tx *gorm.DB) error {
+		if termsChanged {
+			if _, err := createOfferRevision(tx, &offer, userID, terms, ""); err != nil {
+				return err
+			}
+		}
+		if input.Status != nil && *input.Status != offer.Status {
+			return transitionServiceOffer(tx, &offer, *input.Status, &userID, "")
+		}
+		return nil
+	})
+	if err != nil {
+		respondTransitionError(c, err, "Failed to update service offer")
 		return
 	}
 
@@ -711,7 +868,7 @@ func deleteServiceOffer(c *gin.Context, db *gorm.DB, offerID uint) {
 	}
 
 	// Cannot delete accepted offers
-	if offer.Status == "accepted" {
+	if offer.Status == offerStatusAccepted {
 		c.JSON(http.StatusBadRequest, gin.H{"message": "Cannot withdraw accepted offers"})
 		return
 	}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"net/http"
 	"strconv"
 
@@ -30,6 +29,9 @@ func getServicesHandler(db *gorm.DB) gin.HandlerFunc {
 		if communityID != "" {
 			query = query.Where("community_id = ?", communityID)
 		}
+		if tenant := getTenant(c); tenant != nil {
+			query = query.Where("community_id = ?", tenant.ID)
+		}
 		if search != "" {
 			searchPattern := "%" + search + "%"
 			query = query.Where("title LIKE ? OR description LIKE ?", searchPattern, searchPattern)
@@ -67,8 +69,8 @@ func getServiceByIDHandler(db *gorm.DB) gin.HandlerFunc {
 			Preload("Community").
 			Preload("ServiceOffers").
 			Preload("ServiceOffers.Provider").
-			Preload("Comments").
-			Preload("Comments.Author").
+			Preload("Comments", "is_removed = ?", false).
+			Preload("Comments.Author", publicUserColumns).
 			First(&service, id).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.JSON(http.StatusNotFound, gin.H{"message": "Service not found"})
@@ -119,11 +121,17 @@ func createServiceRequestHandler(db *gorm.DB) gin.HandlerFunc {
 			Category:    input.Category,
 			RequesterID: userID,
 			CommunityID: input.CommunityID,
-			Status:      "open",
+			Status:      requestStatusOpen,
 			Budget:      input.Budget,
 		}
 
-		if err := db.Create(&service).Error; err != nil {
+		err = transaction(db, func(tx *gorm.DB) error {
+			if err := tx.Create(&service).Error; err != nil {
+				return err
+			}
+			return queueServiceRequestWebhook(tx, &service, webhookServiceRequestCreated)
+		})
+		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create service request"})
 			return
 		}
@@ -147,16 +155,14 @@ type UpdateServiceRequestInput struct {
 // updateServiceRequestHandler handles PUT /api/services/{id}
 func updateServiceRequestHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, err := getCurrentUser(c)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		authMiddleware(db)(c)
+		if c.IsAborted() {
 			return
 		}
 
-		// Fetch current user to check role
-		var currentUser User
-		if err := db.First(&currentUser, userID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "User not found"})
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
 			return
 		}
 
@@ -178,10 +184,11 @@ func updateServiceRequestHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Check authorization (only requester or admin can update)
-		if service.RequesterID != userID && currentUser.Role != RoleSuperAdmin && currentUser.Role != RoleAdmin {
-			c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
-			return
+		// Only the requester or a community moderator can update
+		if service.RequesterID != userID {
+			if _, ok := authorizeCommunityRole(c, db, service.CommunityID, RoleAdmin, RoleModerator); !ok {
+				return
+			}
 		}
 
 		var input UpdateServiceRequestInput
@@ -190,55 +197,43 @@ func updateServiceRequestHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Update fields
+		// Update fields if provided
+		updates := make(map[string]interface{})
 		if input.Title != nil {
-			service.Title = *input.Title
+			updates["title"] = *input.Title
 		}
 		if input.Description != nil {
-			service.Description = *input.Description
+			updates["description"] = *input.Description
 		}
 		if input.Category != nil {
-			service.Category = *input.Category
-		}
-		// Update status with validation
-		if input.Status != nil {
-			newStatus := *input.Status
-			// Validate state transitions
-			validTransitions := map[string][]string{
-				"open":        {"in_progress", "cancelled"},
-				"in_progress": {"completed", "cancelled"},
-				"completed":   {}, // Cannot transition from completed
-				"cancelled":   {}, // Cannot transition from cancelled
-			}
+			updates["category"] = *input.Category
+		}
+		if input.Budget != nil {
+			updates["budget"] = *input.Budget
+		}
 
-			allowedNextStates, exists := validTransitions[service.Status]
-			if !exists {
-				c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid current status"})
-				return
-			}
+		if input.Status != nil && !serviceRequestLifecycle.isStatus(*input.Status) {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid status"})
+			return
+		}
+		if input.Status != nil && !checkDirectStatusChange(c, service.Status, *input.Status) {
+			return
+		}
 
-			// Check if transition is valid
-			validTransition := false
-			for _, allowed := range allowedNextStates {
-				if newStatus == allowed {
-					validTransition = true
-					break
+		// Status changes go through the lifecycle
+		err = transaction(db, func(tx *gorm.DB) error {
+			if len(updates) > 0 {
+				if err := tx.Model(&service).Updates(updates).Error; err != nil {
+					return err
 				}
 			}
-
-			if !validTransition && newStatus != service.Status {
-				c.JSON(http.StatusBadRequest, gin.H{"message": fmt.Sprintf("Invalid status transition from %s to %s", service.Status, newStatus)})
-				return
+			if input.Status != nil && *input.Status != service.Status {
+				return transitionServiceRequest(tx, &service, *input.Status, &userID, "")
 			}
-
-			service.Status = newStatus
-		}
-		if input.Budget != nil {
-			service.Budget = *input.Budget
-		}
-
-		if err := db.Save(&service).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update service request"})
+			return nil
+		})
+		if err != nil {
+			respondTransitionError(c, err, "Failed to update service request")
 			return
 		}
 
@@ -252,16 +247,14 @@ func updateServiceRequestHandler(db *gorm.DB) gin.HandlerFunc {
 // deleteServiceRequestHandler handles DELETE /api/services/{id}
 func deleteServiceRequestHandler(db *gorm.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, err := getCurrentUser(c)
-		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+		authMiddleware(db)(c)
+		if c.IsAborted() {
 			return
 		}
 
-		// Fetch current user to check role
-		var currentUser User
-		if err := db.First(&currentUser, userID).Error; err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{"message": "User not found"})
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
 			return
 		}
 
@@ -283,15 +276,15 @@ func deleteServiceRequestHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
-		// Check authorization (only requester or admin can delete)
-		if service.RequesterID != userID && currentUser.Role != RoleSuperAdmin && currentUser.Role != RoleAdmin {
-			c.JSON(http.StatusForbidden, gin.H{"message": "Forbidden"})
-			return
+		// Only the requester or a community moderator can delete
+		if service.RequesterID != userID {
+			if _, ok := authorizeCommunityRole(c, db, service.CommunityID, RoleAdmin, RoleModerator); !ok {
+				return
+			}
 		}
 
-		// Soft delete
-		if err := db.Delete(&service).Error; err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete service request"})
+		if err := removeServiceRequest(db, &service, &userID); err != nil {
+			respondRemoveServiceRequestError(c, err)
 			return
 		}
 
@@ -0,0 +1,421 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Sessions and refresh tokens
+//
+// Logging in creates a Session and returns a short-lived access token (JWT
+// carrying the session ID) plus a refresh token. Each refresh token can be
+// used once; using it returns a new pair. Presenting an already-used refresh
+// token means it was copied, so the whole session is revoked.
+
+const (
+	accessTokenTTL  = 15 * time.Minute
+	refreshTokenTTL = 30 * 24 * time.Hour
+
+	// lastUsedResolution limits how often authMiddleware writes Session.LastUsedAt
+	lastUsedResolution = time.Minute
+)
+
+// Session revocation reasons
+const (
+	revokeReasonLogout          = "logout"
+	revokeReasonLogoutAll       = "logout_all"
+	revokeReasonRevokedByUser   = "revoked_by_user"
+	revokeReasonRefreshReuse    = "refresh_token_reuse"
+	revokeReasonUserDeactivated = "user_deactivated"
+	revokeReasonUserDeleted     = "user_deleted"
+	revokeReasonPasswordChanged = "password_changed"
+	revokeReasonPasswordReset   = "password_reset"
+)
+
+var (
+	errInvalidRefreshToken = errors.New("invalid refresh token")
+	errRefreshTokenReused  = errors.New("refresh token reuse detected")
+	errSessionRevoked      = errors.New("session revoked or expired")
+)
+
+// generateSecureToken returns a random URL-safe token
+func generateSecureToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken returns the hex SHA-256 of a token for storage and lookup
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueRefreshToken creates the next refresh token in a session's chain
+func issueRefreshToken(tx *gorm.DB, session *Session) (string, error) {
+	token, err := generateSecureToken()
+	if err != nil {
+		return "", err
+	}
+
+	refreshToken := RefreshToken{
+		SessionID: session.ID,
+		TokenHash: hashToken(token),
+		ExpiresAt: session.ExpiresAt,
+	}
+	if err := tx.Create(&refreshToken).Error; err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// startSession creates a session for the user and sets the auth cookies.
+// It returns the access and refresh tokens so they can also be sent in the body.
+func startSession(c *gin.Context, db *gorm.DB, user *User) (string, string, error) {
+	now := time.Now()
+	session := Session{
+		UserID:     user.ID,
+		UserAgent:  c.Request.UserAgent(),
+		IPAddress:  c.ClientIP(),
+		LastUsedAt: now,
+		ExpiresAt:  now.Add(refreshTokenTTL),
+	}
+
+	var refreshToken string
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&session).Error; err != nil {
+			return err
+		}
+
+		var err error
+		refreshToken, err = issueRefreshToken(tx, &session)
+		return err
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	accessToken, err := generateToken(user, session.ID)
+	if err != nil {
+		return "", "", err
+	}
+
+	setAuthCookie(c, accessToken)
+	setRefreshCookie(c, refreshToken)
+
+	return accessToken, refreshToken, nil
+}
+
+// rotateRefreshToken exchanges a refresh token for a new one and returns the session.
+// Reusing a token that was already exchanged revokes the session.
+func rotateRefreshToken(db *gorm.DB, token string) (*Session, string, error) {
+	var session Session
+	var newToken string
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var current RefreshToken
+		if err := tx.Where("token_hash = ?", hashToken(token)).First(&current).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errInvalidRefreshToken
+			}
+			return err
+		}
+
+		if err := tx.First(&session, current.SessionID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return errInvalidRefreshToken
+			}
+			return err
+		}
+
+		now := time.Now()
+		if session.RevokedAt != nil || now.After(session.ExpiresAt) || now.After(current.ExpiresAt) {
+			return errSessionRevoked
+		}
+
+		// Only one caller can mark the token used; anyone else is replaying it
+		result := tx.Model(&RefreshToken{}).
+			Where("id = ? AND used_at IS NULL", current.ID).
+			Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errRefreshTokenReused
+		}
+
+		if err := tx.Model(&session).Update("last_used_at", now).Error; err != nil {
+			return err
+		}
+
+		var err error
+		newToken, err = issueRefreshToken(tx, &session)
+		return err
+	})
+
+	if err == errRefreshTokenReused {
+		// Revoke outside the rolled-back transaction
+		revokeSession(db, session.ID, revokeReasonRefreshReuse)
+	}
+	if err != nil {
+		return nil, "", err
+	}
+
+	return &session, newToken, nil
+}
+
+// validateSession checks that an access token's session is still live
+func validateSession(db *gorm.DB, sessionID, userID uint) (*Session, error) {
+	var session Session
+	if err := db.First(&session, sessionID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return nil, errSessionRevoked
+		}
+		return nil, err
+	}
+
+	if session.UserID != userID || session.RevokedAt != nil || time.Now().After(session.ExpiresAt) {
+		return nil, errSessionRevoked
+	}
+
+	if time.Since(session.LastUsedAt) > lastUsedResolution {
+		db.Model(&session).UpdateColumn("last_used_at", time.Now())
+	}
+
+	return &session, nil
+}
+
+// revokeSession revokes a single session
+func revokeSession(db *gorm.DB, sessionID uint, reason string) error {
+	return db.Model(&Session{}).
+		Where("id = ? AND revoked_at IS NULL", sessionID).
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason}).Error
+}
+
+// revokeUserSessions revokes every session of a user except the ones listed in keep
+func revokeUserSessions(db *gorm.DB, userID uint, reason string, keep ...uint) error {
+	query := db.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", userID)
+	if len(keep) > 0 {
+		query = query.Where("id NOT IN ?", keep)
+	}
+	return query.Updates(map[string]interface{}{"revoked_at": time.Now(), "revoke_reason": reason}).Error
+}
+
+// getCurrentSessionID returns the session of the authenticated request
+func getCurrentSessionID(c *gin.Context) uint {
+	sessionID, exists := c.Get("sessionID")
+	if !exists {
+		return 0
+	}
+	return sessionID.(uint)
+}
+
+func setRefreshCookie(c *gin.Context, token string) {
+	c.SetCookie(
+		"refresh_token",
+		token,
+		int(refreshTokenTTL.Seconds()),
+		"/api/auth",
+		"",
+		os.Getenv("MODE") == "production",
+		true, // HttpOnly
+	)
+}
+
+func clearRefreshCookie(c *gin.Context) {
+	c.SetCookie(
+		"refresh_token",
+		"",
+		-1,
+		"/api/auth",
+		"",
+		os.Getenv("MODE") == "production",
+		true, // HttpOnly
+	)
+}
+
+// getRefreshToken reads the refresh token from the cookie or the JSON body
+func getRefreshToken(c *gin.Context) string {
+	if token, err := c.Cookie("refresh_token"); err == nil && token != "" {
+		return token
+	}
+
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := c.ShouldBindJSON(&req); err == nil {
+		return req.RefreshToken
+	}
+	return ""
+}
+
+// Session handlers
+
+func refreshTokenHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		token := getRefreshToken(c)
+		if token == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Refresh token is required"})
+			return
+		}
+
+		session, refreshToken, err := rotateRefreshToken(db, token)
+		if err != nil {
+			clearAuthCookie(c)
+			switch err {
+			case errInvalidRefreshToken, errSessionRevoked, errRefreshTokenReused:
+				c.JSON(http.StatusUnauthorized, gin.H{"message": "Invalid or expired refresh token"})
+			default:
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to refresh session"})
+			}
+			return
+		}
+
+		var user User
+		if err := db.First(&user, session.UserID).Error; err != nil {
+			clearAuthCookie(c)
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "User not found"})
+			return
+		}
+
+		if !user.IsActive {
+			revokeSession(db, session.ID, revokeReasonUserDeactivated)
+			clearAuthCookie(c)
+			c.JSON(http.StatusForbidden, gin.H{"message": "User is inactive"})
+			return
+		}
+
+		accessToken, err := generateToken(&user, session.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate token"})
+			return
+		}
+
+		setAuthCookie(c, accessToken)
+		setRefreshCookie(c, refreshToken)
+
+		c.JSON(http.StatusOK, gin.H{
+			"user":          sanitizeUser(&user),
+			"token":         accessToken,
+			"refresh_token": refreshToken,
+			"expires_in":    int(accessTokenTTL.Seconds()),
+		})
+	}
+}
+
+func logoutAllHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		if err := revokeUserSessions(db, userID, revokeReasonLogoutAll); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to revoke sessions"})
+			return
+		}
+
+		clearAuthCookie(c)
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out of all devices"})
+	}
+}
+
+func getSessionsHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		var sessions []Session
+		if err := db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, time.Now()).
+			Order("last_used_at DESC").
+			Find(&sessions).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch sessions"})
+			return
+		}
+
+		currentSessionID := getCurrentSessionID(c)
+		result := make([]map[string]interface{}, len(sessions))
+		for i, session := range sessions {
+			result[i] = map[string]interface{}{
+				"ID":         session.ID,
+				"CreatedAt":  session.CreatedAt,
+				"LastUsedAt": session.LastUsedAt,
+				"ExpiresAt":  session.ExpiresAt,
+				"UserAgent":  session.UserAgent,
+				"IPAddress":  session.IPAddress,
+				"Current":    session.ID == currentSessionID,
+			}
+		}
+
+		c.JSON(http.StatusOK, result)
+	}
+}
+
+func revokeSessionHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		userID, err := getCurrentUser(c)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"message": "Unauthorized"})
+			return
+		}
+
+		sessionID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid session ID"})
+			return
+		}
+
+		var session Session
+		if err := db.Where("user_id = ?", userID).First(&session, sessionID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Session not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch session"})
+			}
+			return
+		}
+
+		if err := revokeSession(db, session.ID, revokeReasonRevokedByUser); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to revoke session"})
+			return
+		}
+
+		if session.ID == getCurrentSessionID(c) {
+			clearAuthCookie(c)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+	}
+}
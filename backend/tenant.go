@@ -0,0 +1,124 @@
+package main
+
+import (
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Host-based tenant resolution
+//
+// Requests to a community's subdomain or custom domain are scoped to that
+// community. The resolved community is stored in the context as "tenant".
+
+const (
+	tenantFallbackNone   = "none"   // unknown hosts see every community
+	tenantFallbackReject = "reject" // unknown hosts get a 404
+)
+
+// TenantConfig controls how hosts are mapped to communities
+type TenantConfig struct {
+	// BaseDomain is the shared domain community subdomains live under (e.g.
+	// "commune.com"); without it, only custom domains are matched
+	BaseDomain string
+	// Fallback is "none", "reject" or the slug of a community to use as the default tenant
+	Fallback string
+}
+
+// loadTenantConfig reads TENANT_BASE_DOMAIN and TENANT_FALLBACK (default
+// "none") from the environment
+func loadTenantConfig() TenantConfig {
+	fallback := strings.TrimSpace(os.Getenv("TENANT_FALLBACK"))
+	if fallback == "" {
+		fallback = tenantFallbackNone
+	}
+	return TenantConfig{
+		BaseDomain: normalizeHost(strings.TrimSpace(os.Getenv("TENANT_BASE_DOMAIN"))),
+		Fallback:   fallback,
+	}
+}
+
+// normalizeHost strips the port and trailing dot from a Host header and lowercases it
+func normalizeHost(host string) string {
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	return strings.TrimSuffix(strings.ToLower(host), ".")
+}
+
+// Middleware to resolve the tenant community from the Host header
+func tenantMiddleware(db *gorm.DB, config TenantConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		host := normalizeHost(c.Request.Host)
+
+		// IP addresses and bare hostnames such as "localhost" never map to a community
+		if host != "" && net.ParseIP(host) == nil && strings.Contains(host, ".") {
+			community, err := GetCommunityByDomain(db, host, config.BaseDomain)
+			if err == nil {
+				c.Set("tenant", community)
+				c.Next()
+				return
+			}
+			if err != gorm.ErrRecordNotFound {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to resolve community"})
+				c.Abort()
+				return
+			}
+		}
+
+		switch config.Fallback {
+		case tenantFallbackNone:
+		case tenantFallbackReject:
+			c.JSON(http.StatusNotFound, gin.H{"message": "Unknown community"})
+			c.Abort()
+			return
+		default:
+			community, err := GetCommunityBySlug(db, config.Fallback)
+			if err != nil {
+				log.Printf("Tenant fallback community %q not found: %v", config.Fallback, err)
+				c.JSON(http.StatusNotFound, gin.H{"message": "Unknown community"})
+				c.Abort()
+				return
+			}
+			c.Set("tenant", community)
+		}
+
+		c.Next()
+	}
+}
+
+// getTenant returns the community resolved from the Host header, or nil
+func getTenant(c *gin.Context) *Community {
+	tenant, exists := c.Get("tenant")
+	if !exists {
+		return nil
+	}
+	return tenant.(*Community)
+}
+
+// checkTenantCommunity writes a 404 and returns false when a tenant is active
+// and the community belongs to a different one
+func checkTenantCommunity(c *gin.Context, communityID uint) bool {
+	if tenant := getTenant(c); tenant != nil && tenant.ID != communityID {
+		c.JSON(http.StatusNotFound, gin.H{"message": "Community not found"})
+		return false
+	}
+	return true
+}
+
+// getTenantHandler handles GET /api/tenant
+func getTenantHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tenant := getTenant(c)
+		if tenant == nil {
+			c.JSON(http.StatusOK, gin.H{"tenant": nil})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"tenant": tenant})
+	}
+}
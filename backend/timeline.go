@@ -0,0 +1,245 @@
+package main
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Service request timeline
+//
+// Merges the request's creation, its status changes, its offers with their
+// counter-offers and status changes, milestone progress, appointments, and the comments on
+// the request, its offers and milestones into one oldest-first feed for the
+// request detail page.
+
+// Timeline entry types
+const (
+	timelineRequestCreated       = "request_created"
+	timelineStatusChanged        = "status_changed"
+	timelineOfferSubmitted       = "offer_submitted"
+	timelineOfferCountered       = "offer_countered"
+	timelineOfferAccepted        = "offer_accepted"
+	timelineOfferRejected        = "offer_rejected"
+	timelineOfferWithdrawn       = "offer_withdrawn"
+	timelineOfferExpired         = "offer_expired"
+	timelineMilestone            = "milestone_updated"
+	timelineAppointment          = "appointment_booked"
+	timelineAppointmentCancelled = "appointment_cancelled"
+	timelineComment              = "comment"
+)
+
+// timelineOfferTypes maps an offer's new status to its entry type
+var timelineOfferTypes = map[string]string{
+	offerStatusAccepted:  timelineOfferAccepted,
+	offerStatusRejected:  timelineOfferRejected,
+	offerStatusWithdrawn: timelineOfferWithdrawn,
+	offerStatusExpired:   timelineOfferExpired,
+}
+
+// timelineEntry is one item in the feed. Only the fields relevant to the
+// entry's type are set.
+type timelineEntry struct {
+	Type            string     `json:"type"`
+	At              time.Time  `json:"at"`
+	Actor           *User      `json:"actor,omitempty"` // nil for automatic changes
+	ServiceOfferID  *uint      `json:"service_offer_id,omitempty"`
+	MilestoneID     *uint      `json:"milestone_id,omitempty"`
+	AppointmentID   *uint      `json:"appointment_id,omitempty"`
+	StartsAt        *time.Time `json:"starts_at,omitempty"`
+	FromStatus      string     `json:"from_status,omitempty"`
+	ToStatus        string     `json:"to_status,omitempty"`
+	Reason          string     `json:"reason,omitempty"`
+	Version         int        `json:"version,omitempty"`
+	ProposedPrice   *float64   `json:"proposed_price,omitempty"`
+	CommentID       *uint      `json:"comment_id,omitempty"`
+	ParentCommentID *uint      `json:"parent_comment_id,omitempty"`
+	Content         string     `json:"content,omitempty"`
+}
+
+// serviceRequestTimelineHandler handles GET /api/service-requests/:id/timeline
+func serviceRequestTimelineHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authMiddleware(db)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		requestID, err := strconv.ParseUint(c.Param("id"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid service request ID"})
+			return
+		}
+
+		var request ServiceRequest
+		if err := db.Preload("Requester", publicUserColumns).First(&request, requestID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Service request not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch service request"})
+			}
+			return
+		}
+
+		if _, ok := checkCommunityAccess(c, db, request.CommunityID); !ok {
+			return
+		}
+
+		entries, err := buildServiceRequestTimeline(db, &request)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to build timeline"})
+			return
+		}
+
+		c.JSON(http.StatusOK, entries)
+	}
+}
+
+// buildServiceRequestTimeline collects and orders the feed for a request
+func buildServiceRequestTimeline(db *gorm.DB, request *ServiceRequest) ([]timelineEntry, error) {
+	entries := []timelineEntry{{
+		Type:  timelineRequestCreated,
+		At:    request.CreatedAt,
+		Actor: &request.Requester,
+	}}
+
+	var offers []ServiceOffer
+	if err := db.Preload("Provider", publicUserColumns).
+		Where("service_request_id = ?", request.ID).
+		Find(&offers).Error; err != nil {
+		return nil, err
+	}
+
+	offerIDs := []uint{}
+	for i := range offers {
+		offerIDs = append(offerIDs, offers[i].ID)
+	}
+
+	var revisions []OfferRevision
+	if err := db.Preload("Author", publicUserColumns).
+		Where("service_offer_id IN ?", offerIDs).
+		Find(&revisions).Error; err != nil {
+		return nil, err
+	}
+
+	// The offer itself shows its original terms; later revisions are counter-offers
+	originalPrices := make(map[uint]*float64)
+	for i := range revisions {
+		revision := &revisions[i]
+		if revision.Version == 1 {
+			originalPrices[revision.ServiceOfferID] = &revision.Price
+			continue
+		}
+		entries = append(entries, timelineEntry{
+			Type:           timelineOfferCountered,
+			At:             revision.CreatedAt,
+			Actor:          &revision.Author,
+			ServiceOfferID: &revision.ServiceOfferID,
+			Version:        revision.Version,
+			ProposedPrice:  &revision.Price,
+			Content:        revision.Message,
+		})
+	}
+
+	for i := range offers {
+		offer := &offers[i]
+		entries = append(entries, timelineEntry{
+			Type:           timelineOfferSubmitted,
+			At:             offer.CreatedAt,
+			Actor:          &offer.Provider,
+			ServiceOfferID: &offer.ID,
+			ProposedPrice:  originalPrices[offer.ID],
+		})
+	}
+
+	milestoneIDs := []uint{}
+	if err := db.Model(&Milestone{}).
+		Where("service_offer_id IN ?", offerIDs).
+		Pluck("id", &milestoneIDs).Error; err != nil {
+		return nil, err
+	}
+
+	var appointments []Appointment
+	if err := db.Preload("Requester", publicUserColumns).
+		Where("service_request_id = ?", request.ID).
+		Find(&appointments).Error; err != nil {
+		return nil, err
+	}
+
+	appointmentIDs := []uint{}
+	for i := range appointments {
+		appointment := &appointments[i]
+		appointmentIDs = append(appointmentIDs, appointment.ID)
+		entries = append(entries, timelineEntry{
+			Type:          timelineAppointment,
+			At:            appointment.CreatedAt,
+			Actor:         &appointment.Requester,
+			AppointmentID: &appointment.ID,
+			StartsAt:      &appointment.StartsAt,
+		})
+	}
+
+	var transitions []StatusTransition
+	if err := db.Preload("Actor", publicUserColumns).
+		Where("(entity_type = ? AND entity_id = ?) OR (entity_type = ? AND entity_id IN ?) OR (entity_type = ? AND entity_id IN ?) OR (entity_type = ? AND entity_id IN ?)",
+			entityServiceRequest, request.ID, entityServiceOffer, offerIDs, entityMilestone, milestoneIDs, entityAppointment, appointmentIDs).
+		Find(&transitions).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range transitions {
+		t := &transitions[i]
+		entry := timelineEntry{
+			Type:       timelineStatusChanged,
+			At:         t.CreatedAt,
+			Actor:      t.Actor,
+			FromStatus: t.FromStatus,
+			ToStatus:   t.ToStatus,
+			Reason:     t.Reason,
+		}
+		switch t.EntityType {
+		case entityServiceOffer:
+			entry.Type = timelineOfferTypes[t.ToStatus]
+			entry.ServiceOfferID = &t.EntityID
+		case entityMilestone:
+			entry.Type = timelineMilestone
+			entry.MilestoneID = &t.EntityID
+		case entityAppointment:
+			entry.Type = timelineAppointmentCancelled
+			entry.AppointmentID = &t.EntityID
+		}
+		entries = append(entries, entry)
+	}
+
+	var comments []Comment
+	if err := db.Preload("Author", publicUserColumns).
+		Where("is_removed = ?", false).
+		Where("service_request_id = ? OR service_offer_id IN ? OR milestone_id IN ?", request.ID, offerIDs, milestoneIDs).
+		Find(&comments).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range comments {
+		comment := &comments[i]
+		entries = append(entries, timelineEntry{
+			Type:            timelineComment,
+			At:              comment.CreatedAt,
+			Actor:           &comment.Author,
+			ServiceOfferID:  comment.ServiceOfferID,
+			MilestoneID:     comment.MilestoneID,
+			CommentID:       &comment.ID,
+			ParentCommentID: comment.ParentCommentID,
+			Content:         comment.Content,
+		})
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].At.Before(entries[j].At)
+	})
+
+	return entries, nil
+}
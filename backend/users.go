@@ -1,6 +1,7 @@
 package main
 
 import (
+	"log"
 	"net/http"
 	"strconv"
 
@@ -113,6 +114,17 @@ func createUserHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		if err := sendVerificationEmail(db, &user); err != nil {
+			log.Printf("Failed to queue verification email for user %d: %v", user.ID, err)
+		}
+
+		recordAudit(db, c, auditEntry{
+			Action:     auditUserCreate,
+			TargetType: "user",
+			TargetID:   user.ID,
+			After:      userAuditFields(&user),
+		})
+
 		c.JSON(http.StatusCreated, sanitizeUser(&user))
 	}
 }
@@ -170,8 +182,16 @@ func updateUserHandler(db *gorm.DB) gin.HandlerFunc {
 		if name, ok := req["Name"].(string); ok && name != "" {
 			updates["name"] = name
 		}
-		if email, ok := req["Email"].(string); ok && email != "" {
+		if email, ok := req["Email"].(string); ok && email != "" && email != user.Email {
+			var existing User
+			if err := db.Where("email = ? AND id != ?", email, user.ID).First(&existing).Error; err == nil {
+				c.JSON(http.StatusConflict, gin.H{"message": "User with this email already exists"})
+				return
+			}
+
+			// The new address hasn't been verified yet
 			updates["email"] = email
+			updates["email_verified_at"] = nil
 		}
 		if role, ok := req["Role"].(string); ok && role != "" {
 			updates["role"] = role
@@ -180,19 +200,50 @@ func updateUserHandler(db *gorm.DB) gin.HandlerFunc {
 			updates["is_active"] = isActive
 		}
 
+		before := userAuditFields(&user)
+
 		if len(updates) > 0 {
 			if err := db.Model(&user).Updates(updates).Error; err != nil {
+				if isUniqueViolation(err) {
+					c.JSON(http.StatusConflict, gin.H{"message": "User with this email already exists"})
+					return
+				}
 				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update user"})
 				return
 			}
 		}
 
+		if isActive, ok := updates["is_active"].(bool); ok && !isActive {
+			revokeUserSessions(db, user.ID, revokeReasonUserDeactivated)
+		}
+
+		_, emailChanged := updates["email"]
+
 		// Fetch updated user
 		if err := db.First(&user, id).Error; err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch updated user"})
 			return
 		}
 
+		if emailChanged {
+			if err := sendVerificationEmail(db, &user); err != nil {
+				log.Printf("Failed to queue verification email for user %d: %v", user.ID, err)
+			}
+		}
+
+		// Users editing their own profile aren't administrative actions
+		_, roleChanged := updates["role"]
+		_, activeChanged := updates["is_active"]
+		if len(updates) > 0 && (uint(id) != currentUserID || roleChanged || activeChanged) {
+			recordAudit(db, c, auditEntry{
+				Action:     auditUserUpdate,
+				TargetType: "user",
+				TargetID:   user.ID,
+				Before:     before,
+				After:      userAuditFields(&user),
+			})
+		}
+
 		c.JSON(http.StatusOK, sanitizeUser(&user))
 	}
 }
@@ -226,6 +277,15 @@ func deleteUserHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		revokeUserSessions(db, user.ID, revokeReasonUserDeleted)
+
+		recordAudit(db, c, auditEntry{
+			Action:     auditUserDelete,
+			TargetType: "user",
+			TargetID:   user.ID,
+			Before:     userAuditFields(&user),
+		})
+
 		c.JSON(http.StatusOK, gin.H{"message": "User deleted successfully"})
 	}
 }
@@ -280,6 +340,9 @@ func changePasswordHandler(db *gorm.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Sign out every other device
+		revokeUserSessions(db, user.ID, revokeReasonPasswordChanged, getCurrentSessionID(c))
+
 		c.JSON(http.StatusOK, gin.H{"message": "Password changed successfully"})
 	}
 }
@@ -0,0 +1,875 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Webhooks
+//
+// Community admins register endpoints that are sent the community's service
+// request events as JSON POSTs. Each endpoint picks the event types it wants
+// and has its own secret; every delivery carries an HMAC-SHA256 signature of
+// its timestamp and body keyed with that secret, so the receiver can check it
+// came from us and wasn't replayed.
+//
+// Deliveries are recorded in the transaction that raised the event and sent
+// by a background job, so an event is never sent for a change that was rolled
+// back. Failed deliveries are retried with exponential backoff; each one is
+// kept as a log entry and can be sent again by hand.
+
+// Webhook event types
+const (
+	webhookServiceRequestCreated   = "service_request.created"
+	webhookServiceRequestAccepted  = "service_request.accepted"
+	webhookServiceRequestCompleted = "service_request.completed"
+	// webhookPing is sent by the ping action to test an endpoint, whatever its event types
+	webhookPing = "ping"
+)
+
+// webhookEventTypes are the event types an endpoint can subscribe to
+var webhookEventTypes = []string{webhookServiceRequestCreated, webhookServiceRequestAccepted, webhookServiceRequestCompleted}
+
+// Webhook delivery statuses
+const (
+	webhookDeliveryPending   = "pending"
+	webhookDeliverySucceeded = "succeeded"
+	webhookDeliveryFailed    = "failed"
+)
+
+const (
+	// webhookDeliveryInterval is how often due deliveries are sent
+	webhookDeliveryInterval = 10 * time.Second
+	// webhookMaxAttempts is how many times a delivery is tried before it fails
+	webhookMaxAttempts = 8
+	// webhookRetryBase is the wait after the first failed attempt; it doubles after each one
+	webhookRetryBase = time.Minute
+	// webhookTimeout is how long an endpoint has to respond
+	webhookTimeout = 10 * time.Second
+	// webhookResponseLimit is how much of a response body is kept, in bytes
+	webhookResponseLimit = 1024
+	// webhookSignatureHeader carries "t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">"
+	webhookSignatureHeader = "X-Commune-Signature"
+)
+
+var (
+	errWebhookClaimed        = errors.New("webhook delivery claimed by another worker")
+	errWebhookAddressBlocked = errors.New("webhook address is not a public address")
+)
+
+// webhookAllowPrivateNetworks lets endpoints on loopback and private networks
+// be used, for local development; set up in main from
+// WEBHOOK_ALLOW_PRIVATE_NETWORKS
+var webhookAllowPrivateNetworks bool
+
+// webhookClient sends deliveries; redirects are not followed, so a delivery
+// only ever goes to the registered URL. Every connection is checked when it
+// is dialled, after DNS resolution, so a host that resolves to an internal
+// address (or is changed to) can't be reached. Proxies aren't used, as the
+// check would then apply to the proxy.
+var webhookClient = &http.Client{
+	Timeout: webhookTimeout,
+	Transport: &http.Transport{
+		DialContext: (&net.Dialer{
+			Timeout: webhookTimeout,
+			Control: func(network, address string, _ syscall.RawConn) error {
+				host, _, err := net.SplitHostPort(address)
+				if err != nil {
+					return err
+				}
+				if !isAllowedWebhookIP(net.ParseIP(host)) {
+					return errWebhookAddressBlocked
+				}
+				return nil
+			},
+		}).DialContext,
+		TLSHandshakeTimeout:   webhookTimeout,
+		ResponseHeaderTimeout: webhookTimeout,
+		MaxIdleConns:          10,
+		IdleConnTimeout:       90 * time.Second,
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// isAllowedWebhookIP reports whether deliveries may be sent to the address.
+// Loopback, link-local (including cloud metadata services), private and
+// unspecified addresses are refused unless webhookAllowPrivateNetworks is set.
+func isAllowedWebhookIP(ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	if webhookAllowPrivateNetworks {
+		return true
+	}
+	return !(ip.IsLoopback() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsPrivate() || ip.IsUnspecified() || ip.IsMulticast())
+}
+
+// checkWebhookHost resolves a URL's host and checks every address it resolves to
+func checkWebhookHost(u *url.URL) error {
+	host := u.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		if !isAllowedWebhookIP(ip) {
+			return errors.New("url must not point at a loopback, link-local or private address")
+		}
+		return nil
+	}
+
+	ips, err := net.LookupIP(host)
+	if err != nil || len(ips) == 0 {
+		return errors.New("url host could not be resolved")
+	}
+	for _, ip := range ips {
+		if !isAllowedWebhookIP(ip) {
+			return errors.New("url must not point at a loopback, link-local or private address")
+		}
+	}
+	return nil
+}
+
+func isValidWebhookEventType(eventType string) bool {
+	for _, t := range webhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// eventTypeList splits the stored comma-separated event types
+func (e *WebhookEndpoint) eventTypeList() []string {
+	if e.EventTypes == "" {
+		return []string{}
+	}
+	return strings.Split(e.EventTypes, ",")
+}
+
+// subscribesTo reports whether the endpoint wants events of the type
+func (e *WebhookEndpoint) subscribesTo(eventType string) bool {
+	for _, t := range e.eventTypeList() {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// generateWebhookSecret returns a new endpoint secret
+func generateWebhookSecret() (string, error) {
+	token, err := generateSecureToken()
+	if err != nil {
+		return "", err
+	}
+	return "whsec_" + token, nil
+}
+
+// generateWebhookEventID returns a new ID for an event sent to webhooks
+func generateWebhookEventID() (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "evt_" + hex.EncodeToString(b), nil
+}
+
+// signWebhookPayload returns the signature header value for a payload sent at timestamp
+func signWebhookPayload(secret string, timestamp int64, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(payload)
+	return fmt.Sprintf("t=%d,v1=%s", timestamp, hex.EncodeToString(mac.Sum(nil)))
+}
+
+// webhookPayload is the JSON body of a delivery
+type webhookPayload struct {
+	ID          string                 `json:"id"`
+	Type        string                 `json:"type"`
+	CreatedAt   time.Time              `json:"created_at"`
+	CommunityID uint                   `json:"community_id"`
+	Data        map[string]interface{} `json:"data"`
+}
+
+// queueWebhook records a delivery of the event to each active endpoint of the
+// community subscribed to it. The job sends them once tx has committed.
+func queueWebhook(tx *gorm.DB, communityID uint, eventType string, data map[string]interface{}) error {
+	var endpoints []WebhookEndpoint
+	if err := tx.Where("community_id = ? AND is_active = ?", communityID, true).Find(&endpoints).Error; err != nil {
+		return err
+	}
+
+	var subscribed []WebhookEndpoint
+	for _, endpoint := range endpoints {
+		if endpoint.subscribesTo(eventType) {
+			subscribed = append(subscribed, endpoint)
+		}
+	}
+	if len(subscribed) == 0 {
+		return nil
+	}
+
+	delivery, err := newWebhookDelivery(communityID, eventType, data)
+	if err != nil {
+		return err
+	}
+	for _, endpoint := range subscribed {
+		d := *delivery
+		d.EndpointID = endpoint.ID
+		if err := tx.Create(&d).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newWebhookDelivery builds a pending delivery of a new event, due now, for
+// the caller to address to an endpoint
+func newWebhookDelivery(communityID uint, eventType string, data map[string]interface{}) (*WebhookDelivery, error) {
+	eventID, err := generateWebhookEventID()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	payload, err := json.Marshal(webhookPayload{
+		ID:          eventID,
+		Type:        eventType,
+		CreatedAt:   now.UTC(),
+		CommunityID: communityID,
+		Data:        data,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &WebhookDelivery{
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       string(payload),
+		Status:        webhookDeliveryPending,
+		NextAttemptAt: &now,
+	}, nil
+}
+
+// webhookServiceRequest is the service request as sent in webhook payloads
+func webhookServiceRequest(request *ServiceRequest) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                   request.ID,
+		"title":                request.Title,
+		"description":          request.Description,
+		"category":             request.Category,
+		"status":               request.Status,
+		"budget":               request.Budget,
+		"requester_id":         request.RequesterID,
+		"accepted_offer_id":    request.AcceptedOfferID,
+		"recurring_request_id": request.RecurringRequestID,
+		"created_at":           request.CreatedAt,
+		"completed_at":         request.CompletedAt,
+	}
+}
+
+// queueServiceRequestWebhook queues a service request event. The accepted
+// offer's provider and terms are included once there is one.
+func queueServiceRequestWebhook(tx *gorm.DB, request *ServiceRequest, eventType string) error {
+	data := map[string]interface{}{"service_request": webhookServiceRequest(request)}
+
+	if request.AcceptedOfferID != nil {
+		var offer ServiceOffer
+		if err := tx.First(&offer, *request.AcceptedOfferID).Error; err != nil {
+			return err
+		}
+		data["offer"] = map[string]interface{}{
+			"id":                 offer.ID,
+			"provider_id":        offer.ProviderID,
+			"price":              offer.ProposedPrice,
+			"description":        offer.Description,
+			"estimated_duration": offer.EstimatedDuration,
+		}
+	}
+
+	return queueWebhook(tx, request.CommunityID, eventType, data)
+}
+
+// deliverWebhooks sends the deliveries that are due
+func deliverWebhooks(db *gorm.DB) error {
+	var due []WebhookDelivery
+	if err := db.Where("status = ? AND next_attempt_at <= ?", webhookDeliveryPending, time.Now()).
+		Order("next_attempt_at").
+		Limit(100).
+		Find(&due).Error; err != nil {
+		return err
+	}
+
+	for i := range due {
+		if err := attemptWebhookDelivery(db, &due[i]); err != nil && err != errWebhookClaimed {
+			log.Printf("Failed to deliver webhook %d: %v", due[i].ID, err)
+		}
+	}
+	return nil
+}
+
+// attemptWebhookDelivery makes one attempt at a delivery and records the outcome
+func attemptWebhookDelivery(db *gorm.DB, delivery *WebhookDelivery) error {
+	// Push the next attempt back while this one is in flight, so no other
+	// instance sends it too. If this one never finishes, it is retried then.
+	now := time.Now()
+	result := db.Model(&WebhookDelivery{}).
+		Where("id = ? AND status = ? AND next_attempt_at <= ?", delivery.ID, webhookDeliveryPending, now).
+		Update("next_attempt_at", now.Add(2*webhookTimeout))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return errWebhookClaimed
+	}
+
+	var endpoint WebhookEndpoint
+	if err := db.First(&endpoint, delivery.EndpointID).Error; err != nil {
+		return err
+	}
+
+	attempts := delivery.Attempts + 1
+	updates := map[string]interface{}{
+		"attempts":        attempts,
+		"last_attempt_at": now,
+		"response_status": 0,
+		"response_body":   "",
+		"error":           "",
+	}
+
+	status, body, err := postWebhook(&endpoint, delivery)
+	updates["response_status"] = status
+	if !errors.Is(err, errWebhookAddressBlocked) {
+		updates["response_body"] = body
+	}
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		updates["status"] = webhookDeliverySucceeded
+		updates["next_attempt_at"] = nil
+		updates["delivered_at"] = now
+	default:
+		if err != nil {
+			updates["error"] = err.Error()
+		} else {
+			updates["error"] = fmt.Sprintf("endpoint responded with HTTP %d", status)
+		}
+		if attempts >= webhookMaxAttempts {
+			updates["status"] = webhookDeliveryFailed
+			updates["next_attempt_at"] = nil
+		} else {
+			updates["next_attempt_at"] = now.Add(webhookRetryDelay(attempts))
+		}
+	}
+
+	return db.Model(&WebhookDelivery{}).Where("id = ?", delivery.ID).Updates(updates).Error
+}
+
+// webhookRetryDelay is the wait before the next attempt after the given number of failed ones
+func webhookRetryDelay(attempts int) time.Duration {
+	return webhookRetryBase << (attempts - 1)
+}
+
+// postWebhook sends a delivery to its endpoint, returning the response status
+// and the start of the response body
+func postWebhook(endpoint *WebhookEndpoint, delivery *WebhookDelivery) (int, string, error) {
+	payload := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, endpoint.URL, bytes.NewReader(payload))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Commune-Webhooks/1.0")
+	req.Header.Set("X-Commune-Event", delivery.EventType)
+	req.Header.Set("X-Commune-Event-ID", delivery.EventID)
+	req.Header.Set("X-Commune-Delivery", strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(webhookSignatureHeader, signWebhookPayload(endpoint.Secret, time.Now().Unix(), payload))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, webhookResponseLimit))
+	return resp.StatusCode, string(body), nil
+}
+
+// validateWebhookURL checks that a URL is an absolute http(s) URL whose host
+// resolves to public addresses only
+func validateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return errors.New("url must be an absolute http or https URL")
+	}
+	if u.User != nil {
+		return errors.New("url must not contain credentials")
+	}
+	return checkWebhookHost(u)
+}
+
+// validateWebhookEventTypes checks a list of event types and joins them for storage
+func validateWebhookEventTypes(eventTypes []string) (string, error) {
+	if len(eventTypes) == 0 {
+		return "", errors.New("At least one event type is required")
+	}
+	seen := make(map[string]bool)
+	var unique []string
+	for _, eventType := range eventTypes {
+		if !isValidWebhookEventType(eventType) {
+			return "", fmt.Errorf("Unknown event type %q", eventType)
+		}
+		if !seen[eventType] {
+			seen[eventType] = true
+			unique = append(unique, eventType)
+		}
+	}
+	return strings.Join(unique, ","), nil
+}
+
+// webhookAuditFields is the part of a webhook endpoint that audit events track
+func webhookAuditFields(endpoint *WebhookEndpoint) map[string]interface{} {
+	return map[string]interface{}{
+		"url":         endpoint.URL,
+		"description": endpoint.Description,
+		"event_types": endpoint.EventTypes,
+		"is_active":   endpoint.IsActive,
+	}
+}
+
+// findCommunityWebhook loads the :webhookId endpoint and checks it belongs to the community
+func findCommunityWebhook(c *gin.Context, db *gorm.DB, communityID uint) (*WebhookEndpoint, bool) {
+	webhookID, err := strconv.ParseUint(c.Param("webhookId"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid webhook ID"})
+		return nil, false
+	}
+
+	var endpoint WebhookEndpoint
+	if err := db.Where("community_id = ?", communityID).First(&endpoint, webhookID).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"message": "Webhook not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch webhook"})
+		}
+		return nil, false
+	}
+
+	return &endpoint, true
+}
+
+// getWebhooksHandler handles GET /api/communities/:id/webhooks (community admins)
+func getWebhooksHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		var endpoints []WebhookEndpoint
+		if err := db.Where("community_id = ?", getCommunityAccess(c).CommunityID).
+			Order("created_at").
+			Find(&endpoints).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch webhooks"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"webhooks":    endpoints,
+			"event_types": webhookEventTypes,
+		})
+	}
+}
+
+// createWebhookHandler handles POST /api/communities/:id/webhooks (community admins)
+// The secret is only returned here and when it is rotated.
+func createWebhookHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+		access := getCommunityAccess(c)
+
+		var input struct {
+			URL         string   `json:"url"`
+			Description string   `json:"description"`
+			EventTypes  []string `json:"event_types"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		input.URL = strings.TrimSpace(input.URL)
+		if err := validateWebhookURL(input.URL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+		eventTypes, err := validateWebhookEventTypes(input.EventTypes)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+			return
+		}
+
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate webhook secret"})
+			return
+		}
+
+		endpoint := WebhookEndpoint{
+			CommunityID: access.CommunityID,
+			URL:         input.URL,
+			Description: input.Description,
+			EventTypes:  eventTypes,
+			Secret:      secret,
+			IsActive:    true,
+			CreatedByID: access.UserID,
+		}
+
+		if err := db.Create(&endpoint).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create webhook"})
+			return
+		}
+
+		recordAudit(db, c, auditEntry{
+			Action:      auditWebhookCreate,
+			TargetType:  "webhook",
+			TargetID:    endpoint.ID,
+			CommunityID: &endpoint.CommunityID,
+			After:       webhookAuditFields(&endpoint),
+		})
+
+		c.JSON(http.StatusCreated, gin.H{
+			"webhook": endpoint,
+			"secret":  secret,
+		})
+	}
+}
+
+// getWebhookHandler handles GET /api/communities/:id/webhooks/:webhookId (community admins)
+func getWebhookHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		endpoint, ok := findCommunityWebhook(c, db, getCommunityAccess(c).CommunityID)
+		if !ok {
+			return
+		}
+
+		c.JSON(http.StatusOK, endpoint)
+	}
+}
+
+// updateWebhookHandler handles PUT /api/communities/:id/webhooks/:webhookId (community admins)
+func updateWebhookHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		endpoint, ok := findCommunityWebhook(c, db, getCommunityAccess(c).CommunityID)
+		if !ok {
+			return
+		}
+
+		var input struct {
+			URL         *string  `json:"url"`
+			Description *string  `json:"description"`
+			EventTypes  []string `json:"event_types"`
+			IsActive    *bool    `json:"is_active"`
+		}
+
+		if err := c.ShouldBindJSON(&input); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid request body"})
+			return
+		}
+
+		updates := make(map[string]interface{})
+		if input.URL != nil {
+			u := strings.TrimSpace(*input.URL)
+			if err := validateWebhookURL(u); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			updates["url"] = u
+		}
+		if input.Description != nil {
+			updates["description"] = *input.Description
+		}
+		if input.EventTypes != nil {
+			eventTypes, err := validateWebhookEventTypes(input.EventTypes)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"message": err.Error()})
+				return
+			}
+			updates["event_types"] = eventTypes
+		}
+		if input.IsActive != nil {
+			updates["is_active"] = *input.IsActive
+		}
+
+		if len(updates) > 0 {
+			before := webhookAuditFields(endpoint)
+			if err := db.Model(endpoint).Updates(updates).Error; err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to update webhook"})
+				return
+			}
+			db.First(endpoint, endpoint.ID)
+
+			recordAudit(db, c, auditEntry{
+				Action:      auditWebhookUpdate,
+				TargetType:  "webhook",
+				TargetID:    endpoint.ID,
+				CommunityID: &endpoint.CommunityID,
+				Before:      before,
+				After:       webhookAuditFields(endpoint),
+			})
+		}
+
+		c.JSON(http.StatusOK, endpoint)
+	}
+}
+
+// deleteWebhookHandler handles DELETE /api/communities/:id/webhooks/:webhookId (community admins)
+// Its delivery log goes with it, and deliveries not yet sent are dropped.
+func deleteWebhookHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		endpoint, ok := findCommunityWebhook(c, db, getCommunityAccess(c).CommunityID)
+		if !ok {
+			return
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("endpoint_id = ?", endpoint.ID).Delete(&WebhookDelivery{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(endpoint).Error
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to delete webhook"})
+			return
+		}
+
+		recordAudit(db, c, auditEntry{
+			Action:      auditWebhookDelete,
+			TargetType:  "webhook",
+			TargetID:    endpoint.ID,
+			CommunityID: &endpoint.CommunityID,
+			Before:      webhookAuditFields(endpoint),
+		})
+
+		c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+	}
+}
+
+// rotateWebhookSecretHandler handles POST /api/communities/:id/webhooks/:webhookId/rotate-secret (community admins)
+// The old secret stops working straight away, including for retries.
+func rotateWebhookSecretHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		endpoint, ok := findCommunityWebhook(c, db, getCommunityAccess(c).CommunityID)
+		if !ok {
+			return
+		}
+
+		secret, err := generateWebhookSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to generate webhook secret"})
+			return
+		}
+		if err := db.Model(endpoint).Update("secret", secret).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to rotate webhook secret"})
+			return
+		}
+
+		// The secret itself is never recorded
+		recordAudit(db, c, auditEntry{
+			Action:      auditWebhookRotateSecret,
+			TargetType:  "webhook",
+			TargetID:    endpoint.ID,
+			CommunityID: &endpoint.CommunityID,
+		})
+
+		c.JSON(http.StatusOK, gin.H{
+			"webhook": endpoint,
+			"secret":  secret,
+		})
+	}
+}
+
+// pingWebhookHandler handles POST /api/communities/:id/webhooks/:webhookId/ping (community admins)
+// Queues a ping event to the endpoint, so admins can check their receiver.
+func pingWebhookHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		endpoint, ok := findCommunityWebhook(c, db, getCommunityAccess(c).CommunityID)
+		if !ok {
+			return
+		}
+
+		delivery, err := newWebhookDelivery(endpoint.CommunityID, webhookPing, map[string]interface{}{
+			"webhook_id": endpoint.ID,
+		})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create ping"})
+			return
+		}
+		delivery.EndpointID = endpoint.ID
+		if err := db.Create(delivery).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to create ping"})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, delivery)
+	}
+}
+
+// getWebhookDeliveriesHandler handles GET /api/communities/:id/webhooks/:webhookId/deliveries (community admins)
+// The endpoint's delivery log, newest first (status, event_type, paginated).
+func getWebhookDeliveriesHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		endpoint, ok := findCommunityWebhook(c, db, getCommunityAccess(c).CommunityID)
+		if !ok {
+			return
+		}
+
+		page, pageSize := parsePagination(c)
+
+		query := db.Model(&WebhookDelivery{}).Where("endpoint_id = ?", endpoint.ID)
+		if status := c.Query("status"); status != "" {
+			query = query.Where("status = ?", status)
+		}
+		if eventType := c.Query("event_type"); eventType != "" {
+			query = query.Where("event_type = ?", eventType)
+		}
+
+		var total int64
+		if err := query.Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to count deliveries"})
+			return
+		}
+
+		var deliveries []WebhookDelivery
+		if err := query.
+			Order("id DESC").
+			Offset((page - 1) * pageSize).
+			Limit(pageSize).
+			Find(&deliveries).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch deliveries"})
+			return
+		}
+
+		// Endpoints registered before addresses were checked may have been
+		// pointed at internal services; don't show what they answered
+		if validateWebhookURL(endpoint.URL) != nil {
+			for i := range deliveries {
+				deliveries[i].ResponseBody = ""
+			}
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"deliveries": deliveries,
+			"page":       page,
+			"page_size":  pageSize,
+			"total":      total,
+		})
+	}
+}
+
+// redeliverWebhookHandler handles POST /api/communities/:id/webhooks/:webhookId/deliveries/:deliveryId/redeliver (community admins)
+// Queues a new delivery of the same payload, with the same event ID, to be
+// sent straight away. The original is left in the log as it was.
+func redeliverWebhookHandler(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		requireCommunityRole(db, RoleAdmin)(c)
+		if c.IsAborted() {
+			return
+		}
+
+		endpoint, ok := findCommunityWebhook(c, db, getCommunityAccess(c).CommunityID)
+		if !ok {
+			return
+		}
+
+		deliveryID, err := strconv.ParseUint(c.Param("deliveryId"), 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"message": "Invalid delivery ID"})
+			return
+		}
+
+		var original WebhookDelivery
+		if err := db.Where("endpoint_id = ?", endpoint.ID).First(&original, deliveryID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"message": "Delivery not found"})
+			} else {
+				c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to fetch delivery"})
+			}
+			return
+		}
+
+		if original.Status == webhookDeliveryPending {
+			c.JSON(http.StatusConflict, gin.H{"message": "This delivery is still being tried"})
+			return
+		}
+
+		now := time.Now()
+		redelivery := WebhookDelivery{
+			EndpointID:     endpoint.ID,
+			EventID:        original.EventID,
+			EventType:      original.EventType,
+			Payload:        original.Payload,
+			Status:         webhookDeliveryPending,
+			NextAttemptAt:  &now,
+			RedeliveryOfID: &original.ID,
+		}
+		if err := db.Create(&redelivery).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"message": "Failed to queue redelivery"})
+			return
+		}
+
+		c.JSON(http.StatusAccepted, redelivery)
+	}
+}
@@ -1,7 +1,41 @@
-import type { User, UserRole, Community, UserCommunity, JoinRequest, ServiceRequest, ServiceOffer } from '@/types';
+import type { User, UserRole, LoginResponse, PersonalAccessToken, TokenScope, Community, UserCommunity, JoinRequest, ServiceRequest, ServiceOffer, OfferRevision, TimelineEntry, Dispute, DisputeResolution, Milestone, LedgerBalance, StatementLine, CommunityLedger, Appointment, Availability, AvailabilityWindow, AvailabilityBlackout, AvailableSlot, RecurringRequest, RecurringRequestInput, Conversation, Message, UnreadMessages, Notification, NotificationChannel, NotificationPreferences, WebhookEndpoint, WebhookDelivery, WebhookEventType } from '@/types';
 
 const API_BASE = '/api';
 
+// Endpoints that must not trigger a token refresh
+const NO_REFRESH_PATHS = ['/auth/login', '/auth/refresh', '/auth/logout', '/auth/setup-super-user'];
+
+let refreshPromise: Promise<boolean> | null = null;
+
+// Exchange the refresh token cookie for a new access token (one request at a time)
+export function refreshSession(): Promise<boolean> {
+  if (!refreshPromise) {
+    refreshPromise = fetch(`${API_BASE}/auth/refresh`, {
+      method: 'POST',
+      credentials: 'include',
+    })
+      .then((response) => response.ok)
+      .catch(() => false)
+      .finally(() => {
+        refreshPromise = null;
+      });
+  }
+  return refreshPromise;
+}
+
+// fetch wrapper that refreshes an expired access token and retries once
+async function apiFetch(input: string, init?: RequestInit): Promise<Response> {
+  const response = await fetch(input, init);
+  if (response.status !== 401 || NO_REFRESH_PATHS.some((path) => input.startsWith(`${API_BASE}${path}`))) {
+    return response;
+  }
+
+  if (!(await refreshSession())) {
+    return response;
+  }
+  return fetch(input, init);
+}
+
 // Helper to handle API responses
 async function handleResponse<T>(response: Response): Promise<T> {
   if (!response.ok) {
@@ -13,8 +47,8 @@ async function handleResponse<T>(response: Response): Promise<T> {
 
 // Auth APIs
 export const authApi = {
-  async login(email: string, password: string): Promise<{ user: User; token: string }> {
-    const response = await fetch(`${API_BASE}/auth/login`, {
+  async login(email: string, password: string): Promise<LoginResponse> {
+    const response = await apiFetch(`${API_BASE}/auth/login`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ email, password }),
@@ -23,8 +57,18 @@ export const authApi = {
     return handleResponse(response);
   },
 
+  async verifyMfa(mfaToken: string, code: string): Promise<{ user: User; token: string }> {
+    const response = await apiFetch(`${API_BASE}/auth/mfa/verify`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ mfa_token: mfaToken, code }),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
   async logout(): Promise<void> {
-    const response = await fetch(`${API_BASE}/auth/logout`, {
+    const response = await apiFetch(`${API_BASE}/auth/logout`, {
       method: 'POST',
       credentials: 'include',
     });
@@ -32,14 +76,14 @@ export const authApi = {
   },
 
   async getCurrentUser(): Promise<User> {
-    const response = await fetch(`${API_BASE}/auth/me`, {
+    const response = await apiFetch(`${API_BASE}/auth/me`, {
       credentials: 'include',
     });
     return handleResponse(response);
   },
 
   async checkFirstBoot(): Promise<{ needsSetup: boolean }> {
-    const response = await fetch(`${API_BASE}/auth/first-boot`);
+    const response = await apiFetch(`${API_BASE}/auth/first-boot`);
     return handleResponse(response);
   },
 
@@ -48,7 +92,7 @@ export const authApi = {
     email: string;
     password: string;
   }): Promise<{ user: User; token: string }> {
-    const response = await fetch(`${API_BASE}/auth/setup-super-user`, {
+    const response = await apiFetch(`${API_BASE}/auth/setup-super-user`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(data),
@@ -58,17 +102,49 @@ export const authApi = {
   },
 };
 
+// Personal access token APIs
+export const tokenApi = {
+  async getAll(): Promise<PersonalAccessToken[]> {
+    const response = await apiFetch(`${API_BASE}/auth/tokens`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async create(data: {
+    name: string;
+    scopes: TokenScope[];
+    expires_in_days?: number;
+  }): Promise<{ token: string; personal_access_token: PersonalAccessToken }> {
+    const response = await apiFetch(`${API_BASE}/auth/tokens`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async revoke(id: number): Promise<void> {
+    const response = await apiFetch(`${API_BASE}/auth/tokens/${id}`, {
+      method: 'DELETE',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+};
+
 // User APIs
 export const userApi = {
   async getAll(): Promise<User[]> {
-    const response = await fetch(`${API_BASE}/users`, {
+    const response = await apiFetch(`${API_BASE}/users`, {
       credentials: 'include',
     });
     return handleResponse(response);
   },
 
   async getById(id: number): Promise<User> {
-    const response = await fetch(`${API_BASE}/users/${id}`, {
+    const response = await apiFetch(`${API_BASE}/users/${id}`, {
       credentials: 'include',
     });
     return handleResponse(response);
@@ -80,7 +156,7 @@ export const userApi = {
     password: string;
     role: UserRole;
   }): Promise<User> {
-    const response = await fetch(`${API_BASE}/users`, {
+    const response = await apiFetch(`${API_BASE}/users`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(data),
@@ -90,7 +166,7 @@ export const userApi = {
   },
 
   async update(id: number, data: Partial<User>): Promise<User> {
-    const response = await fetch(`${API_BASE}/users/${id}`, {
+    const response = await apiFetch(`${API_BASE}/users/${id}`, {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(data),
@@ -100,7 +176,7 @@ export const userApi = {
   },
 
   async delete(id: number): Promise<void> {
-    const response = await fetch(`${API_BASE}/users/${id}`, {
+    const response = await apiFetch(`${API_BASE}/users/${id}`, {
       method: 'DELETE',
       credentials: 'include',
     });
@@ -108,7 +184,7 @@ export const userApi = {
   },
 
   async changePassword(oldPassword: string, newPassword: string): Promise<void> {
-    const response = await fetch(`${API_BASE}/users/change-password`, {
+    const response = await apiFetch(`${API_BASE}/users/change-password`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ oldPassword, newPassword }),
@@ -118,7 +194,7 @@ export const userApi = {
   },
 
   async getUserCommunities(userId: number): Promise<UserCommunity[]> {
-    const response = await fetch(`${API_BASE}/users/${userId}/communities`, {
+    const response = await apiFetch(`${API_BASE}/users/${userId}/communities`, {
       credentials: 'include',
     });
     return handleResponse(response);
@@ -128,21 +204,21 @@ export const userApi = {
 // Community APIs
 export const communityApi = {
   async getAll(): Promise<Community[]> {
-    const response = await fetch(`${API_BASE}/communities`, {
+    const response = await apiFetch(`${API_BASE}/communities`, {
       credentials: 'include',
     });
     return handleResponse(response);
   },
 
   async getById(id: number): Promise<Community> {
-    const response = await fetch(`${API_BASE}/communities/${id}`, {
+    const response = await apiFetch(`${API_BASE}/communities/${id}`, {
       credentials: 'include',
     });
     return handleResponse(response);
   },
 
   async create(data: Partial<Community>): Promise<Community> {
-    const response = await fetch(`${API_BASE}/communities`, {
+    const response = await apiFetch(`${API_BASE}/communities`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(data),
@@ -152,7 +228,7 @@ export const communityApi = {
   },
 
   async update(id: number, data: Partial<Community>): Promise<Community> {
-    const response = await fetch(`${API_BASE}/communities/${id}`, {
+    const response = await apiFetch(`${API_BASE}/communities/${id}`, {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(data),
@@ -162,7 +238,7 @@ export const communityApi = {
   },
 
   async delete(id: number): Promise<void> {
-    const response = await fetch(`${API_BASE}/communities/${id}`, {
+    const response = await apiFetch(`${API_BASE}/communities/${id}`, {
       method: 'DELETE',
       credentials: 'include',
     });
@@ -170,7 +246,7 @@ export const communityApi = {
   },
 
   async getMembers(communityId: number): Promise<UserCommunity[]> {
-    const response = await fetch(`${API_BASE}/communities/${communityId}/members`, {
+    const response = await apiFetch(`${API_BASE}/communities/${communityId}/members`, {
       credentials: 'include',
     });
     return handleResponse(response);
@@ -181,7 +257,7 @@ export const communityApi = {
     userId: number,
     role: UserRole
   ): Promise<UserCommunity> {
-    const response = await fetch(`${API_BASE}/communities/${communityId}/members`, {
+    const response = await apiFetch(`${API_BASE}/communities/${communityId}/members`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ userId, role }),
@@ -191,7 +267,7 @@ export const communityApi = {
   },
 
   async removeMember(communityId: number, userId: number): Promise<void> {
-    const response = await fetch(
+    const response = await apiFetch(
       `${API_BASE}/communities/${communityId}/members/${userId}`,
       {
         method: 'DELETE',
@@ -206,7 +282,7 @@ export const communityApi = {
     userId: number,
     role: UserRole
   ): Promise<UserCommunity> {
-    const response = await fetch(
+    const response = await apiFetch(
       `${API_BASE}/communities/${communityId}/members/${userId}`,
       {
         method: 'PUT',
@@ -222,21 +298,21 @@ export const communityApi = {
 // Join Request APIs
 export const joinRequestApi = {
   async getAll(): Promise<JoinRequest[]> {
-    const response = await fetch(`${API_BASE}/join-requests`, {
+    const response = await apiFetch(`${API_BASE}/join-requests`, {
       credentials: 'include',
     });
     return handleResponse(response);
   },
 
   async getByCommunity(communityId: number): Promise<JoinRequest[]> {
-    const response = await fetch(`${API_BASE}/communities/${communityId}/join-requests`, {
+    const response = await apiFetch(`${API_BASE}/communities/${communityId}/join-requests`, {
       credentials: 'include',
     });
     return handleResponse(response);
   },
 
   async create(communityId: number, message?: string): Promise<JoinRequest> {
-    const response = await fetch(`${API_BASE}/join-requests`, {
+    const response = await apiFetch(`${API_BASE}/join-requests`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ communityId, message }),
@@ -246,7 +322,7 @@ export const joinRequestApi = {
   },
 
   async approve(requestId: number, role: UserRole = 'user'): Promise<JoinRequest> {
-    const response = await fetch(`${API_BASE}/join-requests/${requestId}/approve`, {
+    const response = await apiFetch(`${API_BASE}/join-requests/${requestId}/approve`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ role }),
@@ -256,7 +332,7 @@ export const joinRequestApi = {
   },
 
   async reject(requestId: number): Promise<JoinRequest> {
-    const response = await fetch(`${API_BASE}/join-requests/${requestId}/reject`, {
+    const response = await apiFetch(`${API_BASE}/join-requests/${requestId}/reject`, {
       method: 'POST',
       credentials: 'include',
     });
@@ -266,19 +342,20 @@ export const joinRequestApi = {
 
 // Service Request APIs
 export const serviceRequestApi = {
-  async getAll(params?: { community_id?: number; status?: string }): Promise<ServiceRequest[]> {
+  async getAll(params?: { community_id?: number; status?: string; recurring_request_id?: number }): Promise<ServiceRequest[]> {
     const queryParams = new URLSearchParams();
     if (params?.community_id) queryParams.append('community_id', params.community_id.toString());
     if (params?.status) queryParams.append('status', params.status);
+    if (params?.recurring_request_id) queryParams.append('recurring_request_id', params.recurring_request_id.toString());
     
-    const response = await fetch(`${API_BASE}/service-requests?${queryParams.toString()}`, {
+    const response = await apiFetch(`${API_BASE}/service-requests?${queryParams.toString()}`, {
       credentials: 'include',
     });
     return handleResponse(response);
   },
 
   async getById(id: number): Promise<ServiceRequest> {
-    const response = await fetch(`${API_BASE}/service-requests/${id}`, {
+    const response = await apiFetch(`${API_BASE}/service-requests/${id}`, {
       credentials: 'include',
     });
     return handleResponse(response);
@@ -291,7 +368,7 @@ export const serviceRequestApi = {
     budget?: number;
     community_id: number;
   }): Promise<ServiceRequest> {
-    const response = await fetch(`${API_BASE}/service-requests`, {
+    const response = await apiFetch(`${API_BASE}/service-requests`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(data),
@@ -301,7 +378,7 @@ export const serviceRequestApi = {
   },
 
   async update(id: number, data: Partial<ServiceRequest>): Promise<ServiceRequest> {
-    const response = await fetch(`${API_BASE}/service-requests/${id}`, {
+    const response = await apiFetch(`${API_BASE}/service-requests/${id}`, {
       method: 'PUT',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(data),
@@ -310,8 +387,15 @@ export const serviceRequestApi = {
     return handleResponse(response);
   },
 
+  async getTimeline(id: number): Promise<TimelineEntry[]> {
+    const response = await apiFetch(`${API_BASE}/service-requests/${id}/timeline`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
   async acceptOffer(requestId: number, offerId: number): Promise<ServiceRequest> {
-    const response = await fetch(`${API_BASE}/service-requests/${requestId}/accept-offer`, {
+    const response = await apiFetch(`${API_BASE}/service-requests/${requestId}/accept-offer`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify({ offer_id: offerId }),
@@ -319,6 +403,553 @@ export const serviceRequestApi = {
     });
     return handleResponse(response);
   },
+
+  async markDone(id: number): Promise<ServiceRequest> {
+    const response = await apiFetch(`${API_BASE}/service-requests/${id}/mark-done`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async confirmCompletion(id: number): Promise<ServiceRequest> {
+    const response = await apiFetch(`${API_BASE}/service-requests/${id}/confirm`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async dispute(id: number, reason: string): Promise<Dispute> {
+    const response = await apiFetch(`${API_BASE}/service-requests/${id}/dispute`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ reason }),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getDisputes(id: number): Promise<Dispute[]> {
+    const response = await apiFetch(`${API_BASE}/service-requests/${id}/disputes`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getAppointments(id: number): Promise<Appointment[]> {
+    const response = await apiFetch(`${API_BASE}/service-requests/${id}/appointments`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async bookAppointment(id: number, data: { starts_at: string; duration_minutes?: number }): Promise<Appointment> {
+    const response = await apiFetch(`${API_BASE}/service-requests/${id}/appointments`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  // The invoice or receipt PDF, served inline so it can be linked to directly
+  invoiceUrl(id: number, document?: 'invoice' | 'receipt'): string {
+    return `${API_BASE}/service-requests/${id}/invoice${document ? `?document=${document}` : ''}`;
+  },
+};
+
+// Milestone APIs
+export const milestoneApi = {
+  async submit(id: number): Promise<Milestone> {
+    const response = await apiFetch(`${API_BASE}/milestones/${id}/submit`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async approve(id: number): Promise<Milestone> {
+    const response = await apiFetch(`${API_BASE}/milestones/${id}/approve`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async reject(id: number, reason: string): Promise<Milestone> {
+    const response = await apiFetch(`${API_BASE}/milestones/${id}/reject`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ reason }),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+};
+
+// Dispute APIs (community moderators)
+export const disputeApi = {
+  async getByCommunity(
+    communityId: number,
+    params?: { status?: string; page?: number; page_size?: number }
+  ): Promise<{ disputes: Dispute[]; page: number; page_size: number; total: number }> {
+    const queryParams = new URLSearchParams();
+    if (params?.status) queryParams.append('status', params.status);
+    if (params?.page) queryParams.append('page', params.page.toString());
+    if (params?.page_size) queryParams.append('page_size', params.page_size.toString());
+
+    const response = await apiFetch(`${API_BASE}/communities/${communityId}/disputes?${queryParams.toString()}`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async mediate(id: number): Promise<Dispute> {
+    const response = await apiFetch(`${API_BASE}/disputes/${id}/mediate`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async resolve(id: number, resolution: DisputeResolution, note: string): Promise<Dispute> {
+    const response = await apiFetch(`${API_BASE}/disputes/${id}/resolve`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ resolution, note }),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+};
+
+// Appointment APIs
+export const appointmentApi = {
+  async reschedule(id: number, data: { starts_at: string; duration_minutes?: number }): Promise<Appointment> {
+    const response = await apiFetch(`${API_BASE}/appointments/${id}/reschedule`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async cancel(id: number, reason?: string): Promise<Appointment> {
+    const response = await apiFetch(`${API_BASE}/appointments/${id}/cancel`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ reason }),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+};
+
+// Provider availability APIs
+export const availabilityApi = {
+  async get(userId: number): Promise<Availability> {
+    const response = await apiFetch(`${API_BASE}/users/${userId}/availability`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async update(userId: number, data: { time_zone?: string; windows: AvailabilityWindow[] }): Promise<Availability> {
+    const response = await apiFetch(`${API_BASE}/users/${userId}/availability`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async addBlackout(userId: number, data: { starts_on: string; ends_on?: string; reason?: string }): Promise<AvailabilityBlackout> {
+    const response = await apiFetch(`${API_BASE}/users/${userId}/blackouts`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async deleteBlackout(userId: number, blackoutId: number): Promise<void> {
+    const response = await apiFetch(`${API_BASE}/users/${userId}/blackouts/${blackoutId}`, {
+      method: 'DELETE',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getSlots(
+    userId: number,
+    params?: { from?: string; days?: number; duration?: number }
+  ): Promise<{ time_zone: string; duration_minutes: number; slots: AvailableSlot[] }> {
+    const queryParams = new URLSearchParams();
+    if (params?.from) queryParams.append('from', params.from);
+    if (params?.days) queryParams.append('days', params.days.toString());
+    if (params?.duration) queryParams.append('duration', params.duration.toString());
+
+    const response = await apiFetch(`${API_BASE}/users/${userId}/slots?${queryParams.toString()}`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async createCalendarToken(userId: number): Promise<{ token: string; url: string }> {
+    const response = await apiFetch(`${API_BASE}/users/${userId}/calendar-token`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+};
+
+// Recurring service request APIs
+export const recurringRequestApi = {
+  async getAll(params?: { status?: string }): Promise<RecurringRequest[]> {
+    const queryParams = new URLSearchParams();
+    if (params?.status) queryParams.append('status', params.status);
+
+    const response = await apiFetch(`${API_BASE}/recurring-requests?${queryParams.toString()}`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getById(id: number): Promise<RecurringRequest> {
+    const response = await apiFetch(`${API_BASE}/recurring-requests/${id}`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async create(data: RecurringRequestInput): Promise<RecurringRequest> {
+    const response = await apiFetch(`${API_BASE}/recurring-requests`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async update(id: number, data: RecurringRequestInput): Promise<RecurringRequest> {
+    const response = await apiFetch(`${API_BASE}/recurring-requests/${id}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async end(id: number): Promise<RecurringRequest> {
+    const response = await apiFetch(`${API_BASE}/recurring-requests/${id}`, {
+      method: 'DELETE',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async pause(id: number): Promise<RecurringRequest> {
+    const response = await apiFetch(`${API_BASE}/recurring-requests/${id}/pause`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async resume(id: number): Promise<RecurringRequest> {
+    const response = await apiFetch(`${API_BASE}/recurring-requests/${id}/resume`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async skip(id: number): Promise<RecurringRequest> {
+    const response = await apiFetch(`${API_BASE}/recurring-requests/${id}/skip`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+};
+
+// Direct message APIs
+export const conversationApi = {
+  async getAll(params?: { service_request_id?: number; page?: number; page_size?: number }): Promise<{
+    conversations: Conversation[];
+    page: number;
+    page_size: number;
+    total: number;
+  }> {
+    const queryParams = new URLSearchParams();
+    if (params?.service_request_id) queryParams.append('service_request_id', params.service_request_id.toString());
+    if (params?.page) queryParams.append('page', params.page.toString());
+    if (params?.page_size) queryParams.append('page_size', params.page_size.toString());
+
+    const response = await apiFetch(`${API_BASE}/conversations?${queryParams.toString()}`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getUnread(): Promise<UnreadMessages> {
+    const response = await apiFetch(`${API_BASE}/conversations/unread`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  // Starts a conversation, or returns the one the two already have about the same request
+  async start(data: {
+    recipient_id: number;
+    community_id?: number;
+    service_request_id?: number;
+    body?: string;
+  }): Promise<Conversation> {
+    const response = await apiFetch(`${API_BASE}/conversations`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getById(id: number): Promise<Conversation> {
+    const response = await apiFetch(`${API_BASE}/conversations/${id}`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getMessages(
+    id: number,
+    params?: { page?: number; page_size?: number }
+  ): Promise<{ messages: Message[]; page: number; page_size: number; total: number }> {
+    const queryParams = new URLSearchParams();
+    if (params?.page) queryParams.append('page', params.page.toString());
+    if (params?.page_size) queryParams.append('page_size', params.page_size.toString());
+
+    const response = await apiFetch(`${API_BASE}/conversations/${id}/messages?${queryParams.toString()}`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async send(id: number, body: string): Promise<Message> {
+    const response = await apiFetch(`${API_BASE}/conversations/${id}/messages`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ body }),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async markRead(id: number, messageId?: number): Promise<Conversation> {
+    const response = await apiFetch(`${API_BASE}/conversations/${id}/read`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ message_id: messageId }),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+};
+
+// Notification APIs
+export const notificationApi = {
+  async getAll(params?: { unread?: boolean; page?: number; page_size?: number }): Promise<{
+    notifications: Notification[];
+    unread_count: number;
+    page: number;
+    page_size: number;
+    total: number;
+  }> {
+    const queryParams = new URLSearchParams();
+    if (params?.unread) queryParams.append('unread', 'true');
+    if (params?.page) queryParams.append('page', params.page.toString());
+    if (params?.page_size) queryParams.append('page_size', params.page_size.toString());
+
+    const response = await apiFetch(`${API_BASE}/notifications?${queryParams.toString()}`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getUnreadCount(): Promise<{ unread_count: number }> {
+    const response = await apiFetch(`${API_BASE}/notifications/unread-count`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async markRead(id: number): Promise<Notification> {
+    const response = await apiFetch(`${API_BASE}/notifications/${id}/read`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async markAllRead(): Promise<{ marked_read: number }> {
+    const response = await apiFetch(`${API_BASE}/notifications/read-all`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getPreferences(): Promise<NotificationPreferences> {
+    const response = await apiFetch(`${API_BASE}/notifications/preferences`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  // Quiet hours are set together; send both as '' to turn them off
+  async updatePreferences(data: {
+    channels?: Partial<Record<string, NotificationChannel>>;
+    quiet_hours_start?: string;
+    quiet_hours_end?: string;
+    daily_digest?: boolean;
+    digest_hour?: number;
+  }): Promise<NotificationPreferences> {
+    const response = await apiFetch(`${API_BASE}/notifications/preferences`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+};
+
+// Community webhook APIs (community admins)
+export const webhookApi = {
+  async getAll(communityId: number): Promise<{ webhooks: WebhookEndpoint[]; event_types: WebhookEventType[] }> {
+    const response = await apiFetch(`${API_BASE}/communities/${communityId}/webhooks`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  // The secret is only returned here and by rotateSecret
+  async create(
+    communityId: number,
+    data: { url: string; description?: string; event_types: WebhookEventType[] }
+  ): Promise<{ webhook: WebhookEndpoint; secret: string }> {
+    const response = await apiFetch(`${API_BASE}/communities/${communityId}/webhooks`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async update(
+    communityId: number,
+    id: number,
+    data: { url?: string; description?: string; event_types?: WebhookEventType[]; is_active?: boolean }
+  ): Promise<WebhookEndpoint> {
+    const response = await apiFetch(`${API_BASE}/communities/${communityId}/webhooks/${id}`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async delete(communityId: number, id: number): Promise<void> {
+    const response = await apiFetch(`${API_BASE}/communities/${communityId}/webhooks/${id}`, {
+      method: 'DELETE',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async rotateSecret(communityId: number, id: number): Promise<{ webhook: WebhookEndpoint; secret: string }> {
+    const response = await apiFetch(`${API_BASE}/communities/${communityId}/webhooks/${id}/rotate-secret`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async ping(communityId: number, id: number): Promise<WebhookDelivery> {
+    const response = await apiFetch(`${API_BASE}/communities/${communityId}/webhooks/${id}/ping`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getDeliveries(
+    communityId: number,
+    id: number,
+    params?: { status?: string; page?: number; page_size?: number }
+  ): Promise<{ deliveries: WebhookDelivery[]; page: number; page_size: number; total: number }> {
+    const queryParams = new URLSearchParams();
+    if (params?.status) queryParams.append('status', params.status);
+    if (params?.page) queryParams.append('page', params.page.toString());
+    if (params?.page_size) queryParams.append('page_size', params.page_size.toString());
+
+    const response = await apiFetch(
+      `${API_BASE}/communities/${communityId}/webhooks/${id}/deliveries?${queryParams.toString()}`,
+      { credentials: 'include' }
+    );
+    return handleResponse(response);
+  },
+
+  async redeliver(communityId: number, id: number, deliveryId: number): Promise<WebhookDelivery> {
+    const response = await apiFetch(
+      `${API_BASE}/communities/${communityId}/webhooks/${id}/deliveries/${deliveryId}/redeliver`,
+      { method: 'POST', credentials: 'include' }
+    );
+    return handleResponse(response);
+  },
+};
+
+// Payments ledger APIs
+export const ledgerApi = {
+  async getBalances(userId: number): Promise<LedgerBalance[]> {
+    const response = await apiFetch(`${API_BASE}/users/${userId}/balances`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getStatement(
+    userId: number,
+    params?: { currency?: string; page?: number; page_size?: number }
+  ): Promise<{ entries: StatementLine[]; page: number; page_size: number; total: number }> {
+    const queryParams = new URLSearchParams();
+    if (params?.currency) queryParams.append('currency', params.currency);
+    if (params?.page) queryParams.append('page', params.page.toString());
+    if (params?.page_size) queryParams.append('page_size', params.page_size.toString());
+
+    const response = await apiFetch(`${API_BASE}/users/${userId}/statement?${queryParams.toString()}`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getCommunityLedger(communityId: number): Promise<CommunityLedger> {
+    const response = await apiFetch(`${API_BASE}/communities/${communityId}/ledger`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
 };
 
 // Service Offer APIs
@@ -328,14 +959,14 @@ export const serviceOfferApi = {
     if (params?.provider_id) queryParams.append('provider_id', params.provider_id.toString());
     if (params?.status) queryParams.append('status', params.status);
     
-    const response = await fetch(`${API_BASE}/service-offers?${queryParams.toString()}`, {
+    const response = await apiFetch(`${API_BASE}/service-offers?${queryParams.toString()}`, {
       credentials: 'include',
     });
     return handleResponse(response);
   },
 
   async getById(id: number): Promise<ServiceOffer> {
-    const response = await fetch(`${API_BASE}/service-offers/${id}`, {
+    const response = await apiFetch(`${API_BASE}/service-offers/${id}`, {
       credentials: 'include',
     });
     return handleResponse(response);
@@ -347,7 +978,7 @@ export const serviceOfferApi = {
     proposed_price?: number;
     estimated_duration?: string;
   }): Promise<ServiceOffer> {
-    const response = await fetch(`${API_BASE}/service-offers`, {
+    const response = await apiFetch(`${API_BASE}/service-offers`, {
       method: 'POST',
       headers: { 'Content-Type': 'application/json' },
       body: JSON.stringify(data),
@@ -357,8 +988,68 @@ export const serviceOfferApi = {
   },
 
   async withdraw(id: number): Promise<ServiceOffer> {
-    const response = await fetch(`${API_BASE}/service-offers/${id}/withdraw`, {
+    const response = await apiFetch(`${API_BASE}/service-offers/${id}/withdraw`, {
+      method: 'POST',
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getRevisions(id: number): Promise<OfferRevision[]> {
+    const response = await apiFetch(`${API_BASE}/service-offers/${id}/revisions`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async counter(
+    id: number,
+    data: { price?: number; description?: string; estimated_duration?: string; message?: string }
+  ): Promise<OfferRevision> {
+    const response = await apiFetch(`${API_BASE}/service-offers/${id}/counter`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify(data),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async acceptTerms(id: number, version?: number): Promise<ServiceOffer> {
+    const response = await apiFetch(`${API_BASE}/service-offers/${id}/accept-terms`, {
       method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ version }),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async getMilestones(id: number): Promise<Milestone[]> {
+    const response = await apiFetch(`${API_BASE}/service-offers/${id}/milestones`, {
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async setMilestones(
+    id: number,
+    milestones: { description: string; amount: number; due_date?: string }[]
+  ): Promise<Milestone[]> {
+    const response = await apiFetch(`${API_BASE}/service-offers/${id}/milestones`, {
+      method: 'PUT',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ milestones }),
+      credentials: 'include',
+    });
+    return handleResponse(response);
+  },
+
+  async approveMilestones(id: number, milestoneIds: number[]): Promise<Milestone[]> {
+    const response = await apiFetch(`${API_BASE}/service-offers/${id}/approve-milestones`, {
+      method: 'POST',
+      headers: { 'Content-Type': 'application/json' },
+      body: JSON.stringify({ milestone_ids: milestoneIds }),
       credentials: 'include',
     });
     return handleResponse(response);
@@ -369,7 +1060,7 @@ export const serviceOfferApi = {
     queryParams.append('status', 'accepted');
     if (params?.provider_id) queryParams.append('provider_id', params.provider_id.toString());
     
-    const response = await fetch(`${API_BASE}/service-offers?${queryParams.toString()}`, {
+    const response = await apiFetch(`${API_BASE}/service-offers?${queryParams.toString()}`, {
       credentials: 'include',
     });
     return handleResponse(response);
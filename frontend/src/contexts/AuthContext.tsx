@@ -55,8 +55,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
     }
   };
 
-  const login = async (email: string, password: string) => {
-    const { user: loggedInUser } = await authApi.login(email, password);
+  const finishLogin = async (loggedInUser: User) => {
     setUser(loggedInUser);
 
     // Load user's communities
@@ -64,6 +63,24 @@ export function AuthProvider({ children }: { children: ReactNode }) {
     setUserCommunities(communities);
   };
 
+  const login = async (email: string, password: string) => {
+    const response = await authApi.login(email, password);
+    if (response.mfa_enrollment_required) {
+      throw new Error('Two-factor authentication is required for your role. Ask an administrator to help you set it up.');
+    }
+    if (response.mfa_required && response.mfa_token) {
+      return { mfaToken: response.mfa_token };
+    }
+
+    await finishLogin(response.user!);
+    return {};
+  };
+
+  const verifyMfa = async (mfaToken: string, code: string) => {
+    const { user: loggedInUser } = await authApi.verifyMfa(mfaToken, code);
+    await finishLogin(loggedInUser);
+  };
+
   const logout = async () => {
     await authApi.logout();
     setUser(null);
@@ -92,6 +109,7 @@ export function AuthProvider({ children }: { children: ReactNode }) {
         userCommunities,
         isLoading,
         login,
+        verifyMfa,
         logout,
         switchCommunity,
         refreshUser,
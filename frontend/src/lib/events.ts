@@ -0,0 +1,79 @@
+import { useEffect, useRef } from 'react';
+import { refreshSession } from '@/api/client';
+import type { DomainEvent, DomainEventType } from '@/types';
+
+type Listener = (event: DomainEvent) => void;
+
+const EVENT_TYPES: DomainEventType[] = [
+  'offer.created',
+  'offer.countered',
+  'offer.status_changed',
+  'service_request.status_changed',
+  'join_request.created',
+  'join_request.approved',
+  'join_request.rejected',
+  'message.created',
+  'conversation.read',
+  'notification.created',
+];
+
+// Wait before reopening a stream the server refused
+const RECONNECT_DELAY = 5000;
+
+// One stream is shared by every component listening for events
+const listeners = new Set<Listener>();
+let source: EventSource | null = null;
+let reconnectTimer: ReturnType<typeof setTimeout> | null = null;
+
+function connect() {
+  const stream = new EventSource('/api/events', { withCredentials: true });
+  source = stream;
+
+  for (const type of EVENT_TYPES) {
+    stream.addEventListener(type, (message) => {
+      const event: DomainEvent = JSON.parse((message as MessageEvent).data);
+      listeners.forEach((listener) => listener(event));
+    });
+  }
+
+  // EventSource retries dropped connections itself, but gives up on an error
+  // response such as an expired access token
+  stream.onerror = () => {
+    if (stream.readyState !== EventSource.CLOSED || source !== stream) return;
+    source = null;
+    reconnectTimer = setTimeout(async () => {
+      reconnectTimer = null;
+      await refreshSession();
+      if (listeners.size > 0 && !source) connect();
+    }, RECONNECT_DELAY);
+  };
+}
+
+function disconnect() {
+  source?.close();
+  source = null;
+  if (reconnectTimer) {
+    clearTimeout(reconnectTimer);
+    reconnectTimer = null;
+  }
+}
+
+// Calls handler with the current user's real-time events while the component
+// is mounted and enabled
+export function useEvents(handler: Listener, enabled = true) {
+  const handlerRef = useRef(handler);
+  handlerRef.current = handler;
+
+  useEffect(() => {
+    if (!enabled) return;
+
+    const listener: Listener = (event) => handlerRef.current(event);
+    listeners.add(listener);
+    if (!source && !reconnectTimer) connect();
+
+    return () => {
+      listeners.delete(listener);
+      if (listeners.size === 0) disconnect();
+    };
+  }, [enabled]);
+}
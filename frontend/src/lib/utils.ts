@@ -4,3 +4,16 @@ import { twMerge } from "tailwind-merge"
 export function cn(...inputs: ClassValue[]) {
   return twMerge(clsx(inputs))
 }
+
+// Currencies without a minor unit, matching the backend's ledger
+const zeroDecimalCurrencies = ["CLP", "ISK", "JPY", "KRW", "UGX", "VND", "XAF", "XOF"]
+
+// minorUnitsPer returns how many minor units make one unit of a currency
+export function minorUnitsPer(currency: string) {
+  return zeroDecimalCurrencies.includes(currency) ? 1 : 100
+}
+
+// formatMinorUnits formats an amount held in integer minor units
+export function formatMinorUnits(amount: number, currency: string) {
+  return new Intl.NumberFormat("en-US", { style: "currency", currency }).format(amount / minorUnitsPer(currency))
+}
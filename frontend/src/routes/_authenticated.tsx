@@ -1,5 +1,8 @@
 import { createFileRoute, Outlet, Navigate, Link } from '@tanstack/react-router';
+import { useQuery, useQueryClient } from '@tanstack/react-query';
 import { useAuth } from '@/contexts/AuthContext';
+import { conversationApi, notificationApi } from '@/api/client';
+import { useEvents } from '@/lib/events';
 import { Button } from '@/components/ui/button';
 import {
   DropdownMenu,
@@ -9,14 +12,46 @@ import {
   DropdownMenuSeparator,
   DropdownMenuTrigger,
 } from '@/components/ui/dropdown-menu';
-import { ChevronDown, Building2, LogOut, User as UserIcon, Settings } from 'lucide-react';
+import { ChevronDown, Building2, LogOut, User as UserIcon, Settings, Webhook } from 'lucide-react';
 
 export const Route = createFileRoute('/_authenticated')({
   component: AuthenticatedLayout,
 });
 
 function AuthenticatedLayout() {
-  const { user, currentCommunity, userCommunities, logout, switchCommunity } = useAuth();
+  const { user, currentCommunity, userCommunities, logout, switchCommunity, refreshUser } = useAuth();
+
+  const queryClient = useQueryClient();
+
+  // Messages arrive as events; polling only covers events missed while reconnecting
+  const { data: unread } = useQuery({
+    queryKey: ['unread-messages'],
+    queryFn: conversationApi.getUnread,
+    enabled: !!user,
+    refetchInterval: 120000,
+  });
+
+  const { data: unreadNotifications } = useQuery({
+    queryKey: ['unread-notifications'],
+    queryFn: notificationApi.getUnreadCount,
+    enabled: !!user,
+    refetchInterval: 120000,
+  });
+
+  useEvents((event) => {
+    if (event.type === 'notification.created') {
+      queryClient.invalidateQueries({ queryKey: ['notifications'] });
+      queryClient.invalidateQueries({ queryKey: ['unread-notifications'] });
+    }
+    if (event.type === 'message.created' || event.type === 'conversation.read') {
+      queryClient.invalidateQueries({ queryKey: ['conversations'] });
+      queryClient.invalidateQueries({ queryKey: ['unread-messages'] });
+    }
+    // Pick up the community just joined
+    if (event.type === 'join_request.approved') {
+      refreshUser();
+    }
+  }, !!user);
 
   // Redirect to login if not authenticated
   if (!user) {
@@ -24,6 +59,9 @@ function AuthenticatedLayout() {
   }
 
   const canManageUsers = user.Role === 'super_admin' || user.Role === 'admin';
+  const canManageWebhooks =
+    user.Role === 'super_admin' ||
+    userCommunities.some((uc) => uc.CommunityID === currentCommunity?.ID && uc.Role === 'admin');
 
   return (
     <div className="min-h-screen bg-slate-50">
@@ -55,6 +93,29 @@ function AuthenticatedLayout() {
                 >
                   Service Requests
                 </Link>
+                <Link
+                  to="/messages"
+                  search={{}}
+                  className="text-sm font-medium text-slate-600 hover:text-slate-900 flex items-center gap-1"
+                >
+                  Messages
+                  {!!unread?.unread_messages && (
+                    <span className="text-xs px-1.5 rounded-full bg-blue-600 text-white">
+                      {unread.unread_messages}
+                    </span>
+                  )}
+                </Link>
+                <Link
+                  to="/notifications"
+                  className="text-sm font-medium text-slate-600 hover:text-slate-900 flex items-center gap-1"
+                >
+                  Notifications
+                  {!!unreadNotifications?.unread_count && (
+                    <span className="text-xs px-1.5 rounded-full bg-blue-600 text-white">
+                      {unreadNotifications.unread_count}
+                    </span>
+                  )}
+                </Link>
                 {(user.Role === 'service_provider' || user.Role === 'super_admin' || user.Role === 'admin') && (
                   <Link
                     to="/service-provider/dashboard"
@@ -141,6 +202,14 @@ function AuthenticatedLayout() {
                       Profile Settings
                     </Link>
                   </DropdownMenuItem>
+                  {canManageWebhooks && currentCommunity && (
+                    <DropdownMenuItem asChild>
+                      <Link to="/webhooks" className="flex items-center gap-2">
+                        <Webhook className="h-4 w-4" />
+                        Community Webhooks
+                      </Link>
+                    </DropdownMenuItem>
+                  )}
                   <DropdownMenuSeparator />
                   <DropdownMenuItem onClick={logout} className="flex items-center gap-2">
                     <LogOut className="h-4 w-4" />
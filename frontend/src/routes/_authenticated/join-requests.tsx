@@ -2,6 +2,7 @@ import { createFileRoute, Navigate } from '@tanstack/react-router';
 import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
 import { useAuth } from '@/contexts/AuthContext';
 import { joinRequestApi } from '@/api/client';
+import { useEvents } from '@/lib/events';
 import type { UserRole } from '@/types';
 import {
   Card,
@@ -37,6 +38,13 @@ function JoinRequestsPage() {
     enabled: canManageRequests,
   });
 
+  // New requests, and ones other moderators handled
+  useEvents((event) => {
+    if (event.type.startsWith('join_request.')) {
+      queryClient.invalidateQueries({ queryKey: ['join-requests'] });
+    }
+  }, canManageRequests);
+
   const approveMutation = useMutation({
     mutationFn: ({ requestId, role }: { requestId: number; role: UserRole }) =>
       joinRequestApi.approve(requestId, role),
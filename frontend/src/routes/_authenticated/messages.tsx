@@ -0,0 +1,221 @@
+import { createFileRoute, Link, useNavigate } from '@tanstack/react-router';
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
+import { useAuth } from '@/contexts/AuthContext';
+import { conversationApi } from '@/api/client';
+import type { Conversation } from '@/types';
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from '@/components/ui/card';
+import { Button } from '@/components/ui/button';
+import { Input } from '@/components/ui/input';
+import { MessageSquare, Send, CheckCheck } from 'lucide-react';
+import { useEffect, useState } from 'react';
+
+export const Route = createFileRoute('/_authenticated/messages')({
+  component: MessagesPage,
+  validateSearch: (search: Record<string, unknown>): { conversation?: number } => ({
+    conversation: search.conversation ? Number(search.conversation) : undefined,
+  }),
+});
+
+// New messages and read receipts arrive as events (see _authenticated.tsx);
+// polling only covers events missed while the stream was reconnecting
+const REFRESH_INTERVAL = 60000;
+
+function MessagesPage() {
+  const { user } = useAuth();
+  const { conversation: selectedId } = Route.useSearch();
+  const navigate = useNavigate();
+  const queryClient = useQueryClient();
+  const [draft, setDraft] = useState('');
+
+  const { data: list, isLoading } = useQuery({
+    queryKey: ['conversations'],
+    queryFn: () => conversationApi.getAll({ page_size: 100 }),
+    refetchInterval: REFRESH_INTERVAL,
+  });
+
+  const { data: thread } = useQuery({
+    queryKey: ['conversations', selectedId, 'messages'],
+    queryFn: () => conversationApi.getMessages(selectedId!, { page_size: 100 }),
+    enabled: selectedId != null,
+    refetchInterval: REFRESH_INTERVAL,
+  });
+
+  const { data: selected } = useQuery({
+    queryKey: ['conversations', selectedId],
+    queryFn: () => conversationApi.getById(selectedId!),
+    enabled: selectedId != null,
+    refetchInterval: REFRESH_INTERVAL,
+  });
+
+  const latestId = thread?.messages[0]?.ID;
+
+  // Mark the open conversation read as new messages arrive
+  useEffect(() => {
+    if (selectedId == null || latestId == null) return;
+    conversationApi.markRead(selectedId, latestId).then(() => {
+      queryClient.invalidateQueries({ queryKey: ['conversations'], exact: true });
+      queryClient.invalidateQueries({ queryKey: ['unread-messages'] });
+      // Reading the conversation also reads its message notification
+      queryClient.invalidateQueries({ queryKey: ['unread-notifications'] });
+    });
+  }, [selectedId, latestId, queryClient]);
+
+  const sendMutation = useMutation({
+    mutationFn: (body: string) => conversationApi.send(selectedId!, body),
+    onSuccess: () => {
+      setDraft('');
+      queryClient.invalidateQueries({ queryKey: ['conversations'] });
+    },
+    onError: (error) => {
+      alert(error instanceof Error ? error.message : 'Failed to send message');
+    },
+  });
+
+  const otherParticipants = (conversation: Conversation) =>
+    conversation.Participants.filter((p) => p.UserID !== user?.ID);
+
+  const conversationName = (conversation: Conversation) =>
+    otherParticipants(conversation)
+      .map((p) => p.User?.Name || 'Unknown')
+      .join(', ');
+
+  // The furthest any other participant has read, for "Seen" receipts
+  const seenUpTo = selected
+    ? Math.min(...otherParticipants(selected).map((p) => p.LastReadMessageID))
+    : 0;
+
+  const handleSend = (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!draft.trim() || selectedId == null) return;
+    sendMutation.mutate(draft.trim());
+  };
+
+  // Oldest first for reading
+  const messages = [...(thread?.messages || [])].reverse();
+  const conversations = list?.conversations || [];
+
+  return (
+    <div className="space-y-6">
+      <div>
+        <h1 className="text-3xl font-bold">Messages</h1>
+        <p className="text-slate-600 mt-1">Private conversations with members of your communities</p>
+      </div>
+
+      <div className="grid gap-6 md:grid-cols-3">
+        <Card className="md:col-span-1">
+          <CardHeader>
+            <CardTitle>Conversations</CardTitle>
+            <CardDescription>{list?.total ?? 0} conversation(s)</CardDescription>
+          </CardHeader>
+          <CardContent className="space-y-2">
+            {isLoading ? (
+              <div className="text-center py-8">Loading conversations...</div>
+            ) : conversations.length === 0 ? (
+              <div className="text-center py-8 text-slate-500">
+                <MessageSquare className="h-10 w-10 mx-auto mb-2 text-slate-400" />
+                No conversations yet. Message someone from a service request.
+              </div>
+            ) : (
+              conversations.map((conversation) => (
+                <button
+                  key={conversation.ID}
+                  type="button"
+                  onClick={() => navigate({ to: '/messages', search: { conversation: conversation.ID } })}
+                  className={`w-full text-left p-3 rounded-md border ${
+                    conversation.ID === selectedId ? 'border-blue-500 bg-blue-50' : 'border-slate-200 hover:bg-slate-50'
+                  }`}
+                >
+                  <div className="flex justify-between items-center">
+                    <span className="font-medium text-slate-900">{conversationName(conversation)}</span>
+                    {conversation.unread_count > 0 && (
+                      <span className="text-xs px-2 py-0.5 rounded-full bg-blue-600 text-white">
+                        {conversation.unread_count}
+                      </span>
+                    )}
+                  </div>
+                  {conversation.ServiceRequest && (
+                    <p className="text-xs text-blue-600 mt-1">Re: {conversation.ServiceRequest.Title}</p>
+                  )}
+                  {conversation.last_message && (
+                    <p className="text-sm text-slate-600 mt-1 truncate">{conversation.last_message.Body}</p>
+                  )}
+                </button>
+              ))
+            )}
+          </CardContent>
+        </Card>
+
+        <Card className="md:col-span-2">
+          {selected ? (
+            <>
+              <CardHeader>
+                <CardTitle>{conversationName(selected)}</CardTitle>
+                {selected.ServiceRequest && (
+                  <CardDescription>
+                    About{' '}
+                    <Link
+                      to="/service-requests/$requestId"
+                      params={{ requestId: selected.ServiceRequest.ID.toString() }}
+                      className="text-blue-600 hover:underline"
+                    >
+                      {selected.ServiceRequest.Title}
+                    </Link>
+                  </CardDescription>
+                )}
+              </CardHeader>
+              <CardContent className="space-y-4">
+                <div className="space-y-3 max-h-[480px] overflow-y-auto">
+                  {messages.map((message) => {
+                    const mine = message.SenderID === user?.ID;
+                    return (
+                      <div key={message.ID} className={`flex ${mine ? 'justify-end' : 'justify-start'}`}>
+                        <div
+                          className={`max-w-[75%] rounded-lg px-3 py-2 ${
+                            mine ? 'bg-blue-600 text-white' : 'bg-slate-100 text-slate-900'
+                          }`}
+                        >
+                          <p className="text-sm whitespace-pre-wrap">{message.Body}</p>
+                          <p className={`text-xs mt-1 flex items-center gap-1 ${mine ? 'text-blue-100' : 'text-slate-500'}`}>
+                            {new Date(message.CreatedAt).toLocaleString()}
+                            {mine && message.ID <= seenUpTo && (
+                              <>
+                                <CheckCheck className="h-3 w-3" /> Seen
+                              </>
+                            )}
+                          </p>
+                        </div>
+                      </div>
+                    );
+                  })}
+                </div>
+
+                <form onSubmit={handleSend} className="flex gap-2">
+                  <Input
+                    value={draft}
+                    onChange={(e) => setDraft(e.target.value)}
+                    placeholder="Write a message..."
+                    maxLength={5000}
+                  />
+                  <Button type="submit" disabled={sendMutation.isPending || !draft.trim()}>
+                    <Send className="h-4 w-4" />
+                  </Button>
+                </form>
+              </CardContent>
+            </>
+          ) : (
+            <CardContent className="flex flex-col items-center justify-center py-16 text-slate-500">
+              <MessageSquare className="h-12 w-12 mb-4 text-slate-400" />
+              Select a conversation
+            </CardContent>
+          )}
+        </Card>
+      </div>
+    </div>
+  );
+}
@@ -0,0 +1,257 @@
+import { createFileRoute, useRouter } from '@tanstack/react-router';
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
+import { notificationApi } from '@/api/client';
+import type { Notification, NotificationChannel, NotificationPreferences } from '@/types';
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from '@/components/ui/card';
+import { Button } from '@/components/ui/button';
+import { Input } from '@/components/ui/input';
+import { Label } from '@/components/ui/label';
+import { Bell, CheckCheck } from 'lucide-react';
+import { useEffect, useState } from 'react';
+
+export const Route = createFileRoute('/_authenticated/notifications')({
+  component: NotificationsPage,
+});
+
+const CHANNEL_LABELS: Record<NotificationChannel, string> = {
+  in_app: 'In app',
+  email: 'In app and email',
+  off: 'Off',
+};
+
+function NotificationsPage() {
+  const router = useRouter();
+  const queryClient = useQueryClient();
+  const [unreadOnly, setUnreadOnly] = useState(false);
+
+  // New notifications arrive as events (see _authenticated.tsx)
+  const { data, isLoading } = useQuery({
+    queryKey: ['notifications', { unreadOnly }],
+    queryFn: () => notificationApi.getAll({ unread: unreadOnly, page_size: 50 }),
+  });
+
+  const invalidate = () => {
+    queryClient.invalidateQueries({ queryKey: ['notifications'] });
+    queryClient.invalidateQueries({ queryKey: ['unread-notifications'] });
+  };
+
+  const markReadMutation = useMutation({
+    mutationFn: (id: number) => notificationApi.markRead(id),
+    onSuccess: invalidate,
+  });
+
+  const markAllReadMutation = useMutation({
+    mutationFn: notificationApi.markAllRead,
+    onSuccess: invalidate,
+  });
+
+  const handleOpen = (notification: Notification) => {
+    if (!notification.ReadAt) {
+      markReadMutation.mutate(notification.ID);
+    }
+    if (notification.Link) {
+      router.history.push(notification.Link);
+    }
+  };
+
+  const notifications = data?.notifications || [];
+
+  return (
+    <div className="space-y-6">
+      <div className="flex justify-between items-start">
+        <div>
+          <h1 className="text-3xl font-bold">Notifications</h1>
+          <p className="text-slate-600 mt-1">Updates on your requests, offers, communities and messages</p>
+        </div>
+        <Button
+          variant="outline"
+          onClick={() => markAllReadMutation.mutate()}
+          disabled={markAllReadMutation.isPending || !data?.unread_count}
+        >
+          <CheckCheck className="h-4 w-4 mr-2" />
+          Mark all read
+        </Button>
+      </div>
+
+      <Card>
+        <CardHeader>
+          <div className="flex justify-between items-center">
+            <div>
+              <CardTitle>Inbox</CardTitle>
+              <CardDescription>{data?.unread_count ?? 0} unread</CardDescription>
+            </div>
+            <label className="flex items-center gap-2 text-sm text-slate-700">
+              <input type="checkbox" checked={unreadOnly} onChange={(e) => setUnreadOnly(e.target.checked)} />
+              Unread only
+            </label>
+          </div>
+        </CardHeader>
+        <CardContent className="space-y-2">
+          {isLoading ? (
+            <div className="text-center py-8">Loading notifications...</div>
+          ) : notifications.length === 0 ? (
+            <div className="text-center py-8 text-slate-500">
+              <Bell className="h-10 w-10 mx-auto mb-2 text-slate-400" />
+              {unreadOnly ? "You're all caught up." : 'No notifications yet.'}
+            </div>
+          ) : (
+            notifications.map((notification) => (
+              <button
+                key={notification.ID}
+                type="button"
+                onClick={() => handleOpen(notification)}
+                className={`w-full text-left p-3 rounded-md border ${
+                  notification.ReadAt ? 'border-slate-200 hover:bg-slate-50' : 'border-blue-200 bg-blue-50'
+                }`}
+              >
+                <div className="flex justify-between items-center gap-4">
+                  <span className={`text-slate-900 ${notification.ReadAt ? '' : 'font-medium'}`}>
+                    {notification.Title}
+                  </span>
+                  <span className="text-xs text-slate-500 shrink-0">
+                    {new Date(notification.CreatedAt).toLocaleString()}
+                  </span>
+                </div>
+                {notification.Body && (
+                  <p className="text-sm text-slate-600 mt-1 truncate">{notification.Body}</p>
+                )}
+              </button>
+            ))
+          )}
+        </CardContent>
+      </Card>
+
+      <PreferencesCard />
+    </div>
+  );
+}
+
+function PreferencesCard() {
+  const queryClient = useQueryClient();
+  const [form, setForm] = useState<NotificationPreferences | null>(null);
+
+  const { data: preferences } = useQuery({
+    queryKey: ['notification-preferences'],
+    queryFn: notificationApi.getPreferences,
+  });
+
+  useEffect(() => {
+    if (preferences) setForm(preferences);
+  }, [preferences]);
+
+  const saveMutation = useMutation({
+    mutationFn: (values: NotificationPreferences) =>
+      notificationApi.updatePreferences({
+        channels: Object.fromEntries(values.types.map((t) => [t.type, t.channel])),
+        quiet_hours_start: values.quiet_hours_start || '',
+        quiet_hours_end: values.quiet_hours_end || '',
+        daily_digest: values.daily_digest,
+        digest_hour: values.digest_hour,
+      }),
+    onSuccess: (updated) => {
+      queryClient.setQueryData(['notification-preferences'], updated);
+      alert('Notification settings saved');
+    },
+    onError: (error) => {
+      alert(error instanceof Error ? error.message : 'Failed to save notification settings');
+    },
+  });
+
+  if (!form) {
+    return null;
+  }
+
+  const setChannel = (type: string, channel: NotificationChannel) =>
+    setForm({ ...form, types: form.types.map((t) => (t.type === type ? { ...t, channel } : t)) });
+
+  const handleSubmit = (e: React.FormEvent) => {
+    e.preventDefault();
+    saveMutation.mutate(form);
+  };
+
+  return (
+    <Card>
+      <CardHeader>
+        <CardTitle>Settings</CardTitle>
+        <CardDescription>Choose where each kind of notification goes. Times are in {form.time_zone}.</CardDescription>
+      </CardHeader>
+      <CardContent>
+        <form onSubmit={handleSubmit} className="space-y-6">
+          <div className="space-y-3">
+            {form.types.map((t) => (
+              <div key={t.type} className="flex justify-between items-center gap-4">
+                <span className="text-sm text-slate-700">{t.description}</span>
+                <select
+                  className="px-3 py-2 border border-slate-300 rounded-md text-sm"
+                  value={t.channel}
+                  onChange={(e) => setChannel(t.type, e.target.value as NotificationChannel)}
+                >
+                  {(Object.keys(CHANNEL_LABELS) as NotificationChannel[]).map((channel) => (
+                    <option key={channel} value={channel}>
+                      {CHANNEL_LABELS[channel]}
+                    </option>
+                  ))}
+                </select>
+              </div>
+            ))}
+          </div>
+
+          <div className="grid gap-4 md:grid-cols-2">
+            <div className="space-y-2">
+              <Label htmlFor="quietStart">Quiet hours from</Label>
+              <Input
+                id="quietStart"
+                type="time"
+                value={form.quiet_hours_start || ''}
+                onChange={(e) => setForm({ ...form, quiet_hours_start: e.target.value })}
+              />
+            </div>
+            <div className="space-y-2">
+              <Label htmlFor="quietEnd">Quiet hours until</Label>
+              <Input
+                id="quietEnd"
+                type="time"
+                value={form.quiet_hours_end || ''}
+                onChange={(e) => setForm({ ...form, quiet_hours_end: e.target.value })}
+              />
+            </div>
+          </div>
+          <p className="text-xs text-slate-500">No emails are sent during quiet hours; they go out when quiet hours end. Leave both empty to turn them off.</p>
+
+          <div className="flex flex-wrap items-center gap-4">
+            <label className="flex items-center gap-2 text-sm text-slate-700">
+              <input
+                type="checkbox"
+                checked={form.daily_digest}
+                onChange={(e) => setForm({ ...form, daily_digest: e.target.checked })}
+              />
+              Send emails as one daily digest at
+            </label>
+            <select
+              className="px-3 py-2 border border-slate-300 rounded-md text-sm"
+              value={form.digest_hour}
+              disabled={!form.daily_digest}
+              onChange={(e) => setForm({ ...form, digest_hour: Number(e.target.value) })}
+            >
+              {Array.from({ length: 24 }, (_, hour) => (
+                <option key={hour} value={hour}>
+                  {hour.toString().padStart(2, '0')}:00
+                </option>
+              ))}
+            </select>
+          </div>
+
+          <Button type="submit" disabled={saveMutation.isPending}>
+            {saveMutation.isPending ? 'Saving...' : 'Save settings'}
+          </Button>
+        </form>
+      </CardContent>
+    </Card>
+  );
+}
@@ -1,8 +1,9 @@
 import { createFileRoute } from '@tanstack/react-router';
 import { useState } from 'react';
-import { useMutation } from '@tanstack/react-query';
+import { useMutation, useQuery, useQueryClient } from '@tanstack/react-query';
 import { useAuth } from '@/contexts/AuthContext';
-import { userApi } from '@/api/client';
+import { tokenApi, userApi } from '@/api/client';
+import type { TokenScope } from '@/types';
 import { Button } from '@/components/ui/button';
 import { Input } from '@/components/ui/input';
 import { Label } from '@/components/ui/label';
@@ -13,6 +14,13 @@ import {
   CardHeader,
   CardTitle,
 } from '@/components/ui/card';
+import {
+  Select,
+  SelectContent,
+  SelectItem,
+  SelectTrigger,
+  SelectValue,
+} from '@/components/ui/select';
 
 export const Route = createFileRoute('/_authenticated/profile')({
   component: ProfilePage,
@@ -163,7 +171,178 @@ function ProfilePage() {
           </form>
         </CardContent>
       </Card>
+
+      <AccessTokensCard />
     </div>
   );
 }
 
+const scopeLabels: Record<TokenScope, string> = {
+  read_only: 'Read only',
+  marketplace: 'Marketplace',
+  admin: 'Admin',
+};
+
+function AccessTokensCard() {
+  const queryClient = useQueryClient();
+  const [form, setForm] = useState({ name: '', scope: 'read_only' as TokenScope, expiresInDays: '90' });
+  const [newToken, setNewToken] = useState('');
+  const [error, setError] = useState('');
+
+  const { data: tokens, isLoading } = useQuery({
+    queryKey: ['personal-access-tokens'],
+    queryFn: tokenApi.getAll,
+  });
+
+  const createTokenMutation = useMutation({
+    mutationFn: () =>
+      tokenApi.create({
+        name: form.name,
+        scopes: [form.scope],
+        expires_in_days: parseInt(form.expiresInDays),
+      }),
+    onSuccess: (data) => {
+      setNewToken(data.token);
+      setError('');
+      setForm({ ...form, name: '' });
+      queryClient.invalidateQueries({ queryKey: ['personal-access-tokens'] });
+    },
+    onError: (err: Error) => setError(err.message),
+  });
+
+  const revokeTokenMutation = useMutation({
+    mutationFn: (id: number) => tokenApi.revoke(id),
+    onSuccess: () => queryClient.invalidateQueries({ queryKey: ['personal-access-tokens'] }),
+    onError: (err: Error) => setError(err.message),
+  });
+
+  const handleCreate = (e: React.FormEvent) => {
+    e.preventDefault();
+    setNewToken('');
+
+    if (!form.name.trim()) {
+      setError('Token name is required');
+      return;
+    }
+
+    createTokenMutation.mutate();
+  };
+
+  return (
+    <Card className="max-w-2xl">
+      <CardHeader>
+        <CardTitle>Personal Access Tokens</CardTitle>
+        <CardDescription>
+          Tokens let scripts and integrations call the API as you. Send them in an{' '}
+          <code>Authorization: Bearer</code> header.
+        </CardDescription>
+      </CardHeader>
+      <CardContent className="space-y-6">
+        <form onSubmit={handleCreate} className="space-y-4">
+          <div className="space-y-2">
+            <Label htmlFor="tokenName">Name</Label>
+            <Input
+              id="tokenName"
+              placeholder="e.g. Nightly export"
+              value={form.name}
+              onChange={(e) => setForm({ ...form, name: e.target.value })}
+              disabled={createTokenMutation.isPending}
+            />
+          </div>
+
+          <div className="grid grid-cols-2 gap-4">
+            <div className="space-y-2">
+              <Label>Scope</Label>
+              <Select
+                value={form.scope}
+                onValueChange={(value) => setForm({ ...form, scope: value as TokenScope })}
+              >
+                <SelectTrigger>
+                  <SelectValue />
+                </SelectTrigger>
+                <SelectContent>
+                  <SelectItem value="read_only">Read only</SelectItem>
+                  <SelectItem value="marketplace">Marketplace</SelectItem>
+                  <SelectItem value="admin">Admin</SelectItem>
+                </SelectContent>
+              </Select>
+            </div>
+
+            <div className="space-y-2">
+              <Label>Expires</Label>
+              <Select
+                value={form.expiresInDays}
+                onValueChange={(value) => setForm({ ...form, expiresInDays: value })}
+              >
+                <SelectTrigger>
+                  <SelectValue />
+                </SelectTrigger>
+                <SelectContent>
+                  <SelectItem value="7">In 7 days</SelectItem>
+                  <SelectItem value="30">In 30 days</SelectItem>
+                  <SelectItem value="90">In 90 days</SelectItem>
+                  <SelectItem value="365">In 1 year</SelectItem>
+                </SelectContent>
+              </Select>
+            </div>
+          </div>
+
+          {error && (
+            <div className="bg-red-50 text-red-600 px-4 py-2 rounded-md text-sm">
+              {error}
+            </div>
+          )}
+
+          {newToken && (
+            <div className="bg-green-50 text-green-700 px-4 py-2 rounded-md text-sm space-y-1">
+              <div>Copy your new token now. You won't be able to see it again.</div>
+              <code className="block break-all font-mono">{newToken}</code>
+            </div>
+          )}
+
+          <Button type="submit" disabled={createTokenMutation.isPending}>
+            {createTokenMutation.isPending ? 'Creating...' : 'Create Token'}
+          </Button>
+        </form>
+
+        <div className="space-y-2">
+          {isLoading ? (
+            <div className="text-sm text-slate-500">Loading tokens...</div>
+          ) : !tokens || tokens.length === 0 ? (
+            <div className="text-sm text-slate-500">You have no active tokens.</div>
+          ) : (
+            tokens.map((token) => (
+              <div
+                key={token.ID}
+                className="flex items-center justify-between border rounded-md px-4 py-3"
+              >
+                <div>
+                  <div className="font-medium">{token.Name}</div>
+                  <div className="text-xs text-slate-500">
+                    <code>{token.Prefix}…</code> ·{' '}
+                    {token.Scopes.split(',')
+                      .map((scope) => scopeLabels[scope as TokenScope] ?? scope)
+                      .join(', ')}{' '}
+                    · expires {new Date(token.ExpiresAt).toLocaleDateString()} ·{' '}
+                    {token.LastUsedAt
+                      ? `last used ${new Date(token.LastUsedAt).toLocaleString()}`
+                      : 'never used'}
+                  </div>
+                </div>
+                <Button
+                  variant="outline"
+                  size="sm"
+                  onClick={() => revokeTokenMutation.mutate(token.ID)}
+                  disabled={revokeTokenMutation.isPending}
+                >
+                  Revoke
+                </Button>
+              </div>
+            ))
+          )}
+        </div>
+      </CardContent>
+    </Card>
+  );
+}
+
@@ -1,7 +1,8 @@
-import { createFileRoute, Link } from '@tanstack/react-router';
+import { createFileRoute, Link, useNavigate } from '@tanstack/react-router';
 import { useAuth } from '@/contexts/AuthContext';
 import { useEffect, useState } from 'react';
-import { serviceOfferApi } from '@/api/client';
+import { serviceOfferApi, conversationApi } from '@/api/client';
+import { useEvents } from '@/lib/events';
 import type { ServiceOffer } from '@/types';
 import {
   Card,
@@ -20,6 +21,7 @@ import {
   User,
   Briefcase,
   FileText,
+  MessageSquare,
 } from 'lucide-react';
 
 export const Route = createFileRoute('/_authenticated/service-provider/contacts')({
@@ -28,6 +30,7 @@ export const Route = createFileRoute('/_authenticated/service-provider/contacts'
 
 function ContactsPage() {
   const { user } = useAuth();
+  const navigate = useNavigate();
   const [acceptedOffers, setAcceptedOffers] = useState<ServiceOffer[]>([]);
   const [loading, setLoading] = useState(true);
 
@@ -37,6 +40,13 @@ function ContactsPage() {
     }
   }, [user]);
 
+  // Offers accepted, and requests that moved on, change the list
+  useEvents((event) => {
+    if (event.type === 'offer.status_changed' || event.type === 'service_request.status_changed') {
+      fetchAcceptedOffers();
+    }
+  });
+
   const fetchAcceptedOffers = async () => {
     if (!user) return;
 
@@ -52,6 +62,22 @@ function ContactsPage() {
     }
   };
 
+  // Opens the conversation with the requester about the offer's request
+  const handleMessage = async (offer: ServiceOffer) => {
+    if (!offer.ServiceRequest) return;
+
+    try {
+      const conversation = await conversationApi.start({
+        recipient_id: offer.ServiceRequest.RequesterID,
+        service_request_id: offer.ServiceRequestID,
+      });
+      navigate({ to: '/messages', search: { conversation: conversation.ID } });
+    } catch (error) {
+      console.error('Failed to start conversation:', error);
+      alert(error instanceof Error ? error.message : 'Failed to start conversation');
+    }
+  };
+
   if (loading) {
     return (
       <div className="flex justify-center items-center min-h-[400px]">
@@ -205,8 +231,13 @@ function ContactsPage() {
                       View Details
                     </Button>
                   </Link>
+                  <Button size="sm" className="flex-1" onClick={() => handleMessage(offer)}>
+                    <MessageSquare className="h-4 w-4 mr-2" />
+                    Message
+                  </Button>
                   <Button
                     size="sm"
+                    variant="outline"
                     className="flex-1"
                     onClick={() => {
                       const email = offer.ServiceRequest?.Requester?.Email;
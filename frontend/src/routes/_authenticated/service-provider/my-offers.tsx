@@ -73,6 +73,7 @@ function MyOffersPage() {
       case 'rejected':
         return <XCircle className="h-5 w-5 text-red-600" />;
       case 'withdrawn':
+      case 'expired':
         return <AlertCircle className="h-5 w-5 text-gray-600" />;
       default:
         return <Clock className="h-5 w-5 text-gray-600" />;
@@ -88,6 +89,7 @@ function MyOffersPage() {
       case 'rejected':
         return 'bg-red-100 text-red-800';
       case 'withdrawn':
+      case 'expired':
         return 'bg-gray-100 text-gray-800';
       default:
         return 'bg-gray-100 text-gray-800';
@@ -97,7 +99,8 @@ function MyOffersPage() {
   const groupOffersByStatus = () => {
     const pending = offers.filter((o) => o.Status === 'pending');
     const accepted = offers.filter((o) => o.Status === 'accepted');
-    const rejected = offers.filter((o) => o.Status === 'rejected');
+    // Expired offers weren't accepted either
+    const rejected = offers.filter((o) => o.Status === 'rejected' || o.Status === 'expired');
     const withdrawn = offers.filter((o) => o.Status === 'withdrawn');
 
     return { pending, accepted, rejected, withdrawn };
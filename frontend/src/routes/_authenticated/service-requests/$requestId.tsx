@@ -1,8 +1,9 @@
-import { createFileRoute, Link } from '@tanstack/react-router';
+import { createFileRoute, Link, useNavigate } from '@tanstack/react-router';
 import { useAuth } from '@/contexts/AuthContext';
+import { useEvents } from '@/lib/events';
 import { useEffect, useState } from 'react';
-import { serviceRequestApi, serviceOfferApi } from '@/api/client';
-import type { ServiceRequest } from '@/types';
+import { serviceRequestApi, serviceOfferApi, disputeApi, milestoneApi, appointmentApi, availabilityApi, conversationApi } from '@/api/client';
+import type { ServiceRequest, TimelineEntry, Dispute, DisputeResolution, Appointment, AvailableSlot } from '@/types';
 import {
   Card,
   CardContent,
@@ -10,9 +11,10 @@ import {
   CardHeader,
   CardTitle,
 } from '@/components/ui/card';
-import { Button } from '@/components/ui/button';
+import { Button, buttonVariants } from '@/components/ui/button';
 import { Input } from '@/components/ui/input';
 import { Label } from '@/components/ui/label';
+import { formatMinorUnits, minorUnitsPer } from '@/lib/utils';
 import {
   Clock,
   CheckCircle,
@@ -22,6 +24,9 @@ import {
   User,
   Calendar,
   Mail,
+  MessageSquare,
+  History,
+  Scale,
 } from 'lucide-react';
 
 export const Route = createFileRoute('/_authenticated/service-requests/$requestId')({
@@ -30,8 +35,24 @@ export const Route = createFileRoute('/_authenticated/service-requests/$requestI
 
 function ServiceRequestDetailPage() {
   const { requestId } = Route.useParams();
-  const { user } = useAuth();
+  const { user, userCommunities } = useAuth();
+  const navigate = useNavigate();
   const [serviceRequest, setServiceRequest] = useState<ServiceRequest | null>(null);
+  const [timeline, setTimeline] = useState<TimelineEntry[]>([]);
+  const [disputes, setDisputes] = useState<Dispute[]>([]);
+  const [appointments, setAppointments] = useState<Appointment[]>([]);
+  const [slots, setSlots] = useState<AvailableSlot[] | null>(null);
+  const [showDisputeForm, setShowDisputeForm] = useState(false);
+  const [disputeReason, setDisputeReason] = useState('');
+  const [planRows, setPlanRows] = useState<{ description: string; amount: string; dueDate: string }[] | null>(
+    null
+  );
+  const [resolveForm, setResolveForm] = useState<{ resolution: DisputeResolution; note: string }>({
+    resolution: 'completed',
+    note: '',
+  });
+  const [counterOfferId, setCounterOfferId] = useState<number | null>(null);
+  const [counterForm, setCounterForm] = useState({ price: '', message: '' });
   const [loading, setLoading] = useState(true);
   const [submitting, setSubmitting] = useState(false);
   const [showOfferForm, setShowOfferForm] = useState(false);
@@ -45,11 +66,33 @@ function ServiceRequestDetailPage() {
     fetchServiceRequest();
   }, [requestId]);
 
-  const fetchServiceRequest = async () => {
+  // Offers, counter-offers and status changes on this request
+  useEvents((event) => {
+    if (event.data.service_request_id === parseInt(requestId)) {
+      fetchServiceRequest(false);
+    }
+  });
+
+  const fetchServiceRequest = async (showLoading = true) => {
     try {
-      setLoading(true);
-      const request = await serviceRequestApi.getById(parseInt(requestId));
+      if (showLoading) setLoading(true);
+      const [request, entries] = await Promise.all([
+        serviceRequestApi.getById(parseInt(requestId)),
+        serviceRequestApi.getTimeline(parseInt(requestId)),
+      ]);
       setServiceRequest(request);
+      setTimeline(entries);
+      // Only the parties and community moderators can see disputes
+      setDisputes(
+        request.AcceptedOfferID
+          ? await serviceRequestApi.getDisputes(request.ID).catch(() => [])
+          : []
+      );
+      setAppointments(
+        request.AcceptedOfferID
+          ? await serviceRequestApi.getAppointments(request.ID).catch(() => [])
+          : []
+      );
     } catch (error) {
       console.error('Failed to fetch service request:', error);
     } finally {
@@ -94,12 +137,253 @@ function ServiceRequestDetailPage() {
     }
   };
 
+  const handleCounter = async (e: React.FormEvent, offerId: number) => {
+    e.preventDefault();
+
+    try {
+      setSubmitting(true);
+      await serviceOfferApi.counter(offerId, {
+        price: counterForm.price ? parseFloat(counterForm.price) : undefined,
+        message: counterForm.message || undefined,
+      });
+      setCounterOfferId(null);
+      setCounterForm({ price: '', message: '' });
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error('Failed to send counter-offer:', error);
+      alert(error instanceof Error ? error.message : 'Failed to send counter-offer.');
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleAcceptTerms = async (offerId: number) => {
+    if (!confirm("Accept the requester's terms? This accepts your offer.")) return;
+
+    try {
+      await serviceOfferApi.acceptTerms(offerId);
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error('Failed to accept terms:', error);
+      alert(error instanceof Error ? error.message : 'Failed to accept terms.');
+    }
+  };
+
+  const handleMarkDone = async () => {
+    if (!serviceRequest) return;
+    if (!confirm('Mark the work as done? The requester will be asked to confirm it.')) return;
+
+    try {
+      await serviceRequestApi.markDone(serviceRequest.ID);
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error('Failed to mark work done:', error);
+      alert(error instanceof Error ? error.message : 'Failed to mark work done.');
+    }
+  };
+
+  const handleFindSlots = async () => {
+    if (!serviceRequest?.AcceptedOffer) return;
+
+    try {
+      const result = await availabilityApi.getSlots(serviceRequest.AcceptedOffer.ProviderID, { days: 14 });
+      setSlots(result.slots);
+    } catch (error) {
+      console.error('Failed to fetch available times:', error);
+      alert('Failed to fetch available times.');
+    }
+  };
+
+  const handleBookAppointment = async (slot: AvailableSlot) => {
+    if (!serviceRequest) return;
+
+    try {
+      setSubmitting(true);
+      await serviceRequestApi.bookAppointment(serviceRequest.ID, { starts_at: slot.starts_at });
+      setSlots(null);
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error('Failed to book appointment:', error);
+      alert(error instanceof Error ? error.message : 'Failed to book appointment.');
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  // Opens the conversation about this request with recipientId, starting it if needed
+  const handleMessage = async (recipientId: number) => {
+    try {
+      const conversation = await conversationApi.start({
+        recipient_id: recipientId,
+        service_request_id: Number(requestId),
+      });
+      navigate({ to: '/messages', search: { conversation: conversation.ID } });
+    } catch (error) {
+      console.error('Failed to start conversation:', error);
+      alert(error instanceof Error ? error.message : 'Failed to start conversation');
+    }
+  };
+
+  const handleCancelAppointment = async (appointmentId: number) => {
+    const reason = prompt('Why are you cancelling? (optional)');
+    if (reason === null) return;
+
+    try {
+      await appointmentApi.cancel(appointmentId, reason || undefined);
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error('Failed to cancel appointment:', error);
+      alert(error instanceof Error ? error.message : 'Failed to cancel appointment.');
+    }
+  };
+
+  const handleConfirmCompletion = async () => {
+    if (!serviceRequest) return;
+    if (!confirm('Confirm the work is complete?')) return;
+
+    try {
+      await serviceRequestApi.confirmCompletion(serviceRequest.ID);
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error('Failed to confirm completion:', error);
+      alert(error instanceof Error ? error.message : 'Failed to confirm completion.');
+    }
+  };
+
+  const handleDispute = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!serviceRequest) return;
+
+    try {
+      setSubmitting(true);
+      await serviceRequestApi.dispute(serviceRequest.ID, disputeReason);
+      setShowDisputeForm(false);
+      setDisputeReason('');
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error('Failed to open dispute:', error);
+      alert(error instanceof Error ? error.message : 'Failed to open dispute.');
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleMediate = async (disputeId: number) => {
+    try {
+      await disputeApi.mediate(disputeId);
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error('Failed to mediate dispute:', error);
+      alert(error instanceof Error ? error.message : 'Failed to mediate dispute.');
+    }
+  };
+
+  const handleResolve = async (e: React.FormEvent, disputeId: number) => {
+    e.preventDefault();
+
+    try {
+      setSubmitting(true);
+      await disputeApi.resolve(disputeId, resolveForm.resolution, resolveForm.note);
+      setResolveForm({ resolution: 'completed', note: '' });
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error('Failed to resolve dispute:', error);
+      alert(error instanceof Error ? error.message : 'Failed to resolve dispute.');
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const startPlanning = () => {
+    const current = serviceRequest?.AcceptedOffer?.Milestones || [];
+    setPlanRows(
+      current.length > 0
+        ? current.map((m) => ({
+            description: m.Description,
+            amount: (m.Amount / minorUnitsPer(m.Currency)).toString(),
+            dueDate: m.DueDate ? m.DueDate.slice(0, 10) : '',
+          }))
+        : [{ description: '', amount: '', dueDate: '' }]
+    );
+  };
+
+  const handleSavePlan = async (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!serviceRequest?.AcceptedOfferID || !planRows) return;
+
+    try {
+      setSubmitting(true);
+      const currency = serviceRequest.Community?.Currency || 'USD';
+      await serviceOfferApi.setMilestones(
+        serviceRequest.AcceptedOfferID,
+        planRows.map((row) => ({
+          description: row.description,
+          amount: row.amount ? Math.round(parseFloat(row.amount) * minorUnitsPer(currency)) : 0,
+          due_date: row.dueDate ? new Date(row.dueDate).toISOString() : undefined,
+        }))
+      );
+      setPlanRows(null);
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error('Failed to save milestones:', error);
+      alert(error instanceof Error ? error.message : 'Failed to save milestones.');
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleApprovePlan = async () => {
+    if (!serviceRequest?.AcceptedOfferID) return;
+
+    try {
+      setSubmitting(true);
+      await serviceOfferApi.approveMilestones(
+        serviceRequest.AcceptedOfferID,
+        (serviceRequest.AcceptedOffer?.Milestones || []).map((m) => m.ID)
+      );
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error('Failed to approve plan:', error);
+      alert(error instanceof Error ? error.message : 'Failed to approve plan.');
+    } finally {
+      setSubmitting(false);
+    }
+  };
+
+  const handleMilestoneAction = async (milestoneId: number, action: 'submit' | 'approve' | 'reject') => {
+    try {
+      if (action === 'reject') {
+        const reason = prompt('What needs more work?');
+        if (!reason) return;
+        await milestoneApi.reject(milestoneId, reason);
+      } else {
+        await milestoneApi[action](milestoneId);
+      }
+      await fetchServiceRequest();
+    } catch (error) {
+      console.error(`Failed to ${action} milestone:`, error);
+      alert(error instanceof Error ? error.message : `Failed to ${action} milestone.`);
+    }
+  };
+
+  // Whoever made the latest counter-offer is waiting on the other party
+  const latestTermsAuthorId = (offerId: number, providerId: number) => {
+    const counters = timeline.filter(
+      (entry) => entry.type === 'offer_countered' && entry.service_offer_id === offerId
+    );
+    return counters.length > 0 ? counters[counters.length - 1].actor?.ID : providerId;
+  };
+
   const getStatusIcon = (status: string) => {
     switch (status) {
       case 'open':
         return <Clock className="h-5 w-5 text-blue-600" />;
       case 'in_progress':
         return <AlertCircle className="h-5 w-5 text-yellow-600" />;
+      case 'awaiting_confirmation':
+        return <Clock className="h-5 w-5 text-purple-600" />;
+      case 'disputed':
+        return <Scale className="h-5 w-5 text-orange-600" />;
       case 'completed':
         return <CheckCircle className="h-5 w-5 text-green-600" />;
       case 'cancelled':
@@ -115,12 +399,22 @@ function ServiceRequestDetailPage() {
         return 'bg-blue-100 text-blue-800';
       case 'in_progress':
         return 'bg-yellow-100 text-yellow-800';
+      case 'awaiting_confirmation':
+        return 'bg-purple-100 text-purple-800';
+      case 'disputed':
+        return 'bg-orange-100 text-orange-800';
       case 'completed':
         return 'bg-green-100 text-green-800';
       case 'cancelled':
         return 'bg-red-100 text-red-800';
       case 'pending':
         return 'bg-gray-100 text-gray-800';
+      case 'submitted':
+        return 'bg-purple-100 text-purple-800';
+      case 'scheduled':
+        return 'bg-blue-100 text-blue-800';
+      case 'approved':
+        return 'bg-green-100 text-green-800';
       case 'accepted':
         return 'bg-green-100 text-green-800';
       case 'rejected':
@@ -130,6 +424,45 @@ function ServiceRequestDetailPage() {
     }
   };
 
+  const describeTimelineEntry = (entry: TimelineEntry) => {
+    const actor = entry.actor?.Name || 'Someone';
+    const formatStatus = (status?: string) => (status || '').replace(/_/g, ' ');
+    switch (entry.type) {
+      case 'request_created':
+        return `${actor} created the request`;
+      case 'status_changed':
+        return entry.actor
+          ? `${actor} moved the request to ${formatStatus(entry.to_status)}`
+          : `Request moved to ${formatStatus(entry.to_status)}`;
+      case 'offer_submitted':
+        return entry.proposed_price
+          ? `${actor} made an offer of $${entry.proposed_price}`
+          : `${actor} made an offer`;
+      case 'offer_countered':
+        return `${actor} countered at $${entry.proposed_price}`;
+      case 'offer_accepted':
+        return `${actor} accepted an offer`;
+      case 'offer_rejected':
+        return entry.reason ? `Offer rejected (${entry.reason})` : `${actor} rejected an offer`;
+      case 'offer_withdrawn':
+        return `${actor} withdrew their offer`;
+      case 'offer_expired':
+        return 'An offer expired';
+      case 'milestone_updated':
+        return entry.reason
+          ? `${actor} ${entry.to_status} a milestone (${entry.reason})`
+          : `${actor} ${entry.to_status} a milestone`;
+      case 'appointment_booked':
+        return `${actor} booked a visit for ${entry.starts_at ? new Date(entry.starts_at).toLocaleString() : 'later'}`;
+      case 'appointment_cancelled':
+        return entry.reason ? `${actor} cancelled a visit (${entry.reason})` : `${actor} cancelled a visit`;
+      case 'comment':
+        return `${actor} commented${
+          entry.milestone_id ? ' on a milestone' : entry.service_offer_id ? ' on an offer' : ''
+        }`;
+    }
+  };
+
   if (loading) {
     return (
       <div className="flex justify-center items-center min-h-[400px]">
@@ -153,6 +486,23 @@ function ServiceRequestDetailPage() {
   const isRequester = user?.ID === serviceRequest.RequesterID;
   const canCreateOffer = !isRequester && serviceRequest.Status === 'open';
   const hasAcceptedOffer = serviceRequest.AcceptedOfferID != null;
+  const isProvider = hasAcceptedOffer && user?.ID === serviceRequest.AcceptedOffer?.ProviderID;
+  const canModerate =
+    user?.Role === 'super_admin' ||
+    userCommunities.some(
+      (uc) =>
+        uc.CommunityID === serviceRequest.CommunityID && (uc.Role === 'admin' || uc.Role === 'moderator')
+    );
+  const canMediate = canModerate && !isRequester && !isProvider;
+  const milestones = serviceRequest.AcceptedOffer?.Milestones || [];
+  const planApproved = !!serviceRequest.AcceptedOffer?.MilestonesApprovedAt;
+  const canPlanMilestones =
+    isProvider &&
+    serviceRequest.Status === 'in_progress' &&
+    !planApproved &&
+    milestones.every((m) => m.Status === 'pending');
+  const canApprovePlan =
+    isRequester && serviceRequest.Status === 'in_progress' && !planApproved && milestones.length > 0;
 
   return (
     <div className="space-y-6 max-w-5xl mx-auto">
@@ -160,6 +510,18 @@ function ServiceRequestDetailPage() {
         <Link to="/service-requests">
           <Button variant="outline">← Back to Requests</Button>
         </Link>
+        {!isRequester && (
+          <Button variant="outline" onClick={() => handleMessage(serviceRequest.RequesterID)}>
+            <MessageSquare className="h-4 w-4 mr-2" />
+            Message Requester
+          </Button>
+        )}
+        {isRequester && serviceRequest.AcceptedOffer && (
+          <Button variant="outline" onClick={() => handleMessage(serviceRequest.AcceptedOffer!.ProviderID)}>
+            <MessageSquare className="h-4 w-4 mr-2" />
+            Message Provider
+          </Button>
+        )}
       </div>
 
       {/* Service Request Details */}
@@ -172,7 +534,7 @@ function ServiceRequestDetailPage() {
                 serviceRequest.Status
               )}`}
             >
-              {serviceRequest.Status.replace('_', ' ').toUpperCase()}
+              {serviceRequest.Status.replace(/_/g, ' ').toUpperCase()}
             </span>
           </div>
           <CardTitle className="text-2xl">{serviceRequest.Title}</CardTitle>
@@ -205,6 +567,13 @@ function ServiceRequestDetailPage() {
               <span className="font-medium text-slate-700">Created:</span>
               <span>{new Date(serviceRequest.CreatedAt).toLocaleDateString()}</span>
             </div>
+            {serviceRequest.RecurringRequestID && serviceRequest.ScheduledFor && (
+              <div className="flex items-center gap-2">
+                <Calendar className="h-4 w-4 text-slate-600" />
+                <span className="font-medium text-slate-700">Recurring:</span>
+                <span>{new Date(serviceRequest.ScheduledFor).toLocaleDateString()} occurrence</span>
+              </div>
+            )}
           </div>
         </CardContent>
       </Card>
@@ -260,6 +629,336 @@ function ServiceRequestDetailPage() {
         </Card>
       )}
 
+      {/* Milestones on the accepted offer */}
+      {hasAcceptedOffer && (milestones.length > 0 || canPlanMilestones) && (
+        <Card>
+          <CardHeader>
+            <div className="flex items-center justify-between">
+              <div>
+                <CardTitle>Milestones</CardTitle>
+                <CardDescription>
+                  {planApproved || milestones.length === 0
+                    ? 'Each phase is submitted by the provider and approved by the requester'
+                    : 'The plan is waiting for the requester to approve it'}
+                </CardDescription>
+              </div>
+              {canPlanMilestones && !planRows && (
+                <Button size="sm" variant="outline" onClick={startPlanning}>
+                  {milestones.length > 0 ? 'Edit Plan' : 'Plan Milestones'}
+                </Button>
+              )}
+              {canApprovePlan && (
+                <Button size="sm" onClick={handleApprovePlan} disabled={submitting}>
+                  Approve Plan
+                </Button>
+              )}
+            </div>
+          </CardHeader>
+          <CardContent className="space-y-3">
+            {planRows ? (
+              <form onSubmit={handleSavePlan} className="space-y-3">
+                {planRows.map((row, index) => (
+                  <div key={index} className="grid grid-cols-6 gap-2">
+                    <Input
+                      className="col-span-3"
+                      placeholder={`Phase ${index + 1}`}
+                      value={row.description}
+                      onChange={(e) =>
+                        setPlanRows(planRows.map((r, i) => (i === index ? { ...r, description: e.target.value } : r)))
+                      }
+                      required
+                    />
+                    <Input
+                      type="number"
+                      step="0.01"
+                      placeholder="Amount"
+                      value={row.amount}
+                      onChange={(e) =>
+                        setPlanRows(planRows.map((r, i) => (i === index ? { ...r, amount: e.target.value } : r)))
+                      }
+                    />
+                    <Input
+                      type="date"
+                      value={row.dueDate}
+                      onChange={(e) =>
+                        setPlanRows(planRows.map((r, i) => (i === index ? { ...r, dueDate: e.target.value } : r)))
+                      }
+                    />
+                    <Button
+                      type="button"
+                      variant="outline"
+                      onClick={() => setPlanRows(planRows.filter((_, i) => i !== index))}
+                    >
+                      Remove
+                    </Button>
+                  </div>
+                ))}
+                <div className="flex gap-2">
+                  <Button
+                    type="button"
+                    variant="outline"
+                    onClick={() => setPlanRows([...planRows, { description: '', amount: '', dueDate: '' }])}
+                  >
+                    Add Milestone
+                  </Button>
+                  <Button type="submit" disabled={submitting}>
+                    {submitting ? 'Saving...' : 'Save Plan'}
+                  </Button>
+                  <Button type="button" variant="outline" onClick={() => setPlanRows(null)}>
+                    Cancel
+                  </Button>
+                </div>
+              </form>
+            ) : (
+              milestones.map((milestone) => (
+                <div key={milestone.ID} className="border rounded-lg p-4 flex items-center justify-between gap-4">
+                  <div>
+                    <p className="font-medium text-slate-900">
+                      {milestone.Position}. {milestone.Description}
+                    </p>
+                    <p className="text-sm text-slate-600">
+                      {formatMinorUnits(milestone.Amount, milestone.Currency)}
+                      {milestone.DueDate && ` · due ${new Date(milestone.DueDate).toLocaleDateString()}`}
+                    </p>
+                  </div>
+                  <div className="flex items-center gap-2">
+                    <span
+                      className={`text-xs px-2 py-1 rounded-full font-medium ${getStatusColor(milestone.Status)}`}
+                    >
+                      {milestone.Status.toUpperCase()}
+                    </span>
+                    {isProvider &&
+                      serviceRequest.Status === 'in_progress' &&
+                      planApproved &&
+                      (milestone.Status === 'pending' || milestone.Status === 'rejected') && (
+                        <Button size="sm" onClick={() => handleMilestoneAction(milestone.ID, 'submit')}>
+                          Submit
+                        </Button>
+                      )}
+                    {isRequester && serviceRequest.Status === 'in_progress' && milestone.Status === 'submitted' && (
+                      <>
+                        <Button size="sm" onClick={() => handleMilestoneAction(milestone.ID, 'approve')}>
+                          Approve
+                        </Button>
+                        <Button
+                          size="sm"
+                          variant="outline"
+                          onClick={() => handleMilestoneAction(milestone.ID, 'reject')}
+                        >
+                          Reject
+                        </Button>
+                      </>
+                    )}
+                  </div>
+                </div>
+              ))
+            )}
+          </CardContent>
+        </Card>
+      )}
+
+      {/* Appointments */}
+      {hasAcceptedOffer &&
+        (isRequester || isProvider) &&
+        (serviceRequest.Status === 'in_progress' || appointments.length > 0) && (
+          <Card>
+            <CardHeader>
+              <CardTitle>Appointments</CardTitle>
+              <CardDescription>Visits booked within the provider's availability.</CardDescription>
+            </CardHeader>
+            <CardContent className="space-y-4">
+              {appointments.length === 0 && <p className="text-sm text-slate-500">No visits booked yet.</p>}
+              {appointments.map((appointment) => (
+                <div key={appointment.ID} className="flex items-center justify-between border-b pb-2">
+                  <div>
+                    <p className="text-sm font-medium text-slate-900">
+                      {new Date(appointment.StartsAt).toLocaleString()} -{' '}
+                      {new Date(appointment.EndsAt).toLocaleTimeString()}
+                    </p>
+                    <p className="text-xs text-slate-500">Provider's time zone: {appointment.TimeZone}</p>
+                  </div>
+                  <div className="flex items-center gap-2">
+                    <span className={`text-xs px-2 py-1 rounded-full font-medium ${getStatusColor(appointment.Status)}`}>
+                      {appointment.Status.toUpperCase()}
+                    </span>
+                    {appointment.Status === 'scheduled' && serviceRequest.Status === 'in_progress' && (
+                      <Button size="sm" variant="outline" onClick={() => handleCancelAppointment(appointment.ID)}>
+                        Cancel
+                      </Button>
+                    )}
+                  </div>
+                </div>
+              ))}
+
+              {isRequester && serviceRequest.Status === 'in_progress' && slots === null && (
+                <Button onClick={handleFindSlots}>Book a Visit</Button>
+              )}
+              {slots !== null && (
+                <div className="space-y-2">
+                  {slots.length === 0 && (
+                    <p className="text-sm text-slate-500">The provider has no free times in the next two weeks.</p>
+                  )}
+                  <div className="flex flex-wrap gap-2">
+                    {slots.map((slot) => (
+                      <Button
+                        key={slot.starts_at}
+                        size="sm"
+                        variant="outline"
+                        disabled={submitting}
+                        onClick={() => handleBookAppointment(slot)}
+                      >
+                        {new Date(slot.starts_at).toLocaleString([], {
+                          weekday: 'short',
+                          month: 'short',
+                          day: 'numeric',
+                          hour: '2-digit',
+                          minute: '2-digit',
+                        })}
+                      </Button>
+                    ))}
+                  </div>
+                  <Button variant="ghost" size="sm" onClick={() => setSlots(null)}>
+                    Close
+                  </Button>
+                </div>
+              )}
+            </CardContent>
+          </Card>
+        )}
+
+      {/* Invoice and receipt */}
+      {hasAcceptedOffer && (isRequester || isProvider) && serviceRequest.Status === 'completed' && (
+        <Card>
+          <CardHeader>
+            <CardTitle>{isRequester ? 'Receipt' : 'Invoice'}</CardTitle>
+            <CardDescription>Issued when the request was completed.</CardDescription>
+          </CardHeader>
+          <CardContent className="flex gap-2">
+            <a
+              className={buttonVariants()}
+              href={serviceRequestApi.invoiceUrl(serviceRequest.ID)}
+              target="_blank"
+              rel="noreferrer"
+            >
+              Download {isRequester ? 'Receipt' : 'Invoice'}
+            </a>
+            {isRequester && (
+              <a
+                className={buttonVariants({ variant: 'outline' })}
+                href={serviceRequestApi.invoiceUrl(serviceRequest.ID, 'invoice')}
+                target="_blank"
+                rel="noreferrer"
+              >
+                Invoice
+              </a>
+            )}
+          </CardContent>
+        </Card>
+      )}
+
+      {/* Completion confirmation and disputes */}
+      {hasAcceptedOffer &&
+        (isRequester || isProvider || canModerate) &&
+        ['in_progress', 'awaiting_confirmation', 'disputed'].includes(serviceRequest.Status) && (
+          <Card>
+            <CardHeader>
+              <CardTitle>Completion</CardTitle>
+              <CardDescription>
+                {serviceRequest.Status === 'in_progress' &&
+                  'The provider marks the work done, then the requester confirms it.'}
+                {serviceRequest.Status === 'awaiting_confirmation' &&
+                  serviceRequest.ConfirmationDueAt &&
+                  `Work marked done. Confirmed automatically on ${new Date(
+                    serviceRequest.ConfirmationDueAt
+                  ).toLocaleDateString()} unless disputed.`}
+                {serviceRequest.Status === 'disputed' && 'The requester disputed the work. A moderator will mediate.'}
+              </CardDescription>
+            </CardHeader>
+            <CardContent className="space-y-4">
+              {isProvider && serviceRequest.Status === 'in_progress' && (
+                <Button onClick={handleMarkDone}>Mark Work Done</Button>
+              )}
+
+              {isRequester && serviceRequest.Status === 'awaiting_confirmation' && (
+                <div className="flex gap-2">
+                  <Button onClick={handleConfirmCompletion}>Confirm Completion</Button>
+                  <Button variant="outline" onClick={() => setShowDisputeForm(!showDisputeForm)}>
+                    Dispute
+                  </Button>
+                </div>
+              )}
+
+              {showDisputeForm && (
+                <form onSubmit={handleDispute} className="space-y-2">
+                  <Label htmlFor="disputeReason">What is wrong with the work?</Label>
+                  <textarea
+                    id="disputeReason"
+                    className="w-full min-h-[80px] px-3 py-2 border border-slate-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+                    value={disputeReason}
+                    onChange={(e) => setDisputeReason(e.target.value)}
+                    required
+                  />
+                  <Button type="submit" disabled={submitting}>
+                    {submitting ? 'Submitting...' : 'Open Dispute'}
+                  </Button>
+                </form>
+              )}
+
+              {disputes.map((dispute) => (
+                <div key={dispute.ID} className="border rounded-lg p-4 space-y-2">
+                  <div className="flex items-center justify-between">
+                    <span className="font-medium">
+                      Dispute by {dispute.RaisedBy?.Name || 'the requester'}
+                    </span>
+                    <span className={`text-xs px-2 py-1 rounded-full font-medium ${getStatusColor(dispute.Status)}`}>
+                      {dispute.Status.toUpperCase()}
+                    </span>
+                  </div>
+                  <p className="text-sm text-slate-700">{dispute.Reason}</p>
+                  {dispute.Mediator && (
+                    <p className="text-xs text-slate-500">Mediator: {dispute.Mediator.Name}</p>
+                  )}
+                  {dispute.Status === 'resolved' && (
+                    <p className="text-sm text-slate-700">
+                      Resolved as {dispute.Resolution}: {dispute.ResolutionNote}
+                    </p>
+                  )}
+
+                  {canMediate && dispute.Status === 'open' && (
+                    <Button size="sm" variant="outline" onClick={() => handleMediate(dispute.ID)}>
+                      Mediate
+                    </Button>
+                  )}
+                  {canMediate && dispute.Status === 'mediating' && dispute.MediatorID === user?.ID && (
+                    <form onSubmit={(e) => handleResolve(e, dispute.ID)} className="space-y-2">
+                      <select
+                        className="w-full px-3 py-2 border border-slate-300 rounded-md"
+                        value={resolveForm.resolution}
+                        onChange={(e) =>
+                          setResolveForm({ ...resolveForm, resolution: e.target.value as DisputeResolution })
+                        }
+                      >
+                        <option value="completed">Accept the work as complete</option>
+                        <option value="rework">Send back for rework</option>
+                        <option value="cancelled">Cancel the request</option>
+                      </select>
+                      <Input
+                        placeholder="Resolution note"
+                        value={resolveForm.note}
+                        onChange={(e) => setResolveForm({ ...resolveForm, note: e.target.value })}
+                        required
+                      />
+                      <Button type="submit" size="sm" disabled={submitting}>
+                        Resolve Dispute
+                      </Button>
+                    </form>
+                  )}
+                </div>
+              ))}
+            </CardContent>
+          </Card>
+        )}
+
       {/* Create Offer Form */}
       {canCreateOffer && (
         <Card>
@@ -384,14 +1083,56 @@ function ServiceRequestDetailPage() {
                       <span>{new Date(offer.CreatedAt).toLocaleDateString()}</span>
                     </div>
                   </div>
-                  {isRequester &&
+                  {(isRequester || offer.ProviderID === user?.ID) &&
                     offer.Status === 'pending' &&
                     serviceRequest.Status === 'open' &&
                     !hasAcceptedOffer && (
-                      <div className="mt-3 pt-3 border-t">
-                        <Button size="sm" onClick={() => handleAcceptOffer(offer.ID)}>
-                          Accept Offer
-                        </Button>
+                      <div className="mt-3 pt-3 border-t space-y-3">
+                        <div className="flex gap-2">
+                          {latestTermsAuthorId(offer.ID, offer.ProviderID) !== user?.ID &&
+                            (isRequester ? (
+                              <Button size="sm" onClick={() => handleAcceptOffer(offer.ID)}>
+                                Accept Offer
+                              </Button>
+                            ) : (
+                              <Button size="sm" onClick={() => handleAcceptTerms(offer.ID)}>
+                                Accept Terms
+                              </Button>
+                            ))}
+                          <Button
+                            size="sm"
+                            variant="outline"
+                            onClick={() => setCounterOfferId(counterOfferId === offer.ID ? null : offer.ID)}
+                          >
+                            Counter
+                          </Button>
+                        </div>
+                        {counterOfferId === offer.ID && (
+                          <form onSubmit={(e) => handleCounter(e, offer.ID)} className="flex gap-2 items-end">
+                            <div>
+                              <Label htmlFor={`counter-price-${offer.ID}`}>Price ($)</Label>
+                              <Input
+                                id={`counter-price-${offer.ID}`}
+                                type="number"
+                                step="0.01"
+                                min="0"
+                                value={counterForm.price}
+                                onChange={(e) => setCounterForm({ ...counterForm, price: e.target.value })}
+                              />
+                            </div>
+                            <div className="flex-1">
+                              <Label htmlFor={`counter-message-${offer.ID}`}>Message</Label>
+                              <Input
+                                id={`counter-message-${offer.ID}`}
+                                value={counterForm.message}
+                                onChange={(e) => setCounterForm({ ...counterForm, message: e.target.value })}
+                              />
+                            </div>
+                            <Button type="submit" size="sm" disabled={submitting}>
+                              Send
+                            </Button>
+                          </form>
+                        )}
                       </div>
                     )}
                 </div>
@@ -400,6 +1141,34 @@ function ServiceRequestDetailPage() {
           )}
         </CardContent>
       </Card>
+
+      {/* Timeline */}
+      <Card>
+        <CardHeader>
+          <CardTitle>Timeline</CardTitle>
+          <CardDescription>Everything that has happened on this request</CardDescription>
+        </CardHeader>
+        <CardContent>
+          <ol className="space-y-4">
+            {timeline.map((entry, index) => (
+              <li key={index} className="flex gap-3">
+                {entry.type === 'comment' ? (
+                  <MessageSquare className="h-4 w-4 mt-1 text-slate-500 shrink-0" />
+                ) : (
+                  <History className="h-4 w-4 mt-1 text-slate-500 shrink-0" />
+                )}
+                <div>
+                  <p className="text-sm text-slate-900">{describeTimelineEntry(entry)}</p>
+                  {entry.content && (
+                    <p className="text-sm text-slate-600 mt-1 whitespace-pre-wrap">{entry.content}</p>
+                  )}
+                  <p className="text-xs text-slate-500 mt-1">{new Date(entry.at).toLocaleString()}</p>
+                </div>
+              </li>
+            ))}
+          </ol>
+        </CardContent>
+      </Card>
     </div>
   );
 }
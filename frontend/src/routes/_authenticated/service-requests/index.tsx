@@ -61,6 +61,10 @@ function ServiceRequestsPage() {
         return <Clock className="h-4 w-4 text-blue-600" />;
       case 'in_progress':
         return <AlertCircle className="h-4 w-4 text-yellow-600" />;
+      case 'awaiting_confirmation':
+        return <Clock className="h-4 w-4 text-purple-600" />;
+      case 'disputed':
+        return <AlertCircle className="h-4 w-4 text-orange-600" />;
       case 'completed':
         return <CheckCircle className="h-4 w-4 text-green-600" />;
       case 'cancelled':
@@ -74,6 +78,10 @@ function ServiceRequestsPage() {
         return 'bg-blue-100 text-blue-800';
       case 'in_progress':
         return 'bg-yellow-100 text-yellow-800';
+      case 'awaiting_confirmation':
+        return 'bg-purple-100 text-purple-800';
+      case 'disputed':
+        return 'bg-orange-100 text-orange-800';
       case 'completed':
         return 'bg-green-100 text-green-800';
       case 'cancelled':
@@ -159,7 +167,7 @@ function ServiceRequestsPage() {
                           request.Status
                         )}`}
                       >
-                        {request.Status.replace('_', ' ').toUpperCase()}
+                        {request.Status.replace(/_/g, ' ').toUpperCase()}
                       </span>
                     </div>
                   </div>
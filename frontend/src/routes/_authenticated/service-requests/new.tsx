@@ -1,7 +1,7 @@
 import { createFileRoute, useNavigate } from '@tanstack/react-router';
 import { useAuth } from '@/contexts/AuthContext';
 import { useState } from 'react';
-import { serviceRequestApi } from '@/api/client';
+import { serviceRequestApi, recurringRequestApi } from '@/api/client';
 import {
   Card,
   CardContent,
@@ -26,6 +26,10 @@ function NewServiceRequestPage() {
     description: '',
     category: '',
     budget: '',
+    repeat: '',
+    interval: '1',
+    startsAt: '',
+    rebookProvider: true,
   });
 
   const handleSubmit = async (e: React.FormEvent) => {
@@ -38,13 +42,27 @@ function NewServiceRequestPage() {
 
     try {
       setLoading(true);
-      await serviceRequestApi.create({
+      const details = {
         title: formData.title,
         description: formData.description,
         category: formData.category || undefined,
         budget: formData.budget ? parseFloat(formData.budget) : undefined,
         community_id: currentCommunity.ID,
-      });
+      };
+
+      if (formData.repeat) {
+        // A new request is posted on every occurrence, starting at startsAt
+        const interval = Math.max(1, parseInt(formData.interval, 10) || 1);
+        await recurringRequestApi.create({
+          ...details,
+          rrule: `FREQ=${formData.repeat};INTERVAL=${interval}`,
+          starts_at: new Date(formData.startsAt).toISOString(),
+          time_zone: Intl.DateTimeFormat().resolvedOptions().timeZone,
+          rebook_provider: formData.rebookProvider,
+        });
+      } else {
+        await serviceRequestApi.create(details);
+      }
       
       navigate({ to: '/service-requests' });
     } catch (error) {
@@ -119,6 +137,56 @@ function NewServiceRequestPage() {
               />
             </div>
 
+            <div>
+              <Label htmlFor="repeat">Repeat</Label>
+              <select
+                id="repeat"
+                value={formData.repeat}
+                onChange={(e) => setFormData({ ...formData, repeat: e.target.value })}
+                className="w-full h-10 px-3 border border-slate-300 rounded-md focus:outline-none focus:ring-2 focus:ring-blue-500"
+              >
+                <option value="">Does not repeat</option>
+                <option value="DAILY">Daily</option>
+                <option value="WEEKLY">Weekly</option>
+                <option value="MONTHLY">Monthly</option>
+              </select>
+            </div>
+
+            {formData.repeat && (
+              <div className="space-y-4 rounded-md border border-slate-200 p-4">
+                <div className="grid grid-cols-2 gap-4">
+                  <div>
+                    <Label htmlFor="interval">Every</Label>
+                    <Input
+                      id="interval"
+                      type="number"
+                      min="1"
+                      value={formData.interval}
+                      onChange={(e) => setFormData({ ...formData, interval: e.target.value })}
+                    />
+                  </div>
+                  <div>
+                    <Label htmlFor="startsAt">First request *</Label>
+                    <Input
+                      id="startsAt"
+                      type="datetime-local"
+                      value={formData.startsAt}
+                      onChange={(e) => setFormData({ ...formData, startsAt: e.target.value })}
+                      required
+                    />
+                  </div>
+                </div>
+                <label className="flex items-center gap-2 text-sm text-slate-700">
+                  <input
+                    type="checkbox"
+                    checked={formData.rebookProvider}
+                    onChange={(e) => setFormData({ ...formData, rebookProvider: e.target.checked })}
+                  />
+                  Re-book the last provider I accepted on the same terms
+                </label>
+              </div>
+            )}
+
             <div className="flex gap-3 pt-4">
               <Button type="submit" disabled={loading}>
                 {loading ? 'Creating...' : 'Create Request'}
@@ -0,0 +1,335 @@
+import { createFileRoute, Navigate } from '@tanstack/react-router';
+import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query';
+import { useAuth } from '@/contexts/AuthContext';
+import { webhookApi } from '@/api/client';
+import type { WebhookEndpoint, WebhookEventType } from '@/types';
+import {
+  Card,
+  CardContent,
+  CardDescription,
+  CardHeader,
+  CardTitle,
+} from '@/components/ui/card';
+import { Button } from '@/components/ui/button';
+import { Input } from '@/components/ui/input';
+import { Label } from '@/components/ui/label';
+import { Webhook, RefreshCw, Send, Trash2, KeyRound } from 'lucide-react';
+import { useState } from 'react';
+
+export const Route = createFileRoute('/_authenticated/webhooks')({
+  component: WebhooksPage,
+});
+
+const EVENT_LABELS: Record<WebhookEventType, string> = {
+  'service_request.created': 'Request created',
+  'service_request.accepted': 'Offer accepted',
+  'service_request.completed': 'Request completed',
+};
+
+const DELIVERY_STATUS_STYLES: Record<string, string> = {
+  pending: 'bg-yellow-100 text-yellow-800',
+  succeeded: 'bg-green-100 text-green-800',
+  failed: 'bg-red-100 text-red-800',
+};
+
+function WebhooksPage() {
+  const { user, currentCommunity, userCommunities } = useAuth();
+  const queryClient = useQueryClient();
+  const [selectedId, setSelectedId] = useState<number | null>(null);
+  // Shown once, after creating an endpoint or rotating its secret
+  const [revealedSecret, setRevealedSecret] = useState<{ id: number; secret: string } | null>(null);
+  const [form, setForm] = useState({
+    url: '',
+    description: '',
+    eventTypes: Object.keys(EVENT_LABELS) as WebhookEventType[],
+  });
+
+  const communityId = currentCommunity?.ID;
+  const membership = userCommunities.find((uc) => uc.CommunityID === communityId);
+  const canManage = user?.Role === 'super_admin' || membership?.Role === 'admin';
+
+  const { data, isLoading } = useQuery({
+    queryKey: ['webhooks', communityId],
+    queryFn: () => webhookApi.getAll(communityId!),
+    enabled: canManage && communityId != null,
+  });
+
+  const { data: deliveries } = useQuery({
+    queryKey: ['webhooks', communityId, selectedId, 'deliveries'],
+    queryFn: () => webhookApi.getDeliveries(communityId!, selectedId!, { page_size: 50 }),
+    enabled: communityId != null && selectedId != null,
+    refetchInterval: 10000,
+  });
+
+  const invalidate = () => queryClient.invalidateQueries({ queryKey: ['webhooks', communityId] });
+
+  const onError = (error: unknown) => {
+    alert(error instanceof Error ? error.message : 'Something went wrong');
+  };
+
+  const createMutation = useMutation({
+    mutationFn: () =>
+      webhookApi.create(communityId!, {
+        url: form.url,
+        description: form.description,
+        event_types: form.eventTypes,
+      }),
+    onSuccess: ({ webhook, secret }) => {
+      setRevealedSecret({ id: webhook.ID, secret });
+      setForm({ ...form, url: '', description: '' });
+      invalidate();
+    },
+    onError,
+  });
+
+  const updateMutation = useMutation({
+    mutationFn: ({ id, data }: { id: number; data: Parameters<typeof webhookApi.update>[2] }) =>
+      webhookApi.update(communityId!, id, data),
+    onSuccess: invalidate,
+    onError,
+  });
+
+  const deleteMutation = useMutation({
+    mutationFn: (id: number) => webhookApi.delete(communityId!, id),
+    onSuccess: (_, id) => {
+      if (selectedId === id) setSelectedId(null);
+      invalidate();
+    },
+    onError,
+  });
+
+  const rotateMutation = useMutation({
+    mutationFn: (id: number) => webhookApi.rotateSecret(communityId!, id),
+    onSuccess: ({ webhook, secret }) => setRevealedSecret({ id: webhook.ID, secret }),
+    onError,
+  });
+
+  const pingMutation = useMutation({
+    mutationFn: (id: number) => webhookApi.ping(communityId!, id),
+    onSuccess: (_, id) => {
+      setSelectedId(id);
+      invalidate();
+    },
+    onError,
+  });
+
+  const redeliverMutation = useMutation({
+    mutationFn: (deliveryId: number) => webhookApi.redeliver(communityId!, selectedId!, deliveryId),
+    onSuccess: invalidate,
+    onError,
+  });
+
+  if (!canManage) {
+    return <Navigate to="/" />;
+  }
+
+  const toggleEventType = (eventType: WebhookEventType) => {
+    setForm({
+      ...form,
+      eventTypes: form.eventTypes.includes(eventType)
+        ? form.eventTypes.filter((t) => t !== eventType)
+        : [...form.eventTypes, eventType],
+    });
+  };
+
+  const handleCreate = (e: React.FormEvent) => {
+    e.preventDefault();
+    if (!form.url.trim() || form.eventTypes.length === 0) return;
+    createMutation.mutate();
+  };
+
+  const handleDelete = (endpoint: WebhookEndpoint) => {
+    if (confirm(`Delete the webhook to ${endpoint.URL} and its delivery log?`)) {
+      deleteMutation.mutate(endpoint.ID);
+    }
+  };
+
+  const handleRotate = (endpoint: WebhookEndpoint) => {
+    if (confirm('The current secret will stop working straight away. Rotate it?')) {
+      rotateMutation.mutate(endpoint.ID);
+    }
+  };
+
+  const webhooks = data?.webhooks || [];
+
+  return (
+    <div className="space-y-6">
+      <div>
+        <h1 className="text-3xl font-bold">Webhooks</h1>
+        <p className="text-slate-600 mt-1">
+          Send {currentCommunity?.Name}'s service request events to your own systems
+        </p>
+      </div>
+
+      <Card>
+        <CardHeader>
+          <CardTitle>Add an endpoint</CardTitle>
+          <CardDescription>
+            Each delivery is signed with the endpoint's secret in the X-Commune-Signature header
+          </CardDescription>
+        </CardHeader>
+        <CardContent>
+          <form onSubmit={handleCreate} className="space-y-4">
+            <div className="grid gap-4 md:grid-cols-2">
+              <div className="space-y-2">
+                <Label htmlFor="url">URL</Label>
+                <Input
+                  id="url"
+                  type="url"
+                  placeholder="https://pms.example.com/commune"
+                  value={form.url}
+                  onChange={(e) => setForm({ ...form, url: e.target.value })}
+                  required
+                />
+              </div>
+              <div className="space-y-2">
+                <Label htmlFor="description">Description</Label>
+                <Input
+                  id="description"
+                  placeholder="Property management system"
+                  value={form.description}
+                  onChange={(e) => setForm({ ...form, description: e.target.value })}
+                />
+              </div>
+            </div>
+            <div className="flex flex-wrap gap-4">
+              {(Object.keys(EVENT_LABELS) as WebhookEventType[]).map((eventType) => (
+                <label key={eventType} className="flex items-center gap-2 text-sm text-slate-700">
+                  <input
+                    type="checkbox"
+                    checked={form.eventTypes.includes(eventType)}
+                    onChange={() => toggleEventType(eventType)}
+                  />
+                  {EVENT_LABELS[eventType]}
+                </label>
+              ))}
+            </div>
+            <Button type="submit" disabled={createMutation.isPending || form.eventTypes.length === 0}>
+              {createMutation.isPending ? 'Adding...' : 'Add endpoint'}
+            </Button>
+          </form>
+        </CardContent>
+      </Card>
+
+      <Card>
+        <CardHeader>
+          <CardTitle>Endpoints</CardTitle>
+          <CardDescription>{webhooks.length} endpoint(s)</CardDescription>
+        </CardHeader>
+        <CardContent className="space-y-3">
+          {isLoading ? (
+            <div className="text-center py-8">Loading webhooks...</div>
+          ) : webhooks.length === 0 ? (
+            <div className="text-center py-8 text-slate-500">
+              <Webhook className="h-10 w-10 mx-auto mb-2 text-slate-400" />
+              No webhooks yet
+            </div>
+          ) : (
+            webhooks.map((endpoint) => (
+              <div
+                key={endpoint.ID}
+                className={`p-3 rounded-md border ${
+                  endpoint.ID === selectedId ? 'border-blue-500 bg-blue-50' : 'border-slate-200'
+                }`}
+              >
+                <div className="flex justify-between items-start gap-4">
+                  <button type="button" className="text-left" onClick={() => setSelectedId(endpoint.ID)}>
+                    <div className="font-mono text-sm text-slate-900 break-all">{endpoint.URL}</div>
+                    {endpoint.Description && <div className="text-sm text-slate-600">{endpoint.Description}</div>}
+                    <div className="text-xs text-slate-500 mt-1">
+                      {endpoint.EventTypes.split(',')
+                        .map((t) => EVENT_LABELS[t as WebhookEventType] || t)
+                        .join(', ')}
+                    </div>
+                  </button>
+                  <div className="flex items-center gap-2 shrink-0">
+                    <label className="flex items-center gap-1 text-sm text-slate-700">
+                      <input
+                        type="checkbox"
+                        checked={endpoint.IsActive}
+                        onChange={(e) =>
+                          updateMutation.mutate({ id: endpoint.ID, data: { is_active: e.target.checked } })
+                        }
+                      />
+                      Active
+                    </label>
+                    <Button size="sm" variant="outline" onClick={() => pingMutation.mutate(endpoint.ID)} title="Send a ping">
+                      <Send className="h-4 w-4" />
+                    </Button>
+                    <Button size="sm" variant="outline" onClick={() => handleRotate(endpoint)} title="Rotate secret">
+                      <KeyRound className="h-4 w-4" />
+                    </Button>
+                    <Button size="sm" variant="outline" onClick={() => handleDelete(endpoint)} title="Delete">
+                      <Trash2 className="h-4 w-4" />
+                    </Button>
+                  </div>
+                </div>
+                {revealedSecret?.id === endpoint.ID && (
+                  <div className="mt-3 p-3 rounded-md bg-amber-50 border border-amber-200 text-sm">
+                    <div className="font-medium text-amber-900">Signing secret - copy it now, it won't be shown again</div>
+                    <div className="font-mono break-all mt-1">{revealedSecret.secret}</div>
+                  </div>
+                )}
+              </div>
+            ))
+          )}
+        </CardContent>
+      </Card>
+
+      {selectedId != null && (
+        <Card>
+          <CardHeader>
+            <CardTitle>Delivery log</CardTitle>
+            <CardDescription>{deliveries?.total ?? 0} delivery(ies), newest first</CardDescription>
+          </CardHeader>
+          <CardContent className="space-y-2">
+            {(deliveries?.deliveries || []).map((delivery) => (
+              <div key={delivery.ID} className="p-3 rounded-md border border-slate-200">
+                <div className="flex justify-between items-center gap-4">
+                  <div className="flex items-center gap-2">
+                    <span className={`text-xs px-2 py-0.5 rounded-full ${DELIVERY_STATUS_STYLES[delivery.Status]}`}>
+                      {delivery.Status}
+                    </span>
+                    <span className="font-mono text-sm">{delivery.EventType}</span>
+                    <span className="text-xs text-slate-500">#{delivery.ID}</span>
+                    {delivery.RedeliveryOfID && (
+                      <span className="text-xs text-slate-500">resends #{delivery.RedeliveryOfID}</span>
+                    )}
+                  </div>
+                  <div className="flex items-center gap-3">
+                    <span className="text-xs text-slate-500">{new Date(delivery.CreatedAt).toLocaleString()}</span>
+                    {delivery.Status !== 'pending' && (
+                      <Button
+                        size="sm"
+                        variant="outline"
+                        onClick={() => redeliverMutation.mutate(delivery.ID)}
+                        disabled={redeliverMutation.isPending}
+                      >
+                        <RefreshCw className="h-4 w-4 mr-1" />
+                        Redeliver
+                      </Button>
+                    )}
+                  </div>
+                </div>
+                <div className="text-xs text-slate-600 mt-2">
+                  {delivery.Attempts} attempt(s)
+                  {delivery.ResponseStatus > 0 && <> · HTTP {delivery.ResponseStatus}</>}
+                  {delivery.Error && <> · {delivery.Error}</>}
+                  {delivery.Status === 'pending' && delivery.NextAttemptAt && (
+                    <> · next attempt {new Date(delivery.NextAttemptAt).toLocaleString()}</>
+                  )}
+                </div>
+                <details className="mt-2">
+                  <summary className="text-xs text-blue-600 cursor-pointer">Payload</summary>
+                  <pre className="text-xs bg-slate-50 p-2 rounded mt-1 overflow-x-auto">
+                    {JSON.stringify(JSON.parse(delivery.Payload), null, 2)}
+                  </pre>
+                </details>
+              </div>
+            ))}
+          </CardContent>
+        </Card>
+      )}
+    </div>
+  );
+}
@@ -17,12 +17,14 @@ export const Route = createFileRoute('/login')({
 });
 
 function LoginPage() {
-  const { user, login } = useAuth();
+  const { user, login, verifyMfa } = useAuth();
   const navigate = useNavigate();
   const [formData, setFormData] = useState({
     email: '',
     password: '',
   });
+  const [mfaToken, setMfaToken] = useState('');
+  const [code, setCode] = useState('');
   const [error, setError] = useState('');
   const [isLoading, setIsLoading] = useState(false);
 
@@ -35,7 +37,12 @@ function LoginPage() {
     e.preventDefault();
     setError('');
 
-    if (!formData.email || !formData.password) {
+    if (mfaToken) {
+      if (!code) {
+        setError('Authentication code is required');
+        return;
+      }
+    } else if (!formData.email || !formData.password) {
       setError('Email and password are required');
       return;
     }
@@ -43,7 +50,16 @@ function LoginPage() {
     setIsLoading(true);
 
     try {
-      await login(formData.email, formData.password);
+      if (mfaToken) {
+        await verifyMfa(mfaToken, code);
+      } else {
+        const result = await login(formData.email, formData.password);
+        if (result.mfaToken) {
+          // Second step: ask for the authenticator code
+          setMfaToken(result.mfaToken);
+          return;
+        }
+      }
       navigate({ to: '/' });
     } catch (err) {
       setError(err instanceof Error ? err.message : 'Failed to login');
@@ -61,29 +77,47 @@ function LoginPage() {
         </CardHeader>
         <CardContent>
           <form onSubmit={handleSubmit} className="space-y-4">
-            <div className="space-y-2">
-              <Label htmlFor="email">Email</Label>
-              <Input
-                id="email"
-                type="email"
-                placeholder="your@email.com"
-                value={formData.email}
-                onChange={(e) => setFormData({ ...formData, email: e.target.value })}
-                disabled={isLoading}
-              />
-            </div>
+            {mfaToken ? (
+              <div className="space-y-2">
+                <Label htmlFor="code">Authentication code</Label>
+                <Input
+                  id="code"
+                  inputMode="numeric"
+                  autoComplete="one-time-code"
+                  placeholder="123456"
+                  value={code}
+                  onChange={(e) => setCode(e.target.value)}
+                  disabled={isLoading}
+                  autoFocus
+                />
+              </div>
+            ) : (
+              <>
+                <div className="space-y-2">
+                  <Label htmlFor="email">Email</Label>
+                  <Input
+                    id="email"
+                    type="email"
+                    placeholder="your@email.com"
+                    value={formData.email}
+                    onChange={(e) => setFormData({ ...formData, email: e.target.value })}
+                    disabled={isLoading}
+                  />
+                </div>
 
-            <div className="space-y-2">
-              <Label htmlFor="password">Password</Label>
-              <Input
-                id="password"
-                type="password"
-                placeholder="••••••••"
-                value={formData.password}
-                onChange={(e) => setFormData({ ...formData, password: e.target.value })}
-                disabled={isLoading}
-              />
-            </div>
+                <div className="space-y-2">
+                  <Label htmlFor="password">Password</Label>
+                  <Input
+                    id="password"
+                    type="password"
+                    placeholder="••••••••"
+                    value={formData.password}
+                    onChange={(e) => setFormData({ ...formData, password: e.target.value })}
+                    disabled={isLoading}
+                  />
+                </div>
+              </>
+            )}
 
             {error && (
               <div className="bg-red-50 text-red-600 px-4 py-2 rounded-md text-sm">
@@ -11,6 +11,32 @@ export interface User {
   Email: string;
   Role: UserRole;
   IsActive: boolean;
+  EmailVerifiedAt?: string | null;
+  MFAEnabled?: boolean;
+}
+
+// Personal access token scopes
+export type TokenScope = 'read_only' | 'marketplace' | 'admin';
+
+// Personal access token (the secret itself is only returned on creation)
+export interface PersonalAccessToken {
+  ID: number;
+  CreatedAt: string;
+  Name: string;
+  Prefix: string;
+  Scopes: string; // comma-separated TokenScope values
+  ExpiresAt: string;
+  LastUsedAt?: string | null;
+  LastUsedIP: string;
+}
+
+// Login response; when 2FA applies, only the mfa fields are set
+export interface LoginResponse {
+  user?: User;
+  token?: string;
+  mfa_required?: boolean;
+  mfa_enrollment_required?: boolean;
+  mfa_token?: string;
 }
 
 // Community type
@@ -30,6 +56,10 @@ export interface Community {
   Country?: string;
   ZipCode?: string;
   IsActive: boolean;
+  Currency: string;
+  PlatformFeeBps: number;
+  TaxRateBps: number;
+  TaxLabel: string;
 }
 
 // UserCommunity type
@@ -56,13 +86,19 @@ export interface JoinRequest {
 }
 
 // Service status type
-export type ServiceStatus = 'open' | 'in_progress' | 'completed' | 'cancelled';
+export type ServiceStatus =
+  | 'open'
+  | 'in_progress'
+  | 'awaiting_confirmation'
+  | 'disputed'
+  | 'completed'
+  | 'cancelled';
 
 // Service request status type (alias for ServiceStatus)
 export type ServiceRequestStatus = ServiceStatus;
 
 // Service offer status type
-export type ServiceOfferStatus = 'pending' | 'accepted' | 'rejected' | 'withdrawn';
+export type ServiceOfferStatus = 'pending' | 'accepted' | 'rejected' | 'withdrawn' | 'expired';
 
 // Common service categories
 export type ServiceCategory = 
@@ -93,7 +129,11 @@ export interface ServiceRequest {
   Status: ServiceStatus;
   Budget?: number;
   AcceptedOfferID?: number;
+  WorkDoneAt?: string;
+  ConfirmationDueAt?: string;
   CompletedAt?: string;
+  RecurringRequestID?: number;
+  ScheduledFor?: string;
   Requester?: User;
   Community?: Community;
   ServiceOffers?: ServiceOffer[];
@@ -105,7 +145,13 @@ export interface ServiceRequest {
 }
 
 // Service status type
-export type ServiceStatus = 'open' | 'in_progress' | 'completed' | 'cancelled';
+export type ServiceStatus =
+  | 'open'
+  | 'in_progress'
+  | 'awaiting_confirmation'
+  | 'disputed'
+  | 'completed'
+  | 'cancelled';
 
 // Common service categories
 export type ServiceCategory = 
@@ -134,10 +180,369 @@ export interface ServiceOffer {
   EstimatedDuration?: string;
   Status: ServiceOfferStatus;
   ServiceRequest?: ServiceRequest;
-  Status: 'pending' | 'accepted' | 'rejected' | 'withdrawn';
+  Status: 'pending' | 'accepted' | 'rejected' | 'withdrawn' | 'expired';
+  AgreedRevisionID?: number;
+  AgreedRevision?: OfferRevision;
+  MilestonesApprovedAt?: string;
+  Milestones?: Milestone[];
   Provider?: User;
 }
 
+// Milestone status type
+export type MilestoneStatus = 'pending' | 'submitted' | 'approved' | 'rejected';
+
+// One phase of the work on an accepted offer
+export interface Milestone {
+  ID: number;
+  CreatedAt: string;
+  UpdatedAt: string;
+  ServiceOfferID: number;
+  Position: number;
+  Description: string;
+  Amount: number; // In minor units of Currency
+  Currency: string;
+  DueDate?: string;
+  Status: MilestoneStatus;
+  SubmittedAt?: string;
+  ApprovedAt?: string;
+}
+
+export type AppointmentStatus = 'scheduled' | 'cancelled';
+
+// A booked visit; times are UTC, TimeZone is the provider's
+export interface Appointment {
+  ID: number;
+  CreatedAt: string;
+  UpdatedAt: string;
+  ServiceRequestID: number;
+  ProviderID: number;
+  RequesterID: number;
+  StartsAt: string;
+  EndsAt: string;
+  TimeZone: string;
+  Status: AppointmentStatus;
+  Sequence: number;
+  CancelledAt?: string;
+  Provider?: User;
+  Requester?: User;
+}
+
+// A weekly availability window in the provider's time zone
+export interface AvailabilityWindow {
+  weekday: number; // 0 = Sunday
+  start: string; // HH:MM
+  end: string; // HH:MM
+}
+
+export interface AvailabilityBlackout {
+  ID: number;
+  ProviderID: number;
+  StartsOn: string;
+  EndsOn: string;
+  Reason?: string;
+}
+
+export interface Availability {
+  time_zone: string;
+  windows: AvailabilityWindow[];
+  blackouts: AvailabilityBlackout[];
+}
+
+export interface AvailableSlot {
+  starts_at: string;
+  ends_at: string;
+}
+
+export type RecurringRequestStatus = 'active' | 'paused' | 'ended';
+
+// A template that posts a new service request on every occurrence of RRule
+export interface RecurringRequest {
+  ID: number;
+  CreatedAt: string;
+  UpdatedAt: string;
+  RequesterID: number;
+  CommunityID: number;
+  Title: string;
+  Description: string;
+  Category?: string;
+  Budget?: number;
+  RRule: string;
+  TimeZone: string;
+  StartsAt: string;
+  NextRunAt?: string;
+  Status: RecurringRequestStatus;
+  RebookProvider: boolean;
+  Requester?: User;
+  Community?: Community;
+  upcoming: string[];
+}
+
+export interface RecurringRequestInput {
+  title?: string;
+  description?: string;
+  category?: string;
+  community_id?: number;
+  budget?: number;
+  rrule?: string;
+  starts_at?: string;
+  time_zone?: string;
+  rebook_provider?: boolean;
+}
+
+// A member of a conversation; messages up to LastReadMessageID have been read
+export interface ConversationParticipant {
+  ConversationID: number;
+  UserID: number;
+  LastReadMessageID: number;
+  LastReadAt?: string;
+  JoinedAt: string;
+  User?: User;
+}
+
+export interface Message {
+  ID: number;
+  CreatedAt: string;
+  ConversationID: number;
+  SenderID: number;
+  Body: string;
+  Sender?: User;
+}
+
+// A private thread between community members, optionally about a service request
+export interface Conversation {
+  ID: number;
+  CreatedAt: string;
+  UpdatedAt: string;
+  CommunityID: number;
+  ServiceRequestID?: number;
+  LastMessageAt: string;
+  ServiceRequest?: ServiceRequest;
+  Participants: ConversationParticipant[];
+  last_message?: Message;
+  unread_count: number;
+}
+
+export interface UnreadMessages {
+  unread_messages: number;
+  unread_conversations: number;
+}
+
+export type NotificationType =
+  | 'offer_received'
+  | 'offer_countered'
+  | 'offer_accepted'
+  | 'offer_rejected'
+  | 'offer_expired'
+  | 'request_status_changed'
+  | 'join_request_received'
+  | 'join_request_approved'
+  | 'join_request_rejected'
+  | 'message_received';
+
+// in_app shows it in the inbox only, email also emails it, off drops it
+export type NotificationChannel = 'in_app' | 'email' | 'off';
+
+export interface Notification {
+  ID: number;
+  CreatedAt: string;
+  UserID: number;
+  Type: NotificationType;
+  Title: string;
+  Body: string;
+  Link: string;
+  ReadAt?: string;
+  EmailStatus: 'none' | 'pending' | 'sent' | 'skipped';
+  EmailedAt?: string;
+}
+
+export interface NotificationPreferences {
+  types: {
+    type: NotificationType;
+    description: string;
+    default_channel: NotificationChannel;
+    channel: NotificationChannel;
+  }[];
+  quiet_hours_start?: string;
+  quiet_hours_end?: string;
+  daily_digest: boolean;
+  digest_hour: number;
+  time_zone: string;
+}
+
+export type WebhookEventType =
+  | 'service_request.created'
+  | 'service_request.accepted'
+  | 'service_request.completed';
+
+// A URL a community's service request events are posted to
+export interface WebhookEndpoint {
+  ID: number;
+  CreatedAt: string;
+  UpdatedAt: string;
+  CommunityID: number;
+  URL: string;
+  Description: string;
+  EventTypes: string; // Comma-separated WebhookEventType values
+  IsActive: boolean;
+  CreatedByID: number;
+}
+
+// One event sent, or to be sent, to a webhook endpoint
+export interface WebhookDelivery {
+  ID: number;
+  CreatedAt: string;
+  EndpointID: number;
+  EventID: string;
+  EventType: WebhookEventType | 'ping';
+  Payload: string;
+  Status: 'pending' | 'succeeded' | 'failed';
+  Attempts: number;
+  NextAttemptAt?: string;
+  LastAttemptAt?: string;
+  ResponseStatus: number;
+  ResponseBody: string;
+  Error: string;
+  DeliveredAt?: string;
+  RedeliveryOfID?: number;
+}
+
+// Real-time event types streamed from /api/events
+export type DomainEventType =
+  | 'offer.created'
+  | 'offer.countered'
+  | 'offer.status_changed'
+  | 'service_request.status_changed'
+  | 'join_request.created'
+  | 'join_request.approved'
+  | 'join_request.rejected'
+  | 'message.created'
+  | 'conversation.read'
+  | 'notification.created';
+
+// Something that happened to records the user can see; data holds IDs and statuses
+export interface DomainEvent {
+  type: DomainEventType;
+  user_ids: number[];
+  data: {
+    service_request_id?: number;
+    offer_id?: number;
+    join_request_id?: number;
+    community_id?: number;
+    conversation_id?: number;
+    message_id?: number;
+    sender_id?: number;
+    user_id?: number;
+    last_read_message_id?: number;
+    notification_id?: number;
+    notification_type?: NotificationType;
+    version?: number;
+    from?: string;
+    to?: string;
+  };
+}
+
+// A user's position in one currency, in minor units
+export interface LedgerBalance {
+  currency: string;
+  available: number;
+  held: number;
+  incoming: number;
+}
+
+// One entry on a user's statement, in minor units
+export interface StatementLine {
+  id: number;
+  at: string;
+  kind: 'escrow_hold' | 'escrow_release' | 'escrow_refund';
+  description: string;
+  memo: string;
+  service_request_id?: number;
+  payment_reference?: string;
+  amount: number;
+  currency: string;
+  balance?: number;
+}
+
+export interface LedgerAmount {
+  currency: string;
+  amount: number;
+}
+
+export interface CommunityLedger {
+  currency: string;
+  platform_fee_bps: number;
+  fees: LedgerAmount[];
+  escrow: LedgerAmount[];
+}
+
+// One version of an offer's terms during negotiation
+export interface OfferRevision {
+  ID: number;
+  CreatedAt: string;
+  ServiceOfferID: number;
+  Version: number;
+  AuthorID: number;
+  Author?: User;
+  Price: number;
+  Description: string;
+  EstimatedDuration: string;
+  Message: string;
+}
+
+// Dispute over work marked done, mediated by a community moderator
+export type DisputeStatus = 'open' | 'mediating' | 'resolved';
+export type DisputeResolution = 'completed' | 'rework' | 'cancelled';
+
+export interface Dispute {
+  ID: number;
+  CreatedAt: string;
+  UpdatedAt: string;
+  ServiceRequestID: number;
+  RaisedByID: number;
+  RaisedBy?: User;
+  Reason: string;
+  Status: DisputeStatus;
+  MediatorID?: number;
+  Mediator?: User;
+  Resolution?: DisputeResolution | '';
+  ResolutionNote?: string;
+  ResolvedAt?: string;
+  ServiceRequest?: ServiceRequest;
+}
+
+// Service request timeline entry
+export type TimelineEntryType =
+  | 'request_created'
+  | 'status_changed'
+  | 'offer_submitted'
+  | 'offer_countered'
+  | 'offer_accepted'
+  | 'offer_rejected'
+  | 'offer_withdrawn'
+  | 'offer_expired'
+  | 'milestone_updated'
+  | 'appointment_booked'
+  | 'appointment_cancelled'
+  | 'comment';
+
+export interface TimelineEntry {
+  type: TimelineEntryType;
+  at: string;
+  actor?: User;
+  service_offer_id?: number;
+  milestone_id?: number;
+  appointment_id?: number;
+  starts_at?: string;
+  from_status?: string;
+  to_status?: string;
+  reason?: string;
+  version?: number;
+  proposed_price?: number;
+  comment_id?: number;
+  parent_comment_id?: number;
+  content?: string;
+}
+
 // Comment type
 export interface Comment {
   ID: number;
@@ -149,6 +554,7 @@ export interface Comment {
   PostID?: number;
   ServiceRequestID?: number;
   ServiceOfferID?: number;
+  MilestoneID?: number;
   ParentCommentID?: number;
 }
 
@@ -158,7 +564,8 @@ export interface AuthContextType {
   currentCommunity: Community | null;
   userCommunities: UserCommunity[];
   isLoading: boolean;
-  login: (email: string, password: string) => Promise<void>;
+  login: (email: string, password: string) => Promise<{ mfaToken?: string }>;
+  verifyMfa: (mfaToken: string, code: string) => Promise<void>;
   logout: () => Promise<void>;
   switchCommunity: (communityId: number) => void;
   refreshUser: () => Promise<void>;